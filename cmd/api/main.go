@@ -5,40 +5,62 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
+	"time"
 
 	"arrivo-transit-api/internal/cache"
 	"arrivo-transit-api/internal/config"
 	"arrivo-transit-api/internal/database"
 	"arrivo-transit-api/internal/handlers"
-	"arrivo-transit-api/internal/ovapi"
 	"arrivo-transit-api/internal/services"
+	"arrivo-transit-api/internal/store/postgres"
+	"arrivo-transit-api/internal/store/redisstore"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %s", err)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Error loading config: %s", err)
 	}
 
-	db, err := database.NewDB(context.Background(), cfg.PostgresDSN)
+	if err := checkSchema(context.Background(), cfg.PostgresDSN, cfg.SchemaCheck); err != nil {
+		log.Fatalf("Error checking database schema: %s", err)
+	}
+
+	db, err := database.Open(context.Background(), database.Options{
+		PrimaryDSN:       cfg.PostgresDSN,
+		ReplicaDSNs:      cfg.PostgresReplicaDSNs,
+		MaxConns:         cfg.PostgresMaxConns,
+		ReplicaMaxConns:  cfg.PostgresReplicaMaxConns,
+		StatementTimeout: cfg.PostgresStatementTimeout,
+	})
 	if err != nil {
 		log.Fatalf("Error connecting to database: %s", err)
 	}
+	defer db.Close()
 
 	redisClient, err := cache.NewRedis(cfg.RedisDSN)
 	if err != nil {
 		log.Fatalf("Error connecting to redis: %s", err)
 	}
 
-	// Initialize OVapi client and transit service
-	ovapiClient := ovapi.NewClient()
-	lruCache := cache.NewLRUCache(1000) // Cache up to 1000 items
-	transitService := services.NewTransitService(ovapiClient, lruCache, redisClient, db)
+	// Initialize transit service
+	lruCache := cache.NewLRUCache(1000, 30*time.Second) // Cache up to 1000 items for 30s
+	transitService := services.NewTransitService(lruCache, postgres.New(db), redisstore.New(redisClient))
 	transitHandler := handlers.NewTransitHandler(transitService)
+	adminHandler := handlers.NewAdminHandler(transitService, cfg.AdminToken)
+	gtfsrtHandler := handlers.NewGTFSRTHandler(transitService)
 
 	// Initialize Swagger handler
 	apiSpecPath := filepath.Join("docs", "api.yaml")
@@ -52,6 +74,14 @@ func main() {
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
+	r.Get("/health/realtime", transitHandler.GetRealtimeHealth)
+
+	// GTFS-Realtime feeds
+	r.Route("/gtfs-rt", func(r chi.Router) {
+		r.Get("/trip-updates.pb", gtfsrtHandler.TripUpdates)
+		r.Get("/vehicle-positions.pb", gtfsrtHandler.VehiclePositions)
+		r.Get("/alerts.pb", gtfsrtHandler.Alerts)
+	})
 
 	// API Documentation endpoints
 	r.Get("/swagger/*", swaggerHandler.ServeSwaggerUI())
@@ -67,9 +97,23 @@ func main() {
 			r.Get("/stops/search", transitHandler.SearchStops)
 			r.Get("/stops/nearby", transitHandler.GetNearbyStops)
 			r.Get("/stops/{stopID}/departures", transitHandler.GetDepartures)
+			r.Get("/stops/{stopID}/punctuality", transitHandler.GetStopPunctuality)
 			r.Get("/routes/search", transitHandler.SearchRoutes)
 			r.Get("/routes/{routeID}/vehicles", transitHandler.GetVehiclesByRoute)
+			r.Get("/routes/{routeID}/punctuality", transitHandler.GetRoutePunctuality)
 			r.Get("/vehicles/active", transitHandler.GetAllActiveVehicles)
+			r.Get("/alerts", transitHandler.GetAlerts)
+
+			// Admin endpoints, only mounted when an admin token is configured
+			if cfg.AdminToken != "" {
+				r.Route("/admin", func(r chi.Router) {
+					r.Use(adminHandler.RequireToken)
+					r.Get("/stop-aliases", adminHandler.ListStopAliases)
+					r.Post("/stop-aliases", adminHandler.CreateStopAlias)
+					r.Put("/stop-aliases/{aliasID}", adminHandler.UpdateStopAlias)
+					r.Delete("/stop-aliases/{aliasID}", adminHandler.DeleteStopAlias)
+				})
+			}
 		})
 
 	fmt.Printf("Starting server on :%s\n", cfg.Port)
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"arrivo-transit-api/internal/database"
+)
+
+const migrateUsage = `usage: arrivo-api migrate <command>
+
+commands:
+  up           apply all pending migrations
+  down [N]     roll back N migrations (default 1)
+  status       show the applied and expected schema version
+  force V      set the schema version to V and clear the dirty flag`
+
+// runMigrate implements the "migrate" subcommand. It only needs POSTGRES_DSN,
+// so it can run as a one-off job before the API is configured.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", migrateUsage)
+	}
+
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		return fmt.Errorf("POSTGRES_DSN environment variable not set")
+	}
+
+	mg, err := database.NewMigrator(dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer mg.Close()
+
+	switch args[0] {
+	case "up":
+		if err := mg.Up(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		if err := mg.Down(steps); err != nil {
+			return err
+		}
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("force requires a version\n%s", migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := mg.Force(version); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
+
+	version, dirty, err := mg.Version()
+	if err != nil {
+		return err
+	}
+	expected, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version: %d (expected %d)", version, expected)
+	if dirty {
+		fmt.Print(", dirty")
+	}
+	fmt.Println()
+	return nil
+}
+
+// checkSchema compares the database schema version with the one embedded in
+// this binary. In strict mode an outdated or dirty schema stops the API from
+// starting; otherwise it is only logged.
+func checkSchema(ctx context.Context, dsn, mode string) error {
+	if mode == "off" {
+		return nil
+	}
+
+	expected, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	mg, err := database.NewMigrator(dsn)
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	version, dirty, err := mg.Version()
+	if err != nil {
+		return err
+	}
+
+	var problem string
+	switch {
+	case dirty:
+		problem = fmt.Sprintf("schema version %d is dirty, a migration failed halfway", version)
+	case version < expected:
+		problem = fmt.Sprintf("schema version %d is behind the expected version %d, run 'arrivo-api migrate up'", version, expected)
+	case version > expected:
+		log.Printf("WARN: Schema version %d is ahead of the expected version %d", version, expected)
+	}
+
+	if problem == "" {
+		return nil
+	}
+	if mode == "strict" {
+		return fmt.Errorf("%s", problem)
+	}
+	log.Printf("WARN: %s", problem)
+	return nil
+}
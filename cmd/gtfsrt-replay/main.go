@@ -0,0 +1,170 @@
+// Command gtfsrt-replay records GTFS-Realtime feeds to disk and serves the
+// recordings over HTTP, so the realtime worker can be run against known
+// input:
+//
+//	gtfsrt-replay record -url https://gtfs.ovapi.nl/nl/tripUpdates.pb -dir recordings
+//	gtfsrt-replay serve -dir recordings -listen 127.0.0.1:8090
+//	GTFSRT_FEED_URLS=http://127.0.0.1:8090/tripUpdates.pb go run ./cmd/realtime-worker
+//
+// A recording is a directory per feed holding one <unix time>.pb file per
+// fetch. serve answers every request for /<feed>.pb with the next file of
+// that directory, starting over at the end; a plain <feed>.pb file is served
+// as is.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"arrivo-transit-api/internal/gtfsrt"
+)
+
+const usage = `usage: gtfsrt-replay <command> [flags]
+
+commands:
+  record   fetch a feed periodically and save every response
+  serve    serve recorded feeds over HTTP`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = record(ctx, os.Args[2:])
+	case "serve":
+		err = serve(ctx, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", os.Args[1], usage)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
+
+func record(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	url := fs.String("url", "https://gtfs.ovapi.nl/nl/tripUpdates.pb", "feed to record")
+	dir := fs.String("dir", "recordings", "directory to record into")
+	interval := fs.Duration("interval", 15*time.Second, "time between fetches")
+	count := fs.Int("count", 0, "number of fetches, 0 records until interrupted")
+	fs.Parse(args)
+
+	feedDir := filepath.Join(*dir, strings.TrimSuffix(path.Base(*url), ".pb"))
+	if err := os.MkdirAll(feedDir, 0o755); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for n := 0; *count == 0 || n < *count; n++ {
+		if n > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+
+		data, err := fetch(ctx, *url)
+		if err != nil {
+			log.Printf("WARN: Failed to fetch %s: %v", *url, err)
+			continue
+		}
+		feed, err := gtfsrt.Decode(data)
+		if err != nil {
+			log.Printf("WARN: Skipping undecodable response: %v", err)
+			continue
+		}
+
+		name := filepath.Join(feedDir, fmt.Sprintf("%d.pb", time.Now().Unix()))
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			return err
+		}
+		log.Printf("Recorded %d entities to %s", len(feed.Entities), name)
+	}
+	return nil
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func serve(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := fs.String("dir", "recordings", "directory with recordings")
+	listen := fs.String("listen", "127.0.0.1:8090", "address to listen on")
+	fs.Parse(args)
+
+	var mu sync.Mutex
+	next := make(map[string]int) // Next file to serve per feed
+
+	server := &http.Server{
+		Addr: *listen,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			feed := strings.TrimSuffix(path.Base(r.URL.Path), ".pb")
+			if feed == "" || feed == "." || feed == "/" {
+				http.NotFound(w, r)
+				return
+			}
+
+			name := filepath.Join(*dir, feed+".pb")
+			if files, _ := filepath.Glob(filepath.Join(*dir, feed, "*.pb")); len(files) > 0 {
+				sort.Strings(files)
+				mu.Lock()
+				name = files[next[feed]%len(files)]
+				next[feed]++
+				mu.Unlock()
+			}
+
+			data, err := os.ReadFile(name)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			log.Printf("Serving %s", name)
+			w.Header().Set("Content-Type", "application/x-protobuf")
+			w.Write(data)
+		}),
+	}
+	context.AfterFunc(ctx, func() { server.Close() })
+
+	log.Printf("Serving recordings from %s on %s", *dir, *listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
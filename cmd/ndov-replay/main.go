@@ -0,0 +1,162 @@
+// Command ndov-replay records NDOV Loket ZeroMQ traffic to a file and plays
+// it back from a local publisher, so the realtime worker can be run against
+// known input:
+//
+//	ndov-replay record -from tcp://pubsub.ndovloket.nl:7658 -topic /GVB/ -out gvb.rec
+//	ndov-replay play -in gvb.rec -listen tcp://127.0.0.1:7658
+//	NDOV_ENDPOINTS=tcp://127.0.0.1:7658 REALTIME_POLL_LINES=false go run ./cmd/realtime-worker
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"arrivo-transit-api/internal/ndov"
+)
+
+const usage = `usage: ndov-replay <command> [flags]
+
+commands:
+  record   subscribe to an endpoint and append its messages to a file
+  play     publish a recording on a local endpoint`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = record(ctx, os.Args[2:])
+	case "play":
+		err = play(ctx, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", os.Args[1], usage)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
+
+func record(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	from := fs.String("from", "tcp://pubsub.ndovloket.nl:7658", "endpoint to subscribe to")
+	topic := fs.String("topic", "/", "topic prefix to subscribe to")
+	out := fs.String("out", "ndov.rec", "recording to append to")
+	fs.Parse(args)
+
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sub, err := ndov.Subscribe(ctx, *from, 0, *topic)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", *from, err)
+	}
+	context.AfterFunc(ctx, func() { sub.Close() })
+
+	recorder := ndov.NewRecorder(f)
+	defer recorder.Flush()
+
+	count := 0
+	for {
+		frames, err := sub.Receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Printf("Recorded %d messages to %s", count, *out)
+				return nil
+			}
+			return err
+		}
+		if err := recorder.Record(time.Now(), frames); err != nil {
+			return err
+		}
+		count++
+	}
+}
+
+func play(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	in := fs.String("in", "ndov.rec", "recording to play")
+	listen := fs.String("listen", "tcp://127.0.0.1:7658", "endpoint to publish on")
+	speed := fs.Float64("speed", 1, "playback speed, 0 publishes as fast as possible")
+	loop := fs.Bool("loop", false, "restart at the end of the recording")
+	fs.Parse(args)
+
+	pub, err := ndov.Listen(*listen)
+	if err != nil {
+		return err
+	}
+	defer pub.Close()
+
+	log.Printf("Waiting for a subscriber on %s", pub.Addr())
+	for pub.Subscribers() == 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	// Give the subscriber a moment to send its subscriptions
+	time.Sleep(100 * time.Millisecond)
+
+	for {
+		count, err := playOnce(ctx, pub, *in, *speed)
+		if err != nil {
+			return err
+		}
+		log.Printf("Published %d messages", count)
+		if !*loop {
+			return nil
+		}
+	}
+}
+
+// playOnce publishes a recording, keeping the original gaps between
+// messages divided by speed.
+func playOnce(ctx context.Context, pub *ndov.Publisher, path string, speed float64) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	player := ndov.NewPlayer(f)
+	var previous time.Time
+	count := 0
+	for {
+		at, frames, err := player.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		if speed > 0 && !previous.IsZero() && at.After(previous) {
+			select {
+			case <-ctx.Done():
+				return count, ctx.Err()
+			case <-time.After(time.Duration(float64(at.Sub(previous)) / speed)):
+			}
+		}
+		previous = at
+
+		pub.Publish(frames...)
+		count++
+	}
+}
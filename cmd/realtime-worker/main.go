@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+
+	"arrivo-transit-api/internal/cache"
+	"arrivo-transit-api/internal/config"
+	"arrivo-transit-api/internal/database"
+	"arrivo-transit-api/internal/gtfsrt"
+	"arrivo-transit-api/internal/metrics"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/realtime"
+	"arrivo-transit-api/internal/store/postgres"
+	"arrivo-transit-api/internal/store/redisstore"
+	"arrivo-transit-api/internal/upstream"
+)
+
+func main() {
+	log.Println("Starting realtime worker...")
+
+	cfg, err := config.LoadRealtimeWorker()
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.Open(ctx, database.Options{PrimaryDSN: cfg.PostgresDSN})
+	if err != nil {
+		log.Fatalf("Error connecting to database: %s", err)
+	}
+	defer db.Close()
+
+	redisClient, err := cache.NewRedis(cfg.RedisDSN)
+	if err != nil {
+		log.Fatalf("Error connecting to redis: %s", err)
+	}
+	defer redisClient.Close()
+
+	static := postgres.New(db)
+
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", metrics.Handler())
+		go func() {
+			if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+				log.Printf("ERROR: Metrics server stopped: %v", err)
+			}
+		}()
+	}
+
+	ovapiConfig := upstream.DefaultConfig("OVapi", cfg.OVapiBaseURL)
+	ovapiConfig.Timeout = cfg.OVapiTimeout
+	ovapiConfig.MaxAttempts = cfg.OVapiMaxAttempts
+	ovapiConfig.RetryBudget = cfg.OVapiRetryBudget
+	ovapiConfig.HedgeAfter = cfg.OVapiHedgeAfter
+	ovapiConfig.MaxConcurrent = cfg.OVapiMaxConcurrent
+
+	// Every source that is configured becomes a provider
+	providers := map[string]realtime.Provider{
+		realtime.SourceOVapi: realtime.NewOVapiProvider(ovapi.NewClient(ovapiConfig), realtime.OVapiConfig{
+			PollLines:   cfg.PollLines,
+			BatchSize:   cfg.BatchSize,
+			Concurrency: cfg.Concurrency,
+		}),
+	}
+	if len(cfg.NDOVEndpoints) > 0 {
+		journeys := realtime.NewJourneys()
+		for _, endpoint := range cfg.NDOVEndpoints {
+			go realtime.NewNDOVFeed(endpoint, cfg.NDOVTopics, journeys).Run(ctx)
+		}
+		providers[realtime.SourceNDOV] = journeys
+	}
+	if len(cfg.GTFSRTFeedURLs) > 0 {
+		gtfsRealtime := realtime.NewGTFSRT()
+		go realtime.NewGTFSRTFeed(gtfsrt.NewClient(), static, cfg.GTFSRTFeedURLs, cfg.GTFSRTPollInterval, gtfsRealtime).Run(ctx)
+		providers[realtime.SourceGTFSRT] = gtfsRealtime
+	}
+
+	var ordered []realtime.Provider
+	for _, source := range cfg.SourcePriority {
+		provider, ok := providers[source]
+		if !ok {
+			log.Printf("WARN: Realtime source %q in REALTIME_SOURCE_PRIORITY is unknown or not configured", source)
+			continue
+		}
+		ordered = append(ordered, provider)
+		delete(providers, source)
+	}
+	for source := range providers {
+		log.Printf("WARN: Realtime source %q is configured but missing from REALTIME_SOURCE_PRIORITY, ignoring it", source)
+	}
+	if len(ordered) == 0 {
+		log.Fatal("No realtime sources configured")
+	}
+
+	var archive *realtime.Archive
+	archiveDone := make(chan struct{})
+	if cfg.ArchiveEnabled {
+		archive = realtime.NewArchive(static, realtime.ArchiveConfig{
+			BatchSize:     cfg.ArchiveBatchSize,
+			FlushInterval: cfg.ArchiveFlushInterval,
+			Retention:     cfg.ArchiveRetention,
+		})
+		go func() {
+			archive.Run(ctx)
+			close(archiveDone)
+		}()
+	} else {
+		close(archiveDone)
+	}
+
+	worker := realtime.NewWorker(realtime.NewMerger(ordered...), static, redisstore.New(redisClient), realtime.WorkerConfig{
+		Interval:     cfg.Interval,
+		BatchSize:    cfg.BatchSize,
+		Concurrency:  cfg.Concurrency,
+		DepartureTTL: cfg.DepartureTTL,
+		LineTTL:      cfg.LineTTL,
+		MaxStaleness: cfg.MaxStaleness,
+		TimingPoints: cfg.TimingPoints,
+		Archive:      archive,
+	})
+
+	if err := worker.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatalf("Realtime worker stopped: %s", err)
+	}
+	<-archiveDone
+	log.Println("Realtime worker stopped")
+}
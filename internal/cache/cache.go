@@ -0,0 +1,14 @@
+package cache
+
+import "context"
+
+// Cache keeps serialized responses for a short while. LRUCache implements it
+// in process.
+type Cache interface {
+	// Get returns the value stored under key, if it has not expired.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores value under key, replacing an existing value.
+	Set(ctx context.Context, key string, value []byte)
+}
+
+var _ Cache = (*LRUCache)(nil)
@@ -3,20 +3,30 @@ package cache
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/golang/groupcache/lru"
 )
 
-// LRUCache is a simple thread-safe LRU cache.
+// LRUCache is a simple thread-safe LRU cache whose entries expire after a
+// fixed TTL.
 type LRUCache struct {
 	cache *lru.Cache
+	ttl   time.Duration
 	mu    sync.Mutex
 }
 
-// NewLRUCache creates a new LRU cache with the given max size.
-func NewLRUCache(maxEntries int) *LRUCache {
+type lruEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRUCache creates a new LRU cache with the given max size. Entries are
+// served for at most ttl; zero disables expiry.
+func NewLRUCache(maxEntries int, ttl time.Duration) *LRUCache {
 	return &LRUCache{
 		cache: lru.New(maxEntries),
+		ttl:   ttl,
 	}
 }
 
@@ -30,13 +40,18 @@ func (c *LRUCache) Get(ctx context.Context, key string) ([]byte, bool) {
 		return nil, false
 	}
 
-	byteVal, ok := val.([]byte)
+	entry, ok := val.(lruEntry)
 	if !ok {
-		// This should not happen if we only add byte slices
+		// This should not happen if we only add lruEntry values
+		return nil, false
+	}
+
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.cache.Remove(key)
 		return nil, false
 	}
 
-	return byteVal, true
+	return entry.value, true
 }
 
 // Set sets a value in the cache.
@@ -44,5 +59,9 @@ func (c *LRUCache) Set(ctx context.Context, key string, value []byte) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.cache.Add(key, value)
+	entry := lruEntry{value: value}
+	if c.ttl > 0 {
+		entry.expiresAt = time.Now().Add(c.ttl)
+	}
+	c.cache.Add(key, entry)
 }
\ No newline at end of file
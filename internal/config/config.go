@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -10,6 +12,14 @@ type Config struct {
 	PostgresDSN string `envconfig:"POSTGRES_DSN" required:"true"`
 	RedisDSN    string `envconfig:"REDIS_DSN" required:"true"`
 	Port        string `envconfig:"PORT" default:"8080"`
+	AdminToken  string `envconfig:"ADMIN_TOKEN"`                 // Enables /api/v1/admin when set
+	SchemaCheck string `envconfig:"SCHEMA_CHECK" default:"warn"` // "strict", "warn" or "off"
+
+	// Read replicas (comma-separated DSNs) and pool tuning for the API
+	PostgresReplicaDSNs      []string      `envconfig:"POSTGRES_REPLICA_DSNS"`
+	PostgresMaxConns         int32         `envconfig:"POSTGRES_MAX_CONNS" default:"10"`
+	PostgresReplicaMaxConns  int32         `envconfig:"POSTGRES_REPLICA_MAX_CONNS" default:"20"`
+	PostgresStatementTimeout time.Duration `envconfig:"POSTGRES_STATEMENT_TIMEOUT" default:"2s"`
 }
 
 // Load returns a new Config struct populated from environment variables.
@@ -19,4 +29,57 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 	return &cfg, nil
-}
\ No newline at end of file
+}
+
+// RealtimeWorkerConfig holds the configuration of the realtime worker.
+type RealtimeWorkerConfig struct {
+	PostgresDSN  string        `envconfig:"POSTGRES_DSN" required:"true"`
+	RedisDSN     string        `envconfig:"REDIS_DSN" required:"true"`
+	Interval     time.Duration `envconfig:"REALTIME_POLL_INTERVAL" default:"30s"`
+	BatchSize    int           `envconfig:"REALTIME_BATCH_SIZE" default:"50"`
+	Concurrency  int           `envconfig:"REALTIME_CONCURRENCY" default:"8"`
+	DepartureTTL time.Duration `envconfig:"REALTIME_DEPARTURE_TTL" default:"2m"`
+	LineTTL      time.Duration `envconfig:"REALTIME_LINE_TTL" default:"2m"`
+	// Serve the last snapshot, marked stale, up to this age during incidents
+	MaxStaleness time.Duration `envconfig:"REALTIME_MAX_STALENESS" default:"5m"`
+	TimingPoints []string      `envconfig:"REALTIME_TIMING_POINTS"` // Poll only these TPCs (comma-separated)
+	PollLines    bool          `envconfig:"REALTIME_POLL_LINES" default:"true"`
+	// Configured sources, most trusted first: "ovapi", "ndov", "gtfs-rt"
+	SourcePriority []string `envconfig:"REALTIME_SOURCE_PRIORITY" default:"ndov,gtfs-rt,ovapi"`
+
+	// OVapi upstream; per-endpoint concurrency should cover REALTIME_CONCURRENCY
+	OVapiBaseURL       string        `envconfig:"OVAPI_BASE_URL" default:"http://v0.ovapi.nl"`
+	OVapiTimeout       time.Duration `envconfig:"OVAPI_TIMEOUT" default:"10s"` // Per attempt
+	OVapiMaxAttempts   int           `envconfig:"OVAPI_MAX_ATTEMPTS" default:"3"`
+	OVapiRetryBudget   float64       `envconfig:"OVAPI_RETRY_BUDGET" default:"0.2"`  // Retries and hedges per request
+	OVapiHedgeAfter    time.Duration `envconfig:"OVAPI_HEDGE_AFTER" default:"2s"`    // 0 disables hedging
+	OVapiMaxConcurrent int           `envconfig:"OVAPI_MAX_CONCURRENT" default:"16"` // Per endpoint
+
+	// Serves expvar metrics at /debug/vars when set, e.g. :9090
+	MetricsAddr string `envconfig:"REALTIME_METRICS_ADDR"`
+
+	// NDOV Loket ZeroMQ feeds overlaid on OVapi, e.g. tcp://pubsub.ndovloket.nl:7658
+	NDOVEndpoints []string `envconfig:"NDOV_ENDPOINTS"`
+	NDOVTopics    []string `envconfig:"NDOV_TOPICS" default:"/"` // Topic prefixes, e.g. /GVB/KV6posinfo
+
+	// GTFS-Realtime feeds overlaid on OVapi, e.g. https://gtfs.ovapi.nl/nl/tripUpdates.pb
+	GTFSRTFeedURLs     []string      `envconfig:"GTFSRT_FEED_URLS"`
+	GTFSRTPollInterval time.Duration `envconfig:"GTFSRT_POLL_INTERVAL" default:"15s"`
+
+	// Archive of observed calls and vehicle positions in Postgres
+	ArchiveEnabled       bool          `envconfig:"ARCHIVE_ENABLED" default:"true"`
+	ArchiveBatchSize     int           `envconfig:"ARCHIVE_BATCH_SIZE" default:"1000"`
+	ArchiveFlushInterval time.Duration `envconfig:"ARCHIVE_FLUSH_INTERVAL" default:"30s"`
+	// Day partitions older than this are dropped, e.g. 2160h for 90 days
+	ArchiveRetention time.Duration `envconfig:"ARCHIVE_RETENTION" default:"2160h"`
+}
+
+// LoadRealtimeWorker returns the realtime worker configuration populated from
+// environment variables.
+func LoadRealtimeWorker() (*RealtimeWorkerConfig, error) {
+	var cfg RealtimeWorkerConfig
+	if err := envconfig.Process("", &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
@@ -2,7 +2,18 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -18,4 +29,248 @@ func NewDB(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 	}
 
 	return pool, nil
-}
\ No newline at end of file
+}
+
+// Options configures the pools opened by Open.
+type Options struct {
+	PrimaryDSN       string
+	ReplicaDSNs      []string
+	MaxConns         int32         // Primary pool size, 0 keeps the pgx default
+	ReplicaMaxConns  int32         // Size of each replica pool, 0 keeps the pgx default
+	StatementTimeout time.Duration // Server-side statement_timeout, 0 disables it
+	HealthInterval   time.Duration // How often replicas are pinged, defaults to 10s
+}
+
+// DB routes reads to read replicas and writes to the primary. Replicas that
+// fail a health check or a query on a broken connection are skipped until
+// they respond to a ping again; without healthy replicas reads go to the
+// primary.
+type DB struct {
+	primary  *pgxpool.Pool
+	replicas []*replica
+	next     atomic.Uint32
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// Open connects to the primary and creates pools for all replicas. The
+// primary must be reachable; replicas that are down only log a warning.
+func Open(ctx context.Context, opts Options) (*DB, error) {
+	primary, err := newPool(ctx, opts.PrimaryDSN, opts.MaxConns, opts.StatementTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("primary: %w", err)
+	}
+	if err := primary.Ping(ctx); err != nil {
+		primary.Close()
+		return nil, fmt.Errorf("primary: %w", err)
+	}
+
+	db := &DB{
+		primary: primary,
+		stop:    make(chan struct{}),
+	}
+
+	for i, dsn := range opts.ReplicaDSNs {
+		pool, err := newPool(ctx, dsn, opts.ReplicaMaxConns, opts.StatementTimeout)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+
+		r := &replica{name: "replica-" + strconv.Itoa(i), pool: pool}
+		if err := pool.Ping(ctx); err != nil {
+			log.Printf("WARN: Database %s unavailable, reads fall back to primary: %v", r.name, err)
+		} else {
+			r.healthy.Store(true)
+		}
+		db.replicas = append(db.replicas, r)
+	}
+
+	if len(db.replicas) > 0 {
+		interval := opts.HealthInterval
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		go db.monitor(interval)
+	}
+
+	return db, nil
+}
+
+func newPool(ctx context.Context, dsn string, maxConns int32, statementTimeout time.Duration) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if maxConns > 0 {
+		cfg.MaxConns = maxConns
+	}
+	if statementTimeout > 0 {
+		cfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(statementTimeout.Milliseconds(), 10)
+	}
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
+
+// monitor pings every replica until Close is called, updating its health.
+func (db *DB) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, r := range db.replicas {
+			ctx, cancel := context.WithTimeout(context.Background(), interval/2)
+			err := r.pool.Ping(ctx)
+			cancel()
+
+			healthy := err == nil
+			if r.healthy.Swap(healthy) != healthy {
+				if healthy {
+					log.Printf("Database %s recovered", r.name)
+				} else {
+					log.Printf("WARN: Database %s unhealthy: %v", r.name, err)
+				}
+			}
+		}
+	}
+}
+
+// Primary returns the pool for writes and reads that must see them.
+func (db *DB) Primary() *pgxpool.Pool {
+	return db.primary
+}
+
+// reader returns a healthy replica in round-robin order, or nil.
+func (db *DB) reader() *replica {
+	n := len(db.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := int(db.next.Add(1))
+	for i := 0; i < n; i++ {
+		r := db.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// Reader returns the pool reads should use: a healthy replica, or the
+// primary when none is available.
+func (db *DB) Reader() *pgxpool.Pool {
+	if r := db.reader(); r != nil {
+		return r.pool
+	}
+	return db.primary
+}
+
+// Query runs a read-only query on a replica. When the replica fails with a
+// connection-level error it is marked unhealthy and the query is retried on
+// the primary.
+func (db *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	r := db.reader()
+	if r == nil {
+		return db.primary.Query(ctx, sql, args...)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err == nil || !isConnectionError(ctx, err) {
+		return rows, err
+	}
+
+	r.fail(err)
+	return db.primary.Query(ctx, sql, args...)
+}
+
+// QueryRow runs a read-only single-row query on a replica, or the primary
+// when no replica is healthy. Like Query, it is retried on the primary when
+// the replica fails with a connection-level error; as QueryRow reports
+// errors from Scan, that is where the retry happens.
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	r := db.reader()
+	if r == nil {
+		return db.primary.QueryRow(ctx, sql, args...)
+	}
+	return &replicaRow{
+		db:      db,
+		replica: r,
+		row:     r.pool.QueryRow(ctx, sql, args...),
+		ctx:     ctx,
+		sql:     sql,
+		args:    args,
+	}
+}
+
+// replicaRow is a row read from a replica that is read again from the
+// primary when the replica fails.
+type replicaRow struct {
+	db      *DB
+	replica *replica
+	row     pgx.Row
+
+	ctx  context.Context
+	sql  string
+	args []any
+}
+
+func (r *replicaRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if err == nil || !isConnectionError(r.ctx, err) {
+		return err
+	}
+
+	r.replica.fail(err)
+	return r.db.primary.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+}
+
+// fail marks the replica unhealthy until it responds to a ping again.
+func (r *replica) fail(err error) {
+	if r.healthy.Swap(false) {
+		log.Printf("WARN: Database %s failed, falling back to primary: %v", r.name, err)
+	}
+}
+
+// Exec runs a statement on the primary.
+func (db *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return db.primary.Exec(ctx, sql, args...)
+}
+
+// Close stops the health checks and closes all pools.
+func (db *DB) Close() {
+	db.stopOnce.Do(func() { close(db.stop) })
+	for _, r := range db.replicas {
+		r.pool.Close()
+	}
+	db.primary.Close()
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached or the connection broke, as opposed to the server rejecting the
+// query, the client failing to encode or scan values, or the caller giving
+// up. Only such errors make a replica unhealthy.
+func isConnectionError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) || pgconn.Timeout(err) || errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// retryable is an error pgconn marks as safe to retry, like a failure to
+// send a query.
+type retryable struct{}
+
+func (retryable) Error() string     { return "failed to write startup message" }
+func (retryable) SafeToRetry() bool { return true }
+
+// connectTimeout returns the error of connecting to a server that accepts
+// connections but never answers.
+func connectTimeout(t *testing.T) error {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer nc.Close()
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	config, err := pgconn.ParseConfig(fmt.Sprintf("postgres://user@127.0.0.1:%d/db?sslmode=disable", addr.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ConnectTimeout = 50 * time.Millisecond
+	conn, err := pgconn.ConnectConfig(context.Background(), config)
+	if err == nil {
+		conn.Close(context.Background())
+		t.Fatal("connected to a server that never answers")
+	}
+	return err
+}
+
+func TestIsConnectionError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"unexpected EOF", context.Background(), fmt.Errorf("failed to read: %w", io.ErrUnexpectedEOF), true},
+		{"EOF", context.Background(), fmt.Errorf("failed to receive message: %w", io.EOF), true},
+		{"reset", context.Background(), &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"safe to retry", context.Background(), fmt.Errorf("failed to send: %w", retryable{}), true},
+		{"connect timeout", context.Background(), connectTimeout(t), true},
+		{"no rows", context.Background(), pgx.ErrNoRows, false},
+		{"scan", context.Background(), pgx.ScanArgError{ColumnIndex: 1, Err: errors.New("cannot scan")}, false},
+		{"encode", context.Background(), errors.New("failed to encode args[0]: unable to encode 1 into text format"), false},
+		{"server error", context.Background(), &pgconn.PgError{Code: "42P01"}, false},
+		{"cancelled", cancelled, fmt.Errorf("failed to read: %w", io.ErrUnexpectedEOF), false},
+	} {
+		if got := isConnectionError(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("%s: isConnectionError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
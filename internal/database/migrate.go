@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// migrationFiles holds the SQL migrations compiled into every binary, so no
+// process depends on the migrations directory being present at runtime.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrator applies the embedded migrations to a database.
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// NewMigrator opens a dedicated connection to dsn for running migrations.
+// Close must be called when done.
+func NewMigrator(dsn string) (*Migrator, error) {
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+
+	source, err := iofs.New(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, connConfig.Database, driver)
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+
+	return &Migrator{m: m}, nil
+}
+
+// Up applies all pending migrations.
+func (mg *Migrator) Up() error {
+	if err := mg.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Down rolls back the given number of migrations.
+func (mg *Migrator) Down(steps int) error {
+	if err := mg.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Force sets the recorded schema version without running any migration and
+// clears the dirty flag, to recover from a failed migration.
+func (mg *Migrator) Force(version int) error {
+	return mg.m.Force(version)
+}
+
+// Version returns the applied schema version. A database without any
+// migration applied reports version 0.
+func (mg *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Close releases the migration connection.
+func (mg *Migrator) Close() {
+	if srcErr, dbErr := mg.m.Close(); srcErr != nil || dbErr != nil {
+		log.Printf("WARN: Failed to close migrator: %v %v", srcErr, dbErr)
+	}
+}
+
+// SchemaVersion returns the highest migration version embedded in this
+// binary, i.e. the schema version the code expects.
+func SchemaVersion() (uint, error) {
+	entries, err := fs.Glob(migrationFiles, "migrations/*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint
+	for _, entry := range entries {
+		var version uint
+		if _, err := fmt.Sscanf(entry, "migrations/%d_", &version); err != nil {
+			return 0, fmt.Errorf("unexpected migration file name %s: %w", entry, err)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// MigrateUp applies all pending migrations to dsn.
+func MigrateUp(dsn string) error {
+	mg, err := NewMigrator(dsn)
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	return mg.Up()
+}
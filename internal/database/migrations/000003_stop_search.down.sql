@@ -0,0 +1,11 @@
+-- Revert fuzzy stop search
+DROP INDEX IF EXISTS stops_search_place_trgm_idx;
+DROP INDEX IF EXISTS stops_search_name_trgm_idx;
+
+ALTER TABLE stops
+    DROP COLUMN IF EXISTS search_place,
+    DROP COLUMN IF EXISTS search_city,
+    DROP COLUMN IF EXISTS search_name;
+
+DROP FUNCTION IF EXISTS stop_search_name(text);
+DROP FUNCTION IF EXISTS f_unaccent(text);
@@ -0,0 +1,30 @@
+-- Fuzzy, accent-insensitive stop search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent() is only STABLE; wrap it with an explicit dictionary so it can be
+-- used in generated columns and index expressions.
+CREATE OR REPLACE FUNCTION f_unaccent(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+    AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$;
+
+-- stop_search_name normalizes a name the same way search.Normalize does in Go:
+-- lowercase, no diacritics, punctuation collapsed into single spaces.
+CREATE OR REPLACE FUNCTION stop_search_name(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+    AS $$ SELECT btrim(regexp_replace(lower(f_unaccent($1)), '[^a-z0-9]+', ' ', 'g')) $$;
+
+-- GTFS-NL stop names are formatted as "City, Stop name".
+ALTER TABLE stops
+    ADD COLUMN IF NOT EXISTS search_name TEXT
+        GENERATED ALWAYS AS (stop_search_name(stop_name)) STORED,
+    ADD COLUMN IF NOT EXISTS search_city TEXT
+        GENERATED ALWAYS AS (CASE WHEN position(',' IN stop_name) > 0
+            THEN stop_search_name(split_part(stop_name, ',', 1)) ELSE '' END) STORED,
+    ADD COLUMN IF NOT EXISTS search_place TEXT
+        GENERATED ALWAYS AS (CASE WHEN position(',' IN stop_name) > 0
+            THEN stop_search_name(substr(stop_name, position(',' IN stop_name) + 1))
+            ELSE stop_search_name(stop_name) END) STORED;
+
+CREATE INDEX IF NOT EXISTS stops_search_name_trgm_idx ON stops USING GIN (search_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS stops_search_place_trgm_idx ON stops USING GIN (search_place gin_trgm_ops);
@@ -0,0 +1,4 @@
+-- Revert stop name aliases
+DROP TRIGGER IF EXISTS stops_former_name ON stops;
+DROP FUNCTION IF EXISTS record_former_stop_name();
+DROP TABLE IF EXISTS stop_aliases;
@@ -0,0 +1,45 @@
+-- Stop name aliases: abbreviations ("CS"), colloquial names ("Den Bosch") and
+-- former names. The table is maintained by admins and is never touched by
+-- the GTFS ingestor, so it survives feed reloads.
+CREATE TABLE IF NOT EXISTS stop_aliases (
+    id BIGSERIAL PRIMARY KEY,
+    alias TEXT NOT NULL,
+    alias_key TEXT GENERATED ALWAYS AS (stop_search_name(alias)) STORED,
+    expansion TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'abbreviation' CHECK (kind IN ('abbreviation', 'colloquial', 'former')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stop_aliases_alias_key_idx ON stop_aliases (alias_key);
+
+INSERT INTO stop_aliases (alias, expansion, kind) VALUES
+    ('CS', 'Centraal Station', 'abbreviation'),
+    ('Adam', 'Amsterdam', 'colloquial'),
+    ('A''dam', 'Amsterdam', 'colloquial'),
+    ('Mokum', 'Amsterdam', 'colloquial'),
+    ('Rdam', 'Rotterdam', 'colloquial'),
+    ('R''dam', 'Rotterdam', 'colloquial'),
+    ('Den Bosch', '''s-Hertogenbosch', 'colloquial'),
+    ('''s-Gravenhage', 'Den Haag', 'colloquial'),
+    ('Dordt', 'Dordrecht', 'colloquial')
+ON CONFLICT (alias_key) DO NOTHING;
+
+-- Keep renamed stops findable: when a feed update renames a stop, the old name
+-- becomes a 'former' alias for the new one.
+CREATE OR REPLACE FUNCTION record_former_stop_name() RETURNS trigger
+    LANGUAGE plpgsql AS $$
+BEGIN
+    INSERT INTO stop_aliases (alias, expansion, kind)
+    VALUES (OLD.stop_name, NEW.stop_name, 'former')
+    ON CONFLICT (alias_key) DO NOTHING;
+    RETURN NEW;
+END
+$$;
+
+DROP TRIGGER IF EXISTS stops_former_name ON stops;
+CREATE TRIGGER stops_former_name
+    AFTER UPDATE OF stop_name ON stops
+    FOR EACH ROW
+    WHEN (stop_search_name(OLD.stop_name) IS DISTINCT FROM stop_search_name(NEW.stop_name))
+    EXECUTE FUNCTION record_former_stop_name();
@@ -0,0 +1,3 @@
+-- Revert stop/TPC mapping
+DROP INDEX IF EXISTS stops_parent_station_idx;
+DROP TABLE IF EXISTS stop_timing_points;
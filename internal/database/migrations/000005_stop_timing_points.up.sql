@@ -0,0 +1,14 @@
+-- Mapping between GTFS stops and OVapi/KV78turbo TimingPointCodes (TPCs).
+-- GTFS-NL publishes the TPC of a quay as stop_code and groups quays into
+-- stop areas through parent_station ("stoparea:<code>"). Rebuilt on every
+-- ingest from the stops table.
+CREATE TABLE IF NOT EXISTS stop_timing_points (
+    stop_id TEXT NOT NULL,
+    timing_point_code TEXT NOT NULL,
+    stop_area_code TEXT,
+    PRIMARY KEY (stop_id, timing_point_code)
+);
+
+CREATE INDEX IF NOT EXISTS stop_timing_points_tpc_idx ON stop_timing_points (timing_point_code);
+CREATE INDEX IF NOT EXISTS stop_timing_points_stop_area_idx ON stop_timing_points (stop_area_code);
+CREATE INDEX IF NOT EXISTS stops_parent_station_idx ON stops (parent_station);
@@ -0,0 +1,3 @@
+-- Revert realtime trip IDs
+DROP INDEX IF EXISTS trips_realtime_trip_id_idx;
+ALTER TABLE trips DROP COLUMN IF EXISTS realtime_trip_id;
@@ -0,0 +1,6 @@
+-- GTFS-NL links every trip to its KV78turbo/KV6 journey through
+-- realtime_trip_id ("<DataOwnerCode>:<LinePlanningNumber>:<JourneyNumber>"),
+-- so realtime passes can be matched to scheduled trips and routes.
+ALTER TABLE trips ADD COLUMN IF NOT EXISTS realtime_trip_id TEXT;
+
+CREATE INDEX IF NOT EXISTS trips_realtime_trip_id_idx ON trips (realtime_trip_id);
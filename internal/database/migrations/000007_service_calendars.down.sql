@@ -0,0 +1,4 @@
+-- Revert service calendars
+DROP INDEX IF EXISTS trips_service_id_idx;
+DROP TABLE IF EXISTS calendar_dates;
+DROP TABLE IF EXISTS calendar;
@@ -0,0 +1,25 @@
+-- Service calendars decide on which dates the trips of a service_id run.
+-- GTFS-NL only publishes calendar_dates; calendar is optional in GTFS.
+-- Both are replaced on every ingest.
+CREATE TABLE IF NOT EXISTS calendar (
+    service_id TEXT PRIMARY KEY,
+    monday SMALLINT NOT NULL,
+    tuesday SMALLINT NOT NULL,
+    wednesday SMALLINT NOT NULL,
+    thursday SMALLINT NOT NULL,
+    friday SMALLINT NOT NULL,
+    saturday SMALLINT NOT NULL,
+    sunday SMALLINT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS calendar_dates (
+    service_id TEXT NOT NULL,
+    date DATE NOT NULL,
+    exception_type SMALLINT NOT NULL, -- 1 = service added, 2 = service removed
+    PRIMARY KEY (service_id, date)
+);
+
+CREATE INDEX IF NOT EXISTS calendar_dates_date_idx ON calendar_dates (date);
+CREATE INDEX IF NOT EXISTS trips_service_id_idx ON trips (service_id);
@@ -0,0 +1,2 @@
+-- Revert the stop_times departure index
+DROP INDEX IF EXISTS stop_times_stop_departure_idx;
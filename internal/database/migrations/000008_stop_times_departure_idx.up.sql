@@ -0,0 +1,3 @@
+-- Scheduled departures are looked up by stop and time of day. The ingestor
+-- only created this index on its slow stop_times path.
+CREATE INDEX IF NOT EXISTS stop_times_stop_departure_idx ON stop_times (stop_id, departure_sec);
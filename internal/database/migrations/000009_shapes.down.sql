@@ -0,0 +1,2 @@
+-- Revert shapes
+DROP TABLE IF EXISTS shapes;
@@ -0,0 +1,10 @@
+-- Trip geometry from shapes.txt, replaced on every ingest. Vehicle positions
+-- are projected along the shape of their trip between updates.
+CREATE TABLE IF NOT EXISTS shapes (
+    shape_id TEXT NOT NULL,
+    shape_pt_sequence INTEGER NOT NULL,
+    shape_pt_lat DOUBLE PRECISION NOT NULL,
+    shape_pt_lon DOUBLE PRECISION NOT NULL,
+    shape_dist_traveled DOUBLE PRECISION,
+    PRIMARY KEY (shape_id, shape_pt_sequence)
+);
@@ -0,0 +1,3 @@
+-- Revert observations; drops all partitions with them
+DROP TABLE IF EXISTS vehicle_observations;
+DROP TABLE IF EXISTS call_observations;
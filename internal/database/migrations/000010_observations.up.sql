@@ -0,0 +1,45 @@
+-- Archive of realtime observations, written in batches by the realtime worker.
+-- Both tables are partitioned by day; the worker creates partitions ahead of
+-- time and drops those older than the configured retention.
+
+-- Calls journeys completed or skipped, once per call of a journey.
+CREATE TABLE IF NOT EXISTS call_observations (
+    service_date DATE NOT NULL,
+    realtime_trip_id TEXT NOT NULL,
+    stop_sequence INTEGER NOT NULL, -- UserStopOrderNumber
+    trip_id TEXT,
+    route_id TEXT,
+    stop_id TEXT,
+    timing_point_code TEXT NOT NULL,
+    scheduled_arrival TIMESTAMPTZ,
+    scheduled_departure TIMESTAMPTZ,
+    actual_arrival TIMESTAMPTZ,
+    actual_departure TIMESTAMPTZ,
+    status TEXT NOT NULL, -- DEPARTED, CANCELLED or SKIPPED
+    source TEXT NOT NULL,
+    observed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (service_date, realtime_trip_id, stop_sequence)
+) PARTITION BY RANGE (service_date);
+
+CREATE INDEX IF NOT EXISTS idx_call_observations_stop ON call_observations (stop_id, service_date);
+CREATE INDEX IF NOT EXISTS idx_call_observations_route ON call_observations (route_id, service_date);
+
+-- Reported vehicle positions, once per report.
+CREATE TABLE IF NOT EXISTS vehicle_observations (
+    observed_at TIMESTAMPTZ NOT NULL, -- Time of the report
+    vehicle_id TEXT NOT NULL,
+    trip_id TEXT,
+    route_id TEXT,
+    lat DOUBLE PRECISION NOT NULL,
+    lon DOUBLE PRECISION NOT NULL,
+    bearing DOUBLE PRECISION,
+    speed DOUBLE PRECISION,
+    delay INTEGER,
+    status TEXT NOT NULL,
+    stop_id TEXT,
+    source TEXT NOT NULL,
+    PRIMARY KEY (vehicle_id, observed_at)
+) PARTITION BY RANGE (observed_at);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_observations_route ON vehicle_observations (route_id, observed_at);
+CREATE INDEX IF NOT EXISTS idx_vehicle_observations_trip ON vehicle_observations (trip_id);
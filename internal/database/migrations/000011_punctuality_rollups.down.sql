@@ -0,0 +1,2 @@
+-- Revert punctuality rollups
+DROP TABLE IF EXISTS punctuality_rollups;
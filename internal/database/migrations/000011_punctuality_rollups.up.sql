@@ -0,0 +1,19 @@
+-- Daily punctuality per route, stop and hour of the scheduled departure,
+-- rolled up from call_observations by the realtime worker. Rollups outlive the
+-- observation partitions they were computed from.
+CREATE TABLE IF NOT EXISTS punctuality_rollups (
+    service_date DATE NOT NULL,
+    route_id TEXT NOT NULL,
+    stop_id TEXT NOT NULL,
+    hour SMALLINT NOT NULL, -- Local hour of the scheduled departure
+    calls INTEGER NOT NULL,
+    measured INTEGER NOT NULL,
+    on_time INTEGER NOT NULL,
+    cancelled INTEGER NOT NULL,
+    skipped INTEGER NOT NULL,
+    delay_histogram INTEGER[] NOT NULL, -- Measured calls per 30 second bucket from -10 minutes
+    PRIMARY KEY (service_date, route_id, stop_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_punctuality_rollups_route ON punctuality_rollups (route_id, service_date);
+CREATE INDEX IF NOT EXISTS idx_punctuality_rollups_stop ON punctuality_rollups (stop_id, service_date);
@@ -0,0 +1,3 @@
+-- Revert occupancy rollups
+DROP TABLE IF EXISTS occupancy_rollups;
+ALTER TABLE vehicle_observations DROP COLUMN IF EXISTS occupancy;
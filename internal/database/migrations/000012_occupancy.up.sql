@@ -0,0 +1,18 @@
+-- Occupancy reported with vehicle positions, and how crowded vehicles
+-- leaving a stop were per trip and hour of a day, rolled up from
+-- vehicle_observations by the realtime worker to forecast occupancy.
+ALTER TABLE vehicle_observations ADD COLUMN IF NOT EXISTS occupancy TEXT;
+
+CREATE TABLE IF NOT EXISTS occupancy_rollups (
+    service_date DATE NOT NULL, -- Local day of the reports
+    route_id TEXT NOT NULL,
+    trip_id TEXT NOT NULL,
+    stop_id TEXT NOT NULL, -- Stop the vehicles were at or heading to
+    hour SMALLINT NOT NULL, -- Local hour of the last report before the stop
+    vehicles INTEGER NOT NULL,
+    levels INTEGER[] NOT NULL, -- Vehicles per occupancy level, from EMPTY to NOT_ACCEPTING_PASSENGERS
+    PRIMARY KEY (service_date, route_id, trip_id, stop_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_occupancy_rollups_route ON occupancy_rollups (route_id, service_date);
+CREATE INDEX IF NOT EXISTS idx_occupancy_rollups_stop ON occupancy_rollups (stop_id, service_date);
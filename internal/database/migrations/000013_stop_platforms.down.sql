@@ -0,0 +1,3 @@
+-- Revert stop platforms
+ALTER TABLE stops DROP COLUMN IF EXISTS quay_name;
+ALTER TABLE stops DROP COLUMN IF EXISTS platform_code;
@@ -0,0 +1,4 @@
+-- Platform codes of quays ("5b") from GTFS, and the quay names of NeTEx
+-- exports, which also fill in platform codes GTFS lacks.
+ALTER TABLE stops ADD COLUMN IF NOT EXISTS platform_code TEXT;
+ALTER TABLE stops ADD COLUMN IF NOT EXISTS quay_name TEXT;
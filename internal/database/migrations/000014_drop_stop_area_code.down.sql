@@ -0,0 +1,3 @@
+-- Revert dropping the stop area code
+ALTER TABLE stop_timing_points ADD COLUMN IF NOT EXISTS stop_area_code TEXT;
+CREATE INDEX IF NOT EXISTS stop_timing_points_stop_area_idx ON stop_timing_points (stop_area_code);
@@ -0,0 +1,4 @@
+-- Stations are resolved through stops.parent_station; the stop area code
+-- derived from it was never read.
+DROP INDEX IF EXISTS stop_timing_points_stop_area_idx;
+ALTER TABLE stop_timing_points DROP COLUMN IF EXISTS stop_area_code;
@@ -0,0 +1,7 @@
+-- Revert searching for ø as "oe"
+CREATE OR REPLACE FUNCTION stop_search_name(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+    AS $$ SELECT btrim(regexp_replace(lower(f_unaccent($1)), '[^a-z0-9]+', ' ', 'g')) $$;
+
+UPDATE stops SET stop_name = stop_name WHERE stop_name ~ '[øØ]';
+UPDATE stop_aliases SET alias = alias WHERE alias ~ '[øØ]';
@@ -0,0 +1,9 @@
+-- unaccent spells ø as "o"; search for it as "oe" like search.Normalize, so
+-- "Kødbyen" and "Koedbyen" find each other.
+CREATE OR REPLACE FUNCTION stop_search_name(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+    AS $$ SELECT btrim(regexp_replace(lower(f_unaccent(replace(replace($1, 'ø', 'oe'), 'Ø', 'oe'))), '[^a-z0-9]+', ' ', 'g')) $$;
+
+-- Generated columns are only recomputed when their row is written
+UPDATE stops SET stop_name = stop_name WHERE stop_name ~ '[øØ]';
+UPDATE stop_aliases SET alias = alias WHERE alias ~ '[øØ]';
@@ -0,0 +1,72 @@
+// Package geo contains the small amount of spherical geometry the API needs.
+package geo
+
+import "math"
+
+// earthRadius is the mean earth radius in meters.
+const earthRadius = 6371000.0
+
+// Distance returns the great-circle distance in meters between two WGS84
+// coordinates.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Bearing returns the initial compass bearing in degrees [0, 360) for
+// travelling from the first point to the second.
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// RDToWGS84 converts Rijksdriehoek (EPSG:28992) coordinates, as used by
+// Dutch KV6 feeds, to WGS84. It uses the polynomial approximation by
+// Schreutelkamp and Strang van Hees, accurate to about a meter within the
+// Netherlands.
+func RDToWGS84(x, y float64) (lat, lon float64) {
+	dX := (x - 155000) * 1e-5
+	dY := (y - 463000) * 1e-5
+
+	sumN := 3235.65389*dY - 32.58297*dX*dX - 0.2475*dY*dY - 0.84978*dX*dX*dY -
+		0.0655*dY*dY*dY - 0.01709*dX*dX*dY*dY - 0.00738*dX + 0.0053*math.Pow(dX, 4) -
+		0.00039*dX*dX*dY*dY*dY + 0.00033*math.Pow(dX, 4)*dY - 0.00012*dX*dY
+	sumE := 5260.52916*dX + 105.94684*dX*dY + 2.45656*dX*dY*dY - 0.81885*dX*dX*dX +
+		0.05594*dX*dY*dY*dY - 0.05607*dX*dX*dX*dY + 0.01199*dY - 0.00256*dX*dX*dX*dY*dY +
+		0.00128*dX*math.Pow(dY, 4) + 0.00022*dY*dY - 0.00022*dX*dX + 0.00026*math.Pow(dX, 5)
+
+	return 52.15517440 + sumN/3600, 5.38720621 + sumE/3600
+}
+
+// Interpolate returns the point at fraction f of the way from the first
+// point to the second. It is linear in degrees, which is accurate enough for
+// the short segments of a shape.
+func Interpolate(lat1, lon1, lat2, lon2, f float64) (lat, lon float64) {
+	return lat1 + (lat2-lat1)*f, lon1 + (lon2-lon1)*f
+}
+
+// Project finds the point of the segment from the first to the second point
+// closest to (lat, lon). It returns its position on the segment as a
+// fraction in [0, 1] and its distance to (lat, lon) in meters.
+func Project(lat, lon, lat1, lon1, lat2, lon2 float64) (f, distance float64) {
+	// Equirectangular around the segment, which is short enough to be flat
+	k := math.Cos(lat1 * math.Pi / 180)
+	dx, dy := (lon2-lon1)*k, lat2-lat1
+	px, py := (lon-lon1)*k, lat-lat1
+	if l := dx*dx + dy*dy; l > 0 {
+		f = math.Max(0, math.Min(1, (px*dx+py*dy)/l))
+	}
+	plat, plon := Interpolate(lat1, lon1, lat2, lon2, f)
+	return f, Distance(lat, lon, plat, plon)
+}
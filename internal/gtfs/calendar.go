@@ -0,0 +1,133 @@
+package gtfs
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// processCalendars replaces the service calendars with calendar.txt and
+// calendar_dates.txt. Either file may be missing, but not both.
+func (s *Service) processCalendars(gtfsPath string) error {
+	ctx := context.Background()
+	log.Println("Processing calendar.txt and calendar_dates.txt...")
+
+	calendar, err := readCalendarFile(gtfsPath, "calendar.txt", func(col func(string) string) ([]interface{}, error) {
+		row := []interface{}{col("service_id")}
+		for _, day := range []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"} {
+			runs, _ := strconv.Atoi(col(day))
+			row = append(row, runs)
+		}
+		start, err := time.Parse("20060102", col("start_date"))
+		if err != nil {
+			return nil, err
+		}
+		end, err := time.Parse("20060102", col("end_date"))
+		if err != nil {
+			return nil, err
+		}
+		return append(row, start, end), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	calendarDates, err := readCalendarFile(gtfsPath, "calendar_dates.txt", func(col func(string) string) ([]interface{}, error) {
+		date, err := time.Parse("20060102", col("date"))
+		if err != nil {
+			return nil, err
+		}
+		exceptionType, err := strconv.Atoi(col("exception_type"))
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{col("service_id"), date, exceptionType}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if calendar == nil && calendarDates == nil {
+		return errors.New("feed has neither calendar.txt nor calendar_dates.txt")
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Rollback on error
+
+	if _, err := tx.Exec(ctx, "TRUNCATE calendar, calendar_dates"); err != nil {
+		return err
+	}
+	calendarCols := []string{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"calendar"}, calendarCols, pgx.CopyFromRows(calendar)); err != nil {
+		return fmt.Errorf("failed to copy calendar: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"calendar_dates"}, []string{"service_id", "date", "exception_type"}, pgx.CopyFromRows(calendarDates)); err != nil {
+		return fmt.Errorf("failed to copy calendar_dates: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Finished processing calendars: %d calendar rows, %d calendar dates", len(calendar), len(calendarDates))
+	return nil
+}
+
+// readCalendarFile converts every record of a calendar file into a row with
+// parse, which reads columns by name. A missing file returns nil rows.
+func readCalendarFile(gtfsPath, name string, parse func(col func(string) string) ([]interface{}, error)) ([][]interface{}, error) {
+	file, err := os.Open(filepath.Join(gtfsPath, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s header: %w", name, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[h] = i
+	}
+
+	rows := [][]interface{}{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		col := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		row, err := parse(col)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record on line %d of %s: %w", line, name, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
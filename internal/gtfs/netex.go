@@ -0,0 +1,195 @@
+package gtfs
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// netexQuay is a quay from a NeTEx export.
+type netexQuay struct {
+	ID          string `xml:"id,attr"`
+	Name        string `xml:"Name"`
+	PublicCode  string `xml:"PublicCode"`
+	PrivateCode string `xml:"PrivateCode"`
+}
+
+// netexStopPoint is a scheduled stop point; Dutch exports carry the
+// TimingPointCode (userstopcode) as its private code.
+type netexStopPoint struct {
+	ID          string `xml:"id,attr"`
+	PrivateCode string `xml:"PrivateCode"`
+}
+
+// netexAssignment places a scheduled stop point at a quay.
+type netexAssignment struct {
+	StopPoint netexRef `xml:"ScheduledStopPointRef"`
+	Quay      netexRef `xml:"QuayRef"`
+}
+
+type netexRef struct {
+	Ref string `xml:"ref,attr"`
+}
+
+// netexQuays collects the quays, stop points and assignments of NeTEx files.
+type netexQuays struct {
+	quays       map[string]netexQuay // By ID
+	stopPoints  map[string]string    // TimingPointCode by stop point ID
+	assignments map[string]string    // Quay ID by stop point ID
+}
+
+// processNeTEx sets the quay names of stops, and the platform codes GTFS
+// has none for, from the NeTEx files at path: an .xml or .xml.gz file, or a
+// directory of them. Quays are matched to stops by TimingPointCode, through
+// the stop points assigned to them or else their own private code.
+func (s *Service) processNeTEx(path string) error {
+	log.Printf("Processing NeTEx quays from %s...", path)
+
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return err
+	} else if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if !e.IsDir() && (strings.HasSuffix(e.Name(), ".xml") || strings.HasSuffix(e.Name(), ".xml.gz")) {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	n := &netexQuays{
+		quays:       make(map[string]netexQuay),
+		stopPoints:  make(map[string]string),
+		assignments: make(map[string]string),
+	}
+	for _, file := range files {
+		if err := n.read(file); err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+
+	var codes, names, platforms []string
+	for code, quay := range n.byTimingPoint() {
+		codes = append(codes, code)
+		names = append(names, quay.Name)
+		platforms = append(platforms, quay.PublicCode)
+	}
+
+	ctx := context.Background()
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Rollback on error
+
+	if _, err := tx.Exec(ctx, "UPDATE stops SET quay_name = NULL WHERE quay_name IS NOT NULL"); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE stops s SET
+			quay_name = NULLIF(q.name, ''),
+			platform_code = COALESCE(NULLIF(s.platform_code, ''), NULLIF(q.platform_code, ''))
+		FROM unnest($1::text[], $2::text[], $3::text[]) AS q(timing_point_code, name, platform_code)
+		WHERE s.stop_code = q.timing_point_code AND COALESCE(s.location_type, 0) = 0`,
+		codes, names, platforms)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Matched %d stops to NeTEx quays", tag.RowsAffected())
+	return nil
+}
+
+// read streams a NeTEx file, decoding only the elements it needs.
+func (n *netexQuays) read(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "Quay":
+			var q netexQuay
+			if err := decoder.DecodeElement(&q, &start); err != nil {
+				return err
+			}
+			n.quays[q.ID] = q
+		case "ScheduledStopPoint":
+			var p netexStopPoint
+			if err := decoder.DecodeElement(&p, &start); err != nil {
+				return err
+			}
+			if p.PrivateCode != "" {
+				n.stopPoints[p.ID] = p.PrivateCode
+			}
+		case "PassengerStopAssignment":
+			var a netexAssignment
+			if err := decoder.DecodeElement(&a, &start); err != nil {
+				return err
+			}
+			if a.StopPoint.Ref != "" && a.Quay.Ref != "" {
+				n.assignments[a.StopPoint.Ref] = a.Quay.Ref
+			}
+		}
+	}
+}
+
+// byTimingPoint returns the quays by the TimingPointCodes of the stop
+// points assigned to them, or else by their private code.
+func (n *netexQuays) byTimingPoint() map[string]netexQuay {
+	quays := make(map[string]netexQuay)
+	for _, q := range n.quays {
+		if q.PrivateCode != "" {
+			quays[q.PrivateCode] = q
+		}
+	}
+	for stopPoint, quayID := range n.assignments {
+		code, ok := n.stopPoints[stopPoint]
+		if !ok {
+			continue
+		}
+		if q, ok := n.quays[quayID]; ok {
+			quays[code] = q
+		}
+	}
+	return quays
+}
@@ -21,12 +21,14 @@ const gtfsURL = "http://gtfs.ovapi.nl/gtfs-nl.zip"
 
 // Service handles the GTFS data processing.
 type Service struct {
-	pool *pgxpool.Pool
+	pool      *pgxpool.Pool
+	netexPath string // NeTEx export with quay names; empty skips it
 }
 
-// NewService creates a new GTFS service.
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+// NewService creates a new GTFS service. netexPath optionally points at a
+// NeTEx file or directory to take quay names from.
+func NewService(pool *pgxpool.Pool, netexPath string) *Service {
+	return &Service{pool: pool, netexPath: netexPath}
 }
 
 // IngestGTFSData downloads and processes GTFS data.
@@ -100,6 +102,17 @@ func (s *Service) processGTFS(gtfsPath string) error {
 		return fmt.Errorf("failed to process stops: %w", err)
 	}
 
+	if err := s.refreshTimingPoints(); err != nil {
+		return fmt.Errorf("failed to refresh timing points: %w", err)
+	}
+
+	// Quay names are extra, so the timetable is loaded without them
+	if s.netexPath != "" {
+		if err := s.processNeTEx(s.netexPath); err != nil {
+			log.Printf("WARN: Failed to process NeTEx quays, keeping stops without quay names: %v", err)
+		}
+	}
+
 	if err := s.processRoutes(gtfsPath); err != nil {
 		return fmt.Errorf("failed to process routes: %w", err)
 	}
@@ -112,6 +125,14 @@ func (s *Service) processGTFS(gtfsPath string) error {
 		return fmt.Errorf("failed to process stop_times: %w", err)
 	}
 
+	if err := s.processCalendars(gtfsPath); err != nil {
+		return fmt.Errorf("failed to process calendars: %w", err)
+	}
+
+	if err := s.processShapes(gtfsPath); err != nil {
+		return fmt.Errorf("failed to process shapes: %w", err)
+	}
+
 	log.Println("Successfully processed GTFS data")
 	return nil
 }
@@ -140,7 +161,7 @@ func (s *Service) processStops(gtfsPath string) error {
 
 	batchSize := 1000
 	valueStrings := make([]string, 0, batchSize)
-	valueArgs := make([]interface{}, 0, batchSize*12)
+	valueArgs := make([]interface{}, 0, batchSize*13)
 	i := 0
 
 	for {
@@ -153,7 +174,7 @@ func (s *Service) processStops(gtfsPath string) error {
 		}
 
 		i++
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*12-11, i*12-10, i*12-9, i*12-8, i*12-7, i*12-6, i*12-5, i*12-4, i*12-3, i*12-2, i*12-1, i*12))
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*13-12, i*13-11, i*13-10, i*13-9, i*13-8, i*13-7, i*13-6, i*13-5, i*13-4, i*13-3, i*13-2, i*13-1, i*13))
 
 		stopData := make(map[string]string)
 		for i, value := range record {
@@ -165,23 +186,23 @@ func (s *Service) processStops(gtfsPath string) error {
 		locationType, _ := strconv.Atoi(stopData["location_type"])
 		wheelchairBoarding, _ := strconv.Atoi(stopData["wheelchair_boarding"])
 
-		valueArgs = append(valueArgs, stopData["stop_id"], stopData["stop_code"], stopData["stop_name"], stopData["stop_desc"], lat, lon, stopData["zone_id"], stopData["stop_url"], locationType, stopData["parent_station"], stopData["stop_timezone"], wheelchairBoarding)
+		valueArgs = append(valueArgs, stopData["stop_id"], stopData["stop_code"], stopData["stop_name"], stopData["stop_desc"], lat, lon, stopData["zone_id"], stopData["stop_url"], locationType, stopData["parent_station"], stopData["stop_timezone"], wheelchairBoarding, stopData["platform_code"])
 
 		if len(valueStrings) == batchSize {
-			stmt := fmt.Sprintf("INSERT INTO stops (stop_id, stop_code, stop_name, stop_desc, stop_lat, stop_lon, zone_id, stop_url, location_type, parent_station, stop_timezone, wheelchair_boarding) VALUES %s ON CONFLICT (stop_id) DO UPDATE SET stop_code = EXCLUDED.stop_code, stop_name = EXCLUDED.stop_name, stop_desc = EXCLUDED.stop_desc, stop_lat = EXCLUDED.stop_lat, stop_lon = EXCLUDED.stop_lon, zone_id = EXCLUDED.zone_id, stop_url = EXCLUDED.stop_url, location_type = EXCLUDED.location_type, parent_station = EXCLUDED.parent_station, stop_timezone = EXCLUDED.stop_timezone, wheelchair_boarding = EXCLUDED.wheelchair_boarding",
+			stmt := fmt.Sprintf("INSERT INTO stops (stop_id, stop_code, stop_name, stop_desc, stop_lat, stop_lon, zone_id, stop_url, location_type, parent_station, stop_timezone, wheelchair_boarding, platform_code) VALUES %s ON CONFLICT (stop_id) DO UPDATE SET stop_code = EXCLUDED.stop_code, stop_name = EXCLUDED.stop_name, stop_desc = EXCLUDED.stop_desc, stop_lat = EXCLUDED.stop_lat, stop_lon = EXCLUDED.stop_lon, zone_id = EXCLUDED.zone_id, stop_url = EXCLUDED.stop_url, location_type = EXCLUDED.location_type, parent_station = EXCLUDED.parent_station, stop_timezone = EXCLUDED.stop_timezone, wheelchair_boarding = EXCLUDED.wheelchair_boarding, platform_code = EXCLUDED.platform_code",
 				strings.Join(valueStrings, ","))
 			_, err = tx.Exec(context.Background(), stmt, valueArgs...)
 			if err != nil {
 				return err
 			}
 			valueStrings = make([]string, 0, batchSize)
-			valueArgs = make([]interface{}, 0, batchSize*12)
+			valueArgs = make([]interface{}, 0, batchSize*13)
 			i = 0
 		}
 	}
 
 	if len(valueStrings) > 0 {
-		stmt := fmt.Sprintf("INSERT INTO stops (stop_id, stop_code, stop_name, stop_desc, stop_lat, stop_lon, zone_id, stop_url, location_type, parent_station, stop_timezone, wheelchair_boarding) VALUES %s ON CONFLICT (stop_id) DO UPDATE SET stop_code = EXCLUDED.stop_code, stop_name = EXCLUDED.stop_name, stop_desc = EXCLUDED.stop_desc, stop_lat = EXCLUDED.stop_lat, stop_lon = EXCLUDED.stop_lon, zone_id = EXCLUDED.zone_id, stop_url = EXCLUDED.stop_url, location_type = EXCLUDED.location_type, parent_station = EXCLUDED.parent_station, stop_timezone = EXCLUDED.stop_timezone, wheelchair_boarding = EXCLUDED.wheelchair_boarding",
+		stmt := fmt.Sprintf("INSERT INTO stops (stop_id, stop_code, stop_name, stop_desc, stop_lat, stop_lon, zone_id, stop_url, location_type, parent_station, stop_timezone, wheelchair_boarding, platform_code) VALUES %s ON CONFLICT (stop_id) DO UPDATE SET stop_code = EXCLUDED.stop_code, stop_name = EXCLUDED.stop_name, stop_desc = EXCLUDED.stop_desc, stop_lat = EXCLUDED.stop_lat, stop_lon = EXCLUDED.stop_lon, zone_id = EXCLUDED.zone_id, stop_url = EXCLUDED.stop_url, location_type = EXCLUDED.location_type, parent_station = EXCLUDED.parent_station, stop_timezone = EXCLUDED.stop_timezone, wheelchair_boarding = EXCLUDED.wheelchair_boarding, platform_code = EXCLUDED.platform_code",
 			strings.Join(valueStrings, ","))
 		_, err = tx.Exec(context.Background(), stmt, valueArgs...)
 		if err != nil {
@@ -192,6 +213,42 @@ func (s *Service) processStops(gtfsPath string) error {
 	return tx.Commit(context.Background())
 }
 
+// refreshTimingPoints rebuilds the stop_id -> TimingPointCode mapping from the
+// stop codes and stop area groupings just loaded into stops.
+func (s *Service) refreshTimingPoints() error {
+	log.Println("Refreshing stop timing points...")
+	ctx := context.Background()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Rollback on error
+
+	if _, err := tx.Exec(ctx, "DELETE FROM stop_timing_points"); err != nil {
+		return err
+	}
+
+	// Quays carry their TPC as stop_code; stations find the TPCs of their
+	// quays through parent_station.
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO stop_timing_points (stop_id, timing_point_code)
+		SELECT s.stop_id, s.stop_code
+		FROM stops s
+		WHERE COALESCE(s.stop_code, '') <> '' AND COALESCE(s.location_type, 0) = 0
+		ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Mapped %d stops to timing points", tag.RowsAffected())
+	return nil
+}
+
 func (s *Service) processRoutes(gtfsPath string) error {
 	log.Println("Processing routes.txt...")
 	routesFile, err := os.Open(filepath.Join(gtfsPath, "routes.txt"))
@@ -290,7 +347,7 @@ func (s *Service) processTrips(gtfsPath string) error {
 
 	batchSize := 1000
 	valueStrings := make([]string, 0, batchSize)
-	valueArgs := make([]interface{}, 0, batchSize*10)
+	valueArgs := make([]interface{}, 0, batchSize*11)
 	i := 0
 
 	for {
@@ -303,7 +360,7 @@ func (s *Service) processTrips(gtfsPath string) error {
 		}
 
 		i++
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*10-9, i*10-8, i*10-7, i*10-6, i*10-5, i*10-4, i*10-3, i*10-2, i*10-1, i*10))
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*11-10, i*11-9, i*11-8, i*11-7, i*11-6, i*11-5, i*11-4, i*11-3, i*11-2, i*11-1, i*11))
 
 		tripData := make(map[string]string)
 		for i, value := range record {
@@ -314,11 +371,17 @@ func (s *Service) processTrips(gtfsPath string) error {
 		wheelchairAccessible, _ := strconv.Atoi(tripData["wheelchair_accessible"])
 		bikesAllowed, _ := strconv.Atoi(tripData["bikes_allowed"])
 
-		valueArgs = append(valueArgs, tripData["route_id"], tripData["service_id"], tripData["trip_id"], tripData["trip_headsign"], tripData["trip_short_name"], directionID, tripData["block_id"], tripData["shape_id"], wheelchairAccessible, bikesAllowed)
+		// Trips without a realtime journey are stored as NULL
+		var realtimeTripID interface{}
+		if tripData["realtime_trip_id"] != "" {
+			realtimeTripID = tripData["realtime_trip_id"]
+		}
+
+		valueArgs = append(valueArgs, tripData["route_id"], tripData["service_id"], tripData["trip_id"], tripData["trip_headsign"], tripData["trip_short_name"], directionID, tripData["block_id"], tripData["shape_id"], wheelchairAccessible, bikesAllowed, realtimeTripID)
 
 		if len(valueStrings) == batchSize {
 			stmt := fmt.Sprintf(`
-				INSERT INTO trips (route_id, service_id, id, trip_headsign, trip_short_name, direction_id, block_id, shape_id, wheelchair_accessible, bikes_allowed)
+				INSERT INTO trips (route_id, service_id, id, trip_headsign, trip_short_name, direction_id, block_id, shape_id, wheelchair_accessible, bikes_allowed, realtime_trip_id)
 				VALUES %s
 				ON CONFLICT (id) DO UPDATE SET
 					route_id = EXCLUDED.route_id,
@@ -329,21 +392,22 @@ func (s *Service) processTrips(gtfsPath string) error {
 					block_id = EXCLUDED.block_id,
 					shape_id = EXCLUDED.shape_id,
 					wheelchair_accessible = EXCLUDED.wheelchair_accessible,
-					bikes_allowed = EXCLUDED.bikes_allowed
+					bikes_allowed = EXCLUDED.bikes_allowed,
+					realtime_trip_id = EXCLUDED.realtime_trip_id
 			`, strings.Join(valueStrings, ","))
 			_, err = tx.Exec(context.Background(), stmt, valueArgs...)
 			if err != nil {
 				return err
 			}
 			valueStrings = make([]string, 0, batchSize)
-			valueArgs = make([]interface{}, 0, batchSize*10)
+			valueArgs = make([]interface{}, 0, batchSize*11)
 			i = 0
 		}
 	}
 
 	if len(valueStrings) > 0 {
 		stmt := fmt.Sprintf(`
-			INSERT INTO trips (route_id, service_id, id, trip_headsign, trip_short_name, direction_id, block_id, shape_id, wheelchair_accessible, bikes_allowed)
+			INSERT INTO trips (route_id, service_id, id, trip_headsign, trip_short_name, direction_id, block_id, shape_id, wheelchair_accessible, bikes_allowed, realtime_trip_id)
 			VALUES %s
 			ON CONFLICT (id) DO UPDATE SET
 				route_id = EXCLUDED.route_id,
@@ -354,7 +418,8 @@ func (s *Service) processTrips(gtfsPath string) error {
 				block_id = EXCLUDED.block_id,
 				shape_id = EXCLUDED.shape_id,
 				wheelchair_accessible = EXCLUDED.wheelchair_accessible,
-				bikes_allowed = EXCLUDED.bikes_allowed
+				bikes_allowed = EXCLUDED.bikes_allowed,
+				realtime_trip_id = EXCLUDED.realtime_trip_id
 		`, strings.Join(valueStrings, ","))
 		_, err = tx.Exec(context.Background(), stmt, valueArgs...)
 		if err != nil {
@@ -370,8 +435,8 @@ func (s *Service) processTrips(gtfsPath string) error {
 	return nil
 }
 
-// parseSeconds converts HH:MM:SS (allowing >24h) into seconds since midnight. Missing or malformed returns -1.
-func parseSeconds(t string) int {
+// ParseSeconds converts HH:MM:SS (allowing >24h) into seconds since midnight. Missing or malformed returns -1.
+func ParseSeconds(t string) int {
     if t == "" {
         return -1
     }
@@ -453,7 +518,7 @@ func (s *Service) processStopTimes(gtfsPath string) error {
 		dist, _ := strconv.ParseFloat(rec[iDist], 64)
 		tp, _ := strconv.Atoi(rec[iTP])
 
-		row := []interface{}{rec[iTrip], parseSeconds(rec[iArr]), parseSeconds(rec[iDep]), rec[iStop], seq, rec[iHead], pick, drop, dist, tp}
+		row := []interface{}{rec[iTrip], ParseSeconds(rec[iArr]), ParseSeconds(rec[iDep]), rec[iStop], seq, rec[iHead], pick, drop, dist, tp}
 		buffer = append(buffer, row)
 
 		if len(buffer) == cap(buffer) {
@@ -617,8 +682,8 @@ func (s *Service) processStopTimesFast(gtfsPath string) error {
 
 		buffer = append(buffer, []interface{}{
 			rec[iTrip],
-			parseSeconds(rec[iArr]),
-			parseSeconds(rec[iDep]),
+			ParseSeconds(rec[iArr]),
+			ParseSeconds(rec[iDep]),
 			rec[iStop],
 			seq,
 			rec[iHead],
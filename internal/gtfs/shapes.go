@@ -0,0 +1,104 @@
+package gtfs
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// processShapes replaces the shapes with shapes.txt. The file runs into
+// millions of points, so it is streamed into the table instead of read into
+// memory first. Without shapes.txt the table is left empty.
+func (s *Service) processShapes(gtfsPath string) error {
+	ctx := context.Background()
+	log.Println("Processing shapes.txt...")
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // Rollback on error
+
+	if _, err := tx.Exec(ctx, "TRUNCATE shapes"); err != nil {
+		return err
+	}
+
+	file, err := os.Open(filepath.Join(gtfsPath, "shapes.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		log.Println("WARN: Feed has no shapes.txt, vehicle positions will be projected between stops")
+		return tx.Commit(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open shapes.txt: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read shapes.txt header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[h] = i
+	}
+
+	line := 1
+	source := pgx.CopyFromFunc(func() ([]any, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read shapes.txt: %w", err)
+		}
+		line++
+		col := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		sequence, err := strconv.Atoi(col("shape_pt_sequence"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid shape_pt_sequence on line %d of shapes.txt: %w", line, err)
+		}
+		lat, err := strconv.ParseFloat(col("shape_pt_lat"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shape_pt_lat on line %d of shapes.txt: %w", line, err)
+		}
+		lon, err := strconv.ParseFloat(col("shape_pt_lon"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shape_pt_lon on line %d of shapes.txt: %w", line, err)
+		}
+		var dist *float64
+		if d, err := strconv.ParseFloat(col("shape_dist_traveled"), 64); err == nil {
+			dist = &d
+		}
+		return []any{col("shape_id"), sequence, lat, lon, dist}, nil
+	})
+
+	cols := []string{"shape_id", "shape_pt_sequence", "shape_pt_lat", "shape_pt_lon", "shape_dist_traveled"}
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{"shapes"}, cols, source)
+	if err != nil {
+		return fmt.Errorf("failed to copy shapes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Finished processing shapes.txt: %d points", n)
+	return nil
+}
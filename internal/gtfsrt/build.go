@@ -0,0 +1,211 @@
+package gtfsrt
+
+import (
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// Version is the GTFS-Realtime version of the feeds this package builds.
+const Version = "2.0"
+
+// newFeed returns an empty full dataset feed generated at now.
+func newFeed(now time.Time) *FeedMessage {
+	return &FeedMessage{
+		Header: FeedHeader{
+			Version:        Version,
+			Incrementality: FullDataset,
+			Timestamp:      uint64(now.Unix()),
+		},
+		Entities: []FeedEntity{},
+	}
+}
+
+// enumValue returns the index of name in names, or fallback when it is
+// missing.
+func enumValue(names []string, name string, fallback int) int {
+	for i, n := range names {
+		if n != "" && n == name {
+			return i
+		}
+	}
+	return fallback
+}
+
+func posix(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix())
+}
+
+// TripUpdatesFeed builds a feed with one TripUpdate entity per trip, keyed
+// by trip ID and service day.
+func TripUpdatesFeed(updates []models.TripUpdate, now time.Time) *FeedMessage {
+	feed := newFeed(now)
+	for _, u := range updates {
+		tu := &TripUpdate{
+			Trip: TripDescriptor{
+				TripID:               u.TripID,
+				RouteID:              u.RouteID,
+				StartDate:            u.StartDate,
+				ScheduleRelationship: tripRelationships[u.Relationship],
+			},
+			Timestamp:       posix(u.Timestamp),
+			StopTimeUpdates: make([]StopTimeUpdate, 0, len(u.StopTimeUpdates)),
+		}
+		if u.VehicleID != "" {
+			tu.Vehicle = &VehicleDescriptor{ID: u.VehicleID}
+		}
+		if u.Delay != nil {
+			delay := int32(*u.Delay)
+			tu.Delay = &delay
+		}
+		for _, s := range u.StopTimeUpdates {
+			stu := StopTimeUpdate{
+				StopID:               s.StopID,
+				ScheduleRelationship: stopRelationships[s.Relationship],
+				AssignedStopID:       s.AssignedStopID,
+			}
+			if s.StopSequence != nil {
+				sequence := uint32(*s.StopSequence)
+				stu.StopSequence = &sequence
+			}
+			if stu.ScheduleRelationship == StopScheduled {
+				stu.Arrival = stopTimeEvent(s.ArrivalDelay, s.ArrivalTime)
+				stu.Departure = stopTimeEvent(s.DepartureDelay, s.DepartureTime)
+				if s.Occupancy != nil {
+					if status := enumValue(OccupancyStatus, *s.Occupancy, -1); status >= 0 {
+						stu.DepartureOccupancyStatus = &status
+					}
+				}
+			}
+			tu.StopTimeUpdates = append(tu.StopTimeUpdates, stu)
+		}
+
+		id := u.TripID
+		if u.StartDate != "" {
+			id += ":" + u.StartDate
+		}
+		feed.Entities = append(feed.Entities, FeedEntity{ID: id, TripUpdate: tu})
+	}
+	return feed
+}
+
+var tripRelationships = map[string]int{
+	models.TripScheduled:   TripScheduled,
+	models.TripAdded:       TripAdded,
+	models.TripUnscheduled: TripUnscheduled,
+	models.TripCanceled:    TripCanceled,
+}
+
+var stopRelationships = map[string]int{
+	models.StopScheduled: StopScheduled,
+	models.StopSkipped:   StopSkipped,
+	models.StopNoData:    StopNoData,
+}
+
+func stopTimeEvent(delay *int, at *time.Time) *StopTimeEvent {
+	if delay == nil && at == nil {
+		return nil
+	}
+	var e StopTimeEvent
+	if delay != nil {
+		d := int32(*delay)
+		e.Delay = &d
+	}
+	if at != nil {
+		t := at.Unix()
+		e.Time = &t
+	}
+	return &e
+}
+
+// VehiclePositionsFeed builds a feed with one VehiclePosition entity per
+// vehicle, keyed by vehicle ID.
+func VehiclePositionsFeed(vehicles []models.Vehicle, now time.Time) *FeedMessage {
+	feed := newFeed(now)
+	for _, v := range vehicles {
+		vp := &VehiclePosition{
+			Vehicle:       &VehicleDescriptor{ID: v.ID},
+			Position:      &Position{Latitude: float32(v.Lat), Longitude: float32(v.Lon)},
+			CurrentStatus: vehicleStatuses[v.Status],
+			Timestamp:     posix(v.Timestamp),
+		}
+		if v.TripID != "" || v.RouteID != "" {
+			vp.Trip = &TripDescriptor{TripID: v.TripID, RouteID: v.RouteID}
+		}
+		if v.Bearing != nil {
+			bearing := float32(*v.Bearing)
+			vp.Position.Bearing = &bearing
+		}
+		if v.Speed != nil {
+			speed := float32(*v.Speed / 3.6)
+			vp.Position.Speed = &speed
+		}
+		if v.StopID != nil {
+			vp.StopID = *v.StopID
+		}
+		if v.Occupancy != nil {
+			if status := enumValue(OccupancyStatus, *v.Occupancy, -1); status >= 0 {
+				vp.OccupancyStatus = &status
+			}
+		}
+
+		feed.Entities = append(feed.Entities, FeedEntity{ID: v.ID, Vehicle: vp})
+	}
+	return feed
+}
+
+// vehicleStatuses maps vehicle statuses onto VehicleStopStatus. Off route
+// vehicles are reported as in transit.
+var vehicleStatuses = map[string]int{
+	models.VehicleInTransit:  InTransitTo,
+	models.VehicleStoppedAt:  StoppedAt,
+	models.VehicleIncomingAt: IncomingAt,
+	models.VehicleOffRoute:   InTransitTo,
+}
+
+// AlertsFeed builds a feed with one Alert entity per alert. Texts are
+// published untagged, as they come in a single language.
+func AlertsFeed(alerts []models.Alert, now time.Time) *FeedMessage {
+	feed := newFeed(now)
+	for _, a := range alerts {
+		alert := &Alert{
+			Cause:            enumValue(Causes, a.Cause, 1),
+			Effect:           enumValue(Effects, a.Effect, 8),
+			SeverityLevel:    enumValue(SeverityLevels, a.Severity, 1),
+			HeaderText:       translated(a.Header),
+			DescriptionText:  translated(a.Description),
+			URL:              translated(a.URL),
+			InformedEntities: make([]EntitySelector, 0, len(a.Informed)),
+		}
+		for _, p := range a.ActivePeriods {
+			var r TimeRange
+			if p.Start != nil {
+				r.Start = posix(*p.Start)
+			}
+			if p.End != nil {
+				r.End = posix(*p.End)
+			}
+			alert.ActivePeriods = append(alert.ActivePeriods, r)
+		}
+		for _, e := range a.Informed {
+			selector := EntitySelector{AgencyID: e.AgencyID, RouteID: e.RouteID, StopID: e.StopID}
+			if e.TripID != "" {
+				selector.Trip = &TripDescriptor{TripID: e.TripID}
+			}
+			alert.InformedEntities = append(alert.InformedEntities, selector)
+		}
+
+		feed.Entities = append(feed.Entities, FeedEntity{ID: a.ID, Alert: alert})
+	}
+	return feed
+}
+
+func translated(text string) TranslatedString {
+	if text == "" {
+		return TranslatedString{}
+	}
+	return TranslatedString{Translations: []Translation{{Text: text}}}
+}
@@ -0,0 +1,36 @@
+package gtfsrt
+
+import (
+	"context"
+	"time"
+
+	"arrivo-transit-api/internal/upstream"
+)
+
+// maxFeedSize bounds the size of a downloaded feed. The full NL trip updates
+// feed is a few tens of megabytes.
+const maxFeedSize = 256 << 20
+
+// Client fetches GTFS-Realtime feeds over HTTP.
+type Client struct {
+	upstream *upstream.Client
+}
+
+// NewClient creates a new GTFS-Realtime client.
+func NewClient() *Client {
+	cfg := upstream.DefaultConfig("GTFS-RT", "")
+	cfg.Accept = "application/x-protobuf"
+	cfg.Timeout = 30 * time.Second
+	cfg.MaxBodySize = maxFeedSize
+	cfg.BreakerTimeout = 30 * time.Second
+	return &Client{upstream: upstream.New(cfg)}
+}
+
+// Fetch downloads and decodes the feed at url.
+func (c *Client) Fetch(ctx context.Context, url string) (*FeedMessage, error) {
+	data, err := c.upstream.Get(ctx, url, url)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
@@ -0,0 +1,392 @@
+package gtfsrt
+
+import "fmt"
+
+// Field numbers below follow gtfs-realtime.proto.
+
+// Decode parses a serialized FeedMessage.
+func Decode(data []byte) (*FeedMessage, error) {
+	var feed FeedMessage
+	err := fields(&reader{buf: data}, func(r *reader, field, wireType int) (bool, error) {
+		switch {
+		case field == 1 && wireType == wireBytes:
+			return true, r.message(feed.Header.decode)
+		case field == 2 && wireType == wireBytes:
+			var entity FeedEntity
+			if err := r.message(entity.decode); err != nil {
+				return true, err
+			}
+			feed.Entities = append(feed.Entities, entity)
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode feed: %w", err)
+	}
+	return &feed, nil
+}
+
+// fields calls fn for every field of the message in r. fn reports whether it
+// consumed the field; fields it did not consume are skipped.
+func fields(r *reader, fn func(r *reader, field, wireType int) (bool, error)) error {
+	for {
+		field, wireType, ok, err := r.next()
+		if err != nil || !ok {
+			return err
+		}
+		consumed, err := fn(r, field, wireType)
+		if err != nil {
+			return err
+		}
+		if !consumed {
+			if err := r.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func readUint32(r *reader) (*uint32, error) {
+	v, err := r.varint()
+	u := uint32(v)
+	return &u, err
+}
+
+func readInt32(r *reader) (*int32, error) {
+	v, err := r.varint()
+	i := int32(v)
+	return &i, err
+}
+
+func readInt64(r *reader) (*int64, error) {
+	v, err := r.varint()
+	i := int64(v)
+	return &i, err
+}
+
+func readEnum(r *reader, dst *int) error {
+	v, err := r.varint()
+	*dst = int(int32(v))
+	return err
+}
+
+func (h *FeedHeader) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			h.Version, err = r.string()
+		case field == 2 && wireType == wireVarint:
+			err = readEnum(r, &h.Incrementality)
+		case field == 3 && wireType == wireVarint:
+			h.Timestamp, err = r.varint()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (e *FeedEntity) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			e.ID, err = r.string()
+		case field == 2 && wireType == wireVarint:
+			var v uint64
+			v, err = r.varint()
+			e.IsDeleted = v != 0
+		case field == 3 && wireType == wireBytes:
+			e.TripUpdate = &TripUpdate{}
+			err = r.message(e.TripUpdate.decode)
+		case field == 4 && wireType == wireBytes:
+			e.Vehicle = &VehiclePosition{CurrentStatus: InTransitTo}
+			err = r.message(e.Vehicle.decode)
+		case field == 5 && wireType == wireBytes:
+			e.Alert = &Alert{Cause: 1, Effect: 8, SeverityLevel: 1}
+			err = r.message(e.Alert.decode)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (t *TripDescriptor) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			t.TripID, err = r.string()
+		case field == 2 && wireType == wireBytes:
+			t.StartTime, err = r.string()
+		case field == 3 && wireType == wireBytes:
+			t.StartDate, err = r.string()
+		case field == 4 && wireType == wireVarint:
+			err = readEnum(r, &t.ScheduleRelationship)
+		case field == 5 && wireType == wireBytes:
+			t.RouteID, err = r.string()
+		case field == 6 && wireType == wireVarint:
+			t.DirectionID, err = readUint32(r)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (v *VehicleDescriptor) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			v.ID, err = r.string()
+		case field == 2 && wireType == wireBytes:
+			v.Label, err = r.string()
+		case field == 3 && wireType == wireBytes:
+			v.LicensePlate, err = r.string()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (t *TripUpdate) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			err = r.message(t.Trip.decode)
+		case field == 2 && wireType == wireBytes:
+			var stu StopTimeUpdate
+			if err = r.message(stu.decode); err == nil {
+				t.StopTimeUpdates = append(t.StopTimeUpdates, stu)
+			}
+		case field == 3 && wireType == wireBytes:
+			t.Vehicle = &VehicleDescriptor{}
+			err = r.message(t.Vehicle.decode)
+		case field == 4 && wireType == wireVarint:
+			t.Timestamp, err = r.varint()
+		case field == 5 && wireType == wireVarint:
+			t.Delay, err = readInt32(r)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (s *StopTimeUpdate) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireVarint:
+			s.StopSequence, err = readUint32(r)
+		case field == 2 && wireType == wireBytes:
+			s.Arrival = &StopTimeEvent{}
+			err = r.message(s.Arrival.decode)
+		case field == 3 && wireType == wireBytes:
+			s.Departure = &StopTimeEvent{}
+			err = r.message(s.Departure.decode)
+		case field == 4 && wireType == wireBytes:
+			s.StopID, err = r.string()
+		case field == 5 && wireType == wireVarint:
+			err = readEnum(r, &s.ScheduleRelationship)
+		case field == 6 && wireType == wireBytes:
+			// stop_time_properties
+			err = r.message(func(r *reader) error {
+				return fields(r, func(r *reader, field, wireType int) (bool, error) {
+					if field != 1 || wireType != wireBytes {
+						return false, nil
+					}
+					var err error
+					s.AssignedStopID, err = r.string()
+					return true, err
+				})
+			})
+		case field == 7 && wireType == wireVarint:
+			var status int
+			err = readEnum(r, &status)
+			s.DepartureOccupancyStatus = &status
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (e *StopTimeEvent) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireVarint:
+			e.Delay, err = readInt32(r)
+		case field == 2 && wireType == wireVarint:
+			e.Time, err = readInt64(r)
+		case field == 3 && wireType == wireVarint:
+			e.Uncertainty, err = readInt32(r)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (v *VehiclePosition) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			v.Trip = &TripDescriptor{}
+			err = r.message(v.Trip.decode)
+		case field == 2 && wireType == wireBytes:
+			v.Position = &Position{}
+			err = r.message(v.Position.decode)
+		case field == 3 && wireType == wireVarint:
+			v.CurrentStopSequence, err = readUint32(r)
+		case field == 4 && wireType == wireVarint:
+			err = readEnum(r, &v.CurrentStatus)
+		case field == 5 && wireType == wireVarint:
+			v.Timestamp, err = r.varint()
+		case field == 7 && wireType == wireBytes:
+			v.StopID, err = r.string()
+		case field == 8 && wireType == wireBytes:
+			v.Vehicle = &VehicleDescriptor{}
+			err = r.message(v.Vehicle.decode)
+		case field == 9 && wireType == wireVarint:
+			var status int
+			err = readEnum(r, &status)
+			v.OccupancyStatus = &status
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (p *Position) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireFixed32:
+			p.Latitude, err = r.float()
+		case field == 2 && wireType == wireFixed32:
+			p.Longitude, err = r.float()
+		case field == 3 && wireType == wireFixed32:
+			var bearing float32
+			bearing, err = r.float()
+			p.Bearing = &bearing
+		case field == 4 && wireType == wireFixed64:
+			var odometer float64
+			odometer, err = r.double()
+			p.Odometer = &odometer
+		case field == 5 && wireType == wireFixed32:
+			var speed float32
+			speed, err = r.float()
+			p.Speed = &speed
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (a *Alert) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			var period TimeRange
+			if err = r.message(period.decode); err == nil {
+				a.ActivePeriods = append(a.ActivePeriods, period)
+			}
+		case field == 5 && wireType == wireBytes:
+			var selector EntitySelector
+			if err = r.message(selector.decode); err == nil {
+				a.InformedEntities = append(a.InformedEntities, selector)
+			}
+		case field == 6 && wireType == wireVarint:
+			err = readEnum(r, &a.Cause)
+		case field == 7 && wireType == wireVarint:
+			err = readEnum(r, &a.Effect)
+		case field == 8 && wireType == wireBytes:
+			err = r.message(a.URL.decode)
+		case field == 10 && wireType == wireBytes:
+			err = r.message(a.HeaderText.decode)
+		case field == 11 && wireType == wireBytes:
+			err = r.message(a.DescriptionText.decode)
+		case field == 14 && wireType == wireVarint:
+			err = readEnum(r, &a.SeverityLevel)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (t *TimeRange) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireVarint:
+			t.Start, err = r.varint()
+		case field == 2 && wireType == wireVarint:
+			t.End, err = r.varint()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (s *EntitySelector) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			s.AgencyID, err = r.string()
+		case field == 2 && wireType == wireBytes:
+			s.RouteID, err = r.string()
+		case field == 3 && wireType == wireVarint:
+			s.RouteType, err = readInt32(r)
+		case field == 4 && wireType == wireBytes:
+			s.Trip = &TripDescriptor{}
+			err = r.message(s.Trip.decode)
+		case field == 5 && wireType == wireBytes:
+			s.StopID, err = r.string()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (t *TranslatedString) decode(r *reader) error {
+	return fields(r, func(r *reader, field, wireType int) (bool, error) {
+		if field != 1 || wireType != wireBytes {
+			return false, nil
+		}
+		var tr Translation
+		err := r.message(func(r *reader) error {
+			return fields(r, func(r *reader, field, wireType int) (bool, error) {
+				var err error
+				switch {
+				case field == 1 && wireType == wireBytes:
+					tr.Text, err = r.string()
+				case field == 2 && wireType == wireBytes:
+					tr.Language, err = r.string()
+				default:
+					return false, nil
+				}
+				return true, err
+			})
+		})
+		if err == nil {
+			t.Translations = append(t.Translations, tr)
+		}
+		return true, err
+	})
+}
@@ -0,0 +1,87 @@
+package gtfsrt
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readFeed(t *testing.T, name string) ([]byte, *FeedMessage) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode(%s): %v", name, err)
+	}
+	return data, feed
+}
+
+func TestDecodeTripUpdates(t *testing.T) {
+	_, feed := readFeed(t, "trip-updates.pb")
+
+	if feed.Header.Version != "2.0" || feed.Header.Timestamp != 1705307100 {
+		t.Errorf("header = %+v", feed.Header)
+	}
+	if len(feed.Entities) != 2 {
+		t.Fatalf("got %d entities, want 2", len(feed.Entities))
+	}
+
+	tu := feed.Entities[0].TripUpdate
+	if tu == nil {
+		t.Fatal("first entity has no trip update")
+	}
+	if tu.Trip.TripID != "161565793" || tu.Trip.RouteID != "72" || tu.Trip.StartDate != "20240115" || tu.Trip.StartTime != "09:12:00" {
+		t.Errorf("trip = %+v", tu.Trip)
+	}
+	if tu.Vehicle == nil || tu.Vehicle.Label != "GVB 2041" || tu.Timestamp != 1705307090 || tu.Delay == nil || *tu.Delay != 60 {
+		t.Errorf("vehicle = %+v, timestamp = %d, delay = %v", tu.Vehicle, tu.Timestamp, tu.Delay)
+	}
+	if len(tu.StopTimeUpdates) != 3 {
+		t.Fatalf("got %d stop time updates, want 3", len(tu.StopTimeUpdates))
+	}
+
+	first := tu.StopTimeUpdates[0]
+	if first.StopID != "2334564" || first.AssignedStopID != "2334565" {
+		t.Errorf("stop = %q, assigned stop = %q; want 2334564 assigned to 2334565", first.StopID, first.AssignedStopID)
+	}
+	if first.DepartureOccupancyStatus == nil || *first.DepartureOccupancyStatus != 2 {
+		t.Errorf("departure occupancy = %v, want 2", first.DepartureOccupancyStatus)
+	}
+	if first.Departure == nil || *first.Departure.Delay != 60 || *first.Departure.Time != 1705307600 {
+		t.Errorf("departure = %+v", first.Departure)
+	}
+	if skipped := tu.StopTimeUpdates[1]; skipped.ScheduleRelationship != StopSkipped || skipped.AssignedStopID != "" {
+		t.Errorf("second stop = %+v, want skipped", skipped)
+	}
+	if early := tu.StopTimeUpdates[2]; early.Arrival == nil || *early.Arrival.Delay != -30 {
+		t.Errorf("third arrival = %+v, want 30s early", early.Arrival)
+	}
+
+	if cancelled := feed.Entities[1].TripUpdate; cancelled == nil || cancelled.Trip.ScheduleRelationship != TripCanceled {
+		t.Errorf("second entity = %+v, want a cancelled trip", feed.Entities[1])
+	}
+}
+
+func TestEncodeReproducesRecordedFeed(t *testing.T) {
+	for _, name := range []string{"trip-updates.pb", "vehicle-positions.pb", "alerts.pb"} {
+		t.Run(name, func(t *testing.T) {
+			data, feed := readFeed(t, name)
+
+			encoded := Encode(feed)
+			if !bytes.Equal(encoded, data) {
+				t.Errorf("Encode differs from the recorded feed:\n got %x\nwant %x", encoded, data)
+			}
+			again, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode(Encode): %v", err)
+			}
+			if !reflect.DeepEqual(again, feed) {
+				t.Errorf("Decode(Encode(feed)) = %+v, want %+v", again, feed)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package gtfsrt
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// writer appends the fields of one protobuf message.
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) key(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field<<3|wireType))
+}
+
+func (w *writer) varint(field int, v uint64) {
+	w.key(field, wireVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+// int32 writes a signed int32 field, sign extended to 64 bits as protobuf
+// requires for negative values.
+func (w *writer) int32(field int, v int32) {
+	w.varint(field, uint64(int64(v)))
+}
+
+func (w *writer) int64(field int, v int64) {
+	w.varint(field, uint64(v))
+}
+
+func (w *writer) string(field int, s string) {
+	w.key(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// optionalString writes s unless it is empty.
+func (w *writer) optionalString(field int, s string) {
+	if s != "" {
+		w.string(field, s)
+	}
+}
+
+func (w *writer) float(field int, v float32) {
+	w.key(field, wireFixed32)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, math.Float32bits(v))
+}
+
+func (w *writer) double(field int, v float64) {
+	w.key(field, wireFixed64)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+// message writes a length-delimited submessage encoded by fn.
+func (w *writer) message(field int, fn func(*writer)) {
+	var sub writer
+	fn(&sub)
+	w.key(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(sub.buf)))
+	w.buf = append(w.buf, sub.buf...)
+}
+
+// Encode serializes a FeedMessage. Decode(Encode(feed)) returns an equal
+// feed.
+func Encode(feed *FeedMessage) []byte {
+	var w writer
+	w.message(1, feed.Header.encode)
+	for i := range feed.Entities {
+		w.message(2, feed.Entities[i].encode)
+	}
+	return w.buf
+}
+
+func (h *FeedHeader) encode(w *writer) {
+	w.string(1, h.Version)
+	w.varint(2, uint64(h.Incrementality))
+	if h.Timestamp != 0 {
+		w.varint(3, h.Timestamp)
+	}
+}
+
+func (e *FeedEntity) encode(w *writer) {
+	w.string(1, e.ID)
+	if e.IsDeleted {
+		w.varint(2, 1)
+	}
+	if e.TripUpdate != nil {
+		w.message(3, e.TripUpdate.encode)
+	}
+	if e.Vehicle != nil {
+		w.message(4, e.Vehicle.encode)
+	}
+	if e.Alert != nil {
+		w.message(5, e.Alert.encode)
+	}
+}
+
+func (t *TripDescriptor) encode(w *writer) {
+	w.optionalString(1, t.TripID)
+	w.optionalString(2, t.StartTime)
+	w.optionalString(3, t.StartDate)
+	if t.ScheduleRelationship != TripScheduled {
+		w.varint(4, uint64(t.ScheduleRelationship))
+	}
+	w.optionalString(5, t.RouteID)
+	if t.DirectionID != nil {
+		w.varint(6, uint64(*t.DirectionID))
+	}
+}
+
+func (v *VehicleDescriptor) encode(w *writer) {
+	w.optionalString(1, v.ID)
+	w.optionalString(2, v.Label)
+	w.optionalString(3, v.LicensePlate)
+}
+
+func (t *TripUpdate) encode(w *writer) {
+	w.message(1, t.Trip.encode)
+	for i := range t.StopTimeUpdates {
+		w.message(2, t.StopTimeUpdates[i].encode)
+	}
+	if t.Vehicle != nil {
+		w.message(3, t.Vehicle.encode)
+	}
+	if t.Timestamp != 0 {
+		w.varint(4, t.Timestamp)
+	}
+	if t.Delay != nil {
+		w.int32(5, *t.Delay)
+	}
+}
+
+func (s *StopTimeUpdate) encode(w *writer) {
+	if s.StopSequence != nil {
+		w.varint(1, uint64(*s.StopSequence))
+	}
+	if s.Arrival != nil {
+		w.message(2, s.Arrival.encode)
+	}
+	if s.Departure != nil {
+		w.message(3, s.Departure.encode)
+	}
+	w.optionalString(4, s.StopID)
+	if s.ScheduleRelationship != StopScheduled {
+		w.varint(5, uint64(s.ScheduleRelationship))
+	}
+	if s.AssignedStopID != "" {
+		w.message(6, func(w *writer) { w.string(1, s.AssignedStopID) })
+	}
+	if s.DepartureOccupancyStatus != nil {
+		w.varint(7, uint64(*s.DepartureOccupancyStatus))
+	}
+}
+
+func (e *StopTimeEvent) encode(w *writer) {
+	if e.Delay != nil {
+		w.int32(1, *e.Delay)
+	}
+	if e.Time != nil {
+		w.int64(2, *e.Time)
+	}
+	if e.Uncertainty != nil {
+		w.int32(3, *e.Uncertainty)
+	}
+}
+
+func (v *VehiclePosition) encode(w *writer) {
+	if v.Trip != nil {
+		w.message(1, v.Trip.encode)
+	}
+	if v.Position != nil {
+		w.message(2, v.Position.encode)
+	}
+	if v.CurrentStopSequence != nil {
+		w.varint(3, uint64(*v.CurrentStopSequence))
+	}
+	w.varint(4, uint64(v.CurrentStatus))
+	if v.Timestamp != 0 {
+		w.varint(5, v.Timestamp)
+	}
+	w.optionalString(7, v.StopID)
+	if v.Vehicle != nil {
+		w.message(8, v.Vehicle.encode)
+	}
+	if v.OccupancyStatus != nil {
+		w.varint(9, uint64(*v.OccupancyStatus))
+	}
+}
+
+func (p *Position) encode(w *writer) {
+	w.float(1, p.Latitude)
+	w.float(2, p.Longitude)
+	if p.Bearing != nil {
+		w.float(3, *p.Bearing)
+	}
+	if p.Odometer != nil {
+		w.double(4, *p.Odometer)
+	}
+	if p.Speed != nil {
+		w.float(5, *p.Speed)
+	}
+}
+
+func (a *Alert) encode(w *writer) {
+	for i := range a.ActivePeriods {
+		w.message(1, a.ActivePeriods[i].encode)
+	}
+	for i := range a.InformedEntities {
+		w.message(5, a.InformedEntities[i].encode)
+	}
+	w.varint(6, uint64(a.Cause))
+	w.varint(7, uint64(a.Effect))
+	if len(a.URL.Translations) > 0 {
+		w.message(8, a.URL.encode)
+	}
+	w.message(10, a.HeaderText.encode)
+	if len(a.DescriptionText.Translations) > 0 {
+		w.message(11, a.DescriptionText.encode)
+	}
+	if a.SeverityLevel != 0 {
+		w.varint(14, uint64(a.SeverityLevel))
+	}
+}
+
+func (t *TimeRange) encode(w *writer) {
+	if t.Start != 0 {
+		w.varint(1, t.Start)
+	}
+	if t.End != 0 {
+		w.varint(2, t.End)
+	}
+}
+
+func (s *EntitySelector) encode(w *writer) {
+	w.optionalString(1, s.AgencyID)
+	w.optionalString(2, s.RouteID)
+	if s.RouteType != nil {
+		w.int32(3, *s.RouteType)
+	}
+	if s.Trip != nil {
+		w.message(4, s.Trip.encode)
+	}
+	w.optionalString(5, s.StopID)
+}
+
+func (t *TranslatedString) encode(w *writer) {
+	for _, tr := range t.Translations {
+		w.message(1, func(w *writer) {
+			w.string(1, tr.Text)
+			w.optionalString(2, tr.Language)
+		})
+	}
+}
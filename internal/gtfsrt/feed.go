@@ -0,0 +1,240 @@
+// Package gtfsrt reads GTFS-Realtime feeds
+// (https://gtfs.org/realtime/reference/). It decodes the protocol buffer
+// wire format directly into the subset of gtfs-realtime.proto the API uses,
+// skipping unknown fields and extensions.
+package gtfsrt
+
+// FeedMessage is the root of a GTFS-Realtime feed.
+type FeedMessage struct {
+	Header   FeedHeader   `json:"header"`
+	Entities []FeedEntity `json:"entity"`
+}
+
+// Incrementality values of FeedHeader.
+const (
+	FullDataset  = 0
+	Differential = 1
+)
+
+// FeedHeader describes the feed.
+type FeedHeader struct {
+	Version        string `json:"gtfs_realtime_version"`
+	Incrementality int    `json:"incrementality"`
+	Timestamp      uint64 `json:"timestamp,omitempty"` // POSIX seconds
+}
+
+// FeedEntity is one update in the feed; exactly one of TripUpdate, Vehicle
+// and Alert is set unless IsDeleted.
+type FeedEntity struct {
+	ID         string           `json:"id"`
+	IsDeleted  bool             `json:"is_deleted,omitempty"`
+	TripUpdate *TripUpdate      `json:"trip_update,omitempty"`
+	Vehicle    *VehiclePosition `json:"vehicle,omitempty"`
+	Alert      *Alert           `json:"alert,omitempty"`
+}
+
+// TripDescriptor.ScheduleRelationship values.
+const (
+	TripScheduled   = 0
+	TripAdded       = 1
+	TripUnscheduled = 2
+	TripCanceled    = 3
+	TripReplacement = 5
+	TripDuplicated  = 6
+	TripDeleted     = 7
+)
+
+// TripDescriptor identifies a trip instance.
+type TripDescriptor struct {
+	TripID               string  `json:"trip_id,omitempty"`
+	RouteID              string  `json:"route_id,omitempty"`
+	DirectionID          *uint32 `json:"direction_id,omitempty"`
+	StartTime            string  `json:"start_time,omitempty"` // HH:MM:SS
+	StartDate            string  `json:"start_date,omitempty"` // YYYYMMDD
+	ScheduleRelationship int     `json:"schedule_relationship"`
+}
+
+// VehicleDescriptor identifies a vehicle.
+type VehicleDescriptor struct {
+	ID           string `json:"id,omitempty"`
+	Label        string `json:"label,omitempty"`
+	LicensePlate string `json:"license_plate,omitempty"`
+}
+
+// TripUpdate gives realtime progress of a trip.
+type TripUpdate struct {
+	Trip            TripDescriptor     `json:"trip"`
+	Vehicle         *VehicleDescriptor `json:"vehicle,omitempty"`
+	StopTimeUpdates []StopTimeUpdate   `json:"stop_time_update"`
+	Timestamp       uint64             `json:"timestamp,omitempty"`
+	Delay           *int32             `json:"delay,omitempty"`
+}
+
+// StopTimeUpdate.ScheduleRelationship values.
+const (
+	StopScheduled   = 0
+	StopSkipped     = 1
+	StopNoData      = 2
+	StopUnscheduled = 3
+)
+
+// StopTimeUpdate is the realtime arrival and departure at one stop.
+type StopTimeUpdate struct {
+	StopSequence         *uint32        `json:"stop_sequence,omitempty"`
+	StopID               string         `json:"stop_id,omitempty"`
+	Arrival              *StopTimeEvent `json:"arrival,omitempty"`
+	Departure            *StopTimeEvent `json:"departure,omitempty"`
+	ScheduleRelationship int            `json:"schedule_relationship"`
+
+	// Experimental: the expected crowding when leaving the stop, an index
+	// of OccupancyStatus
+	DepartureOccupancyStatus *int `json:"departure_occupancy_status,omitempty"`
+	// Experimental: the stop the trip calls at instead of StopID, e.g. on a
+	// platform change (stop_time_properties.assigned_stop_id)
+	AssignedStopID string `json:"assigned_stop_id,omitempty"`
+}
+
+// StopTimeEvent is a predicted or observed time, as a delay against the
+// schedule and/or an absolute time.
+type StopTimeEvent struct {
+	Delay       *int32 `json:"delay,omitempty"`
+	Time        *int64 `json:"time,omitempty"` // POSIX seconds
+	Uncertainty *int32 `json:"uncertainty,omitempty"`
+}
+
+// VehiclePosition.CurrentStatus values.
+const (
+	IncomingAt  = 0
+	StoppedAt   = 1
+	InTransitTo = 2
+)
+
+// VehiclePosition is the realtime position of a vehicle.
+type VehiclePosition struct {
+	Trip                *TripDescriptor    `json:"trip,omitempty"`
+	Vehicle             *VehicleDescriptor `json:"vehicle,omitempty"`
+	Position            *Position          `json:"position,omitempty"`
+	CurrentStopSequence *uint32            `json:"current_stop_sequence,omitempty"`
+	StopID              string             `json:"stop_id,omitempty"`
+	CurrentStatus       int                `json:"current_status"`
+	Timestamp           uint64             `json:"timestamp,omitempty"`
+	OccupancyStatus     *int               `json:"occupancy_status,omitempty"`
+}
+
+// OccupancyStatus names indexed by their enum value.
+var OccupancyStatus = []string{
+	"EMPTY",
+	"MANY_SEATS_AVAILABLE",
+	"FEW_SEATS_AVAILABLE",
+	"STANDING_ROOM_ONLY",
+	"CRUSHED_STANDING_ROOM_ONLY",
+	"FULL",
+	"NOT_ACCEPTING_PASSENGERS",
+}
+
+// Position is a WGS84 position with optional heading and speed.
+type Position struct {
+	Latitude  float32  `json:"latitude"`
+	Longitude float32  `json:"longitude"`
+	Bearing   *float32 `json:"bearing,omitempty"`  // Degrees clockwise from north
+	Odometer  *float64 `json:"odometer,omitempty"` // Meters
+	Speed     *float32 `json:"speed,omitempty"`    // Meters per second
+}
+
+// Alert is a service alert affecting the selected entities.
+type Alert struct {
+	ActivePeriods    []TimeRange      `json:"active_period,omitempty"`
+	InformedEntities []EntitySelector `json:"informed_entity"`
+	Cause            int              `json:"cause"`
+	Effect           int              `json:"effect"`
+	URL              TranslatedString `json:"url"`
+	HeaderText       TranslatedString `json:"header_text"`
+	DescriptionText  TranslatedString `json:"description_text"`
+	SeverityLevel    int              `json:"severity_level"`
+}
+
+// Alert causes indexed by their enum value; 0 is unused.
+var Causes = []string{
+	"", "UNKNOWN_CAUSE", "OTHER_CAUSE", "TECHNICAL_PROBLEM", "STRIKE", "DEMONSTRATION", "ACCIDENT",
+	"HOLIDAY", "WEATHER", "MAINTENANCE", "CONSTRUCTION", "POLICE_ACTIVITY", "MEDICAL_EMERGENCY",
+}
+
+// Alert effects indexed by their enum value; 0 is unused.
+var Effects = []string{
+	"", "NO_SERVICE", "REDUCED_SERVICE", "SIGNIFICANT_DELAYS", "DETOUR", "ADDITIONAL_SERVICE",
+	"MODIFIED_SERVICE", "OTHER_EFFECT", "UNKNOWN_EFFECT", "STOP_MOVED", "NO_EFFECT", "ACCESSIBILITY_ISSUE",
+}
+
+// Alert severity levels indexed by their enum value; 0 is unused.
+var SeverityLevels = []string{"", "UNKNOWN_SEVERITY", "INFO", "WARNING", "SEVERE"}
+
+// TimeRange is an interval in POSIX seconds; 0 leaves a side open.
+type TimeRange struct {
+	Start uint64 `json:"start,omitempty"`
+	End   uint64 `json:"end,omitempty"`
+}
+
+// EntitySelector selects the agencies, routes, trips or stops an alert
+// applies to. Set fields are combined with AND.
+type EntitySelector struct {
+	AgencyID  string          `json:"agency_id,omitempty"`
+	RouteID   string          `json:"route_id,omitempty"`
+	RouteType *int32          `json:"route_type,omitempty"`
+	Trip      *TripDescriptor `json:"trip,omitempty"`
+	StopID    string          `json:"stop_id,omitempty"`
+}
+
+// TranslatedString holds the same text in several languages.
+type TranslatedString struct {
+	Translations []Translation `json:"translation"`
+}
+
+// Translation is a text in one language.
+type Translation struct {
+	Text     string `json:"text"`
+	Language string `json:"language,omitempty"`
+}
+
+// Text returns the translation in language, the untagged translation, or
+// the first one, in that order of preference.
+func (t TranslatedString) Text(language string) string {
+	var untagged string
+	for _, tr := range t.Translations {
+		if tr.Language == language {
+			return tr.Text
+		}
+		if tr.Language == "" && untagged == "" {
+			untagged = tr.Text
+		}
+	}
+	if untagged != "" {
+		return untagged
+	}
+	if len(t.Translations) > 0 {
+		return t.Translations[0].Text
+	}
+	return ""
+}
+
+// enumName returns names[v], or "" when v is out of range.
+func enumName(names []string, v int) string {
+	if v < 0 || v >= len(names) {
+		return ""
+	}
+	return names[v]
+}
+
+// CauseName returns the name of the alert's cause.
+func (a *Alert) CauseName() string {
+	return enumName(Causes, a.Cause)
+}
+
+// EffectName returns the name of the alert's effect.
+func (a *Alert) EffectName() string {
+	return enumName(Effects, a.Effect)
+}
+
+// SeverityName returns the name of the alert's severity level.
+func (a *Alert) SeverityName() string {
+	return enumName(SeverityLevels, a.SeverityLevel)
+}
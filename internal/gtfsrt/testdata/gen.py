@@ -0,0 +1,169 @@
+#!/usr/bin/env python3
+"""Writes the GTFS-Realtime fixtures of this directory.
+
+The feeds follow the field numbers of gtfs-realtime.proto
+(https://gtfs.org/realtime/proto/) and mirror entities of the NL feeds at
+gtfs.ovapi.nl. They are serialized here rather than by the gtfsrt package,
+so its tests catch field numbers the package gets wrong.
+
+    python3 internal/gtfsrt/testdata/gen.py
+"""
+
+import os
+import struct
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+
+
+def varint(v):
+    v &= (1 << 64) - 1  # Negative int32/int64 take ten bytes
+    out = bytearray()
+    while True:
+        b = v & 0x7F
+        v >>= 7
+        if v:
+            out.append(b | 0x80)
+        else:
+            out.append(b)
+            return bytes(out)
+
+
+def key(field, wire_type):
+    return varint(field << 3 | wire_type)
+
+
+def uint(field, v):
+    return key(field, 0) + varint(v)
+
+
+def string(field, s):
+    data = s.encode() if isinstance(s, str) else s
+    return key(field, 2) + varint(len(data)) + data
+
+
+def message(field, *parts):
+    return string(field, b"".join(parts))
+
+
+def float32(field, v):
+    return key(field, 5) + struct.pack("<f", v)
+
+
+def header(timestamp):
+    # gtfs_realtime_version = 1, incrementality = 2 (FULL_DATASET), timestamp = 3
+    return message(1, string(1, "2.0"), uint(2, 0), uint(3, timestamp))
+
+
+def entity(id, *parts):
+    return message(2, string(1, id), *parts)
+
+
+def trip(field, trip_id, start_date, route_id="", start_time="", relationship=None):
+    # trip_id = 1, start_time = 2, start_date = 3, schedule_relationship = 4,
+    # route_id = 5
+    parts = [string(1, trip_id)]
+    if start_time:
+        parts.append(string(2, start_time))
+    parts.append(string(3, start_date))
+    if relationship is not None:
+        parts.append(uint(4, relationship))
+    if route_id:
+        parts.append(string(5, route_id))
+    return message(field, *parts)
+
+
+def event(field, delay, time):
+    # delay = 1, time = 2
+    return message(field, uint(1, delay), uint(2, time))
+
+
+def trip_updates():
+    return header(1705307100) + entity(
+        "2024-01-15:GVB:22:1045",
+        # FeedEntity.trip_update = 3
+        message(
+            3,
+            trip(1, "161565793", "20240115", route_id="72", start_time="09:12:00"),
+            # TripUpdate.stop_time_update = 2: stop_sequence = 1, arrival = 2,
+            # departure = 3, stop_id = 4, schedule_relationship = 5,
+            # stop_time_properties = 6 (assigned_stop_id = 1),
+            # departure_occupancy_status = 7
+            message(
+                2,
+                uint(1, 3),
+                event(2, 60, 1705307580),
+                event(3, 60, 1705307600),
+                string(4, "2334564"),
+                message(6, string(1, "2334565")),
+                uint(7, 2),
+            ),
+            message(2, uint(1, 4), string(4, "2334570"), uint(5, 1)),
+            message(2, uint(1, 5), event(2, -30, 1705307850), string(4, "2334580")),
+            # vehicle = 3 (label = 2), timestamp = 4, delay = 5
+            message(3, string(2, "GVB 2041")),
+            uint(4, 1705307090),
+            uint(5, 60),
+        ),
+    ) + entity(
+        "2024-01-15:GVB:22:1047",
+        message(3, trip(1, "161565800", "20240115", relationship=3)),
+    )
+
+
+def vehicle_positions():
+    return header(1705307100) + entity(
+        "vehicle:GVB:2041",
+        # FeedEntity.vehicle = 4: trip = 1, position = 2,
+        # current_stop_sequence = 3, current_status = 4 (STOPPED_AT),
+        # timestamp = 5, stop_id = 7, vehicle = 8, occupancy_status = 9
+        message(
+            4,
+            trip(1, "161565793", "20240115", route_id="72"),
+            # latitude = 1, longitude = 2, bearing = 3, speed = 5
+            message(2, float32(1, 52.378), float32(2, 4.9), float32(3, 90.0), float32(5, 10.0)),
+            uint(3, 3),
+            uint(4, 1),
+            uint(5, 1705307090),
+            string(7, "2334564"),
+            message(8, string(1, "2041"), string(2, "GVB 2041")),
+            uint(9, 1),
+        ),
+    )
+
+
+def translated(field, *texts):
+    # translation = 1: text = 1, language = 2
+    return message(field, *(message(1, string(1, text), string(2, language)) for text, language in texts))
+
+
+def alerts():
+    return header(1705307100) + entity(
+        "alert:GVB:1234",
+        # FeedEntity.alert = 5: active_period = 1, informed_entity = 5,
+        # cause = 6, effect = 7, url = 8, header_text = 10,
+        # description_text = 11, severity_level = 14
+        message(
+            5,
+            message(1, uint(1, 1705300000)),
+            # agency_id = 1, route_id = 2, stop_id = 5
+            message(5, string(1, "GVB"), string(2, "72")),
+            message(5, string(5, "2334564")),
+            uint(6, 10),
+            uint(7, 4),
+            translated(8, ("https://gvb.nl/omleidingen", "nl")),
+            translated(10, ("Diversion line 22", "en"), ("Omleiding lijn 22", "nl")),
+            translated(11, ("Halte Centraal Station wordt niet bediend", "nl")),
+            uint(14, 3),
+        ),
+    )
+
+
+def write(name, data):
+    with open(os.path.join(HERE, name), "wb") as f:
+        f.write(data)
+
+
+if __name__ == "__main__":
+    write("trip-updates.pb", trip_updates())
+    write("vehicle-positions.pb", vehicle_positions())
+    write("alerts.pb", alerts())
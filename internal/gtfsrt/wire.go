@@ -0,0 +1,119 @@
+package gtfsrt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// reader walks the fields of one protobuf message.
+type reader struct {
+	buf []byte
+}
+
+// next returns the number and wire type of the next field, or ok=false at
+// the end of the message.
+func (r *reader) next() (field int, wireType int, ok bool, err error) {
+	if len(r.buf) == 0 {
+		return 0, 0, false, nil
+	}
+	key, err := r.varint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return int(key >> 3), int(key & 7), true, nil
+}
+
+func (r *reader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *reader) fixed32() (uint32, error) {
+	if len(r.buf) < 4 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v, nil
+}
+
+func (r *reader) fixed64() (uint64, error) {
+	if len(r.buf) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.buf)
+	r.buf = r.buf[8:]
+	return v, nil
+}
+
+func (r *reader) bytes() ([]byte, error) {
+	size, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(len(r.buf)) {
+		return nil, errTruncated
+	}
+	b := r.buf[:size]
+	r.buf = r.buf[size:]
+	return b, nil
+}
+
+func (r *reader) string() (string, error) {
+	b, err := r.bytes()
+	return string(b), err
+}
+
+func (r *reader) float() (float32, error) {
+	v, err := r.fixed32()
+	return math.Float32frombits(v), err
+}
+
+func (r *reader) double() (float64, error) {
+	v, err := r.fixed64()
+	return math.Float64frombits(v), err
+}
+
+// skip discards a field of the given wire type, for fields and extensions
+// this package does not know.
+func (r *reader) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case wireVarint:
+		_, err = r.varint()
+	case wireFixed64:
+		_, err = r.fixed64()
+	case wireBytes:
+		_, err = r.bytes()
+	case wireFixed32:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	return err
+}
+
+// message decodes a length-delimited submessage with fn.
+func (r *reader) message(fn func(*reader) error) error {
+	b, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	return fn(&reader{buf: b})
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminHandler serves the endpoints used to maintain reference data.
+type AdminHandler struct {
+	transitService services.StopAliases
+	token          string
+}
+
+// NewAdminHandler creates a new admin handler. Requests must carry token as a
+// bearer token.
+func NewAdminHandler(transitService services.StopAliases, token string) *AdminHandler {
+	return &AdminHandler{
+		transitService: transitService,
+		token:          token,
+	}
+}
+
+// RequireToken rejects requests without the admin bearer token.
+func (h *AdminHandler) RequireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListStopAliases handles listing all stop aliases
+func (h *AdminHandler) ListStopAliases(w http.ResponseWriter, r *http.Request) {
+	aliases, err := h.transitService.ListStopAliases(r.Context())
+	if err != nil {
+		log.Printf("ERROR: Failed to list stop aliases: %v", err)
+		http.Error(w, "Failed to list stop aliases", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(aliases)
+}
+
+// CreateStopAlias handles adding a stop alias
+func (h *AdminHandler) CreateStopAlias(w http.ResponseWriter, r *http.Request) {
+	var alias models.StopAlias
+	if err := json.NewDecoder(r.Body).Decode(&alias); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.transitService.CreateStopAlias(r.Context(), alias)
+	if errors.Is(err, services.ErrInvalid) {
+		http.Error(w, "alias, expansion and a valid kind are required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to create stop alias '%s': %v", alias.Alias, err)
+		http.Error(w, "Failed to create stop alias", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// UpdateStopAlias handles replacing a stop alias
+func (h *AdminHandler) UpdateStopAlias(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "aliasID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid aliasID", http.StatusBadRequest)
+		return
+	}
+
+	var alias models.StopAlias
+	if err := json.NewDecoder(r.Body).Decode(&alias); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.transitService.UpdateStopAlias(r.Context(), id, alias)
+	switch {
+	case errors.Is(err, services.ErrInvalid):
+		http.Error(w, "alias, expansion and a valid kind are required", http.StatusBadRequest)
+		return
+	case errors.Is(err, services.ErrNotFound):
+		http.Error(w, "stop alias not found", http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrConflict):
+		http.Error(w, "another stop alias normalizes to the same text", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("ERROR: Failed to update stop alias %d: %v", id, err)
+		http.Error(w, "Failed to update stop alias", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteStopAlias handles removing a stop alias
+func (h *AdminHandler) DeleteStopAlias(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "aliasID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid aliasID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.transitService.DeleteStopAlias(r.Context(), id)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "stop alias not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to delete stop alias %d: %v", id, err)
+		http.Error(w, "Failed to delete stop alias", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
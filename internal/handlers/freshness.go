@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// setFreshnessHeaders reports the freshness of the realtime data in a
+// response, so clients can show a "data may be stale" banner without
+// parsing the body.
+func setFreshnessHeaders(w http.ResponseWriter, f *models.Freshness) {
+	if f == nil || f.LastUpdated.IsZero() {
+		return
+	}
+	w.Header().Set("Last-Modified", f.LastUpdated.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Data-Age", strconv.Itoa(f.Age))
+	w.Header().Set("X-Data-Stale", strconv.FormatBool(f.Stale))
+}
+
+// departuresFreshness returns the freshness of the oldest realtime data
+// among departures, or nil when none came from a realtime source.
+func departuresFreshness(departures []models.Departure) *models.Freshness {
+	var oldest *models.Freshness
+	for _, d := range departures {
+		if d.Freshness != nil && (oldest == nil || d.LastUpdated.Before(oldest.LastUpdated)) {
+			oldest = d.Freshness
+		}
+	}
+	return oldest
+}
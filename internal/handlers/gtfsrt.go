@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"arrivo-transit-api/internal/gtfsrt"
+	"arrivo-transit-api/internal/services"
+)
+
+// GTFSRTHandler publishes the merged realtime state as GTFS-Realtime feeds.
+// Add ?format=json for a readable version of the same feed.
+type GTFSRTHandler struct {
+	transitService services.RealtimeFeeds
+}
+
+// NewGTFSRTHandler creates a new GTFS-Realtime handler.
+func NewGTFSRTHandler(transitService services.RealtimeFeeds) *GTFSRTHandler {
+	return &GTFSRTHandler{
+		transitService: transitService,
+	}
+}
+
+// TripUpdates serves the trip updates feed.
+func (h *GTFSRTHandler) TripUpdates(w http.ResponseWriter, r *http.Request) {
+	updates, err := h.transitService.RealtimeTripUpdates(r.Context())
+	if err != nil {
+		log.Printf("ERROR: Failed to get trip updates: %v", err)
+		http.Error(w, "Failed to get trip updates", http.StatusInternalServerError)
+		return
+	}
+	writeFeed(w, r, gtfsrt.TripUpdatesFeed(updates, time.Now()))
+}
+
+// VehiclePositions serves the vehicle positions feed.
+func (h *GTFSRTHandler) VehiclePositions(w http.ResponseWriter, r *http.Request) {
+	vehicles, err := h.transitService.RealtimeVehicles(r.Context())
+	if err != nil {
+		log.Printf("ERROR: Failed to get vehicle positions: %v", err)
+		http.Error(w, "Failed to get vehicle positions", http.StatusInternalServerError)
+		return
+	}
+	writeFeed(w, r, gtfsrt.VehiclePositionsFeed(vehicles, time.Now()))
+}
+
+// Alerts serves the service alerts feed.
+func (h *GTFSRTHandler) Alerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := h.transitService.RealtimeAlerts(r.Context())
+	if err != nil {
+		log.Printf("ERROR: Failed to get alerts: %v", err)
+		http.Error(w, "Failed to get alerts", http.StatusInternalServerError)
+		return
+	}
+	writeFeed(w, r, gtfsrt.AlertsFeed(alerts, time.Now()))
+}
+
+// writeFeed writes feed as protobuf, or as indented JSON with ?format=json.
+func writeFeed(w http.ResponseWriter, r *http.Request, feed *gtfsrt.FeedMessage) {
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(feed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(gtfsrt.Encode(feed))
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/services"
+)
+
+// GetRoutePunctuality handles the punctuality statistics of a route.
+func (h *TransitHandler) GetRoutePunctuality(w http.ResponseWriter, r *http.Request) {
+	filter, ok := punctualityFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.RouteID = chi.URLParam(r, "routeID")
+	h.writePunctuality(w, r, filter, "route")
+}
+
+// GetStopPunctuality handles the punctuality statistics of a stop and, for
+// a station, its quays.
+func (h *TransitHandler) GetStopPunctuality(w http.ResponseWriter, r *http.Request) {
+	filter, ok := punctualityFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.StopID = chi.URLParam(r, "stopID")
+	h.writePunctuality(w, r, filter, "stop")
+}
+
+func (h *TransitHandler) writePunctuality(w http.ResponseWriter, r *http.Request, filter services.PunctualityFilter, subject string) {
+	if filter.RouteID == "" && filter.StopID == "" {
+		http.Error(w, subject+"ID is required", http.StatusBadRequest)
+		return
+	}
+
+	punctuality, err := h.transitService.GetPunctuality(r.Context(), filter)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, subject+" not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, services.ErrInvalid) {
+		http.Error(w, "invalid window, from must not be after to and the window must not exceed 366 days", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to get punctuality of %s %s%s: %v", subject, filter.RouteID, filter.StopID, err)
+		http.Error(w, "Failed to get punctuality", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(punctuality)
+}
+
+// punctualityFilter parses the from and to service days, as YYYY-MM-DD,
+// writing a 400 when they are malformed.
+func punctualityFilter(w http.ResponseWriter, r *http.Request) (services.PunctualityFilter, bool) {
+	var filter services.PunctualityFilter
+	for _, param := range []struct {
+		name string
+		day  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", value, models.TimetableLocation)
+		if err != nil {
+			http.Error(w, "invalid "+param.name+", expected YYYY-MM-DD", http.StatusBadRequest)
+			return filter, false
+		}
+		*param.day = day
+	}
+	return filter, true
+}
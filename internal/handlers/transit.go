@@ -3,18 +3,21 @@ package handlers
 import (
 	"arrivo-transit-api/internal/services"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type TransitHandler struct {
-	transitService *services.TransitService
+	transitService services.Transit
 }
 
-func NewTransitHandler(transitService *services.TransitService) *TransitHandler {
+func NewTransitHandler(transitService services.Transit) *TransitHandler {
 	return &TransitHandler{
 		transitService: transitService,
 	}
@@ -28,12 +31,17 @@ func (h *TransitHandler) GetDepartures(w http.ResponseWriter, r *http.Request) {
 	}
 
 	departures, err := h.transitService.GetDepartures(r.Context(), stopID)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "stop not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("ERROR: Failed to get departures for stop %s: %v", stopID, err)
 		http.Error(w, "Failed to get departures", http.StatusInternalServerError)
 		return
 	}
 
+	setFreshnessHeaders(w, departuresFreshness(departures))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(departures)
 }
@@ -87,19 +95,34 @@ func (h *TransitHandler) GetVehiclesByRoute(w http.ResponseWriter, r *http.Reque
 	}
 
 	routeVehicles, err := h.transitService.GetVehiclesByRoute(r.Context(), routeID)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("ERROR: Failed to get vehicles for route %s: %v", routeID, err)
 		http.Error(w, "Failed to get vehicles for route", http.StatusInternalServerError)
 		return
 	}
 
+	setFreshnessHeaders(w, &routeVehicles.Freshness)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(routeVehicles)
 }
 
-// GetAllActiveVehicles handles fetching all currently active vehicles
+// GetAllActiveVehicles handles fetching all currently active vehicles;
+// ?estimate=true projects their positions
 func (h *TransitHandler) GetAllActiveVehicles(w http.ResponseWriter, r *http.Request) {
-	vehicles, err := h.transitService.GetAllActiveVehicles(r.Context())
+	var estimate bool
+	if value := r.URL.Query().Get("estimate"); value != "" {
+		var err error
+		if estimate, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "invalid estimate", http.StatusBadRequest)
+			return
+		}
+	}
+
+	vehicles, err := h.transitService.GetAllActiveVehicles(r.Context(), estimate)
 	if err != nil {
 		log.Printf("ERROR: Failed to get all active vehicles: %v", err)
 		http.Error(w, "Failed to get active vehicles", http.StatusInternalServerError)
@@ -169,4 +192,80 @@ func (h *TransitHandler) GetNearbyStops(w http.ResponseWriter, r *http.Request)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stops)
-}
\ No newline at end of file
+}
+
+// GetAlerts lists the service alerts, filtered by route, stop, agency,
+// effect, severity and active period.
+func (h *TransitHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.AlertFilter{
+		RouteID:    query.Get("route_id"),
+		StopID:     query.Get("stop_id"),
+		AgencyID:   query.Get("agency_id"),
+		Effects:    listParam(query.Get("effect")),
+		Severities: listParam(query.Get("severity")),
+	}
+	if activeAt := query.Get("active_at"); activeAt != "" {
+		at, err := time.Parse(time.RFC3339, activeAt)
+		if err != nil {
+			http.Error(w, "invalid active_at, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		filter.At = at
+	}
+	if upcoming := query.Get("include_upcoming"); upcoming != "" {
+		include, err := strconv.ParseBool(upcoming)
+		if err != nil {
+			http.Error(w, "invalid include_upcoming", http.StatusBadRequest)
+			return
+		}
+		filter.IncludeUpcoming = include
+	}
+
+	alerts, err := h.transitService.GetAlerts(r.Context(), filter)
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "route or stop not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to get alerts: %v", err)
+		http.Error(w, "Failed to get alerts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// listParam splits a comma-separated query parameter into upper case
+// values.
+func listParam(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, strings.ToUpper(v))
+		}
+	}
+	return values
+}
+
+// GetRealtimeHealth reports the last realtime worker cycle. It answers 503
+// when no worker reported recently, so load balancers can alert on it.
+func (h *TransitHandler) GetRealtimeHealth(w http.ResponseWriter, r *http.Request) {
+	status, err := h.transitService.RealtimeStatus(r.Context())
+	if errors.Is(err, services.ErrNotFound) {
+		http.Error(w, "realtime worker not reporting", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to get realtime status: %v", err)
+		http.Error(w, "Failed to get realtime status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.Stale(time.Now()) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,67 @@
+// Package metrics publishes operational counters through expvar, served as
+// JSON by Handler (conventionally at /debug/vars).
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// upstreams holds one map per upstream client:
+//
+//	{"OVapi": {"breaker_state": "closed", "breaker_transitions": {"open": 2, ...},
+//	           "requests": {"tpc:ok": 120, "tpc:error": 3, ...}, "retries": 7, ...}}
+var upstreams = expvar.NewMap("upstream")
+
+func upstream(name string) *expvar.Map {
+	if m, ok := upstreams.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	upstreams.Set(name, m)
+	return m
+}
+
+func counters(parent *expvar.Map, key string) *expvar.Map {
+	if m, ok := parent.Get(key).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	parent.Set(key, m)
+	return m
+}
+
+// BreakerState records the current circuit breaker state of an upstream.
+func BreakerState(name, state string) {
+	s := new(expvar.String)
+	s.Set(state)
+	upstream(name).Set("breaker_state", s)
+}
+
+// BreakerTransition records that the breaker of an upstream changed to
+// state.
+func BreakerTransition(name, state string) {
+	BreakerState(name, state)
+	counters(upstream(name), "breaker_transitions").Add(state, 1)
+}
+
+// Request counts a finished request to an endpoint of an upstream by
+// outcome, e.g. "ok", "error" or "rejected".
+func Request(name, endpoint, outcome string) {
+	counters(upstream(name), "requests").Add(endpoint+":"+outcome, 1)
+}
+
+// Retry counts a retried attempt.
+func Retry(name string) {
+	upstream(name).Add("retries", 1)
+}
+
+// Hedge counts a hedged attempt.
+func Hedge(name string) {
+	upstream(name).Add("hedges", 1)
+}
+
+// Handler serves all published variables as JSON.
+func Handler() http.Handler {
+	return expvar.Handler()
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// Alert is a service alert, such as a disruption or a detour, and the
+// agencies, routes, trips and stops it affects.
+type Alert struct {
+	ID            string        `json:"id"`
+	Source        string        `json:"source"`   // Realtime source that reported the alert
+	Cause         string        `json:"cause"`    // GTFS-Realtime cause, e.g. "CONSTRUCTION"
+	Effect        string        `json:"effect"`   // GTFS-Realtime effect, e.g. "DETOUR"
+	Severity      string        `json:"severity"` // One of the Severity* constants
+	Header        string        `json:"header"`
+	Description   string        `json:"description,omitempty"`
+	URL           string        `json:"url,omitempty"`
+	ActivePeriods []AlertPeriod `json:"active_periods,omitempty"` // Always active when empty
+	Informed      []AlertEntity `json:"informed_entities"`
+}
+
+// Alert severities, as in GTFS-Realtime
+const (
+	SeverityUnknown = "UNKNOWN_SEVERITY"
+	SeverityInfo    = "INFO"
+	SeverityWarning = "WARNING"
+	SeveritySevere  = "SEVERE"
+)
+
+// ActiveAt reports whether the alert is active at t.
+func (a Alert) ActiveAt(t time.Time) bool {
+	if len(a.ActivePeriods) == 0 {
+		return true
+	}
+	for _, p := range a.ActivePeriods {
+		if (p.Start == nil || !t.Before(*p.Start)) && (p.End == nil || t.Before(*p.End)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ended reports whether all active periods of the alert ended before t.
+func (a Alert) Ended(t time.Time) bool {
+	for _, p := range a.ActivePeriods {
+		if p.End == nil || t.Before(*p.End) {
+			return false
+		}
+	}
+	return len(a.ActivePeriods) > 0
+}
+
+// AlertPeriod is a time range an alert is active in; either side may be
+// open.
+type AlertPeriod struct {
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// AlertEntity selects what an alert applies to. Set fields are combined.
+type AlertEntity struct {
+	AgencyID string `json:"agency_id,omitempty"`
+	RouteID  string `json:"route_id,omitempty"`
+	TripID   string `json:"trip_id,omitempty"`
+	StopID   string `json:"stop_id,omitempty"`
+}
+
+// Matches reports whether the entity selects a journey of the given agency,
+// route and trip calling at one of stopIDs. Every set field must match;
+// empty arguments match nothing but unset fields.
+func (e AlertEntity) Matches(agencyID, routeID, tripID string, stopIDs ...string) bool {
+	if e.AgencyID != "" && e.AgencyID != agencyID {
+		return false
+	}
+	if e.RouteID != "" && e.RouteID != routeID {
+		return false
+	}
+	if e.TripID != "" && e.TripID != tripID {
+		return false
+	}
+	if e.StopID != "" {
+		for _, stopID := range stopIDs {
+			if e.StopID == stopID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -2,8 +2,93 @@ package models
 
 import "time"
 
+// Departure is a journey leaving a stop.
 type Departure struct {
-	Line        string    `json:"line"`
-	Destination string    `json:"destination"`
-	Departure   time.Time `json:"departure"`
-}
\ No newline at end of file
+	Line               string     `json:"line"`
+	Destination        string     `json:"destination"`
+	Departure          time.Time  `json:"departure"`                    // Best known departure time, expected when tracked
+	ScheduledDeparture time.Time  `json:"scheduled_departure"`          // Timetabled departure time
+	ExpectedDeparture  *time.Time `json:"expected_departure,omitempty"` // Only set for tracked journeys
+	Delay              int        `json:"delay"`                        // Delay in seconds (positive = late, negative = early)
+	Status             string     `json:"status"`                       // One of the Departure* status constants
+	Operator           string     `json:"operator,omitempty"`           // Operator code, e.g. "GVB", "RET"
+	TransportType      string     `json:"transport_type,omitempty"`     // "BUS", "TRAM", "METRO", "TRAIN", "BOAT"
+	TimingPointCode    string     `json:"timing_point_code,omitempty"`  // OVapi stop the departure was reported for
+	JourneyNumber      int        `json:"journey_number,omitempty"`
+	Source             string     `json:"source,omitempty"`          // Realtime source the departure was taken from, e.g. "ovapi"
+	StopID             string     `json:"stop_id,omitempty"`         // GTFS stop (quay) the journey calls at
+	PlannedStopID      string     `json:"planned_stop_id,omitempty"` // GTFS stop (quay) in the timetable; differs from StopID on a platform change
+	RouteID            string     `json:"route_id,omitempty"`        // GTFS route, when the journey is known
+	TripID             string     `json:"trip_id,omitempty"`         // GTFS trip, when the journey is known
+	ServiceDate        string     `json:"service_date,omitempty"`    // Service day of the trip as YYYYMMDD
+	Cancelled          bool       `json:"cancelled,omitempty"`       // The whole trip does not run
+	Skipped            bool       `json:"skipped,omitempty"`         // The trip runs but does not call at this stop
+	Added              bool       `json:"added,omitempty"`           // The trip is not in the timetable
+	Realtime           bool       `json:"realtime"`                  // Reported by a realtime source; false when taken from the timetable alone
+	Predicted          bool       `json:"predicted,omitempty"`       // The expected time is propagated from the delay at an earlier stop
+	Alerts             []Alert    `json:"alerts,omitempty"`          // Active alerts affecting the departure
+
+	// Freshness of the realtime data, when the departure was reported by a
+	// realtime source
+	*Freshness
+
+	// How far Departure can be trusted; not set for cancelled and skipped
+	// calls
+	Reliability *Reliability `json:"reliability,omitempty"`
+
+	// Expected crowding when leaving the stop, one of the Occupancy* levels
+	Occupancy *string `json:"occupancy,omitempty"`
+	// Occupancy is forecast from earlier trips rather than reported by a
+	// realtime source
+	OccupancyForecast bool `json:"occupancy_forecast,omitempty"`
+
+	// Platform code of StopID and PlannedStopID, when the quays have one
+	Platform        string `json:"platform,omitempty"`
+	PlannedPlatform string `json:"planned_platform,omitempty"`
+	// The journey calls at another quay than in the timetable
+	PlatformChanged bool `json:"platform_changed,omitempty"`
+}
+
+// Reliability is how far the departure time of a departure can be
+// trusted: a score and the interval the departure will likely fall in.
+type Reliability struct {
+	Score    int       `json:"score"`    // 0 to 100, higher is more reliable
+	Earliest time.Time `json:"earliest"` // Lower bound of the likely departure
+	Latest   time.Time `json:"latest"`   // Upper bound of the likely departure
+	Basis    string    `json:"basis"`    // One of the Reliability* basis constants
+}
+
+// Bases of a departure's reliability.
+const (
+	ReliabilityRealtime  = "REALTIME"  // Tracked by a realtime source
+	ReliabilityPredicted = "PREDICTED" // Propagated from the delay at an earlier stop
+	ReliabilityTimetable = "TIMETABLE" // Timetabled only, spread by the delays measured before
+)
+
+// Departure statuses
+const (
+	DepartureScheduled = "SCHEDULED" // Not tracked yet, times are from the timetable
+	DepartureEnRoute   = "EN_ROUTE"  // Vehicle is driving towards the stop
+	DepartureArrived   = "ARRIVED"   // Vehicle is at the stop
+	DepartureDeparted  = "DEPARTED"  // Vehicle has left the stop
+	DepartureCancelled = "CANCELLED"
+	DepartureOffRoute  = "OFF_ROUTE" // Vehicle deviates from its route
+	DepartureUnknown   = "UNKNOWN"
+)
+
+// TransportType returns the transport type of departures on routes of the
+// GTFS routeType, e.g. "BUS".
+func TransportType(routeType int) string {
+	switch routeType {
+	case RouteTypeTram, RouteTypeCableTram:
+		return "TRAM"
+	case RouteTypeSubway, RouteTypeMonorail:
+		return "METRO"
+	case RouteTypeRail:
+		return "TRAIN"
+	case RouteTypeFerry:
+		return "BOAT"
+	default:
+		return "BUS"
+	}
+}
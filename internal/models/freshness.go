@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Freshness tells how current realtime data is. Stale data is the last
+// snapshot the realtime worker stored before an upstream failed, served
+// until it is older than the configured maximum staleness.
+type Freshness struct {
+	LastUpdated time.Time `json:"last_updated"`
+	FreshUntil  time.Time `json:"fresh_until"` // The snapshot is stale after this time
+	Stale       bool      `json:"stale"`
+	Age         int       `json:"age"` // Seconds since LastUpdated
+}
+
+// At returns the freshness of the same snapshot at now, so responses cached
+// for a while report their age when served.
+func (f Freshness) At(now time.Time) Freshness {
+	if f.LastUpdated.IsZero() {
+		return f
+	}
+	f.Stale = now.After(f.FreshUntil)
+	f.Age = int(now.Sub(f.LastUpdated).Seconds())
+	return f
+}
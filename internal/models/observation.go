@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// CallObservation is a call of a journey at a stop as it was completed or
+// skipped, archived for punctuality statistics.
+type CallObservation struct {
+	ServiceDate        string // Service day of the journey as YYYYMMDD
+	RealtimeTripID     string // Journey ID, e.g. "GVB:1:7"
+	StopSequence       int    // Order of the call in the journey
+	TripID             string // GTFS trip, when the journey is known
+	RouteID            string // GTFS route, when the journey is known
+	StopID             string // GTFS stop (quay)
+	TimingPointCode    string
+	ScheduledArrival   *time.Time
+	ScheduledDeparture *time.Time
+	ActualArrival      *time.Time // Not set for cancelled and skipped calls
+	ActualDeparture    *time.Time
+	Status             string // DepartureDeparted, DepartureCancelled or CallSkipped
+	Source             string // Realtime source that reported the call
+	ObservedAt         time.Time
+}
+
+// CallSkipped is the status of an archived call a running journey did not
+// make.
+const CallSkipped = "SKIPPED"
+
+// PositionObservation is a reported vehicle position, archived for
+// reliability analysis.
+type PositionObservation struct {
+	ObservedAt time.Time // Time of the report
+	VehicleID  string
+	TripID     string
+	RouteID    string
+	Lat        float64
+	Lon        float64
+	Bearing    *float64
+	Speed      *float64
+	Delay      *int
+	Status     string
+	StopID     *string
+	Source     string
+	Occupancy  *string // One of the Occupancy* levels, when reported
+}
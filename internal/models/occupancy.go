@@ -0,0 +1,85 @@
+package models
+
+// Occupancy levels, following GTFS-Realtime OccupancyStatus.
+const (
+	OccupancyEmpty                   = "EMPTY"
+	OccupancyManySeatsAvailable      = "MANY_SEATS_AVAILABLE"
+	OccupancyFewSeatsAvailable       = "FEW_SEATS_AVAILABLE"
+	OccupancyStandingRoomOnly        = "STANDING_ROOM_ONLY"
+	OccupancyCrushedStandingRoomOnly = "CRUSHED_STANDING_ROOM_ONLY"
+	OccupancyFull                    = "FULL"
+	OccupancyNotAcceptingPassengers  = "NOT_ACCEPTING_PASSENGERS"
+)
+
+// OccupancyLevels are the occupancy levels from least to most crowded.
+var OccupancyLevels = []string{
+	OccupancyEmpty,
+	OccupancyManySeatsAvailable,
+	OccupancyFewSeatsAvailable,
+	OccupancyStandingRoomOnly,
+	OccupancyCrushedStandingRoomOnly,
+	OccupancyFull,
+	OccupancyNotAcceptingPassengers,
+}
+
+// OccupancyLevel returns the index of occupancy in OccupancyLevels, or -1
+// for an unknown level.
+func OccupancyLevel(occupancy string) int {
+	for i, level := range OccupancyLevels {
+		if level == occupancy {
+			return i
+		}
+	}
+	return -1
+}
+
+// OccupancySlot identifies the vehicles of a trip leaving a stop on a
+// weekday, in an hour of the day.
+type OccupancySlot struct {
+	RouteID string
+	TripID  string
+	StopID  string
+	Weekday int // ISO weekday, 1 is Monday
+	Hour    int
+}
+
+// OccupancyCounts counts the vehicles seen at each occupancy level.
+type OccupancyCounts struct {
+	Vehicles int
+	Levels   []int // Vehicles per index of OccupancyLevels
+}
+
+// NewOccupancyCounts returns empty counts.
+func NewOccupancyCounts() *OccupancyCounts {
+	return &OccupancyCounts{Levels: make([]int, len(OccupancyLevels))}
+}
+
+// AddLevel counts a vehicle at an index of OccupancyLevels.
+func (o *OccupancyCounts) AddLevel(level int) {
+	if level < 0 || level >= len(o.Levels) {
+		return
+	}
+	o.Vehicles++
+	o.Levels[level]++
+}
+
+// Add adds the counts of other.
+func (o *OccupancyCounts) Add(other *OccupancyCounts) {
+	o.Vehicles += other.Vehicles
+	for i, n := range other.Levels {
+		o.Levels[i] += n
+	}
+}
+
+// Median returns the occupancy level at least half the vehicles were at or
+// below, or "" without vehicles.
+func (o *OccupancyCounts) Median() string {
+	seen := 0
+	for i, n := range o.Levels {
+		seen += n
+		if n > 0 && 2*seen >= o.Vehicles {
+			return OccupancyLevels[i]
+		}
+	}
+	return ""
+}
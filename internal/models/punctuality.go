@@ -0,0 +1,196 @@
+package models
+
+import "time"
+
+// A departure is on time from OnTimeEarliest to OnTimeLatest seconds of
+// delay, following the Dutch punctuality norm.
+const (
+	OnTimeEarliest = -60
+	OnTimeLatest   = 180
+)
+
+// Delays are counted in a histogram of DelayBuckets buckets of
+// DelayBucketWidth seconds from DelayHistogramStart; delays outside it count
+// in the first or last bucket.
+const (
+	DelayHistogramStart = -600
+	DelayBucketWidth    = 30
+	DelayBuckets        = 80
+)
+
+// DelayBucket returns the histogram bucket of delay seconds.
+func DelayBucket(delay int) int {
+	bucket := delay - DelayHistogramStart
+	if bucket < 0 {
+		return 0
+	}
+	return min(bucket/DelayBucketWidth, DelayBuckets-1)
+}
+
+// Delay returns how many seconds late the call departed, or arrived at the
+// end of its journey, or false when it was not made or not measured.
+func (c CallObservation) Delay() (int, bool) {
+	if c.Status != DepartureDeparted {
+		return 0, false
+	}
+	if c.ActualDeparture != nil && c.ScheduledDeparture != nil {
+		return int(c.ActualDeparture.Sub(*c.ScheduledDeparture).Seconds()), true
+	}
+	if c.ActualArrival != nil && c.ScheduledArrival != nil {
+		return int(c.ActualArrival.Sub(*c.ScheduledArrival).Seconds()), true
+	}
+	return 0, false
+}
+
+// Scheduled returns the timetabled departure of the call, or its arrival at
+// the end of its journey.
+func (c CallObservation) Scheduled() time.Time {
+	if c.ScheduledDeparture != nil {
+		return *c.ScheduledDeparture
+	}
+	if c.ScheduledArrival != nil {
+		return *c.ScheduledArrival
+	}
+	return time.Time{}
+}
+
+// DelayProfileKey identifies the calls of a route in an hour of the day, by
+// scheduled departure.
+type DelayProfileKey struct {
+	RouteID string
+	Hour    int
+}
+
+// PunctualitySlot is an hour of a weekday, by scheduled departure.
+type PunctualitySlot struct {
+	Weekday int // ISO weekday, 1 is Monday
+	Hour    int
+}
+
+// PunctualityCounts counts archived calls.
+type PunctualityCounts struct {
+	Calls     int   // All calls, including cancelled and skipped ones
+	Measured  int   // Calls made with a known delay
+	OnTime    int   // Measured calls that were on time
+	Cancelled int   // Calls of cancelled journeys
+	Skipped   int   // Calls running journeys did not make
+	Delays    []int // Measured calls per DelayBucket
+}
+
+// NewPunctualityCounts returns empty counts.
+func NewPunctualityCounts() *PunctualityCounts {
+	return &PunctualityCounts{Delays: make([]int, DelayBuckets)}
+}
+
+// AddCall counts c.
+func (p *PunctualityCounts) AddCall(c CallObservation) {
+	p.Calls++
+	switch c.Status {
+	case DepartureCancelled:
+		p.Cancelled++
+	case CallSkipped:
+		p.Skipped++
+	}
+	if delay, ok := c.Delay(); ok {
+		p.Measured++
+		if delay >= OnTimeEarliest && delay <= OnTimeLatest {
+			p.OnTime++
+		}
+		p.Delays[DelayBucket(delay)]++
+	}
+}
+
+// Add adds the counts of o.
+func (p *PunctualityCounts) Add(o *PunctualityCounts) {
+	p.Calls += o.Calls
+	p.Measured += o.Measured
+	p.OnTime += o.OnTime
+	p.Cancelled += o.Cancelled
+	p.Skipped += o.Skipped
+	for i, n := range o.Delays {
+		p.Delays[i] += n
+	}
+}
+
+// Stats summarizes the counts.
+func (p *PunctualityCounts) Stats() PunctualityStats {
+	stats := PunctualityStats{Calls: p.Calls, Measured: p.Measured}
+	if p.Calls > 0 {
+		stats.CancelledPercentage = percentage(p.Cancelled, p.Calls)
+		stats.SkippedPercentage = percentage(p.Skipped, p.Calls)
+	}
+	if p.Measured > 0 {
+		stats.OnTimePercentage = percentage(p.OnTime, p.Measured)
+		stats.DelayPercentiles = &DelayPercentiles{
+			P50: p.DelayQuantile(0.5),
+			P75: p.DelayQuantile(0.75),
+			P90: p.DelayQuantile(0.9),
+			P95: p.DelayQuantile(0.95),
+		}
+	}
+	return stats
+}
+
+// DelayQuantile returns the middle of the bucket holding the q quantile of
+// the measured delays.
+func (p *PunctualityCounts) DelayQuantile(q float64) int {
+	rank := q * float64(p.Measured)
+	seen := 0
+	for i, n := range p.Delays {
+		seen += n
+		if float64(seen) >= rank && n > 0 {
+			return DelayHistogramStart + i*DelayBucketWidth + DelayBucketWidth/2
+		}
+	}
+	return DelayHistogramStart + DelayBuckets*DelayBucketWidth
+}
+
+func percentage(n, total int) float64 {
+	return float64(int(float64(n)/float64(total)*1000+0.5)) / 10
+}
+
+// PunctualityStats summarizes how punctual the calls of a route or at a stop
+// were.
+type PunctualityStats struct {
+	Calls               int               `json:"calls"`                       // Timetabled calls observed, including cancelled and skipped ones
+	Measured            int               `json:"measured"`                    // Calls made with a known delay
+	OnTimePercentage    float64           `json:"on_time_percentage"`          // Share of measured calls departing 1 minute early to 3 minutes late
+	CancelledPercentage float64           `json:"cancelled_percentage"`        // Share of calls whose journey was cancelled
+	SkippedPercentage   float64           `json:"skipped_percentage"`          // Share of calls a running journey did not make
+	DelayPercentiles    *DelayPercentiles `json:"delay_percentiles,omitempty"` // Not set without measured calls
+}
+
+// DelayPercentiles are percentiles of the delay in seconds, accurate to
+// half a DelayBucketWidth.
+type DelayPercentiles struct {
+	P50 int `json:"p50"`
+	P75 int `json:"p75"`
+	P90 int `json:"p90"`
+	P95 int `json:"p95"`
+}
+
+// Punctuality is the punctuality of a route or stop over a window of service
+// days, overall and by hour of the day and weekday of the scheduled
+// departure.
+type Punctuality struct {
+	RouteID   string               `json:"route_id,omitempty"`
+	StopID    string               `json:"stop_id,omitempty"`
+	From      string               `json:"from"` // First service day as YYYY-MM-DD
+	To        string               `json:"to"`   // Last service day as YYYY-MM-DD
+	Overall   PunctualityStats     `json:"overall"`
+	ByHour    []HourPunctuality    `json:"by_hour"`    // Hours with calls
+	ByWeekday []WeekdayPunctuality `json:"by_weekday"` // Weekdays with calls
+}
+
+// HourPunctuality is the punctuality in an hour of the day.
+type HourPunctuality struct {
+	Hour int `json:"hour"`
+	PunctualityStats
+}
+
+// WeekdayPunctuality is the punctuality on a weekday, overall and by hour.
+type WeekdayPunctuality struct {
+	Weekday int `json:"weekday"` // ISO weekday, 1 is Monday
+	PunctualityStats
+	Hours []HourPunctuality `json:"hours"`
+}
@@ -12,6 +12,7 @@ type Route struct {
 	Color           *string `json:"color,omitempty"`    // Route color (hex)
 	TextColor       *string `json:"text_color,omitempty"` // Text color (hex)
 	Distance        *float64 `json:"distance,omitempty"` // Distance for location-based searches
+	Alerts          []Alert  `json:"alerts,omitempty"`   // Active alerts affecting the route
 }
 
 // RouteType constants for different transit modes
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+	_ "time/tzdata" // Timetables are local; distroless images ship no zoneinfo
+)
+
+// TimetableLocation is the time zone of the timetable.
+var TimetableLocation, _ = time.LoadLocation("Europe/Amsterdam")
+
+// maxServiceDayHours is how far past midnight trips of a service day may
+// run.
+const maxServiceDayHours = 30
+
+// ServiceDay is a day of the timetable. GTFS stop times count seconds from
+// noon minus 12 hours, which is midnight except on days with a DST change,
+// and exceed 24 hours for trips running past midnight.
+type ServiceDay struct {
+	Date  time.Time // Midnight at the start of the day, in TimetableLocation
+	Start time.Time // Noon minus 12 hours, the reference of stop times
+}
+
+// NewServiceDay returns the service day of the calendar date of t in
+// TimetableLocation.
+func NewServiceDay(t time.Time) ServiceDay {
+	t = t.In(TimetableLocation)
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, TimetableLocation)
+	noon := time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, TimetableLocation)
+	return ServiceDay{Date: date, Start: noon.Add(-12 * time.Hour)}
+}
+
+// ServiceDays returns the service days with stop times between from and to,
+// oldest first.
+func ServiceDays(from, to time.Time) []ServiceDay {
+	var days []ServiceDay
+	day := NewServiceDay(from.Add(-maxServiceDayHours * time.Hour))
+	for !day.Start.After(to) {
+		if day.Start.Add(maxServiceDayHours * time.Hour).After(from) {
+			days = append(days, day)
+		}
+		day = NewServiceDay(day.Date.AddDate(0, 0, 1))
+	}
+	return days
+}
+
+// String returns the date as YYYYMMDD, the GTFS date format.
+func (d ServiceDay) String() string {
+	return d.Date.Format("20060102")
+}
+
+// Time returns the time sec seconds into the service day.
+func (d ServiceDay) Time(sec int) time.Time {
+	return d.Start.Add(time.Duration(sec) * time.Second)
+}
+
+// Seconds returns t as seconds into the service day.
+func (d ServiceDay) Seconds(t time.Time) int {
+	return int(t.Sub(d.Start) / time.Second)
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"sort"
+
+	"arrivo-transit-api/internal/geo"
+)
+
+// callSnapDistance is the distance in meters within which a stop is taken to
+// lie on the shape when its calls are located along it.
+const callSnapDistance = 25
+
+// scheduleMargin is how long, in seconds, before its first and after its
+// last call a trip is still placed on its path by the timetable.
+const scheduleMargin = 30 * 60
+
+// ShapePoint is a point of a trip's shape.
+type ShapePoint struct {
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Dist float64 `json:"dist"` // Meters along the shape
+}
+
+// PathCall is a call of a trip, located on the trip's path.
+type PathCall struct {
+	StopID       string  `json:"stop_id"`
+	Lat          float64 `json:"lat"`
+	Lon          float64 `json:"lon"`
+	ArrivalSec   int     `json:"arrival_sec"` // Seconds since the start of the service day; -1 means not set
+	DepartureSec int     `json:"departure_sec"`
+	Dist         float64 `json:"dist"` // Meters along the path
+}
+
+// TripPath is the geometry of a trip: the points of its shape and where
+// along them it calls, with the timetable of those calls.
+type TripPath struct {
+	TripID string       `json:"trip_id"`
+	Points []ShapePoint `json:"points"`
+	Calls  []PathCall   `json:"calls"`
+}
+
+// NewTripPath builds the path of a trip from the points of its shape, in
+// sequence, and its calls ordered by stop_sequence. It measures the shape and
+// locates the calls on it, in order. A trip without a shape runs in straight
+// lines from stop to stop.
+func NewTripPath(tripID string, points []ShapePoint, calls []PathCall) *TripPath {
+	if len(points) < 2 {
+		points = make([]ShapePoint, len(calls))
+		for i, c := range calls {
+			points[i] = ShapePoint{Lat: c.Lat, Lon: c.Lon}
+		}
+	}
+	for i := range points {
+		points[i].Dist = 0
+		if i > 0 {
+			points[i].Dist = points[i-1].Dist + geo.Distance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+		}
+	}
+
+	path := &TripPath{TripID: tripID, Points: points, Calls: calls}
+	segment := 0
+	for i, c := range calls {
+		segment, path.Calls[i].Dist = path.locateCall(c.Lat, c.Lon, segment)
+	}
+	return path
+}
+
+// locateCall returns the segment from which on the stop at (lat, lon) is
+// closest to the shape, and its distance along it. The search stops at the
+// first segment within callSnapDistance once the shape leads away again, so
+// stops on routes that loop back are not matched to a later pass.
+func (p *TripPath) locateCall(lat, lon float64, from int) (int, float64) {
+	best, bestF, bestOffset := from, 0.0, -1.0
+	for i := from; i < len(p.Points)-1; i++ {
+		a, b := p.Points[i], p.Points[i+1]
+		f, offset := geo.Project(lat, lon, a.Lat, a.Lon, b.Lat, b.Lon)
+		if bestOffset < 0 || offset < bestOffset {
+			best, bestF, bestOffset = i, f, offset
+		} else if bestOffset <= callSnapDistance && offset > bestOffset+4*callSnapDistance {
+			break
+		}
+	}
+	if bestOffset < 0 {
+		return from, p.Length()
+	}
+	a, b := p.Points[best], p.Points[best+1]
+	return best, a.Dist + (b.Dist-a.Dist)*bestF
+}
+
+// Length returns the length of the path in meters.
+func (p *TripPath) Length() float64 {
+	if len(p.Points) == 0 {
+		return 0
+	}
+	return p.Points[len(p.Points)-1].Dist
+}
+
+// Locate projects (lat, lon) onto the part of the path within window meters
+// of near. It returns the distance along the path of the closest point and
+// how far (lat, lon) lies from it, or false for a path without segments
+// there.
+func (p *TripPath) Locate(lat, lon, near, window float64) (dist, offset float64, ok bool) {
+	offset = -1
+	for i := 0; i < len(p.Points)-1; i++ {
+		a, b := p.Points[i], p.Points[i+1]
+		if b.Dist < near-window || a.Dist > near+window {
+			continue
+		}
+		f, o := geo.Project(lat, lon, a.Lat, a.Lon, b.Lat, b.Lon)
+		if offset < 0 || o < offset {
+			dist, offset = a.Dist+(b.Dist-a.Dist)*f, o
+		}
+	}
+	return dist, offset, offset >= 0
+}
+
+// PointAt returns the position dist meters along the path, clamped to its
+// ends, and the bearing of the path there.
+func (p *TripPath) PointAt(dist float64) (lat, lon, bearing float64) {
+	if len(p.Points) == 0 {
+		return 0, 0, 0
+	}
+	if len(p.Points) == 1 {
+		return p.Points[0].Lat, p.Points[0].Lon, 0
+	}
+
+	// The segment containing dist, skipping points that do not advance
+	i := sort.Search(len(p.Points), func(i int) bool { return p.Points[i].Dist > dist })
+	i = max(1, min(i, len(p.Points)-1))
+	a, b := p.Points[i-1], p.Points[i]
+	for j := i; a.Dist == b.Dist && j+1 < len(p.Points); j++ {
+		b = p.Points[j+1]
+	}
+
+	f := 0.0
+	if b.Dist > a.Dist {
+		f = max(0, min(1, (dist-a.Dist)/(b.Dist-a.Dist)))
+	}
+	lat, lon = geo.Interpolate(a.Lat, a.Lon, b.Lat, b.Lon, f)
+	return lat, lon, geo.Bearing(a.Lat, a.Lon, b.Lat, b.Lon)
+}
+
+// ScheduledDist returns where along the path the timetable puts the trip
+// sec seconds after the start of its service day: at a stop between its
+// arrival and departure, and interpolated by time between stops. Before the
+// first call and after the last one it waits there, up to scheduleMargin.
+func (p *TripPath) ScheduledDist(sec float64) (float64, bool) {
+	var prev *PathCall
+	var prevDeparture float64
+	for i := range p.Calls {
+		c := &p.Calls[i]
+		arrival, departure := c.ArrivalSec, c.DepartureSec
+		if arrival < 0 {
+			arrival = departure
+		}
+		if departure < 0 {
+			departure = arrival
+		}
+		if arrival < 0 {
+			continue
+		}
+
+		if prev == nil {
+			if sec < float64(arrival)-scheduleMargin {
+				return 0, false
+			}
+			if sec <= float64(departure) {
+				return c.Dist, true
+			}
+		} else if sec < float64(arrival) {
+			f := (sec - prevDeparture) / (float64(arrival) - prevDeparture)
+			return prev.Dist + (c.Dist-prev.Dist)*f, true
+		} else if sec <= float64(departure) {
+			return c.Dist, true
+		}
+		prev, prevDeparture = c, float64(departure)
+	}
+
+	if prev == nil || sec > prevDeparture+scheduleMargin {
+		return 0, false
+	}
+	return prev.Dist, true
+}
@@ -7,4 +7,9 @@ type Stop struct {
 	Lat      float64 `json:"lat"`
 	Lon      float64 `json:"lon"`
 	Distance float64 `json:"distance,omitempty"` // Distance in meters
+	Score    float64 `json:"score,omitempty"`    // Search relevance, higher is better
+
+	// Quay details, from GTFS and the NeTEx export
+	PlatformCode string `json:"platform_code,omitempty"` // Platform of a quay, e.g. "5b"
+	QuayName     string `json:"quay_name,omitempty"`     // Name of the quay in NeTEx, when imported
 }
\ No newline at end of file
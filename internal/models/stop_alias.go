@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"arrivo-transit-api/internal/search"
+)
+
+// StopAlias is an alternative way users refer to a stop or place, such as an
+// abbreviation ("CS"), a colloquial name ("Den Bosch") or a former name.
+type StopAlias struct {
+	ID        int64     `json:"id"`
+	Alias     string    `json:"alias"`     // What users type
+	Expansion string    `json:"expansion"` // What it stands for in stop names
+	Kind      string    `json:"kind"`      // "abbreviation", "colloquial" or "former"
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StopAlias kinds
+const (
+	StopAliasAbbreviation = "abbreviation"
+	StopAliasColloquial   = "colloquial"
+	StopAliasFormer       = "former"
+)
+
+// Valid reports whether the alias has the fields required to store it. An
+// alias or expansion without letters or digits is rejected: it normalizes to
+// an empty key that would match every query or none.
+func (a *StopAlias) Valid() bool {
+	if search.Normalize(a.Alias) == "" || search.Normalize(a.Expansion) == "" {
+		return false
+	}
+	switch a.Kind {
+	case StopAliasAbbreviation, StopAliasColloquial, StopAliasFormer:
+		return true
+	default:
+		return false
+	}
+}
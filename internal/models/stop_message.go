@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// StopMessage is a free-text passenger message an operator publishes for a
+// set of stops, such as a diversion notice (KV15).
+type StopMessage struct {
+	ID               string     `json:"id"`                 // "<DataOwnerCode>:<MessageCodeDate>:<MessageCodeNumber>"
+	Operator         string     `json:"operator"`           // DataOwnerCode
+	TimingPointCodes []string   `json:"timing_point_codes"` // Stops the message is shown at
+	Lines            []string   `json:"lines,omitempty"`    // LinePlanningNumbers it is restricted to
+	Priority         string     `json:"priority"`           // "PTPROCESS", "COMMERCIAL" or "MISC"
+	Type             string     `json:"type"`               // "GENERAL", "ADDITIONAL", "OVERRULE" or "BOTTOMLINE"
+	Text             string     `json:"text"`
+	Reason           string     `json:"reason,omitempty"`
+	Effect           string     `json:"effect,omitempty"`
+	Advice           string     `json:"advice,omitempty"`
+	Start            time.Time  `json:"start"`
+	End              *time.Time `json:"end,omitempty"` // Open-ended when unset
+}
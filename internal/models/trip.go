@@ -0,0 +1,36 @@
+package models
+
+// Trip is a single scheduled journey of a route.
+type Trip struct {
+	ID          string  `json:"id"`
+	RouteID     string  `json:"route_id"`
+	ServiceID   string  `json:"service_id"`
+	Headsign    *string `json:"headsign,omitempty"`
+	ShortName   *string `json:"short_name,omitempty"`
+	DirectionID int     `json:"direction_id"`
+	ShapeID     *string `json:"shape_id,omitempty"`
+	// RealtimeID links the trip to its KV78turbo/KV6 journey as
+	// "<DataOwnerCode>:<LinePlanningNumber>:<JourneyNumber>".
+	RealtimeID *string `json:"realtime_trip_id,omitempty"`
+}
+
+// Journey is a realtime journey on one service day. GTFS-NL reuses a
+// realtime journey ID for different trips on different days, so the day is
+// needed to find the trip that runs it.
+type Journey struct {
+	RealtimeID  string // "<DataOwnerCode>:<LinePlanningNumber>:<JourneyNumber>"
+	ServiceDate string // YYYYMMDD
+}
+
+// StopTime is a scheduled call of a trip at a stop. Times are seconds since
+// the start of the service day and may exceed 24h; -1 means not set.
+type StopTime struct {
+	TripID            string  `json:"trip_id"`
+	StopID            string  `json:"stop_id"`
+	StopSequence      int     `json:"stop_sequence"`
+	ArrivalSec        int     `json:"arrival_sec"`
+	DepartureSec      int     `json:"departure_sec"`
+	Headsign          *string `json:"headsign,omitempty"`
+	ShapeDistTraveled float64 `json:"shape_dist_traveled,omitempty"`
+	PickupType        int     `json:"pickup_type,omitempty"` // 1 means no boarding, e.g. at the last stop
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// TripUpdate is realtime progress of a GTFS trip, normalized from
+// GTFS-Realtime.
+type TripUpdate struct {
+	TripID          string           `json:"trip_id"`
+	RouteID         string           `json:"route_id"`
+	StartDate       string           `json:"start_date,omitempty"` // Service day, YYYYMMDD
+	Relationship    string           `json:"schedule_relationship"`
+	VehicleID       string           `json:"vehicle_id,omitempty"`
+	Delay           *int             `json:"delay,omitempty"` // Seconds, for stops without an update
+	Timestamp       time.Time        `json:"timestamp"`
+	Source          string           `json:"source"` // Realtime source of the latest information
+	StopTimeUpdates []StopTimeUpdate `json:"stop_time_updates"`
+}
+
+// StopTimeUpdate is the realtime arrival and departure of a trip at a stop.
+type StopTimeUpdate struct {
+	StopSequence   *int       `json:"stop_sequence,omitempty"`
+	StopID         string     `json:"stop_id"`
+	ArrivalDelay   *int       `json:"arrival_delay,omitempty"`
+	ArrivalTime    *time.Time `json:"arrival_time,omitempty"`
+	DepartureDelay *int       `json:"departure_delay,omitempty"`
+	DepartureTime  *time.Time `json:"departure_time,omitempty"`
+	Relationship   string     `json:"schedule_relationship"`
+	Occupancy      *string    `json:"occupancy,omitempty"`        // Expected crowding when leaving the stop, one of the Occupancy* levels
+	AssignedStopID string     `json:"assigned_stop_id,omitempty"` // Stop (quay) the trip calls at instead of StopID, on a platform change
+}
+
+// Trip schedule relationships
+const (
+	TripScheduled   = "SCHEDULED"
+	TripAdded       = "ADDED"
+	TripUnscheduled = "UNSCHEDULED"
+	TripCanceled    = "CANCELED"
+)
+
+// Stop schedule relationships
+const (
+	StopScheduled = "SCHEDULED"
+	StopSkipped   = "SKIPPED"
+	StopNoData    = "NO_DATA"
+)
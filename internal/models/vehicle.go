@@ -7,6 +7,7 @@ type Vehicle struct {
 	ID          string    `json:"id"`          // Vehicle identifier
 	RouteID     string    `json:"route_id"`    // Route this vehicle is serving
 	TripID      string    `json:"trip_id"`     // Current trip identifier
+	ServiceDate string    `json:"service_date,omitempty"` // Service day of the trip as YYYYMMDD
 	Lat         float64   `json:"lat"`         // Current latitude
 	Lon         float64   `json:"lon"`         // Current longitude
 	Bearing     *float64  `json:"bearing,omitempty"` // Direction of travel in degrees (0-359)
@@ -15,9 +16,30 @@ type Vehicle struct {
 	Delay       *int      `json:"delay,omitempty"`   // Delay in seconds (positive = late, negative = early)
 	Status      string    `json:"status"`      // Vehicle status (e.g., "IN_TRANSIT", "STOPPED_AT", "INCOMING_AT")
 	StopID      *string   `json:"stop_id,omitempty"` // Current or next stop ID
+	LastStopID  *string   `json:"last_stop_id,omitempty"` // Last stop the vehicle called at
+	Source      string    `json:"source,omitempty"` // Realtime source the position was taken from, e.g. "ndov"
 	Occupancy   *string   `json:"occupancy,omitempty"` // Occupancy level ("EMPTY", "MANY_SEATS_AVAILABLE", "FEW_SEATS_AVAILABLE", "STANDING_ROOM_ONLY", "CRUSHED_STANDING_ROOM_ONLY", "FULL", "NOT_ACCEPTING_PASSENGERS")
+	OccupancyForecast bool `json:"occupancy_forecast,omitempty"` // Occupancy is forecast from earlier trips rather than reported
+	Estimated   bool       `json:"estimated,omitempty"` // Lat, Lon and Bearing are projected along the trip's shape since Timestamp
+	Reported    *Position  `json:"reported,omitempty"`  // Last reported position, when Estimated
+	Path        []Position `json:"path,omitempty"`      // Predicted positions for the next seconds, for smooth animation
 }
 
+// Position is a vehicle's location at a time.
+type Position struct {
+	Lat  float64   `json:"lat"`
+	Lon  float64   `json:"lon"`
+	Time time.Time `json:"time"`
+}
+
+// Vehicle statuses, following GTFS-Realtime VehicleStopStatus where possible.
+const (
+	VehicleInTransit  = "IN_TRANSIT"  // Driving towards StopID
+	VehicleStoppedAt  = "STOPPED_AT"  // Standing at StopID
+	VehicleIncomingAt = "INCOMING_AT" // About to arrive at StopID
+	VehicleOffRoute   = "OFF_ROUTE"   // Deviates from its route, position is approximate
+)
+
 // VehiclePosition represents a simplified vehicle position for tracking
 type VehiclePosition struct {
 	VehicleID string    `json:"vehicle_id"`
@@ -32,5 +54,6 @@ type RouteVehicles struct {
 	RouteID     string    `json:"route_id"`
 	RouteName   string    `json:"route_name"`
 	Vehicles    []Vehicle `json:"vehicles"`
-	LastUpdated time.Time `json:"last_updated"`
+	Freshness
+	Alerts      []Alert   `json:"alerts,omitempty"` // Active alerts affecting the route
 }
\ No newline at end of file
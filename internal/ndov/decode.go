@@ -0,0 +1,96 @@
+// Package ndov subscribes to the realtime feeds of the NDOV Loket
+// (https://ndovloket.nl). They are published over ZeroMQ as two-frame
+// messages: a topic such as "/ARR/KV6posinfo" and a gzipped TMI8 XML
+// document. The package decodes KV6 (vehicle positions and punctuality), KV15
+// (free-text stop messages) and KV17 (journey mutations).
+package ndov
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Message is one decoded NDOV message. A document carries a single kind of
+// data, but a message may hold several documents.
+type Message struct {
+	Topic               string
+	Positions           []KV6Position
+	StopMessages        []KV15Message
+	DeletedStopMessages []KV15Delete
+	Mutations           []KV17Mutation
+}
+
+// Decode decodes the frames of an NDOV message. Payloads are gzipped unless
+// they already start with '<'.
+func Decode(frames [][]byte) (*Message, error) {
+	if len(frames) < 2 {
+		return nil, errors.New("expected topic and payload frames")
+	}
+	msg := &Message{Topic: string(frames[0])}
+
+	for _, payload := range frames[1:] {
+		var r io.Reader = bytes.NewReader(payload)
+		if len(payload) > 0 && payload[0] != '<' {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to gunzip %s: %w", msg.Topic, err)
+			}
+			r = gz
+		}
+		if err := msg.decodeXML(r); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", msg.Topic, err)
+		}
+	}
+	return msg, nil
+}
+
+// decodeXML walks a VV_TM_PUSH document and decodes the KV6, KV15 and KV17
+// blocks it contains. Elements are matched on their local name, so the
+// TMI8 namespaces do not matter.
+func (m *Message) decodeXML(r io.Reader) error {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "KV6posinfo":
+			var info struct {
+				Positions []KV6Position `xml:",any"`
+			}
+			if err := d.DecodeElement(&info, &start); err != nil {
+				return err
+			}
+			m.Positions = append(m.Positions, info.Positions...)
+		case "KV15messages":
+			var messages struct {
+				StopMessages []KV15Message `xml:"STOPMESSAGE"`
+				Deletes      []KV15Delete  `xml:"DELETEMESSAGE"`
+			}
+			if err := d.DecodeElement(&messages, &start); err != nil {
+				return err
+			}
+			m.StopMessages = append(m.StopMessages, messages.StopMessages...)
+			m.DeletedStopMessages = append(m.DeletedStopMessages, messages.Deletes...)
+		case "KV17cvlinfo":
+			var info kv17cvlinfo
+			if err := d.DecodeElement(&info, &start); err != nil {
+				return err
+			}
+			m.Mutations = append(m.Mutations, info.mutations()...)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ndov
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func decodeRecording(t *testing.T) []*Message {
+	t.Helper()
+	var messages []*Message
+	for _, frames := range readRecording(t) {
+		msg, err := Decode(frames)
+		if err != nil {
+			t.Fatalf("Decode(%s): %v", frames[0], err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestDecodeKV6(t *testing.T) {
+	msg := decodeRecording(t)[0]
+	if msg.Topic != "/GVB/KV6posinfo" || len(msg.Positions) != 4 {
+		t.Fatalf("topic %s with %d positions, want /GVB/KV6posinfo with 4", msg.Topic, len(msg.Positions))
+	}
+
+	p := msg.Positions[1]
+	if p.Type() != KV6OnRoute || p.RealtimeTripID() != "GVB:22:3001" || p.OperatingDay != "2024-01-15" {
+		t.Errorf("position = %s of %s on %s", p.Type(), p.RealtimeTripID(), p.OperatingDay)
+	}
+	if p.UserStopCode != "30004564" || p.VehicleNumber != 2041 || p.Punctuality == nil || *p.Punctuality != 60 {
+		t.Errorf("stop = %s, vehicle = %d, punctuality = %v", p.UserStopCode, p.VehicleNumber, p.Punctuality)
+	}
+	if !p.Timestamp.Equal(time.Date(2024, 1, 15, 8, 24, 50, 0, time.UTC)) {
+		t.Errorf("timestamp = %s", p.Timestamp)
+	}
+	lat, lon, ok := p.Coordinates()
+	if !ok || math.Abs(lat-52.377) > 0.01 || math.Abs(lon-4.898) > 0.01 {
+		t.Errorf("coordinates = %f, %f (%v), want central Amsterdam", lat, lon, ok)
+	}
+
+	delay := msg.Positions[2]
+	if delay.Type() != KV6Delay || delay.RealtimeTripID() != "GVB:22:3002" || *delay.Punctuality != 120 {
+		t.Errorf("third position = %s of %s", delay.Type(), delay.RealtimeTripID())
+	}
+	if _, _, ok := delay.Coordinates(); ok {
+		t.Error("a DELAY without rd-x has coordinates")
+	}
+	if reinforcement := msg.Positions[3]; reinforcement.ReinforcementNumber != 1 {
+		t.Errorf("fourth position has reinforcement number %d, want 1", reinforcement.ReinforcementNumber)
+	}
+}
+
+func TestDecodeKV15(t *testing.T) {
+	msg := decodeRecording(t)[2]
+	if len(msg.StopMessages) != 1 || len(msg.DeletedStopMessages) != 1 {
+		t.Fatalf("got %d messages and %d deletes, want 1 and 1", len(msg.StopMessages), len(msg.DeletedStopMessages))
+	}
+
+	m := msg.StopMessages[0]
+	if m.ID() != "GVB:2024-01-15:12" || len(m.UserStopCodes) != 2 || m.UserStopCodes[1] != "30004565" {
+		t.Errorf("message %s for stops %v", m.ID(), m.UserStopCodes)
+	}
+	if len(m.LinePlanningNumbers) != 1 || m.LinePlanningNumbers[0] != "22" || m.MessagePriority != "PTPROCESS" || m.MessageType != "GENERAL" {
+		t.Errorf("lines = %v, priority = %s, type = %s", m.LinePlanningNumbers, m.MessagePriority, m.MessageType)
+	}
+	if m.ReasonContent != "Werkzaamheden" || m.AdviceContent != "Volg de borden" || m.MessageEndTime.Sub(m.MessageStartTime) != 9*time.Hour {
+		t.Errorf("message = %+v", m)
+	}
+	if id := msg.DeletedStopMessages[0].ID(); id != "GVB:2024-01-15:7" {
+		t.Errorf("deleted %s, want GVB:2024-01-15:7", id)
+	}
+}
+
+func TestDecodeKV17(t *testing.T) {
+	msg := decodeRecording(t)[3]
+	if len(msg.Mutations) != 3 {
+		t.Fatalf("got %d mutations, want 3: %+v", len(msg.Mutations), msg.Mutations)
+	}
+
+	if cancel := msg.Mutations[0]; cancel.Type != KV17Cancel || cancel.Journey.RealtimeTripID() != "GVB:22:3003" {
+		t.Errorf("first mutation = %s of %s, want CANCEL of GVB:22:3003", cancel.Type, cancel.Journey.RealtimeTripID())
+	}
+	shorten, lag := msg.Mutations[1], msg.Mutations[2]
+	if shorten.Type != KV17Shorten || shorten.Journey.RealtimeTripID() != "GVB:22:3002" || shorten.UserStopCode != "30004565" {
+		t.Errorf("second mutation = %+v, want SHORTEN of GVB:22:3002 at 30004565", shorten)
+	}
+	if lag.Type != KV17Lag || lag.LagTime != 180 || lag.UserStopCode != "30004565" {
+		t.Errorf("third mutation = %+v, want a LAG of 180s", lag)
+	}
+}
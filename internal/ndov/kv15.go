@@ -0,0 +1,44 @@
+package ndov
+
+import (
+	"fmt"
+	"time"
+)
+
+// KV15Message is a free-text passenger message for a set of stops.
+type KV15Message struct {
+	DataOwnerCode       string    `xml:"dataownercode"`
+	MessageCodeDate     string    `xml:"messagecodedate"`
+	MessageCodeNumber   int       `xml:"messagecodenumber"`
+	UserStopCodes       []string  `xml:"userstopcodes>userstopcode"`
+	LinePlanningNumbers []string  `xml:"lineplanningnumbers>lineplanningnumber"`
+	MessagePriority     string    `xml:"messagepriority"` // PTPROCESS, COMMERCIAL or MISC
+	MessageType         string    `xml:"messagetype"`     // GENERAL, ADDITIONAL, OVERRULE or BOTTOMLINE
+	MessageStartTime    time.Time `xml:"messagestarttime"`
+	MessageEndTime      time.Time `xml:"messageendtime"`
+	MessageContent      string    `xml:"messagecontent"`
+	ReasonContent       string    `xml:"reasoncontent"`
+	EffectContent       string    `xml:"effectcontent"`
+	AdviceContent       string    `xml:"advicecontent"`
+}
+
+// ID identifies the message across updates and deletes.
+func (m KV15Message) ID() string {
+	return kv15ID(m.DataOwnerCode, m.MessageCodeDate, m.MessageCodeNumber)
+}
+
+// KV15Delete withdraws a previously published message.
+type KV15Delete struct {
+	DataOwnerCode     string `xml:"dataownercode"`
+	MessageCodeDate   string `xml:"messagecodedate"`
+	MessageCodeNumber int    `xml:"messagecodenumber"`
+}
+
+// ID identifies the message to delete.
+func (d KV15Delete) ID() string {
+	return kv15ID(d.DataOwnerCode, d.MessageCodeDate, d.MessageCodeNumber)
+}
+
+func kv15ID(dataOwnerCode, date string, number int) string {
+	return fmt.Sprintf("%s:%s:%d", dataOwnerCode, date, number)
+}
@@ -0,0 +1,96 @@
+package ndov
+
+import (
+	"fmt"
+	"time"
+)
+
+// KV17 mutation types.
+const (
+	KV17Cancel            = "CANCEL"            // Journey does not run
+	KV17Recover           = "RECOVER"           // Undoes an earlier CANCEL
+	KV17Shorten           = "SHORTEN"           // Journey skips the stop
+	KV17Lag               = "LAG"               // Journey runs LagTime seconds late from the stop on
+	KV17ChangeDestination = "CHANGEDESTINATION" // New destination from the stop on
+)
+
+// KV17Journey identifies the journey a mutation applies to.
+type KV17Journey struct {
+	DataOwnerCode       string `xml:"dataownercode"`
+	LinePlanningNumber  string `xml:"lineplanningnumber"`
+	OperatingDay        string `xml:"operatingday"` // YYYY-MM-DD
+	JourneyNumber       int    `xml:"journeynumber"`
+	ReinforcementNumber int    `xml:"reinforcementnumber"`
+}
+
+// RealtimeTripID returns the journey ID GTFS-NL publishes as
+// trips.realtime_trip_id.
+func (j KV17Journey) RealtimeTripID() string {
+	return fmt.Sprintf("%s:%s:%d", j.DataOwnerCode, j.LinePlanningNumber, j.JourneyNumber)
+}
+
+// KV17Mutation is a single change to a journey, flattened from the nested
+// KV17MUTATEJOURNEY and KV17MUTATEJOURNEYSTOP elements. Stop level mutations
+// carry the UserStopCode they take effect at.
+type KV17Mutation struct {
+	Journey      KV17Journey
+	Type         string
+	Timestamp    time.Time
+	UserStopCode string
+	LagTime      int    // LAG only
+	Destination  string // CHANGEDESTINATION only
+}
+
+type kv17cvlinfo struct {
+	Journey       KV17Journey `xml:"KV17JOURNEY"`
+	MutateJourney []struct {
+		Timestamp time.Time `xml:"timestamp"`
+		Cancel    *struct{} `xml:"CANCEL"`
+		Recover   *struct{} `xml:"RECOVER"`
+	} `xml:"KV17MUTATEJOURNEY"`
+	MutateJourneyStop []struct {
+		Timestamp    time.Time `xml:"timestamp"`
+		UserStopCode string    `xml:"userstopcode"`
+		Shorten      *struct{} `xml:"SHORTEN"`
+		Lag          *struct {
+			LagTime int `xml:"lagtime"`
+		} `xml:"LAG"`
+		ChangeDestination *struct {
+			DestinationName50 string `xml:"destinationname50"`
+		} `xml:"CHANGEDESTINATION"`
+	} `xml:"KV17MUTATEJOURNEYSTOP"`
+}
+
+func (info kv17cvlinfo) mutations() []KV17Mutation {
+	var mutations []KV17Mutation
+	for _, m := range info.MutateJourney {
+		base := KV17Mutation{Journey: info.Journey, Timestamp: m.Timestamp}
+		if m.Cancel != nil {
+			base.Type = KV17Cancel
+			mutations = append(mutations, base)
+		}
+		if m.Recover != nil {
+			base.Type = KV17Recover
+			mutations = append(mutations, base)
+		}
+	}
+
+	for _, m := range info.MutateJourneyStop {
+		base := KV17Mutation{Journey: info.Journey, Timestamp: m.Timestamp, UserStopCode: m.UserStopCode}
+		if m.Shorten != nil {
+			base.Type = KV17Shorten
+			mutations = append(mutations, base)
+		}
+		if m.Lag != nil {
+			lag := base
+			lag.Type, lag.LagTime = KV17Lag, m.Lag.LagTime
+			mutations = append(mutations, lag)
+		}
+		if m.ChangeDestination != nil {
+			change := base
+			change.Type, change.Destination = KV17ChangeDestination, m.ChangeDestination.DestinationName50
+			mutations = append(mutations, change)
+		}
+	}
+	return mutations
+}
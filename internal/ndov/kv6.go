@@ -0,0 +1,60 @@
+package ndov
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"arrivo-transit-api/internal/geo"
+)
+
+// KV6 message types.
+const (
+	KV6Delay     = "DELAY"     // Punctuality before the journey started
+	KV6Init      = "INIT"      // Vehicle logged on to the journey
+	KV6Arrival   = "ARRIVAL"   // Arrived at a stop
+	KV6OnStop    = "ONSTOP"    // Standing at a stop
+	KV6Departure = "DEPARTURE" // Departed from a stop
+	KV6OnRoute   = "ONROUTE"   // Position between stops
+	KV6OnPath    = "ONPATH"    // Position on the route without stop reference
+	KV6OffRoute  = "OFFROUTE"  // Position off the route
+	KV6End       = "END"       // Journey finished
+)
+
+// KV6Position is a KV6posinfo message. The element name is the message type.
+type KV6Position struct {
+	XMLName               xml.Name
+	DataOwnerCode         string    `xml:"dataownercode"`
+	LinePlanningNumber    string    `xml:"lineplanningnumber"`
+	OperatingDay          string    `xml:"operatingday"` // YYYY-MM-DD
+	JourneyNumber         int       `xml:"journeynumber"`
+	ReinforcementNumber   int       `xml:"reinforcementnumber"`
+	UserStopCode          string    `xml:"userstopcode"`
+	PassageSequenceNumber int       `xml:"passagesequencenumber"`
+	Timestamp             time.Time `xml:"timestamp"`
+	Source                string    `xml:"source"`
+	VehicleNumber         int       `xml:"vehiclenumber"`
+	Punctuality           *int      `xml:"punctuality"` // Seconds, positive is late
+	RDX                   *int      `xml:"rd-x"`        // Rijksdriehoek meters, -1 when unknown
+	RDY                   *int      `xml:"rd-y"`
+}
+
+// Type returns the message type, one of the KV6 constants.
+func (p KV6Position) Type() string {
+	return p.XMLName.Local
+}
+
+// RealtimeTripID returns the journey ID GTFS-NL publishes as
+// trips.realtime_trip_id.
+func (p KV6Position) RealtimeTripID() string {
+	return fmt.Sprintf("%s:%s:%d", p.DataOwnerCode, p.LinePlanningNumber, p.JourneyNumber)
+}
+
+// Coordinates converts the Rijksdriehoek position to WGS84.
+func (p KV6Position) Coordinates() (lat, lon float64, ok bool) {
+	if p.RDX == nil || p.RDY == nil || *p.RDX <= 0 || *p.RDY <= 0 {
+		return 0, 0, false
+	}
+	lat, lon = geo.RDToWGS84(float64(*p.RDX), float64(*p.RDY))
+	return lat, lon, true
+}
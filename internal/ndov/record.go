@@ -0,0 +1,92 @@
+package ndov
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// Recordings store NDOV traffic for replay through a Publisher. Each message
+// is written as its receive time (int64 Unix nanoseconds), the number of
+// frames (uint32) and every frame as a uint32 length followed by its bytes,
+// all big-endian.
+
+// Recorder appends messages to a recording.
+type Recorder struct {
+	w *bufio.Writer
+}
+
+// NewRecorder creates a recorder writing to w. Flush must be called when
+// done.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: bufio.NewWriter(w)}
+}
+
+// Record appends a message received at t.
+func (r *Recorder) Record(t time.Time, frames [][]byte) error {
+	binary.Write(r.w, binary.BigEndian, t.UnixNano())
+	binary.Write(r.w, binary.BigEndian, uint32(len(frames)))
+	for _, frame := range frames {
+		binary.Write(r.w, binary.BigEndian, uint32(len(frame)))
+		if _, err := r.w.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes buffered messages to the underlying writer.
+func (r *Recorder) Flush() error {
+	return r.w.Flush()
+}
+
+// Player reads messages back from a recording.
+type Player struct {
+	r *bufio.Reader
+}
+
+// NewPlayer creates a player reading from r.
+func NewPlayer(r io.Reader) *Player {
+	return &Player{r: bufio.NewReader(r)}
+}
+
+// Next returns the next message and the time it was received. It returns
+// io.EOF at the end of the recording.
+func (p *Player) Next() (time.Time, [][]byte, error) {
+	var nanos int64
+	if err := binary.Read(p.r, binary.BigEndian, &nanos); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	var count uint32
+	if err := binary.Read(p.r, binary.BigEndian, &count); err != nil {
+		return time.Time{}, nil, truncated(err)
+	}
+
+	frames := make([][]byte, 0, min(count, 16))
+	for i := uint32(0); i < count; i++ {
+		var size uint32
+		if err := binary.Read(p.r, binary.BigEndian, &size); err != nil {
+			return time.Time{}, nil, truncated(err)
+		}
+		if size > maxFrameSize {
+			return time.Time{}, nil, errors.New("corrupt recording: frame too large")
+		}
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(p.r, frame); err != nil {
+			return time.Time{}, nil, truncated(err)
+		}
+		frames = append(frames, frame)
+	}
+	return time.Unix(0, nanos), frames, nil
+}
+
+// truncated turns an EOF in the middle of a message into an error.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
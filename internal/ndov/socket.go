@@ -0,0 +1,201 @@
+package ndov
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subscriber is a ZeroMQ SUB socket connected to a single publisher.
+type Subscriber struct {
+	conn        *conn
+	idleTimeout time.Duration
+}
+
+// Subscribe connects to endpoint ("tcp://host:port") and subscribes to every
+// message whose first frame starts with one of topics. An empty topic
+// receives everything. Receive fails when nothing arrives for idleTimeout,
+// as a dead TCP connection would otherwise go unnoticed; 0 disables that.
+func Subscribe(ctx context.Context, endpoint string, idleTimeout time.Duration, topics ...string) (*Subscriber, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", strings.TrimPrefix(endpoint, "tcp://"))
+	if err != nil {
+		return nil, err
+	}
+
+	nc.SetDeadline(time.Now().Add(10 * time.Second))
+	c, err := handshake(nc, "SUB")
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+
+	if len(topics) == 0 {
+		topics = []string{""}
+	}
+	for _, topic := range topics {
+		if err := c.writeMessage([][]byte{append([]byte{1}, topic...)}); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+
+	return &Subscriber{conn: c, idleTimeout: idleTimeout}, nil
+}
+
+// Receive returns the frames of the next message.
+func (s *Subscriber) Receive() ([][]byte, error) {
+	if s.idleTimeout > 0 {
+		s.conn.nc.SetReadDeadline(time.Now().Add(s.idleTimeout))
+	}
+	return s.conn.readMessage()
+}
+
+// Close disconnects; a blocked Receive returns an error.
+func (s *Subscriber) Close() error {
+	return s.conn.Close()
+}
+
+// Publisher is a ZeroMQ PUB socket accepting any number of subscribers. It
+// exists to replay recorded NDOV traffic to a local subscriber.
+type Publisher struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	subs map[*peer]struct{}
+}
+
+type peer struct {
+	conn *conn
+
+	mu       sync.Mutex // Serializes writes and guards prefixes
+	prefixes [][]byte
+}
+
+// Listen starts a publisher on addr ("tcp://host:port" or "host:port").
+func Listen(addr string) (*Publisher, error) {
+	ln, err := net.Listen("tcp", strings.TrimPrefix(addr, "tcp://"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{ln: ln, subs: make(map[*peer]struct{})}
+	go p.accept()
+	return p, nil
+}
+
+// Addr returns the address the publisher listens on.
+func (p *Publisher) Addr() net.Addr {
+	return p.ln.Addr()
+}
+
+// Subscribers returns the number of connected subscribers.
+func (p *Publisher) Subscribers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.subs)
+}
+
+func (p *Publisher) accept() {
+	for {
+		nc, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.serve(nc)
+	}
+}
+
+// serve completes the handshake and tracks the subscriptions of one peer
+// until it disconnects.
+func (p *Publisher) serve(nc net.Conn) {
+	nc.SetDeadline(time.Now().Add(10 * time.Second))
+	c, err := handshake(nc, "PUB")
+	if err != nil {
+		nc.Close()
+		return
+	}
+	nc.SetDeadline(time.Time{})
+
+	sub := &peer{conn: c}
+	p.mu.Lock()
+	p.subs[sub] = struct{}{}
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.subs, sub)
+		p.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		frames, err := c.readMessage()
+		if err != nil {
+			return
+		}
+		if len(frames) != 1 || len(frames[0]) == 0 {
+			continue
+		}
+
+		sub.mu.Lock()
+		switch frame := frames[0]; frame[0] {
+		case 1:
+			sub.prefixes = append(sub.prefixes, frame[1:])
+		case 0:
+			for i, prefix := range sub.prefixes {
+				if string(prefix) == string(frame[1:]) {
+					sub.prefixes = append(sub.prefixes[:i], sub.prefixes[i+1:]...)
+					break
+				}
+			}
+		}
+		sub.mu.Unlock()
+	}
+}
+
+// Publish sends a message to every subscriber whose subscriptions match its
+// first frame. Subscribers that cannot keep up are disconnected.
+func (p *Publisher) Publish(frames ...[]byte) {
+	p.mu.Lock()
+	subs := make([]*peer, 0, len(p.subs))
+	for sub := range p.subs {
+		subs = append(subs, sub)
+	}
+	p.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.mu.Lock()
+		if sub.matches(frames[0]) {
+			sub.conn.nc.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if err := sub.conn.writeMessage(frames); err != nil {
+				sub.conn.Close()
+			}
+		}
+		sub.mu.Unlock()
+	}
+}
+
+func (sub *peer) matches(topic []byte) bool {
+	for _, prefix := range sub.prefixes {
+		if len(topic) >= len(prefix) && string(topic[:len(prefix)]) == string(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops accepting subscribers and disconnects the existing ones.
+func (p *Publisher) Close() error {
+	err := p.ln.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for sub := range p.subs {
+		sub.conn.Close()
+	}
+	return err
+}
@@ -0,0 +1,292 @@
+package ndov
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readRecording returns the messages of testdata/ndov.rec.
+func readRecording(t *testing.T) [][][]byte {
+	t.Helper()
+	f, err := os.Open("testdata/ndov.rec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var messages [][][]byte
+	player := NewPlayer(f)
+	for {
+		_, frames, err := player.Next()
+		if err == io.EOF {
+			return messages
+		}
+		if err != nil {
+			t.Fatalf("failed to read recording: %v", err)
+		}
+		messages = append(messages, frames)
+	}
+}
+
+// pubFixture is a ZeroMQ PUB socket written from the ZMTP 3.0 spec rather
+// than with this package, so the tests catch a subscriber that only talks to
+// itself. It serves one subscriber: after the handshake it waits for the
+// expected number of subscriptions, sends the messages they match and hangs
+// up.
+type pubFixture struct {
+	messages      [][][]byte
+	subscriptions int
+
+	// greeting replaces the spec greeting when set
+	greeting []byte
+	// hold keeps the connection open after the replay
+	hold bool
+
+	ln         net.Listener
+	subscribed chan []string
+	errs       chan error
+}
+
+// startPub starts serving f on a local port.
+func startPub(t *testing.T, f *pubFixture) *pubFixture {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	f.ln = ln
+	f.subscribed = make(chan []string, 1)
+	f.errs = make(chan error, 1)
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		nc.SetDeadline(time.Now().Add(10 * time.Second))
+		f.errs <- f.serve(nc)
+	}()
+	return f
+}
+
+func (f *pubFixture) endpoint() string {
+	return "tcp://" + f.ln.Addr().String()
+}
+
+func (f *pubFixture) serve(nc net.Conn) error {
+	r := bufio.NewReader(nc)
+
+	greeting := f.greeting
+	if greeting == nil {
+		// Signature, version 3.0, NULL mechanism, not as-server, filler
+		greeting = append([]byte{0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0x7f, 3, 0}, "NULL"...)
+		greeting = append(greeting, make([]byte, 64-len(greeting))...)
+	}
+	if _, err := nc.Write(greeting); err != nil {
+		return err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(r, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f || peer[10] != 3 || !bytes.HasPrefix(peer[12:32], []byte("NULL\x00")) {
+		return errors.New("bad greeting from subscriber")
+	}
+
+	ready := []byte("\x05READY\x0bSocket-Type\x00\x00\x00\x03PUB")
+	if _, err := nc.Write(append([]byte{0x04, byte(len(ready))}, ready...)); err != nil {
+		return err
+	}
+	flags, body, err := readSpecFrame(r)
+	if err != nil {
+		return err
+	}
+	if flags != 0x04 || !bytes.Equal(body, []byte("\x05READY\x0bSocket-Type\x00\x00\x00\x03SUB")) {
+		return errors.New("expected READY for a SUB socket")
+	}
+
+	var topics []string
+	for len(topics) < f.subscriptions {
+		flags, body, err := readSpecFrame(r)
+		if err != nil {
+			return err
+		}
+		if flags != 0 || len(body) == 0 || body[0] != 1 {
+			return errors.New("expected a subscription message")
+		}
+		topics = append(topics, string(body[1:]))
+	}
+	f.subscribed <- topics
+
+	for _, frames := range f.messages {
+		if !matchesAny(topics, string(frames[0])) {
+			continue
+		}
+		for i, frame := range frames {
+			var flags byte
+			if i < len(frames)-1 {
+				flags |= 0x01
+			}
+			header := []byte{flags, byte(len(frame))}
+			if len(frame) > 255 {
+				header = binary.BigEndian.AppendUint64([]byte{flags | 0x02}, uint64(len(frame)))
+			}
+			if _, err := nc.Write(append(header, frame...)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if f.hold {
+		// Wait for the subscriber to hang up
+		io.Copy(io.Discard, r)
+	}
+	return nil
+}
+
+func readSpecFrame(r *bufio.Reader) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&0x02 != 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	body := make([]byte, size)
+	_, err = io.ReadFull(r, body)
+	return flags, body, err
+}
+
+func matchesAny(prefixes []string, topic string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSubscriberReceivesRecordedMessages(t *testing.T) {
+	recording := readRecording(t)
+	pub := startPub(t, &pubFixture{messages: recording, subscriptions: 2})
+
+	sub, err := Subscribe(context.Background(), pub.endpoint(), time.Minute, "/GVB/KV6", "/GVB/KV1")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if topics := <-pub.subscribed; strings.Join(topics, ",") != "/GVB/KV6,/GVB/KV1" {
+		t.Errorf("publisher got subscriptions %q", topics)
+	}
+
+	// The /ARR/ message is not subscribed to
+	for _, want := range [][][]byte{recording[0], recording[2], recording[3]} {
+		frames, err := sub.Receive()
+		if err != nil {
+			t.Fatalf("Receive: %v", err)
+		}
+		if len(frames) != len(want) || !bytes.Equal(frames[0], want[0]) || !bytes.Equal(frames[1], want[1]) {
+			t.Errorf("received %q, want %q", frames[0], want[0])
+		}
+	}
+
+	if err := <-pub.errs; err != nil {
+		t.Fatalf("publisher: %v", err)
+	}
+	if _, err := sub.Receive(); !errors.Is(err, io.EOF) {
+		t.Errorf("Receive after the publisher hung up = %v, want EOF", err)
+	}
+}
+
+func TestSubscribeRejectsOtherProtocols(t *testing.T) {
+	for name, greeting := range map[string][]byte{
+		"ZMTP 2": append([]byte{0xff, 0, 0, 0, 0, 0, 0, 0, 1, 0x7f, 1, 0}, make([]byte, 52)...),
+		"CURVE":  append(append([]byte{0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0x7f, 3, 0}, "CURVE"...), make([]byte, 47)...),
+	} {
+		t.Run(name, func(t *testing.T) {
+			pub := startPub(t, &pubFixture{subscriptions: 1, greeting: greeting})
+
+			sub, err := Subscribe(context.Background(), pub.endpoint(), 0)
+			if err == nil {
+				sub.Close()
+				t.Fatal("Subscribe succeeded")
+			}
+		})
+	}
+}
+
+func TestReceiveTimesOutWhenIdle(t *testing.T) {
+	pub := startPub(t, &pubFixture{subscriptions: 1, hold: true})
+
+	sub, err := Subscribe(context.Background(), pub.endpoint(), 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if topics := <-pub.subscribed; len(topics) != 1 || topics[0] != "" {
+		t.Errorf("publisher got subscriptions %q, want everything", topics)
+	}
+	var netErr net.Error
+	if _, err := sub.Receive(); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Receive = %v, want a timeout", err)
+	}
+}
+
+func TestPublisherServesSubscriptions(t *testing.T) {
+	recording := readRecording(t)
+	pub, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	sub, err := Subscribe(context.Background(), "tcp://"+pub.Addr().String(), 5*time.Second, "/ARR/")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	// The subscription arrives after the handshake; publish until it has
+	received := make(chan [][]byte, 1)
+	go func() {
+		frames, _ := sub.Receive()
+		received <- frames
+	}()
+	for {
+		for _, frames := range recording {
+			pub.Publish(frames...)
+		}
+		select {
+		case frames := <-received:
+			if string(frames[0]) != "/ARR/KV6posinfo" {
+				t.Errorf("received %q, want only /ARR/ messages", frames[0])
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
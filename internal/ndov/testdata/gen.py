@@ -0,0 +1,172 @@
+#!/usr/bin/env python3
+"""Writes the NDOV recording of this directory.
+
+ndov.rec holds four messages in the format of ndov.Recorder, as
+ndov-replay record would write them from the NDOV Loket:
+
+  /GVB/KV6posinfo    gzipped: GVB:22:3001 arrives and drives on, GVB:22:3002
+                     reports a delay, a reinforcement of 3001 is ignored
+  /ARR/KV6posinfo    gzipped: a journey of another operator
+  /GVB/KV15messages  plain XML: a stop message and a delete
+  /GVB/KV17cvlinfo   gzipped: GVB:22:3003 is cancelled, GVB:22:3002 lags and
+                     skips a stop
+
+    python3 internal/ndov/testdata/gen.py
+"""
+
+import gzip
+import os
+import struct
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+
+# 2024-01-15 09:25:00 CET, the time the recording starts
+RECORDED_AT = 1705307100
+
+
+def push(dossier, namespace, body):
+    return (
+        '<?xml version="1.0" encoding="UTF-8"?>'
+        f'<VV_TM_PUSH xmlns="http://bison.connekt.nl/tmi8/{namespace}/msg">'
+        "<SubscriberID>GOVI</SubscriberID>"
+        "<Version>BISON 8.1.1.0</Version>"
+        f"<DossierName>{dossier}</DossierName>"
+        "<Timestamp>2024-01-15T09:25:00+01:00</Timestamp>"
+        f"<{dossier}>{body}</{dossier}>"
+        "</VV_TM_PUSH>"
+    ).encode()
+
+
+def gz(data):
+    return gzip.compress(data, mtime=0)
+
+
+def position(kind, owner, line, journey, timestamp, stop="", sequence=0,
+             punctuality=None, rd=None, reinforcement=0):
+    fields = [
+        f"<dataownercode>{owner}</dataownercode>",
+        f"<lineplanningnumber>{line}</lineplanningnumber>",
+        "<operatingday>2024-01-15</operatingday>",
+        f"<journeynumber>{journey}</journeynumber>",
+        f"<reinforcementnumber>{reinforcement}</reinforcementnumber>",
+    ]
+    if stop:
+        fields.append(f"<userstopcode>{stop}</userstopcode>")
+        fields.append(f"<passagesequencenumber>{sequence}</passagesequencenumber>")
+    fields.append(f"<timestamp>{timestamp}</timestamp>")
+    fields.append("<source>VEHICLE</source>")
+    fields.append("<vehiclenumber>2041</vehiclenumber>")
+    if punctuality is not None:
+        fields.append(f"<punctuality>{punctuality}</punctuality>")
+    if rd is not None:
+        fields.append(f"<rd-x>{rd[0]}</rd-x><rd-y>{rd[1]}</rd-y>")
+    return f"<{kind}>{''.join(fields)}</{kind}>"
+
+
+def kv6_gvb():
+    return push("KV6posinfo", "kv6", "".join([
+        position("ARRIVAL", "GVB", "22", 3001, "2024-01-15T09:24:30+01:00",
+                 stop="30004564", punctuality=45, rd=(121500, 487800)),
+        position("ONROUTE", "GVB", "22", 3001, "2024-01-15T09:24:50+01:00",
+                 stop="30004564", punctuality=60, rd=(121600, 487900)),
+        position("DELAY", "GVB", "22", 3002, "2024-01-15T09:24:55+01:00",
+                 punctuality=120),
+        position("ONROUTE", "GVB", "22", 3001, "2024-01-15T09:24:58+01:00",
+                 stop="30004564", punctuality=900, rd=(130000, 480000),
+                 reinforcement=1),
+    ]))
+
+
+def kv6_arr():
+    return push("KV6posinfo", "kv6", position(
+        "ONROUTE", "ARR", "M300", 1001, "2024-01-15T09:24:40+01:00",
+        stop="57003010", punctuality=0, rd=(93000, 463000)))
+
+
+def kv15_gvb():
+    return push("KV15messages", "kv15", "".join([
+        "<STOPMESSAGE>"
+        "<dataownercode>GVB</dataownercode>"
+        "<messagecodedate>2024-01-15</messagecodedate>"
+        "<messagecodenumber>12</messagecodenumber>"
+        "<userstopcodes><userstopcode>30004564</userstopcode>"
+        "<userstopcode>30004565</userstopcode></userstopcodes>"
+        "<lineplanningnumbers><lineplanningnumber>22</lineplanningnumber>"
+        "</lineplanningnumbers>"
+        "<messagepriority>PTPROCESS</messagepriority>"
+        "<messagetype>GENERAL</messagetype>"
+        "<messagedurationtype>ENDTIME</messagedurationtype>"
+        "<messagestarttime>2024-01-15T09:00:00+01:00</messagestarttime>"
+        "<messageendtime>2024-01-15T18:00:00+01:00</messageendtime>"
+        "<messagecontent>Halte Centraal Station tijdelijk verplaatst naar de"
+        " overkant van de straat.</messagecontent>"
+        "<reasoncontent>Werkzaamheden</reasoncontent>"
+        "<effectcontent>Extra looptijd</effectcontent>"
+        "<advicecontent>Volg de borden</advicecontent>"
+        "</STOPMESSAGE>",
+        "<DELETEMESSAGE>"
+        "<dataownercode>GVB</dataownercode>"
+        "<messagecodedate>2024-01-15</messagecodedate>"
+        "<messagecodenumber>7</messagecodenumber>"
+        "</DELETEMESSAGE>",
+    ]))
+
+
+def journey(number):
+    return (
+        "<KV17JOURNEY>"
+        "<dataownercode>GVB</dataownercode>"
+        "<lineplanningnumber>22</lineplanningnumber>"
+        "<operatingday>2024-01-15</operatingday>"
+        f"<journeynumber>{number}</journeynumber>"
+        "<reinforcementnumber>0</reinforcementnumber>"
+        "</KV17JOURNEY>"
+    )
+
+
+def kv17_gvb():
+    # Several journeys are sent as consecutive KV17cvlinfo blocks
+    data = push("KV17cvlinfo", "kv17", (
+        journey(3003)
+        + "<KV17MUTATEJOURNEY>"
+        "<timestamp>2024-01-15T09:20:00+01:00</timestamp>"
+        "<CANCEL><reasontype>1</reasontype></CANCEL>"
+        "</KV17MUTATEJOURNEY>"
+    )).decode()
+    second = (
+        "<KV17cvlinfo>"
+        + journey(3002)
+        + "<KV17MUTATEJOURNEYSTOP>"
+        "<timestamp>2024-01-15T09:24:59+01:00</timestamp>"
+        "<userstopcode>30004565</userstopcode>"
+        "<passagesequencenumber>0</passagesequencenumber>"
+        "<SHORTEN/>"
+        "<LAG><lagtime>180</lagtime></LAG>"
+        "</KV17MUTATEJOURNEYSTOP>"
+        "</KV17cvlinfo>"
+    )
+    return data.replace("</VV_TM_PUSH>", second + "</VV_TM_PUSH>").encode()
+
+
+def record(messages):
+    out = bytearray()
+    for i, frames in enumerate(messages):
+        out += struct.pack(">qI", (RECORDED_AT + i) * 10**9, len(frames))
+        for frame in frames:
+            out += struct.pack(">I", len(frame)) + frame
+    return bytes(out)
+
+
+def main():
+    messages = [
+        [b"/GVB/KV6posinfo", gz(kv6_gvb())],
+        [b"/ARR/KV6posinfo", gz(kv6_arr())],
+        [b"/GVB/KV15messages", kv15_gvb()],
+        [b"/GVB/KV17cvlinfo", gz(kv17_gvb())],
+    ]
+    with open(os.path.join(HERE, "ndov.rec"), "wb") as f:
+        f.write(record(messages))
+
+
+if __name__ == "__main__":
+    main()
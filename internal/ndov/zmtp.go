@@ -0,0 +1,180 @@
+package ndov
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// The NDOV Loket publishes over ZeroMQ PUB sockets. This file implements the
+// part of ZMTP 3.0 (https://rfc.zeromq.org/spec/23/) needed to talk to them:
+// the NULL security mechanism, multipart messages and subscriptions sent as
+// messages. Announcing 3.0 keeps peers from expecting 3.1 PING/SUBSCRIBE
+// commands.
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+
+	// maxFrameSize bounds the memory a misbehaving peer can make us allocate.
+	maxFrameSize = 64 << 20
+)
+
+// conn is a ZMTP connection after a completed handshake.
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// handshake exchanges greetings and READY commands announcing socketType.
+func handshake(nc net.Conn, socketType string) (*conn, error) {
+	c := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9] = 0xff, 0x7f
+	greeting[10], greeting[11] = 3, 0
+	copy(greeting[12:32], "NULL")
+	if _, err := c.w.Write(greeting); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(c.r, peer); err != nil {
+		return nil, fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if peer[0] != 0xff || peer[9]&0x01 == 0 || peer[10] < 3 {
+		return nil, errors.New("peer does not speak ZMTP 3")
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return nil, fmt.Errorf("unsupported security mechanism %q", mechanism)
+	}
+
+	if err := c.writeFrame(flagCommand, readyCommand(socketType)); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	flags, body, err := c.readFrame()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read READY: %w", err)
+	}
+	name, _ := commandName(body)
+	if flags&flagCommand == 0 || name != "READY" {
+		return nil, fmt.Errorf("expected READY, got %q", name)
+	}
+	return c, nil
+}
+
+// readyCommand encodes a READY command with the Socket-Type property.
+func readyCommand(socketType string) []byte {
+	var b bytes.Buffer
+	b.WriteByte(5)
+	b.WriteString("READY")
+	b.WriteByte(byte(len("Socket-Type")))
+	b.WriteString("Socket-Type")
+	binary.Write(&b, binary.BigEndian, uint32(len(socketType)))
+	b.WriteString(socketType)
+	return b.Bytes()
+}
+
+func commandName(body []byte) (string, bool) {
+	if len(body) == 0 || int(body[0]) > len(body)-1 {
+		return "", false
+	}
+	return string(body[1 : 1+body[0]]), true
+}
+
+func (c *conn) writeFrame(flags byte, body []byte) error {
+	if len(body) > 255 {
+		flags |= flagLong
+	}
+	if err := c.w.WriteByte(flags); err != nil {
+		return err
+	}
+	if flags&flagLong != 0 {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(body)))
+		c.w.Write(size[:])
+	} else {
+		c.w.WriteByte(byte(len(body)))
+	}
+	_, err := c.w.Write(body)
+	return err
+}
+
+func (c *conn) readFrame() (flags byte, body []byte, err error) {
+	if flags, err = c.r.ReadByte(); err != nil {
+		return 0, nil, err
+	}
+
+	var size uint64
+	if flags&flagLong != 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds limit", size)
+	}
+
+	body = make([]byte, size)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// readMessage returns the next multipart message, skipping commands.
+func (c *conn) readMessage() ([][]byte, error) {
+	var frames [][]byte
+	for {
+		flags, body, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			continue
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// writeMessage sends frames as one multipart message.
+func (c *conn) writeMessage(frames [][]byte) error {
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		if err := c.writeFrame(flags, frame); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) Close() error {
+	return c.nc.Close()
+}
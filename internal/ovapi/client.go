@@ -1,72 +1,93 @@
 package ovapi
 
 import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"time"
+	"context"
+	"strings"
 
-	"github.com/sony/gobreaker"
+	"arrivo-transit-api/internal/upstream"
 )
 
 const (
-	baseURL = "http://v0.ovapi.nl"
+	// DefaultBaseURL is the public OVapi endpoint.
+	DefaultBaseURL = "http://v0.ovapi.nl"
+
+	// maxCodesPerRequest keeps comma-joined TPC URLs at a sane length.
+	maxCodesPerRequest = 50
 )
 
 // Client is a client for the OVapi.
 type Client struct {
-	httpClient *http.Client
-	cb         *gobreaker.CircuitBreaker
+	upstream *upstream.Client
 }
 
-// NewClient creates a new OVapi client.
-func NewClient() *Client {
-	st := gobreaker.Settings{
-		Name:        "OVapi",
-		MaxRequests: 5,
-		Interval:    10 * time.Second,
-		Timeout:     5 * time.Second,
-		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			return counts.ConsecutiveFailures > 3
-		},
+// NewClient creates a new OVapi client. An empty cfg.BaseURL means
+// DefaultBaseURL.
+func NewClient(cfg upstream.Config) *Client {
+	if cfg.Name == "" {
+		cfg.Name = "OVapi"
 	}
-
-	return &Client{
-		httpClient: &http.Client{},
-		cb:         gobreaker.NewCircuitBreaker(st),
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
 	}
+	return &Client{upstream: upstream.New(cfg)}
 }
 
-// GetDepartures fetches departures for a specific stop.
-func (c *Client) GetDepartures(stopCode string) (map[string]interface{}, error) {
-	body, err := c.cb.Execute(func() (interface{}, error) {
-		url := fmt.Sprintf("%s/tpc/%s", baseURL, stopCode)
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
+// Available reports whether requests are let through, i.e. the circuit
+// breaker is not open.
+func (c *Client) Available() bool {
+	return c.upstream.Available()
+}
 
-		resp, err := c.httpClient.Do(req)
+// GetDepartures fetches the passes for one or more timing points. OVapi
+// accepts a comma-separated list, so codes are fetched in batches of
+// maxCodesPerRequest. The result is keyed by TimingPointCode.
+func (c *Client) GetDepartures(ctx context.Context, timingPointCodes ...string) (map[string]TimingPoint, error) {
+	result := make(map[string]TimingPoint, len(timingPointCodes))
+	for start := 0; start < len(timingPointCodes); start += maxCodesPerRequest {
+		end := min(start+maxCodesPerRequest, len(timingPointCodes))
+		batch, err := c.getTimingPoints(ctx, timingPointCodes[start:end])
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-		}
-
-		var data map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-			return nil, err
+		for code, tp := range batch {
+			result[code] = tp
 		}
+	}
+	return result, nil
+}
 
-		return data, nil
-	})
+func (c *Client) getTimingPoints(ctx context.Context, timingPointCodes []string) (map[string]TimingPoint, error) {
+	var data map[string]TimingPoint
+	if err := c.upstream.GetJSON(ctx, "tpc", "/tpc/"+strings.Join(timingPointCodes, ","), &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
 
-	if err != nil {
+// GetLines lists all lines known to OVapi, keyed by line ID
+// ("DataOwnerCode_LinePlanningNumber_LineDirection").
+func (c *Client) GetLines(ctx context.Context) (map[string]Line, error) {
+	var data map[string]Line
+	if err := c.upstream.GetJSON(ctx, "line", "/line/", &data); err != nil {
 		return nil, err
 	}
+	return data, nil
+}
+
+// GetLineActuals fetches the journeys currently on the road for the given
+// line IDs, in batches of maxCodesPerRequest.
+func (c *Client) GetLineActuals(ctx context.Context, lineIDs ...string) (map[string]LineActuals, error) {
+	result := make(map[string]LineActuals, len(lineIDs))
+	for start := 0; start < len(lineIDs); start += maxCodesPerRequest {
+		end := min(start+maxCodesPerRequest, len(lineIDs))
 
-	return body.(map[string]interface{}), nil
-}
\ No newline at end of file
+		var batch map[string]LineActuals
+		if err := c.upstream.GetJSON(ctx, "line", "/line/"+strings.Join(lineIDs[start:end], ","), &batch); err != nil {
+			return nil, err
+		}
+		for id, line := range batch {
+			result[id] = line
+		}
+	}
+	return result, nil
+}
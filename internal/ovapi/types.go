@@ -0,0 +1,214 @@
+package ovapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // OVapi times are local; distroless images ship no zoneinfo
+
+	"arrivo-transit-api/internal/models"
+)
+
+// location is the time zone OVapi reports its (offset-less) times in.
+var location, _ = time.LoadLocation("Europe/Amsterdam")
+
+// LocalTime is an OVapi timestamp such as "2024-01-15T14:32:00", interpreted
+// in Europe/Amsterdam.
+type LocalTime struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Null and empty values leave the
+// time zero.
+func (t *LocalTime) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.ParseInLocation("2006-01-02T15:04:05", s, location)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// TimingPoint is one entry of a /tpc response: the stop and the passes of all
+// journeys that call at it.
+type TimingPoint struct {
+	Stop   TimingPointStop `json:"Stop"`
+	Passes map[string]Pass `json:"Passes"`
+}
+
+// TimingPointStop describes the timing point itself.
+type TimingPointStop struct {
+	TimingPointCode string  `json:"TimingPointCode"`
+	TimingPointName string  `json:"TimingPointName"`
+	TimingPointTown string  `json:"TimingPointTown"`
+	StopAreaCode    string  `json:"StopAreaCode"`
+	Latitude        float64 `json:"Latitude"`
+	Longitude       float64 `json:"Longitude"`
+}
+
+// Line describes a line and direction, as listed by /line/.
+type Line struct {
+	DataOwnerCode      string `json:"DataOwnerCode"`
+	LinePlanningNumber string `json:"LinePlanningNumber"`
+	LinePublicNumber   string `json:"LinePublicNumber"`
+	LineName           string `json:"LineName"`
+	LineDirection      int    `json:"LineDirection"`
+	DestinationName50  string `json:"DestinationName50"`
+	TransportType      string `json:"TransportType"`
+}
+
+// LineActuals is one entry of a /line/{id} response. Actuals holds the
+// current pass of every journey of the line that is on the road; Network
+// lists the timing points of every journey pattern by UserStopOrderNumber.
+type LineActuals struct {
+	Line    Line                              `json:"Line"`
+	Network map[string]map[string]NetworkStop `json:"Network"`
+	Actuals map[string]Pass                   `json:"Actuals"`
+}
+
+// NetworkStop is a timing point on a journey pattern of a line.
+type NetworkStop struct {
+	TimingPointCode     string  `json:"TimingPointCode"`
+	TimingPointName     string  `json:"TimingPointName"`
+	UserStopOrderNumber int     `json:"UserStopOrderNumber"`
+	Latitude            float64 `json:"Latitude"`
+	Longitude           float64 `json:"Longitude"`
+}
+
+// NetworkStop returns the timing point at position order of a journey
+// pattern.
+func (l LineActuals) NetworkStop(journeyPattern Code, order int) (NetworkStop, bool) {
+	stop, ok := l.Network[string(journeyPattern)][strconv.Itoa(order)]
+	return stop, ok
+}
+
+// Code is an identifier OVapi publishes either as a JSON string or as a
+// number, depending on the data owner.
+type Code string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Code) UnmarshalJSON(data []byte) error {
+	*c = Code(strings.Trim(string(data), `"`))
+	if *c == "null" {
+		*c = ""
+	}
+	return nil
+}
+
+// Pass is a journey calling at a timing point, as published by KV78turbo.
+type Pass struct {
+	DataOwnerCode         string    `json:"DataOwnerCode"`
+	OperatorCode          string    `json:"OperatorCode"`
+	OperationDate         string    `json:"OperationDate"`
+	LinePlanningNumber    string    `json:"LinePlanningNumber"`
+	LinePublicNumber      string    `json:"LinePublicNumber"`
+	LineDirection         int       `json:"LineDirection"`
+	JourneyNumber         int       `json:"JourneyNumber"`
+	JourneyPatternCode    Code      `json:"JourneyPatternCode"`
+	UserStopOrderNumber   int       `json:"UserStopOrderNumber"`
+	UserStopCode          string    `json:"UserStopCode"`
+	TimingPointCode       string    `json:"TimingPointCode"`
+	DestinationName50     string    `json:"DestinationName50"`
+	TransportType         string    `json:"TransportType"`
+	TargetArrivalTime     LocalTime `json:"TargetArrivalTime"`
+	TargetDepartureTime   LocalTime `json:"TargetDepartureTime"`
+	ExpectedArrivalTime   LocalTime `json:"ExpectedArrivalTime"`
+	ExpectedDepartureTime LocalTime `json:"ExpectedDepartureTime"`
+	TripStopStatus        string    `json:"TripStopStatus"`
+	LastUpdateTimeStamp   LocalTime `json:"LastUpdateTimeStamp"`
+
+	// Source names the realtime source that reported this version of the
+	// pass. It is not part of the OVapi response.
+	Source string `json:"-"`
+	// Predicted marks expected times filled in by delay propagation rather
+	// than reported by the source.
+	Predicted bool `json:"-"`
+	// Occupancy is the expected crowding when leaving the stop, one of the
+	// models.Occupancy* levels, when a realtime source reports it.
+	Occupancy string `json:"-"`
+	// AssignedStopID is the GTFS stop (quay) the journey calls at instead of
+	// the one of its timing point, when a realtime source reports a platform
+	// change.
+	AssignedStopID string `json:"-"`
+}
+
+// TripStopStatus values published by KV78turbo.
+const (
+	TripStopPlanned  = "PLANNED"
+	TripStopDriving  = "DRIVING"
+	TripStopArrived  = "ARRIVED"
+	TripStopPassed   = "PASSED"
+	TripStopCancel   = "CANCEL"
+	TripStopOffRoute = "OFFROUTE"
+	TripStopUnknown  = "UNKNOWN"
+)
+
+// departureStatuses maps TripStopStatus onto models.Departure statuses.
+var departureStatuses = map[string]string{
+	TripStopPlanned:  models.DepartureScheduled,
+	TripStopDriving:  models.DepartureEnRoute,
+	TripStopArrived:  models.DepartureArrived,
+	TripStopPassed:   models.DepartureDeparted,
+	TripStopCancel:   models.DepartureCancelled,
+	TripStopOffRoute: models.DepartureOffRoute,
+}
+
+// RealtimeTripID returns the journey ID GTFS-NL publishes as
+// trips.realtime_trip_id.
+func (p Pass) RealtimeTripID() string {
+	return fmt.Sprintf("%s:%s:%d", p.DataOwnerCode, p.LinePlanningNumber, p.JourneyNumber)
+}
+
+// Departure maps the pass into our departure model.
+func (p Pass) Departure() models.Departure {
+	status, ok := departureStatuses[p.TripStopStatus]
+	if !ok {
+		status = models.DepartureUnknown
+	}
+
+	d := models.Departure{
+		Line:               p.LinePublicNumber,
+		Destination:        p.DestinationName50,
+		Departure:          p.TargetDepartureTime.Time,
+		ScheduledDeparture: p.TargetDepartureTime.Time,
+		Status:             status,
+		Operator:           p.OperatorCode,
+		TransportType:      p.TransportType,
+		TimingPointCode:    p.TimingPointCode,
+		JourneyNumber:      p.JourneyNumber,
+		Source:             p.Source,
+		ServiceDate:        strings.ReplaceAll(p.OperationDate, "-", ""),
+		Realtime:           true,
+	}
+	if d.Operator == "" {
+		d.Operator = p.DataOwnerCode
+	}
+
+	// Only journeys that are tracked carry a meaningful expected time,
+	// unless it was predicted from the delay at an earlier stop
+	tracked := p.TripStopStatus != TripStopPlanned && p.TripStopStatus != TripStopCancel
+	if (tracked || p.Predicted) && !p.ExpectedDepartureTime.IsZero() {
+		expected := p.ExpectedDepartureTime.Time
+		d.ExpectedDeparture = &expected
+		d.Departure = expected
+		d.Delay = int(expected.Sub(d.ScheduledDeparture).Seconds())
+	}
+	if p.Predicted {
+		d.Predicted = true
+		if d.Status == models.DepartureScheduled {
+			d.Status = models.DepartureEnRoute
+		}
+	}
+	if p.Occupancy != "" {
+		occupancy := p.Occupancy
+		d.Occupancy = &occupancy
+	}
+
+	return d
+}
@@ -0,0 +1,77 @@
+package realtime
+
+import (
+	"strings"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// stopMessageSeverities maps KV15 message priorities onto alert severities.
+var stopMessageSeverities = map[string]string{
+	"PTPROCESS":  models.SeverityWarning,
+	"COMMERCIAL": models.SeverityInfo,
+	"MISC":       models.SeverityInfo,
+}
+
+// stopMessageAlerts converts KV15 stop messages into alerts, so clients get
+// them alongside the alerts of other sources. stops maps TimingPointCodes to
+// GTFS stop IDs and lineRoutes maps "DataOwnerCode:LinePlanningNumber" to
+// GTFS route IDs. A message restricted to lines selects the stop on the
+// routes of those lines; lines without a known route widen the selection to
+// the whole stop. Timing points without a stop are left out.
+func stopMessageAlerts(messages []models.StopMessage, stops map[string]string, lineRoutes map[string][]string) []models.Alert {
+	alerts := make([]models.Alert, 0, len(messages))
+	for _, m := range messages {
+		alert := models.Alert{
+			ID:            "kv15:" + m.ID,
+			Source:        SourceNDOV,
+			Cause:         "UNKNOWN_CAUSE",
+			Effect:        "UNKNOWN_EFFECT",
+			Severity:      models.SeverityUnknown,
+			Header:        m.Text,
+			ActivePeriods: []models.AlertPeriod{{End: m.End}},
+			Informed:      []models.AlertEntity{},
+		}
+		if !m.Start.IsZero() {
+			start := m.Start
+			alert.ActivePeriods[0].Start = &start
+		}
+		if severity, ok := stopMessageSeverities[m.Priority]; ok {
+			alert.Severity = severity
+		}
+
+		var details []string
+		for _, text := range []string{m.Reason, m.Effect, m.Advice} {
+			if text != "" {
+				details = append(details, text)
+			}
+		}
+		alert.Description = strings.Join(details, "\n")
+
+		var routeIDs []string
+		for _, line := range m.Lines {
+			routes, ok := lineRoutes[m.Operator+":"+line]
+			if !ok {
+				routeIDs = nil
+				break
+			}
+			routeIDs = append(routeIDs, routes...)
+		}
+		for _, code := range m.TimingPointCodes {
+			stopID, ok := stops[code]
+			if !ok {
+				continue
+			}
+			if len(routeIDs) == 0 {
+				alert.Informed = append(alert.Informed, models.AlertEntity{StopID: stopID})
+			}
+			for _, routeID := range routeIDs {
+				alert.Informed = append(alert.Informed, models.AlertEntity{RouteID: routeID, StopID: stopID})
+			}
+		}
+		if len(alert.Informed) > 0 {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
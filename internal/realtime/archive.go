@@ -0,0 +1,440 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/store"
+)
+
+const (
+	// maxArchiveBatch keeps a multi-row insert of calls below the Postgres
+	// limit of 65535 parameters.
+	maxArchiveBatch = 4000
+	// maintenanceInterval is how often partitions are created ahead, expired
+	// ones dropped and the punctuality and occupancy of recent days rolled
+	// up.
+	maintenanceInterval = time.Hour
+	// partitionsAhead is how many days of partitions exist beyond today.
+	partitionsAhead = 2
+	// archivedRetention is how long archived calls and positions are
+	// remembered, so each is sent once.
+	archivedRetention = 24 * time.Hour
+	// archiveShutdownTimeout bounds the last flush when the worker stops.
+	archiveShutdownTimeout = 10 * time.Second
+)
+
+// ArchiveConfig tunes the observation archive.
+type ArchiveConfig struct {
+	BatchSize     int           // Rows per insert; a full batch is written right away
+	FlushInterval time.Duration // Longest time an observation waits to be written
+	MaxPending    int           // Observations kept while the database is unavailable; the oldest are dropped beyond this
+	Retention     time.Duration // Age after which day partitions are dropped
+}
+
+// Archive persists the calls and vehicle positions the worker observes. It
+// buffers them so writing never holds up a polling cycle, writes them in
+// batches and keeps the day partitions of the archive tables in place.
+type Archive struct {
+	store store.ObservationStore
+	cfg   ArchiveConfig
+	full  chan struct{}
+
+	mu        sync.Mutex
+	calls     []models.CallObservation
+	positions []models.PositionObservation
+	archived  map[archivedCall]time.Time // Calls sent, by when
+	reported  map[string]time.Time       // Time of the last position sent, by vehicle
+	pruned    time.Time
+}
+
+type archivedCall struct {
+	passKey
+	status string
+}
+
+// NewArchive creates an Archive writing to s.
+func NewArchive(s store.ObservationStore, cfg ArchiveConfig) *Archive {
+	if cfg.BatchSize <= 0 || cfg.BatchSize > maxArchiveBatch {
+		cfg.BatchSize = maxArchiveBatch
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 30 * time.Second
+	}
+	if cfg.MaxPending < cfg.BatchSize {
+		cfg.MaxPending = 100 * cfg.BatchSize
+	}
+	return &Archive{
+		store:    s,
+		cfg:      cfg,
+		full:     make(chan struct{}, 1),
+		archived: make(map[archivedCall]time.Time),
+		reported: make(map[string]time.Time),
+	}
+}
+
+// Run writes buffered observations every FlushInterval, or as soon as a
+// batch is full, and maintains the partitions and rollups, until
+// ctx is cancelled. It then writes what is left.
+func (a *Archive) Run(ctx context.Context) {
+	a.maintainPartitions(ctx)
+	a.rollup(ctx)
+
+	flush := time.NewTicker(a.cfg.FlushInterval)
+	defer flush.Stop()
+	maintenance := time.NewTicker(maintenanceInterval)
+	defer maintenance.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), archiveShutdownTimeout)
+			defer cancel()
+			a.flush(ctx)
+			return
+		case <-flush.C:
+			a.flush(ctx)
+		case <-a.full:
+			a.flush(ctx)
+		case <-maintenance.C:
+			a.maintainPartitions(ctx)
+			a.flush(ctx)
+			a.rollup(ctx)
+		}
+	}
+}
+
+// maintainPartitions creates the partitions from yesterday, for journeys
+// running past midnight, up to partitionsAhead days ahead and drops those
+// past Retention.
+func (a *Archive) maintainPartitions(ctx context.Context) {
+	now := time.Now()
+	if err := a.store.EnsureObservationPartitions(ctx, now.AddDate(0, 0, -1), now.AddDate(0, 0, partitionsAhead)); err != nil {
+		log.Printf("ERROR: Failed to create observation partitions: %v", err)
+	}
+	if a.cfg.Retention <= 0 {
+		return
+	}
+	dropped, err := a.store.DropObservationPartitions(ctx, now.Add(-a.cfg.Retention))
+	if err != nil {
+		log.Printf("ERROR: Failed to drop expired observation partitions: %v", err)
+	}
+	if dropped > 0 {
+		log.Printf("Dropped %d expired observation partitions", dropped)
+	}
+}
+
+// rollup recomputes the punctuality and occupancy rollups of yesterday,
+// which journeys running past midnight may still have added to, and of today
+// so far.
+func (a *Archive) rollup(ctx context.Context) {
+	today := models.NewServiceDay(time.Now()).Date
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		n, err := a.store.RollupPunctuality(ctx, day)
+		if err != nil {
+			log.Printf("ERROR: Failed to roll up punctuality of %s: %v", day.Format("2006-01-02"), err)
+		} else {
+			log.Printf("Rolled up punctuality of %s: %d rollups", day.Format("2006-01-02"), n)
+		}
+
+		n, err = a.store.RollupOccupancy(ctx, day)
+		if err != nil {
+			log.Printf("ERROR: Failed to roll up occupancy of %s: %v", day.Format("2006-01-02"), err)
+		} else {
+			log.Printf("Rolled up occupancy of %s: %d rollups", day.Format("2006-01-02"), n)
+		}
+	}
+}
+
+// AddCalls buffers the calls of journeys that were completed or skipped and
+// were not archived yet.
+func (a *Archive) AddCalls(calls []models.CallObservation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune(time.Now())
+	for _, c := range calls {
+		key := archivedCall{passKey{journeyKey{c.RealtimeTripID, c.ServiceDate}, c.StopSequence}, c.Status}
+		if _, ok := a.archived[key]; ok {
+			continue
+		}
+		a.archived[key] = c.ObservedAt
+		a.calls = append(a.calls, c)
+	}
+	if dropped := len(a.calls) - a.cfg.MaxPending; dropped > 0 {
+		log.Printf("WARN: Observation archive is behind, dropping %d calls", dropped)
+		a.calls = a.calls[dropped:]
+	}
+	a.signal(len(a.calls))
+}
+
+// AddPositions buffers the vehicle positions that were reported since the
+// last ones archived.
+func (a *Archive) AddPositions(positions []models.PositionObservation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, p := range positions {
+		if last, ok := a.reported[p.VehicleID]; ok && !p.ObservedAt.After(last) {
+			continue
+		}
+		a.reported[p.VehicleID] = p.ObservedAt
+		a.positions = append(a.positions, p)
+	}
+	if dropped := len(a.positions) - a.cfg.MaxPending; dropped > 0 {
+		log.Printf("WARN: Observation archive is behind, dropping %d vehicle positions", dropped)
+		a.positions = a.positions[dropped:]
+	}
+	a.signal(len(a.positions))
+}
+
+// signal wakes Run when pending rows fill a batch.
+func (a *Archive) signal(pending int) {
+	if pending < a.cfg.BatchSize {
+		return
+	}
+	select {
+	case a.full <- struct{}{}:
+	default:
+	}
+}
+
+// prune forgets calls and vehicles not seen for archivedRetention.
+func (a *Archive) prune(now time.Time) {
+	if now.Sub(a.pruned) < time.Hour {
+		return
+	}
+	for key, at := range a.archived {
+		if now.Sub(at) > archivedRetention {
+			delete(a.archived, key)
+		}
+	}
+	for vehicle, at := range a.reported {
+		if now.Sub(at) > archivedRetention {
+			delete(a.reported, vehicle)
+		}
+	}
+	a.pruned = now
+}
+
+// flush writes all pending observations in batches. Calls reported more
+// than once are written once, with their latest status, and observations
+// outside the partitions maintainPartitions keeps are skipped. A batch the
+// database rejects is split to drop only the rows it refuses; batches that
+// fail otherwise are put back to be retried with the next flush.
+func (a *Archive) flush(ctx context.Context) {
+	a.mu.Lock()
+	calls, positions := a.calls, a.positions
+	a.calls, a.positions = nil, nil
+	a.mu.Unlock()
+
+	now := time.Now()
+	calls = partitionedCalls(uniqueCalls(calls), now)
+	positions = partitionedPositions(uniquePositions(positions), now)
+
+	var failedCalls []models.CallObservation
+	for start := 0; start < len(calls); start += a.cfg.BatchSize {
+		end := min(start+a.cfg.BatchSize, len(calls))
+		if failed := recordBatch(ctx, calls[start:end], a.store.RecordCalls, "calls"); len(failed) > 0 {
+			failedCalls = append(failed, calls[end:]...)
+			break
+		}
+	}
+	var failedPositions []models.PositionObservation
+	for start := 0; start < len(positions); start += a.cfg.BatchSize {
+		end := min(start+a.cfg.BatchSize, len(positions))
+		if failed := recordBatch(ctx, positions[start:end], a.store.RecordPositions, "vehicle positions"); len(failed) > 0 {
+			failedPositions = append(failed, positions[end:]...)
+			break
+		}
+	}
+
+	if len(failedCalls) == 0 && len(failedPositions) == 0 {
+		return
+	}
+	a.mu.Lock()
+	a.calls = append(failedCalls, a.calls...)
+	a.positions = append(failedPositions, a.positions...)
+	a.mu.Unlock()
+}
+
+// recordBatch writes batch with record and returns the rows to retry. When
+// the store rejects the batch, it is split in halves until the rows it
+// refuses are found and dropped; any other error returns the rows not
+// written yet.
+func recordBatch[T any](ctx context.Context, batch []T, record func(context.Context, []T) error, what string) []T {
+	err := record(ctx, batch)
+	switch {
+	case err == nil:
+		return nil
+	case !errors.Is(err, store.ErrRejected):
+		log.Printf("ERROR: Failed to archive %s: %v", what, err)
+		return batch
+	case len(batch) == 1:
+		log.Printf("ERROR: Dropping %s the archive rejects: %v", what, err)
+		return nil
+	}
+
+	half := len(batch) / 2
+	if failed := recordBatch(ctx, batch[:half], record, what); len(failed) > 0 {
+		return append(failed, batch[half:]...)
+	}
+	return recordBatch(ctx, batch[half:], record, what)
+}
+
+// uniqueCalls keeps the last of the calls with the same service date,
+// journey and stop sequence, as a call that changed status is reported
+// again.
+func uniqueCalls(calls []models.CallObservation) []models.CallObservation {
+	index := make(map[passKey]int, len(calls))
+	unique := calls[:0:0]
+	for _, c := range calls {
+		key := passKey{journeyKey{c.RealtimeTripID, c.ServiceDate}, c.StopSequence}
+		if i, ok := index[key]; ok {
+			unique[i] = c
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, c)
+	}
+	return unique
+}
+
+// uniquePositions keeps the first report of a vehicle at the same time.
+func uniquePositions(positions []models.PositionObservation) []models.PositionObservation {
+	type key struct {
+		vehicleID  string
+		observedAt time.Time
+	}
+	seen := make(map[key]bool, len(positions))
+	unique := positions[:0:0]
+	for _, p := range positions {
+		k := key{p.VehicleID, p.ObservedAt.UTC()}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, p)
+	}
+	return unique
+}
+
+// partitionDays returns the first day and the day after the last day with a
+// partition, as created by maintainPartitions.
+func partitionDays(now time.Time) (from, to time.Time) {
+	now = now.Local()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return today.AddDate(0, 0, -1), today.AddDate(0, 0, partitionsAhead+1)
+}
+
+// partitionedCalls drops calls on service days without a partition.
+func partitionedCalls(calls []models.CallObservation, now time.Time) []models.CallObservation {
+	from, to := partitionDays(now)
+	first, last := from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102")
+
+	kept := calls[:0:0]
+	for _, c := range calls {
+		if c.ServiceDate >= first && c.ServiceDate <= last {
+			kept = append(kept, c)
+		}
+	}
+	if skipped := len(calls) - len(kept); skipped > 0 {
+		log.Printf("WARN: Skipping %d calls on service days outside %s to %s", skipped, first, last)
+	}
+	return kept
+}
+
+// partitionedPositions drops positions reported on days without a
+// partition.
+func partitionedPositions(positions []models.PositionObservation, now time.Time) []models.PositionObservation {
+	from, to := partitionDays(now)
+	first, last := from.Format(time.DateOnly), to.AddDate(0, 0, -1).Format(time.DateOnly)
+
+	kept := positions[:0:0]
+	for _, p := range positions {
+		if !p.ObservedAt.Before(from) && p.ObservedAt.Before(to) {
+			kept = append(kept, p)
+		}
+	}
+	if skipped := len(positions) - len(kept); skipped > 0 {
+		log.Printf("WARN: Skipping %d vehicle positions reported outside %s to %s", skipped, first, last)
+	}
+	return kept
+}
+
+// callObservations returns the calls of all journeys that were completed or
+// skipped, linked to their stop and, when known, GTFS trip and route.
+func (p *journeyPasses) callObservations(tpcStops map[string]string, now time.Time) []models.CallObservation {
+	var calls []models.CallObservation
+	for key, passes := range p.passes {
+		for _, pass := range passes {
+			c := models.CallObservation{
+				ServiceDate:     strings.ReplaceAll(key.operationDate, "-", ""),
+				RealtimeTripID:  key.realtimeTripID,
+				StopSequence:    pass.UserStopOrderNumber,
+				StopID:          tpcStops[pass.TimingPointCode],
+				TimingPointCode: pass.TimingPointCode,
+				Source:          pass.Source,
+				ObservedAt:      now,
+			}
+			switch {
+			case pass.TripStopStatus == ovapi.TripStopPassed:
+				c.Status = models.DepartureDeparted
+				c.ActualArrival = timeOrNil(pass.ExpectedArrivalTime)
+				c.ActualDeparture = timeOrNil(pass.ExpectedDepartureTime)
+			case pass.TripStopStatus == ovapi.TripStopCancel && p.cancelled(pass):
+				c.Status = models.DepartureCancelled
+			case pass.TripStopStatus == ovapi.TripStopCancel:
+				c.Status = models.CallSkipped
+			default:
+				continue
+			}
+			c.ScheduledArrival = timeOrNil(pass.TargetArrivalTime)
+			c.ScheduledDeparture = timeOrNil(pass.TargetDepartureTime)
+			if trip, ok := p.trips[key.journey()]; ok {
+				c.TripID, c.RouteID = trip.ID, trip.RouteID
+			}
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// positionObservations returns the reported positions of vehicles.
+func positionObservations(vehicles []models.Vehicle) []models.PositionObservation {
+	positions := make([]models.PositionObservation, 0, len(vehicles))
+	for _, v := range vehicles {
+		if v.Timestamp.IsZero() {
+			continue
+		}
+		positions = append(positions, models.PositionObservation{
+			ObservedAt: v.Timestamp,
+			VehicleID:  v.ID,
+			TripID:     v.TripID,
+			RouteID:    v.RouteID,
+			Lat:        v.Lat,
+			Lon:        v.Lon,
+			Bearing:    v.Bearing,
+			Speed:      v.Speed,
+			Delay:      v.Delay,
+			Status:     v.Status,
+			StopID:     v.StopID,
+			Source:     v.Source,
+			Occupancy:  v.Occupancy,
+		})
+	}
+	return positions
+}
+
+func timeOrNil(t ovapi.LocalTime) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
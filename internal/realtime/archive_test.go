@@ -0,0 +1,190 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+	"arrivo-transit-api/internal/store/memory"
+)
+
+// archiveStore records what the archive writes and fails like Postgres: a
+// batch repeating a call is rejected, as ON CONFLICT DO UPDATE cannot
+// affect a row twice, and so is a batch with a call at stop sequence 13.
+type archiveStore struct {
+	*memory.Store
+
+	mu          sync.Mutex
+	unavailable bool
+	calls       []models.CallObservation
+	positions   []models.PositionObservation
+}
+
+func newArchiveStore() *archiveStore {
+	return &archiveStore{Store: memory.New()}
+}
+
+func (s *archiveStore) RecordCalls(ctx context.Context, calls []models.CallObservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable {
+		return errors.New("connection refused")
+	}
+	seen := make(map[passKey]bool)
+	for _, c := range calls {
+		key := passKey{journeyKey{c.RealtimeTripID, c.ServiceDate}, c.StopSequence}
+		if seen[key] {
+			return fmt.Errorf("%w: ON CONFLICT DO UPDATE command cannot affect row a second time", store.ErrRejected)
+		}
+		seen[key] = true
+		if c.StopSequence == 13 {
+			return fmt.Errorf("%w: value too long for type character varying(10)", store.ErrRejected)
+		}
+	}
+	s.calls = append(s.calls, calls...)
+	return nil
+}
+
+func (s *archiveStore) RecordPositions(ctx context.Context, positions []models.PositionObservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable {
+		return errors.New("connection refused")
+	}
+	s.positions = append(s.positions, positions...)
+	return nil
+}
+
+func (s *archiveStore) recorded() ([]models.CallObservation, []models.PositionObservation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls, s.positions
+}
+
+func (a *Archive) pending() (calls, positions int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.calls), len(a.positions)
+}
+
+func observedCall(serviceDate string, sequence int, status string) models.CallObservation {
+	return models.CallObservation{
+		ServiceDate:    serviceDate,
+		RealtimeTripID: "GVB:1:100",
+		StopSequence:   sequence,
+		Status:         status,
+		ObservedAt:     time.Now(),
+	}
+}
+
+func TestArchiveWritesRepeatedCallsOnce(t *testing.T) {
+	st := newArchiveStore()
+	a := NewArchive(st, ArchiveConfig{BatchSize: 10})
+	today := time.Now().Format("20060102")
+
+	// The stop was first reported skipped, then the journey made it after all
+	a.AddCalls([]models.CallObservation{observedCall(today, 1, models.CallSkipped), observedCall(today, 2, models.DepartureDeparted)})
+	a.AddCalls([]models.CallObservation{observedCall(today, 1, models.DepartureDeparted)})
+	a.flush(context.Background())
+
+	calls, _ := st.recorded()
+	if len(calls) != 2 || calls[0].StopSequence != 1 || calls[0].Status != models.DepartureDeparted {
+		t.Errorf("recorded %+v, want stop 1 once, departed", calls)
+	}
+	if pending, _ := a.pending(); pending != 0 {
+		t.Errorf("%d calls pending, want none", pending)
+	}
+}
+
+func TestArchiveSkipsObservationsOutsidePartitions(t *testing.T) {
+	st := newArchiveStore()
+	a := NewArchive(st, ArchiveConfig{BatchSize: 10})
+	now := time.Now()
+	day := func(days int) string { return now.AddDate(0, 0, days).Format("20060102") }
+
+	a.AddCalls([]models.CallObservation{
+		observedCall(day(-10), 1, models.DepartureDeparted),
+		observedCall(day(-1), 1, models.DepartureDeparted),
+		observedCall(day(partitionsAhead), 1, models.DepartureDeparted),
+		observedCall(day(partitionsAhead+1), 1, models.DepartureDeparted),
+	})
+	a.AddPositions([]models.PositionObservation{
+		{VehicleID: "old", ObservedAt: now.AddDate(0, 0, -3)},
+		{VehicleID: "current", ObservedAt: now},
+		{VehicleID: "clock-skew", ObservedAt: now.AddDate(0, 0, partitionsAhead+2)},
+	})
+	a.flush(context.Background())
+
+	calls, positions := st.recorded()
+	if len(calls) != 2 || calls[0].ServiceDate != day(-1) || calls[1].ServiceDate != day(partitionsAhead) {
+		t.Errorf("recorded calls %+v, want those of yesterday and %d days ahead", calls, partitionsAhead)
+	}
+	if len(positions) != 1 || positions[0].VehicleID != "current" {
+		t.Errorf("recorded positions %+v, want the current one", positions)
+	}
+	if pendingCalls, pendingPositions := a.pending(); pendingCalls != 0 || pendingPositions != 0 {
+		t.Errorf("%d calls and %d positions pending, want none", pendingCalls, pendingPositions)
+	}
+}
+
+func TestArchiveDropsRejectedRows(t *testing.T) {
+	st := newArchiveStore()
+	a := NewArchive(st, ArchiveConfig{BatchSize: 8})
+	today := time.Now().Format("20060102")
+
+	var observed []models.CallObservation
+	for sequence := 10; sequence < 20; sequence++ {
+		observed = append(observed, observedCall(today, sequence, models.DepartureDeparted))
+	}
+	a.AddCalls(observed)
+	a.flush(context.Background())
+
+	calls, _ := st.recorded()
+	if len(calls) != 9 {
+		t.Fatalf("recorded %d calls, want all but the rejected one", len(calls))
+	}
+	for _, c := range calls {
+		if c.StopSequence == 13 {
+			t.Errorf("recorded the rejected call")
+		}
+	}
+	if pending, _ := a.pending(); pending != 0 {
+		t.Errorf("%d calls pending, want the rejected call dropped", pending)
+	}
+}
+
+func TestArchiveRetriesWhenUnavailable(t *testing.T) {
+	st := newArchiveStore()
+	st.unavailable = true
+	a := NewArchive(st, ArchiveConfig{BatchSize: 2})
+	today := time.Now().Format("20060102")
+
+	a.AddCalls([]models.CallObservation{
+		observedCall(today, 1, models.DepartureDeparted),
+		observedCall(today, 2, models.DepartureDeparted),
+		observedCall(today, 3, models.DepartureDeparted),
+	})
+	a.AddPositions([]models.PositionObservation{{VehicleID: "GVB:1:100", ObservedAt: time.Now()}})
+	a.flush(context.Background())
+	if calls, positions := a.pending(); calls != 3 || positions != 1 {
+		t.Fatalf("%d calls and %d positions pending, want all kept for a retry", calls, positions)
+	}
+
+	st.mu.Lock()
+	st.unavailable = false
+	st.mu.Unlock()
+	a.flush(context.Background())
+
+	calls, positions := st.recorded()
+	if len(calls) != 3 || len(positions) != 1 {
+		t.Errorf("recorded %d calls and %d positions, want 3 and 1", len(calls), len(positions))
+	}
+	if pendingCalls, pendingPositions := a.pending(); pendingCalls != 0 || pendingPositions != 0 {
+		t.Errorf("%d calls and %d positions pending, want none", pendingCalls, pendingPositions)
+	}
+}
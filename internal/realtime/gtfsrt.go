@@ -0,0 +1,296 @@
+package realtime
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+// gtfsrtMaxAge is how long the last snapshot of a feed is used when fetching
+// it keeps failing.
+const gtfsrtMaxAge = 5 * time.Minute
+
+// GTFSRT holds the latest GTFS-Realtime trip updates, vehicle positions and
+// alerts of every configured feed, already joined to the static GTFS trips.
+// It is the Provider for GTFS-Realtime.
+type GTFSRT struct {
+	mu    sync.RWMutex
+	feeds map[string]*gtfsrtSnapshot // By feed URL
+}
+
+// gtfsrtSnapshot is the joined content of one fetch of one feed.
+type gtfsrtSnapshot struct {
+	fetched       time.Time
+	tripUpdates   map[string]models.TripUpdate // By GTFS trip ID
+	realtimeTrips map[string]string            // GTFS trip ID by realtime trip ID
+	vehicles      []models.Vehicle
+	alerts        []models.Alert
+}
+
+// NewGTFSRT creates an empty GTFS-Realtime state.
+func NewGTFSRT() *GTFSRT {
+	return &GTFSRT{feeds: make(map[string]*gtfsrtSnapshot)}
+}
+
+// set replaces the snapshot of a feed.
+func (g *GTFSRT) set(url string, snapshot *gtfsrtSnapshot) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.feeds[url] = snapshot
+}
+
+// Source implements Provider.
+func (g *GTFSRT) Source() string {
+	return SourceGTFSRT
+}
+
+// Available implements Provider. GTFS-Realtime is available while some feed
+// has a snapshot younger than gtfsrtMaxAge.
+func (g *GTFSRT) Available() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, snapshot := range g.feeds {
+		if time.Since(snapshot.fetched) <= gtfsrtMaxAge {
+			return true
+		}
+	}
+	return false
+}
+
+// Departures implements Provider. Trip updates only cover journeys, they do
+// not list passes per timing point.
+func (g *GTFSRT) Departures(ctx context.Context, timingPointCodes []string) (map[string][]ovapi.Pass, error) {
+	return nil, nil
+}
+
+func (g *GTFSRT) updatesPassesOnly() {}
+
+// tripUpdate returns the update of the trip a pass belongs to, if any feed
+// has one.
+func (g *GTFSRT) tripUpdate(realtimeTripID string) (models.TripUpdate, bool) {
+	for _, snapshot := range g.feeds {
+		if tripID, ok := snapshot.realtimeTrips[realtimeTripID]; ok {
+			return snapshot.tripUpdates[tripID], true
+		}
+	}
+	return models.TripUpdate{}, false
+}
+
+// UpdatePass implements Provider with the trip update of the pass's
+// journey: cancelled trips and skipped stops cancel the pass, otherwise the
+// expected times follow the update for the pass's stop, or the delay of the
+// last earlier stop, and the occupancy and platform follow the update for
+// the stop.
+func (g *GTFSRT) UpdatePass(p ovapi.Pass, stops map[string]string) (ovapi.Pass, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	tu, ok := g.tripUpdate(p.RealtimeTripID())
+	if !ok || (tu.StartDate != "" && tu.StartDate != strings.ReplaceAll(p.OperationDate, "-", "")) {
+		return p, false
+	}
+	p.LastUpdateTimeStamp = ovapi.LocalTime{Time: tu.Timestamp}
+
+	if tu.Relationship == models.TripCanceled {
+		p.TripStopStatus = ovapi.TripStopCancel
+		return p, true
+	}
+	switch p.TripStopStatus {
+	case ovapi.TripStopPlanned, ovapi.TripStopDriving, ovapi.TripStopUnknown:
+	default:
+		return p, false
+	}
+
+	p.Occupancy, p.AssignedStopID = stopProperties(tu, stopID(stops, p.TimingPointCode))
+	arrivalDelay, departureDelay, ok := passDelays(tu, stopID(stops, p.TimingPointCode), &p)
+	if !ok {
+		return p, false
+	}
+	if arrivalDelay == nil && departureDelay == nil {
+		// Skipped stop
+		p.TripStopStatus = ovapi.TripStopCancel
+		return p, true
+	}
+	if arrivalDelay == nil {
+		arrivalDelay = departureDelay
+	}
+	if departureDelay == nil {
+		departureDelay = arrivalDelay
+	}
+
+	if !p.TargetArrivalTime.IsZero() {
+		p.ExpectedArrivalTime = ovapi.LocalTime{Time: p.TargetArrivalTime.Add(time.Duration(*arrivalDelay) * time.Second)}
+	}
+	if !p.TargetDepartureTime.IsZero() {
+		p.ExpectedDepartureTime = ovapi.LocalTime{Time: p.TargetDepartureTime.Add(time.Duration(*departureDelay) * time.Second)}
+	}
+	p.TripStopStatus = ovapi.TripStopDriving
+	return p, true
+}
+
+// passDelays returns the arrival and departure delay of a pass at stopID in
+// seconds. Both are nil when the stop is skipped; ok is false when the update
+// says nothing about the stop.
+func passDelays(tu models.TripUpdate, stopID *string, p *ovapi.Pass) (arrival, departure *int, ok bool) {
+	if stopID != nil {
+		for _, stu := range tu.StopTimeUpdates {
+			if stu.StopID != *stopID {
+				continue
+			}
+			switch stu.Relationship {
+			case models.StopSkipped:
+				return nil, nil, true
+			case models.StopNoData:
+				return nil, nil, false
+			}
+			arrival = eventDelay(stu.ArrivalDelay, stu.ArrivalTime, p.TargetArrivalTime.Time)
+			departure = eventDelay(stu.DepartureDelay, stu.DepartureTime, p.TargetDepartureTime.Time)
+			return arrival, departure, arrival != nil || departure != nil
+		}
+	}
+
+	// Propagate the delay of the last stop before this one, which is the
+	// latest update scheduled before the pass
+	target := p.TargetDepartureTime.Time
+	if target.IsZero() {
+		target = p.TargetArrivalTime.Time
+	}
+	var latest time.Time
+	var delay *int
+	for _, stu := range tu.StopTimeUpdates {
+		if stu.Relationship != models.StopScheduled {
+			continue
+		}
+		for _, event := range []struct {
+			delay *int
+			at    *time.Time
+		}{{stu.ArrivalDelay, stu.ArrivalTime}, {stu.DepartureDelay, stu.DepartureTime}} {
+			if event.delay == nil || event.at == nil {
+				continue
+			}
+			scheduled := event.at.Add(-time.Duration(*event.delay) * time.Second)
+			if !scheduled.After(target) && !scheduled.Before(latest) {
+				latest, delay = scheduled, event.delay
+			}
+		}
+	}
+	if delay == nil {
+		delay = tu.Delay
+	}
+	return delay, delay, delay != nil
+}
+
+// stopProperties returns the occupancy a trip update expects when leaving
+// stopID and the stop it assigns the trip to instead, or "" for either.
+func stopProperties(tu models.TripUpdate, stopID *string) (occupancy, assignedStopID string) {
+	if stopID == nil {
+		return "", ""
+	}
+	for _, stu := range tu.StopTimeUpdates {
+		if stu.StopID != *stopID {
+			continue
+		}
+		if stu.Occupancy != nil {
+			occupancy = *stu.Occupancy
+		}
+		if stu.AssignedStopID != *stopID {
+			assignedStopID = stu.AssignedStopID
+		}
+		return occupancy, assignedStopID
+	}
+	return "", ""
+}
+
+// eventDelay returns the delay of a stop time event against the pass's
+// target time.
+func eventDelay(delay *int, at *time.Time, target time.Time) *int {
+	if delay != nil {
+		return delay
+	}
+	if at == nil || target.IsZero() {
+		return nil
+	}
+	d := int(at.Sub(target).Seconds())
+	return &d
+}
+
+// Vehicles implements Provider with the recent positions of all feeds.
+func (g *GTFSRT) Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var vehicles []models.Vehicle
+	for _, snapshot := range g.feeds {
+		for _, v := range snapshot.vehicles {
+			if now.Sub(v.Timestamp) <= positionMaxAge {
+				vehicles = append(vehicles, v)
+			}
+		}
+	}
+	return vehicles, nil
+}
+
+// TripUpdates returns the trip updates of all feeds ordered by trip ID.
+func (g *GTFSRT) TripUpdates() []models.TripUpdate {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	updates := []models.TripUpdate{}
+	for _, snapshot := range g.feeds {
+		for _, tu := range snapshot.tripUpdates {
+			updates = append(updates, tu)
+		}
+	}
+	sort.Slice(updates, func(a, b int) bool { return updates[a].TripID < updates[b].TripID })
+	return updates
+}
+
+// Alerts implements Provider with the alerts of all feeds that have not
+// ended, ordered by ID.
+func (g *GTFSRT) Alerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	alerts := []models.Alert{}
+	for _, snapshot := range g.feeds {
+		for _, a := range snapshot.alerts {
+			if !alertEnded(a, now) {
+				alerts = append(alerts, a)
+			}
+		}
+	}
+	sort.Slice(alerts, func(a, b int) bool { return alerts[a].ID < alerts[b].ID })
+	return alerts, nil
+}
+
+// alertEnded reports whether all active periods of an alert lie in the past.
+func alertEnded(a models.Alert, now time.Time) bool {
+	if len(a.ActivePeriods) == 0 {
+		return false
+	}
+	for _, period := range a.ActivePeriods {
+		if period.End == nil || period.End.After(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Expire drops the snapshots of feeds that have not been fetched for
+// gtfsrtMaxAge, so a feed that went down stops overriding other sources.
+func (g *GTFSRT) Expire(now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for url, snapshot := range g.feeds {
+		if now.Sub(snapshot.fetched) > gtfsrtMaxAge {
+			delete(g.feeds, url)
+		}
+	}
+}
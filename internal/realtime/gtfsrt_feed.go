@@ -0,0 +1,321 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"arrivo-transit-api/internal/gtfsrt"
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+)
+
+// gtfsrtLanguage is the preferred language of alert texts.
+const gtfsrtLanguage = "nl"
+
+// GTFSRTFeed polls GTFS-Realtime feeds, joins their entities to the static
+// GTFS trips and stores the result in GTFSRT.
+type GTFSRTFeed struct {
+	client   *gtfsrt.Client
+	trips    store.TripStore
+	urls     []string
+	interval time.Duration
+	state    *GTFSRT
+}
+
+// NewGTFSRTFeed creates a poller for the feeds at urls. A feed may carry any
+// mix of trip updates, vehicle positions and alerts.
+func NewGTFSRTFeed(client *gtfsrt.Client, trips store.TripStore, urls []string, interval time.Duration, state *GTFSRT) *GTFSRTFeed {
+	return &GTFSRTFeed{client: client, trips: trips, urls: urls, interval: interval, state: state}
+}
+
+// Run polls every feed each interval until ctx is cancelled. A feed that
+// fails keeps its previous snapshot until GTFSRT.Expire drops it.
+func (f *GTFSRTFeed) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, url := range f.urls {
+			if err := f.poll(ctx, url); err != nil && ctx.Err() == nil {
+				log.Printf("ERROR: Failed to poll GTFS-Realtime feed %s: %v", url, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll fetches one feed and replaces its snapshot.
+func (f *GTFSRTFeed) poll(ctx context.Context, url string) error {
+	feed, err := f.client.Fetch(ctx, url)
+	if err != nil {
+		return err
+	}
+	if feed.Header.Incrementality != gtfsrt.FullDataset {
+		return fmt.Errorf("differential feeds are not supported")
+	}
+
+	snapshot, err := f.join(ctx, feed)
+	if err != nil {
+		return err
+	}
+	f.state.set(url, snapshot)
+	return nil
+}
+
+// join converts the entities of a feed and resolves their trips. Trip
+// updates and vehicles of trips missing from the static GTFS are dropped,
+// unless the trip was added by the feed.
+func (f *GTFSRTFeed) join(ctx context.Context, feed *gtfsrt.FeedMessage) (*gtfsrtSnapshot, error) {
+	var tripIDs []string
+	for _, e := range feed.Entities {
+		switch {
+		case e.IsDeleted:
+		case e.TripUpdate != nil:
+			tripIDs = append(tripIDs, e.TripUpdate.Trip.TripID)
+		case e.Vehicle != nil && e.Vehicle.Trip != nil:
+			tripIDs = append(tripIDs, e.Vehicle.Trip.TripID)
+		}
+	}
+	trips, err := f.trips.TripsByID(ctx, tripIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve trips: %w", err)
+	}
+
+	headerTime := time.Unix(int64(feed.Header.Timestamp), 0)
+	snapshot := &gtfsrtSnapshot{
+		fetched:       time.Now(),
+		tripUpdates:   make(map[string]models.TripUpdate),
+		realtimeTrips: make(map[string]string),
+	}
+	unknown := 0
+	for _, e := range feed.Entities {
+		if e.IsDeleted {
+			continue
+		}
+
+		if e.TripUpdate != nil {
+			trip, ok := trips[e.TripUpdate.Trip.TripID]
+			if !ok && e.TripUpdate.Trip.ScheduleRelationship != gtfsrt.TripAdded {
+				unknown++
+			} else {
+				tu, err := f.tripUpdate(ctx, e.TripUpdate, trip, headerTime)
+				if err != nil {
+					return nil, err
+				}
+				snapshot.tripUpdates[tu.TripID] = tu
+				if trip.RealtimeID != nil {
+					snapshot.realtimeTrips[*trip.RealtimeID] = tu.TripID
+				}
+			}
+		}
+
+		if e.Vehicle != nil && e.Vehicle.Position != nil {
+			if v, ok := gtfsrtVehicle(e.ID, e.Vehicle, trips, headerTime); ok {
+				snapshot.vehicles = append(snapshot.vehicles, v)
+			}
+		}
+
+		if e.Alert != nil {
+			snapshot.alerts = append(snapshot.alerts, gtfsrtAlert(e.ID, e.Alert))
+		}
+	}
+	if unknown > 0 {
+		log.Printf("WARN: Skipped %d GTFS-Realtime trip updates of unknown trips", unknown)
+	}
+
+	return snapshot, nil
+}
+
+// tripUpdate converts a trip update. Stop time updates that only give a
+// stop_sequence get their stop ID from the trip's stop times.
+func (f *GTFSRTFeed) tripUpdate(ctx context.Context, u *gtfsrt.TripUpdate, trip models.Trip, headerTime time.Time) (models.TripUpdate, error) {
+	tu := models.TripUpdate{
+		TripID:          u.Trip.TripID,
+		RouteID:         u.Trip.RouteID,
+		StartDate:       u.Trip.StartDate,
+		Relationship:    tripRelationship(u.Trip.ScheduleRelationship),
+		Timestamp:       headerTime,
+		StopTimeUpdates: make([]models.StopTimeUpdate, 0, len(u.StopTimeUpdates)),
+	}
+	if tu.RouteID == "" {
+		tu.RouteID = trip.RouteID
+	}
+	if u.Vehicle != nil {
+		tu.VehicleID = u.Vehicle.ID
+	}
+	if u.Delay != nil {
+		delay := int(*u.Delay)
+		tu.Delay = &delay
+	}
+	if u.Timestamp != 0 {
+		tu.Timestamp = time.Unix(int64(u.Timestamp), 0)
+	}
+
+	var stopsBySequence map[int]string
+	for _, s := range u.StopTimeUpdates {
+		stu := models.StopTimeUpdate{StopID: s.StopID, Relationship: stopRelationship(s.ScheduleRelationship), AssignedStopID: s.AssignedStopID}
+		if s.StopSequence != nil {
+			sequence := int(*s.StopSequence)
+			stu.StopSequence = &sequence
+		}
+		stu.ArrivalDelay, stu.ArrivalTime = stopTimeEvent(s.Arrival)
+		stu.DepartureDelay, stu.DepartureTime = stopTimeEvent(s.Departure)
+		if s.DepartureOccupancyStatus != nil && *s.DepartureOccupancyStatus >= 0 && *s.DepartureOccupancyStatus < len(gtfsrt.OccupancyStatus) {
+			occupancy := gtfsrt.OccupancyStatus[*s.DepartureOccupancyStatus]
+			stu.Occupancy = &occupancy
+		}
+
+		if stu.StopID == "" && stu.StopSequence != nil && trip.ID != "" {
+			if stopsBySequence == nil {
+				stopTimes, err := f.trips.StopTimes(ctx, trip.ID)
+				if err != nil {
+					return tu, fmt.Errorf("failed to get stop times of trip %s: %w", trip.ID, err)
+				}
+				stopsBySequence = make(map[int]string, len(stopTimes))
+				for _, st := range stopTimes {
+					stopsBySequence[st.StopSequence] = st.StopID
+				}
+			}
+			stu.StopID = stopsBySequence[*stu.StopSequence]
+		}
+
+		tu.StopTimeUpdates = append(tu.StopTimeUpdates, stu)
+	}
+	return tu, nil
+}
+
+func stopTimeEvent(e *gtfsrt.StopTimeEvent) (*int, *time.Time) {
+	if e == nil {
+		return nil, nil
+	}
+	var delay *int
+	var at *time.Time
+	if e.Delay != nil {
+		d := int(*e.Delay)
+		delay = &d
+	}
+	if e.Time != nil && *e.Time != 0 {
+		t := time.Unix(*e.Time, 0)
+		at = &t
+	}
+	return delay, at
+}
+
+func tripRelationship(r int) string {
+	switch r {
+	case gtfsrt.TripAdded:
+		return models.TripAdded
+	case gtfsrt.TripUnscheduled:
+		return models.TripUnscheduled
+	case gtfsrt.TripCanceled, gtfsrt.TripDeleted:
+		return models.TripCanceled
+	}
+	return models.TripScheduled
+}
+
+func stopRelationship(r int) string {
+	switch r {
+	case gtfsrt.StopSkipped:
+		return models.StopSkipped
+	case gtfsrt.StopNoData:
+		return models.StopNoData
+	}
+	return models.StopScheduled
+}
+
+// gtfsrtVehicle converts a vehicle position. Vehicles on a trip with a
+// realtime ID use it as their ID, so they merge with the same journey from
+// OVapi and NDOV; others are prefixed with the entity ID.
+func gtfsrtVehicle(entityID string, p *gtfsrt.VehiclePosition, trips map[string]models.Trip, headerTime time.Time) (models.Vehicle, bool) {
+	if p.Position.Latitude == 0 && p.Position.Longitude == 0 {
+		return models.Vehicle{}, false
+	}
+
+	v := models.Vehicle{
+		ID:        "gtfsrt:" + entityID,
+		Lat:       float64(p.Position.Latitude),
+		Lon:       float64(p.Position.Longitude),
+		Timestamp: headerTime,
+		Status:    models.VehicleInTransit,
+	}
+	if p.Timestamp != 0 {
+		v.Timestamp = time.Unix(int64(p.Timestamp), 0)
+	}
+	if p.Trip != nil {
+		v.TripID, v.RouteID, v.ServiceDate = p.Trip.TripID, p.Trip.RouteID, p.Trip.StartDate
+		if trip, ok := trips[p.Trip.TripID]; ok {
+			v.RouteID = trip.RouteID
+			if trip.RealtimeID != nil {
+				v.ID = *trip.RealtimeID
+			}
+		}
+	}
+	if p.Position.Bearing != nil {
+		bearing := float64(*p.Position.Bearing)
+		v.Bearing = &bearing
+	}
+	if p.Position.Speed != nil {
+		speed := float64(*p.Position.Speed) * 3.6
+		v.Speed = &speed
+	}
+	if p.StopID != "" {
+		stopID := p.StopID
+		v.StopID = &stopID
+	}
+	switch p.CurrentStatus {
+	case gtfsrt.IncomingAt:
+		v.Status = models.VehicleIncomingAt
+	case gtfsrt.StoppedAt:
+		v.Status = models.VehicleStoppedAt
+		v.LastStopID = v.StopID
+	}
+	if p.OccupancyStatus != nil && *p.OccupancyStatus >= 0 && *p.OccupancyStatus < len(gtfsrt.OccupancyStatus) {
+		occupancy := gtfsrt.OccupancyStatus[*p.OccupancyStatus]
+		v.Occupancy = &occupancy
+	}
+	return v, true
+}
+
+// gtfsrtAlert converts an alert, preferring Dutch texts.
+func gtfsrtAlert(entityID string, a *gtfsrt.Alert) models.Alert {
+	alert := models.Alert{
+		ID:          entityID,
+		Cause:       a.CauseName(),
+		Effect:      a.EffectName(),
+		Severity:    a.SeverityName(),
+		Header:      a.HeaderText.Text(gtfsrtLanguage),
+		Description: a.DescriptionText.Text(gtfsrtLanguage),
+		URL:         a.URL.Text(gtfsrtLanguage),
+		Informed:    []models.AlertEntity{},
+	}
+	for _, r := range a.ActivePeriods {
+		var period models.AlertPeriod
+		if r.Start != 0 {
+			start := time.Unix(int64(r.Start), 0)
+			period.Start = &start
+		}
+		if r.End != 0 {
+			end := time.Unix(int64(r.End), 0)
+			period.End = &end
+		}
+		alert.ActivePeriods = append(alert.ActivePeriods, period)
+	}
+	for _, s := range a.InformedEntities {
+		entity := models.AlertEntity{AgencyID: s.AgencyID, RouteID: s.RouteID, StopID: s.StopID}
+		if s.Trip != nil {
+			entity.TripID = s.Trip.TripID
+			if entity.RouteID == "" {
+				entity.RouteID = s.Trip.RouteID
+			}
+		}
+		alert.Informed = append(alert.Informed, entity)
+	}
+	return alert
+}
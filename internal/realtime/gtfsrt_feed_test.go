@@ -0,0 +1,282 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/gtfsrt"
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/store/memory"
+)
+
+// recordedAt is the header time of the recorded feeds in
+// ../gtfsrt/testdata.
+var recordedAt = time.Unix(1705307100, 0)
+
+// serveRecordings serves the recorded feeds of ../gtfsrt/testdata by file
+// name, like gtfsrt-replay serve.
+func serveRecordings(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := os.ReadFile(path.Join("../gtfsrt/testdata", path.Base(r.URL.Path)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// recordedStatic returns the static GTFS the recorded feeds refer to: GVB
+// journey 1045 of line 22 (trip 161565793, route 72) on 15 January 2024,
+// calling at stops 2334564, 2334570 and 2334580. Stop 2334565 is another
+// platform of the first stop; trip 161565800 is cancelled in the feed.
+func recordedStatic() *memory.Store {
+	st := memory.New()
+	for i, id := range []string{"2334564", "2334565", "2334570", "2334580"} {
+		st.PutStop(models.Stop{ID: id, Name: "Centraal Station", PlatformCode: string(rune('A' + i))})
+		st.PutTimingPoint(id, "3000"+id[3:])
+	}
+	st.PutRoute(models.Route{ID: "72", Type: models.RouteTypeTram})
+	for _, id := range []string{"161565793", "161565800"} {
+		realtimeID := "GVB:22:1045"
+		if id == "161565800" {
+			realtimeID = "GVB:22:1047"
+		}
+		st.PutTrip(models.Trip{ID: id, RouteID: "72", ServiceID: "weekday", RealtimeID: &realtimeID})
+	}
+	st.SetServiceDate("weekday", "20240115", true)
+	for i, stopID := range []string{"2334564", "2334570", "2334580"} {
+		st.PutStopTime(models.StopTime{TripID: "161565793", StopID: stopID, StopSequence: i + 3})
+	}
+	return st
+}
+
+// pollRecordings fetches the named recorded feeds through a GTFSRTFeed and
+// returns the resulting state.
+func pollRecordings(t *testing.T, st *memory.Store, names ...string) *GTFSRT {
+	t.Helper()
+	srv := serveRecordings(t)
+	state := NewGTFSRT()
+	feed := NewGTFSRTFeed(gtfsrt.NewClient(), st, nil, time.Minute, state)
+	for _, name := range names {
+		if err := feed.poll(context.Background(), srv.URL+"/"+name); err != nil {
+			t.Fatalf("poll %s: %v", name, err)
+		}
+	}
+	return state
+}
+
+// readStored decodes the snapshot the worker stored under key into v.
+func readStored(t *testing.T, rt *memory.Realtime, key string, v interface{}) {
+	t.Helper()
+	data, err := rt.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("get %s: %v", key, err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(snapshot.Data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestWorker(merger *Merger, st *memory.Store, rt *memory.Realtime) *Worker {
+	return NewWorker(merger, st, rt, WorkerConfig{
+		Interval:     time.Minute,
+		DepartureTTL: time.Minute,
+		LineTTL:      time.Minute,
+		MaxStaleness: 5 * time.Minute,
+	})
+}
+
+// listedPasses is a Provider listing fixed passes, like OVapi.
+type listedPasses map[string][]ovapi.Pass
+
+func (p listedPasses) Source() string  { return SourceOVapi }
+func (p listedPasses) Available() bool { return true }
+
+func (p listedPasses) Departures(ctx context.Context, timingPointCodes []string) (map[string][]ovapi.Pass, error) {
+	passes := make(map[string][]ovapi.Pass)
+	for _, code := range timingPointCodes {
+		passes[code] = p[code]
+	}
+	return passes, nil
+}
+
+func (p listedPasses) UpdatePass(pass ovapi.Pass, stops map[string]string) (ovapi.Pass, bool) {
+	return ovapi.Pass{}, false
+}
+
+func (p listedPasses) Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error) {
+	return nil, nil
+}
+
+func (p listedPasses) Alerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	return nil, nil
+}
+
+func TestGTFSRTFeedStoresTripUpdates(t *testing.T) {
+	st := recordedStatic()
+	state := pollRecordings(t, st, "trip-updates.pb")
+	rt := memory.NewRealtime()
+	newTestWorker(NewMerger(listedPasses{}, state), st, rt).Cycle(context.Background(), time.Now())
+
+	var updates []models.TripUpdate
+	readStored(t, rt, TripUpdatesKey, &updates)
+	if len(updates) != 2 {
+		t.Fatalf("got %d trip updates, want 2: %+v", len(updates), updates)
+	}
+
+	tu := updates[0]
+	if tu.TripID != "161565793" || tu.RouteID != "72" || tu.StartDate != "20240115" || tu.Relationship != models.TripScheduled {
+		t.Errorf("trip update = %+v", tu)
+	}
+	if tu.Delay == nil || *tu.Delay != 60 || !tu.Timestamp.Equal(time.Unix(1705307090, 0)) {
+		t.Errorf("delay = %v, timestamp = %s", tu.Delay, tu.Timestamp)
+	}
+	if len(tu.StopTimeUpdates) != 3 {
+		t.Fatalf("got %d stop time updates, want 3", len(tu.StopTimeUpdates))
+	}
+	first := tu.StopTimeUpdates[0]
+	if first.StopID != "2334564" || first.AssignedStopID != "2334565" {
+		t.Errorf("stop = %s assigned to %q, want 2334564 assigned to 2334565", first.StopID, first.AssignedStopID)
+	}
+	if first.Occupancy == nil || *first.Occupancy != models.OccupancyFewSeatsAvailable {
+		t.Errorf("occupancy = %v, want FEW_SEATS_AVAILABLE", first.Occupancy)
+	}
+	if first.DepartureDelay == nil || *first.DepartureDelay != 60 || first.DepartureTime == nil || !first.DepartureTime.Equal(time.Unix(1705307600, 0)) {
+		t.Errorf("departure = %v at %v, want 60s late at 1705307600", first.DepartureDelay, first.DepartureTime)
+	}
+	if tu.StopTimeUpdates[1].Relationship != models.StopSkipped {
+		t.Errorf("second stop = %+v, want skipped", tu.StopTimeUpdates[1])
+	}
+	if early := tu.StopTimeUpdates[2]; early.ArrivalDelay == nil || *early.ArrivalDelay != -30 {
+		t.Errorf("third stop arrival delay = %v, want -30", early.ArrivalDelay)
+	}
+
+	if cancelled := updates[1]; cancelled.TripID != "161565800" || cancelled.Relationship != models.TripCanceled {
+		t.Errorf("second trip update = %+v, want 161565800 cancelled", cancelled)
+	}
+}
+
+func TestGTFSRTFeedUpdatesListedPasses(t *testing.T) {
+	st := recordedStatic()
+	state := pollRecordings(t, st, "trip-updates.pb")
+
+	// OVapi lists the journey as planned at the first stop, before the
+	// trip update was recorded
+	target := time.Unix(1705307540, 0)
+	listed := listedPasses{"30004564": {{
+		DataOwnerCode:       "GVB",
+		OperationDate:       "2024-01-15",
+		LinePlanningNumber:  "22",
+		LinePublicNumber:    "22",
+		JourneyNumber:       1045,
+		UserStopOrderNumber: 3,
+		TimingPointCode:     "30004564",
+		TargetArrivalTime:   ovapi.LocalTime{Time: target},
+		TargetDepartureTime: ovapi.LocalTime{Time: target},
+		TripStopStatus:      ovapi.TripStopPlanned,
+		LastUpdateTimeStamp: ovapi.LocalTime{Time: target.Add(-10 * time.Minute)},
+	}}}
+	rt := memory.NewRealtime()
+	newTestWorker(NewMerger(listed, state), st, rt).Cycle(context.Background(), time.Now())
+
+	var departures []models.Departure
+	readStored(t, rt, DeparturesKey("30004564"), &departures)
+	if len(departures) != 1 {
+		t.Fatalf("got %d departures, want 1", len(departures))
+	}
+	d := departures[0]
+	if d.Source != SourceGTFSRT || d.Delay != 60 || d.Status != models.DepartureEnRoute {
+		t.Errorf("source = %s, delay = %d, status = %s; want the GTFS-Realtime version 60s late", d.Source, d.Delay, d.Status)
+	}
+	if d.StopID != "2334565" || d.TripID != "161565793" {
+		t.Errorf("stop = %s, trip = %s; want the assigned platform 2334565 of trip 161565793", d.StopID, d.TripID)
+	}
+	if d.Occupancy == nil || *d.Occupancy != models.OccupancyFewSeatsAvailable {
+		t.Errorf("occupancy = %v, want FEW_SEATS_AVAILABLE", d.Occupancy)
+	}
+}
+
+func TestGTFSRTFeedStoresVehiclePositions(t *testing.T) {
+	st := recordedStatic()
+	state := pollRecordings(t, st, "vehicle-positions.pb")
+
+	vehicles, err := state.Vehicles(context.Background(), nil, recordedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vehicles) != 1 {
+		t.Fatalf("got %d vehicles, want 1", len(vehicles))
+	}
+	rt := memory.NewRealtime()
+	if err := newTestWorker(NewMerger(state), st, rt).storeVehicles(context.Background(), vehicles, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var route models.RouteVehicles
+	readStored(t, rt, RouteVehiclesKey("72"), &route)
+	if len(route.Vehicles) != 1 {
+		t.Fatalf("got %d vehicles on route 72, want 1", len(route.Vehicles))
+	}
+	v := route.Vehicles[0]
+	if v.ID != "GVB:22:1045" || v.TripID != "161565793" || v.ServiceDate != "20240115" {
+		t.Errorf("vehicle = %s on trip %s on %s, want GVB:22:1045 on 161565793 on 20240115", v.ID, v.TripID, v.ServiceDate)
+	}
+	if v.Lat < 52.377 || v.Lat > 52.379 || v.Lon < 4.899 || v.Lon > 4.901 {
+		t.Errorf("position = %f, %f", v.Lat, v.Lon)
+	}
+	if v.Bearing == nil || *v.Bearing != 90 || v.Speed == nil || *v.Speed != 36 {
+		t.Errorf("bearing = %v, speed = %v; want 90 and 36 km/h", v.Bearing, v.Speed)
+	}
+	if v.Status != models.VehicleStoppedAt || v.StopID == nil || *v.StopID != "2334564" {
+		t.Errorf("status = %s at %v, want STOPPED_AT 2334564", v.Status, v.StopID)
+	}
+	if v.Occupancy == nil || *v.Occupancy != models.OccupancyManySeatsAvailable {
+		t.Errorf("occupancy = %v, want MANY_SEATS_AVAILABLE", v.Occupancy)
+	}
+	if !v.Timestamp.Equal(time.Unix(1705307090, 0)) {
+		t.Errorf("timestamp = %s", v.Timestamp)
+	}
+}
+
+func TestGTFSRTFeedStoresAlerts(t *testing.T) {
+	st := recordedStatic()
+	state := pollRecordings(t, st, "alerts.pb")
+	rt := memory.NewRealtime()
+	newTestWorker(NewMerger(listedPasses{}, state), st, rt).Cycle(context.Background(), time.Now())
+
+	var alerts []models.Alert
+	readStored(t, rt, AlertsKey, &alerts)
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	a := alerts[0]
+	if a.ID != "alert:GVB:1234" || a.Cause != "CONSTRUCTION" || a.Effect != "DETOUR" || a.Severity != "WARNING" {
+		t.Errorf("alert = %s: %s, %s, %s", a.ID, a.Cause, a.Effect, a.Severity)
+	}
+	if a.Header != "Omleiding lijn 22" || a.Description != "Halte Centraal Station wordt niet bediend" || a.URL != "https://gvb.nl/omleidingen" {
+		t.Errorf("texts = %q, %q, %q; want the Dutch translations", a.Header, a.Description, a.URL)
+	}
+	if len(a.ActivePeriods) != 1 || a.ActivePeriods[0].Start == nil || !a.ActivePeriods[0].Start.Equal(time.Unix(1705300000, 0)) || a.ActivePeriods[0].End != nil {
+		t.Errorf("active periods = %+v, want one open-ended from 1705300000", a.ActivePeriods)
+	}
+	want := []models.AlertEntity{{AgencyID: "GVB", RouteID: "72"}, {StopID: "2334564"}}
+	if len(a.Informed) != len(want) || a.Informed[0] != want[0] || a.Informed[1] != want[1] {
+		t.Errorf("informed = %+v, want %+v", a.Informed, want)
+	}
+}
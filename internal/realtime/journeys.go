@@ -0,0 +1,314 @@
+package realtime
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"arrivo-transit-api/internal/geo"
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ndov"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+const (
+	// journeyRetention is how long a journey is kept after its last message.
+	journeyRetention = 2 * time.Hour
+	// positionMaxAge is how old a KV6 position may be to still be shown.
+	positionMaxAge = 5 * time.Minute
+	// minBearingDistance is how far a vehicle must move, in meters, before
+	// its bearing is recomputed; GPS jitter at a stop would spin it around.
+	minBearingDistance = 20.0
+)
+
+// Journeys holds the latest NDOV state per journey and the active KV15 stop
+// messages. It is the Provider for NDOV: it updates passes listed by other
+// providers and reports KV6 vehicle positions.
+//
+// KV6, KV15 and KV17 refer to stops by UserStopCode. For most data owners
+// that equals the TimingPointCode, which is what stops are looked up by.
+type Journeys struct {
+	mu       sync.RWMutex
+	journeys map[string]*journey // By realtime trip ID
+	messages map[string]models.StopMessage
+	received time.Time // Last message applied
+}
+
+type journey struct {
+	operatingDay string
+	updated      time.Time
+
+	// Last KV6 position
+	positionType string
+	positionAt   time.Time
+	lat, lon     float64
+	hasPosition  bool
+	bearing      *float64
+	userStopCode string
+
+	// Punctuality from KV6, or lag from KV17, whichever is newer
+	delay   *int
+	delayAt time.Time
+
+	// KV17 mutations
+	mutatedAt   time.Time
+	cancelled   bool
+	skipped     map[string]bool // UserStopCodes
+	destination string
+}
+
+// NewJourneys creates an empty journey state.
+func NewJourneys() *Journeys {
+	return &Journeys{
+		journeys: make(map[string]*journey),
+		messages: make(map[string]models.StopMessage),
+	}
+}
+
+// Apply updates the state with a decoded NDOV message.
+func (j *Journeys) Apply(msg *ndov.Message, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.received = now
+	for _, p := range msg.Positions {
+		if p.ReinforcementNumber != 0 {
+			// Extra vehicles on a journey have no trip of their own
+			continue
+		}
+		jr := j.journey(p.RealtimeTripID(), p.OperatingDay, now)
+		if p.Timestamp.Before(jr.positionAt) {
+			continue
+		}
+
+		if p.Punctuality != nil && !p.Timestamp.Before(jr.delayAt) {
+			delay := *p.Punctuality
+			jr.delay, jr.delayAt = &delay, p.Timestamp
+		}
+		if p.Type() == ndov.KV6Delay {
+			continue
+		}
+
+		jr.positionType, jr.positionAt = p.Type(), p.Timestamp
+		if p.UserStopCode != "" {
+			jr.userStopCode = p.UserStopCode
+		}
+		if p.Type() == ndov.KV6End {
+			jr.hasPosition = false
+			continue
+		}
+		if lat, lon, ok := p.Coordinates(); ok {
+			if jr.hasPosition && geo.Distance(jr.lat, jr.lon, lat, lon) >= minBearingDistance {
+				b := geo.Bearing(jr.lat, jr.lon, lat, lon)
+				jr.bearing = &b
+			}
+			jr.lat, jr.lon, jr.hasPosition = lat, lon, true
+		}
+	}
+
+	for _, m := range msg.Mutations {
+		if m.Journey.ReinforcementNumber != 0 {
+			continue
+		}
+		jr := j.journey(m.Journey.RealtimeTripID(), m.Journey.OperatingDay, now)
+		if m.Type != ndov.KV17Lag && m.Timestamp.After(jr.mutatedAt) {
+			jr.mutatedAt = m.Timestamp
+		}
+		switch m.Type {
+		case ndov.KV17Cancel:
+			jr.cancelled = true
+		case ndov.KV17Recover:
+			jr.cancelled = false
+		case ndov.KV17Shorten:
+			jr.skipped[m.UserStopCode] = true
+		case ndov.KV17Lag:
+			if !m.Timestamp.Before(jr.delayAt) {
+				lag := m.LagTime
+				jr.delay, jr.delayAt = &lag, m.Timestamp
+			}
+		case ndov.KV17ChangeDestination:
+			jr.destination = m.Destination
+		}
+	}
+
+	for _, m := range msg.StopMessages {
+		message := models.StopMessage{
+			ID:               m.ID(),
+			Operator:         m.DataOwnerCode,
+			TimingPointCodes: m.UserStopCodes,
+			Lines:            m.LinePlanningNumbers,
+			Priority:         m.MessagePriority,
+			Type:             m.MessageType,
+			Text:             m.MessageContent,
+			Reason:           m.ReasonContent,
+			Effect:           m.EffectContent,
+			Advice:           m.AdviceContent,
+			Start:            m.MessageStartTime,
+		}
+		if !m.MessageEndTime.IsZero() {
+			end := m.MessageEndTime
+			message.End = &end
+		}
+		j.messages[message.ID] = message
+	}
+	for _, d := range msg.DeletedStopMessages {
+		delete(j.messages, d.ID())
+	}
+}
+
+// journey returns the state of a journey on operatingDay, replacing state
+// left over from an earlier day.
+func (j *Journeys) journey(id, operatingDay string, now time.Time) *journey {
+	jr, ok := j.journeys[id]
+	if !ok || jr.operatingDay < operatingDay {
+		jr = &journey{operatingDay: operatingDay, skipped: make(map[string]bool)}
+		j.journeys[id] = jr
+	}
+	jr.updated = now
+	return jr
+}
+
+// Source implements Provider.
+func (j *Journeys) Source() string {
+	return SourceNDOV
+}
+
+// Available implements Provider. NDOV is available while messages arrive.
+func (j *Journeys) Available() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return time.Since(j.received) < ndovIdleTimeout
+}
+
+// Departures implements Provider. NDOV only updates journeys, it does not
+// list passes per timing point.
+func (j *Journeys) Departures(ctx context.Context, timingPointCodes []string) (map[string][]ovapi.Pass, error) {
+	return nil, nil
+}
+
+func (j *Journeys) updatesPassesOnly() {}
+
+// UpdatePass implements Provider with the journey's KV17 mutations and
+// latest punctuality. Cancellations are stamped with the time of the
+// mutation, delays with the time of the KV6 position or KV17 lag.
+func (j *Journeys) UpdatePass(p ovapi.Pass, stops map[string]string) (ovapi.Pass, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	jr, ok := j.journeys[p.RealtimeTripID()]
+	if !ok || jr.operatingDay != p.OperationDate {
+		return p, false
+	}
+
+	if jr.cancelled || jr.skipped[p.UserStopCode] {
+		p.TripStopStatus = ovapi.TripStopCancel
+		p.LastUpdateTimeStamp = ovapi.LocalTime{Time: jr.mutatedAt}
+		return p, true
+	}
+	var updatedAt time.Time
+	if jr.destination != "" {
+		p.DestinationName50 = jr.destination
+		updatedAt = jr.mutatedAt
+	}
+
+	switch p.TripStopStatus {
+	case ovapi.TripStopPlanned, ovapi.TripStopDriving, ovapi.TripStopUnknown:
+		// Passes that are past the stop keep their times
+		if jr.delay != nil {
+			delay := time.Duration(*jr.delay) * time.Second
+			if !p.TargetArrivalTime.IsZero() {
+				p.ExpectedArrivalTime = ovapi.LocalTime{Time: p.TargetArrivalTime.Add(delay)}
+			}
+			if !p.TargetDepartureTime.IsZero() {
+				p.ExpectedDepartureTime = ovapi.LocalTime{Time: p.TargetDepartureTime.Add(delay)}
+			}
+			p.TripStopStatus = ovapi.TripStopDriving
+			if jr.delayAt.After(updatedAt) {
+				updatedAt = jr.delayAt
+			}
+		}
+	}
+
+	if updatedAt.IsZero() {
+		return p, false
+	}
+	p.LastUpdateTimeStamp = ovapi.LocalTime{Time: updatedAt}
+	return p, true
+}
+
+// Vehicles implements Provider with the recent KV6 GPS positions of
+// journeys that are not cancelled.
+func (j *Journeys) Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var vehicles []models.Vehicle
+	for id, jr := range j.journeys {
+		if !jr.hasPosition || jr.cancelled || now.Sub(jr.positionAt) > positionMaxAge {
+			continue
+		}
+
+		v := models.Vehicle{
+			ID:          id,
+			ServiceDate: strings.ReplaceAll(jr.operatingDay, "-", ""),
+			Lat:         jr.lat,
+			Lon:         jr.lon,
+			Bearing:     jr.bearing,
+			Timestamp:   jr.positionAt,
+			Delay:       jr.delay,
+			Status:      models.VehicleInTransit,
+		}
+		stopID := stopID(stops, jr.userStopCode)
+		switch jr.positionType {
+		case ndov.KV6Init, ndov.KV6Arrival, ndov.KV6OnStop:
+			v.Status, v.StopID, v.LastStopID = models.VehicleStoppedAt, stopID, stopID
+		case ndov.KV6Departure, ndov.KV6OnRoute:
+			v.LastStopID = stopID
+		case ndov.KV6OffRoute:
+			v.Status = models.VehicleOffRoute
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, nil
+}
+
+// Alerts implements Provider. KV15 stop messages are reported separately by
+// Messages.
+func (j *Journeys) Alerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	return nil, nil
+}
+
+// Messages returns the stop messages that have not ended, ordered by ID.
+func (j *Journeys) Messages(now time.Time) []models.StopMessage {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	messages := []models.StopMessage{}
+	for _, m := range j.messages {
+		if m.End == nil || m.End.After(now) {
+			messages = append(messages, m)
+		}
+	}
+	sort.Slice(messages, func(a, b int) bool { return messages[a].ID < messages[b].ID })
+	return messages
+}
+
+// Expire drops journeys without messages for journeyRetention and stop
+// messages that ended.
+func (j *Journeys) Expire(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for id, jr := range j.journeys {
+		if now.Sub(jr.updated) > journeyRetention {
+			delete(j.journeys, id)
+		}
+	}
+	for id, m := range j.messages {
+		if m.End != nil && !m.End.After(now) {
+			delete(j.messages, id)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Package realtime contains the realtime pipeline: the providers for every
+// upstream source, the merger that combines them, the worker that polls the
+// merger into the realtime store, and the keys under which the API finds the
+// results. Every key but StatusKey holds a Snapshot of the value described.
+package realtime
+
+// DeparturesKey holds the []models.Departure reported for a TimingPointCode,
+// including journeys that already departed.
+func DeparturesKey(timingPointCode string) string {
+	return "realtime:departures:tpc:" + timingPointCode
+}
+
+// VehiclesKey holds the []models.Vehicle of every tracked vehicle.
+const VehiclesKey = "realtime:vehicles"
+
+// RouteVehiclesKey holds the models.RouteVehicles of a GTFS route. Its
+// RouteName and Freshness are left for readers to fill in.
+func RouteVehiclesKey(routeID string) string {
+	return "realtime:vehicles:route:" + routeID
+}
+
+// StopMessagesKey holds the []models.StopMessage that are currently active.
+const StopMessagesKey = "realtime:stop-messages"
+
+// TripUpdatesKey holds the []models.TripUpdate of every trip in the
+// GTFS-Realtime feeds.
+const TripUpdatesKey = "realtime:trip-updates"
+
+// AlertsKey holds the []models.Alert from the GTFS-Realtime feeds that have
+// not ended.
+const AlertsKey = "realtime:alerts"
+
+// StatusKey holds the worker's Status.
+const StatusKey = "realtime:worker:status"
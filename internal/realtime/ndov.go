@@ -0,0 +1,95 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"arrivo-transit-api/internal/ndov"
+)
+
+const (
+	// ndovIdleTimeout is how long a subscription may stay silent before it
+	// is considered dead. KV6 alone sends many messages per second during
+	// service hours.
+	ndovIdleTimeout = 2 * time.Minute
+	// ndovMinBackoff is the delay before the first reconnection attempt; it
+	// doubles up to ndovMaxBackoff while attempts keep failing.
+	ndovMinBackoff = time.Second
+	ndovMaxBackoff = time.Minute
+)
+
+// NDOVFeed subscribes to an NDOV Loket ZeroMQ endpoint and applies every
+// message to Journeys.
+type NDOVFeed struct {
+	endpoint string
+	topics   []string
+	journeys *Journeys
+
+	minBackoff, maxBackoff time.Duration
+}
+
+// NewNDOVFeed creates a feed for endpoint ("tcp://host:port") receiving the
+// given topic prefixes, such as "/GVB/KV6posinfo".
+func NewNDOVFeed(endpoint string, topics []string, journeys *Journeys) *NDOVFeed {
+	return &NDOVFeed{
+		endpoint:   endpoint,
+		topics:     topics,
+		journeys:   journeys,
+		minBackoff: ndovMinBackoff,
+		maxBackoff: ndovMaxBackoff,
+	}
+}
+
+// Run receives messages until ctx is cancelled, reconnecting with
+// exponential backoff when the connection fails.
+func (f *NDOVFeed) Run(ctx context.Context) error {
+	backoff := f.minBackoff
+	for {
+		start := time.Now()
+		err := f.receive(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A connection that lasted starts over with a short delay
+		if time.Since(start) > f.maxBackoff {
+			backoff = f.minBackoff
+		}
+		log.Printf("WARN: NDOV feed %s disconnected, retrying in %s: %v", f.endpoint, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, f.maxBackoff)
+	}
+}
+
+// receive runs one subscription until it fails.
+func (f *NDOVFeed) receive(ctx context.Context) error {
+	sub, err := ndov.Subscribe(ctx, f.endpoint, ndovIdleTimeout, f.topics...)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	log.Printf("Subscribed to NDOV feed %s", f.endpoint)
+
+	stop := context.AfterFunc(ctx, func() { sub.Close() })
+	defer stop()
+
+	for {
+		frames, err := sub.Receive()
+		if err != nil {
+			return err
+		}
+
+		msg, err := ndov.Decode(frames)
+		if err != nil {
+			log.Printf("WARN: Skipping NDOV message: %v", err)
+			continue
+		}
+		f.journeys.Apply(msg, time.Now())
+	}
+}
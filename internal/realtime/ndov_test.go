@@ -0,0 +1,217 @@
+package realtime
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ndov"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+// ndovRecordedAt is when the recording in ../ndov/testdata starts.
+var ndovRecordedAt = time.Unix(1705307100, 0)
+
+// readNDOVRecording returns the messages of ../ndov/testdata/ndov.rec.
+func readNDOVRecording(t *testing.T) [][][]byte {
+	t.Helper()
+	f, err := os.Open("../ndov/testdata/ndov.rec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var messages [][][]byte
+	player := ndov.NewPlayer(f)
+	for {
+		_, frames, err := player.Next()
+		if err == io.EOF {
+			return messages
+		}
+		if err != nil {
+			t.Fatalf("failed to read recording: %v", err)
+		}
+		messages = append(messages, frames)
+	}
+}
+
+// replayUntil publishes the recording until done reports that the feed has
+// applied it. Subscriptions only take effect some time after connecting, so
+// a single replay may go unseen.
+func replayUntil(t *testing.T, pub *ndov.Publisher, recording [][][]byte, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("the feed did not apply the recording")
+		}
+		for _, frames := range recording {
+			pub.Publish(frames...)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// runNDOVFeed runs feed until the test ends.
+func runNDOVFeed(t *testing.T, feed *NDOVFeed) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- feed.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-stopped; err != context.Canceled {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func listenNDOV(t *testing.T) *ndov.Publisher {
+	t.Helper()
+	pub, err := ndov.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pub.Close() })
+	return pub
+}
+
+func TestNDOVFeedAppliesRecordedMessages(t *testing.T) {
+	pub := listenNDOV(t)
+	journeys := NewJourneys()
+	runNDOVFeed(t, NewNDOVFeed("tcp://"+pub.Addr().String(), []string{"/GVB/"}, journeys))
+
+	cancelled := ovapi.Pass{DataOwnerCode: "GVB", LinePlanningNumber: "22", JourneyNumber: 3003, OperationDate: "2024-01-15", TripStopStatus: ovapi.TripStopPlanned}
+	replayUntil(t, pub, readNDOVRecording(t), func() bool {
+		_, updated := journeys.UpdatePass(cancelled, nil)
+		return updated && len(journeys.Messages(ndovRecordedAt)) > 0
+	})
+
+	stops := map[string]string{"30004564": "2334564"}
+	vehicles, err := journeys.Vehicles(context.Background(), stops, ndovRecordedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3002 only reported a delay, the reinforcement and ARR are ignored
+	if len(vehicles) != 1 {
+		t.Fatalf("got %d vehicles, want 1: %+v", len(vehicles), vehicles)
+	}
+	v := vehicles[0]
+	if v.ID != "GVB:22:3001" || v.ServiceDate != "20240115" || v.Status != models.VehicleInTransit {
+		t.Errorf("vehicle %s on %s is %s", v.ID, v.ServiceDate, v.Status)
+	}
+	if v.Delay == nil || *v.Delay != 60 || v.LastStopID == nil || *v.LastStopID != "2334564" {
+		t.Errorf("delay = %v, last stop = %v; want 60 after 2334564", v.Delay, v.LastStopID)
+	}
+	// It moved north-east between the ARRIVAL and the ONROUTE
+	if v.Bearing == nil || *v.Bearing < 0 || *v.Bearing > 90 {
+		t.Errorf("bearing = %v, want north-east", v.Bearing)
+	}
+
+	if p, _ := journeys.UpdatePass(cancelled, nil); p.TripStopStatus != ovapi.TripStopCancel {
+		t.Errorf("GVB:22:3003 is %s, want CANCEL", p.TripStopStatus)
+	}
+	target := time.Date(2024, 1, 15, 9, 40, 0, 0, models.TimetableLocation)
+	lagging := ovapi.Pass{DataOwnerCode: "GVB", LinePlanningNumber: "22", JourneyNumber: 3002, OperationDate: "2024-01-15",
+		UserStopCode: "30004564", TargetDepartureTime: ovapi.LocalTime{Time: target}, TripStopStatus: ovapi.TripStopPlanned}
+	if p, _ := journeys.UpdatePass(lagging, nil); !p.ExpectedDepartureTime.Equal(target.Add(3 * time.Minute)) {
+		t.Errorf("GVB:22:3002 departs %s, want the KV17 lag of 180s over the KV6 delay", p.ExpectedDepartureTime)
+	}
+	lagging.UserStopCode = "30004565"
+	if p, _ := journeys.UpdatePass(lagging, nil); p.TripStopStatus != ovapi.TripStopCancel {
+		t.Errorf("GVB:22:3002 at the shortened stop is %s, want CANCEL", p.TripStopStatus)
+	}
+
+	messages := journeys.Messages(ndovRecordedAt)
+	if len(messages) != 1 || messages[0].ID != "GVB:2024-01-15:12" || len(messages[0].TimingPointCodes) != 2 || messages[0].End == nil {
+		t.Errorf("messages = %+v, want GVB:2024-01-15:12", messages)
+	}
+}
+
+// flakyProxy forwards connections to a publisher after hanging up on the
+// first reject connections, and reports when each connection arrived.
+type flakyProxy struct {
+	ln       net.Listener
+	accepted chan time.Time
+}
+
+func newFlakyProxy(t *testing.T, target string, reject int) *flakyProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	p := &flakyProxy{ln: ln, accepted: make(chan time.Time, 16)}
+	go func() {
+		for n := 0; ; n++ {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			select {
+			case p.accepted <- time.Now():
+			default:
+			}
+			if n < reject {
+				nc.Close()
+				continue
+			}
+			go forward(nc, target)
+		}
+	}()
+	return p
+}
+
+func forward(nc net.Conn, target string) {
+	defer nc.Close()
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go func() {
+		io.Copy(upstream, nc)
+		upstream.Close()
+	}()
+	io.Copy(nc, upstream)
+}
+
+func TestNDOVFeedReconnectsWithBackoff(t *testing.T) {
+	pub := listenNDOV(t)
+	proxy := newFlakyProxy(t, pub.Addr().String(), 4)
+
+	journeys := NewJourneys()
+	feed := NewNDOVFeed("tcp://"+proxy.ln.Addr().String(), []string{"/GVB/"}, journeys)
+	feed.minBackoff, feed.maxBackoff = 25*time.Millisecond, 100*time.Millisecond
+	runNDOVFeed(t, feed)
+
+	// The fifth attempt gets through
+	replayUntil(t, pub, readNDOVRecording(t), func() bool {
+		return len(journeys.Messages(ndovRecordedAt)) > 0
+	})
+
+	var attempts []time.Time
+	for len(proxy.accepted) > 0 {
+		attempts = append(attempts, <-proxy.accepted)
+	}
+	if len(attempts) != 5 {
+		t.Fatalf("got %d connection attempts, want 5", len(attempts))
+	}
+	for i, want := range []time.Duration{25, 50, 100, 100} {
+		want *= time.Millisecond
+		gap := attempts[i+1].Sub(attempts[i])
+		if gap < want {
+			t.Errorf("attempt %d came %s after the previous one, want at least %s", i+2, gap, want)
+		}
+		// Doubling past the cap would wait 200ms
+		if i == 3 && gap >= 2*want {
+			t.Errorf("attempt %d came %s after the previous one, want the backoff capped at %s", i+2, gap, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package realtime
+
+import (
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+// reportOccupancy sets the occupancy vehicles report on the pass of their
+// journey at the stop they are at or heading to, which they will leave
+// with about that load, unless a source reported an occupancy for the pass
+// itself.
+func (p *journeyPasses) reportOccupancy(vehicles []models.Vehicle, tpcStops map[string]string) {
+	type report struct{ stopID, occupancy string }
+	reports := make(map[string]report)
+	for _, v := range vehicles {
+		if v.Occupancy != nil && v.StopID != nil {
+			reports[v.ID] = report{*v.StopID, *v.Occupancy}
+		}
+	}
+	if len(reports) == 0 {
+		return
+	}
+
+	set := func(pass *ovapi.Pass) {
+		r, ok := reports[pass.RealtimeTripID()]
+		if !ok || pass.Occupancy != "" || pass.TripStopStatus == ovapi.TripStopPassed || tpcStops[pass.TimingPointCode] != r.stopID {
+			return
+		}
+		pass.Occupancy = r.occupancy
+	}
+	for _, passes := range p.timingPoints {
+		for i := range passes {
+			set(&passes[i])
+		}
+	}
+	for _, passes := range p.passes {
+		for i := range passes {
+			set(&passes[i])
+		}
+	}
+}
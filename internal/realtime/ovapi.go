@@ -0,0 +1,156 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+// OVapiConfig tunes the OVapi provider.
+type OVapiConfig struct {
+	PollLines   bool // Poll /line actuals for every line to place vehicles
+	BatchSize   int  // Lines per request
+	Concurrency int  // Parallel line requests
+}
+
+// OVapiProvider is the Provider for OVapi (KV78turbo). It lists the passes
+// per timing point and, with PollLines, places the journeys of every line on
+// the map.
+type OVapiProvider struct {
+	client *ovapi.Client
+	cfg    OVapiConfig
+
+	mu          sync.Mutex
+	lines       []string
+	linesLoaded time.Time
+}
+
+// NewOVapiProvider creates a new OVapi provider.
+func NewOVapiProvider(client *ovapi.Client, cfg OVapiConfig) *OVapiProvider {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	return &OVapiProvider{client: client, cfg: cfg}
+}
+
+// Source implements Provider.
+func (o *OVapiProvider) Source() string {
+	return SourceOVapi
+}
+
+// Available implements Provider. OVapi is unavailable while its circuit
+// breaker is open.
+func (o *OVapiProvider) Available() bool {
+	return o.client.Available()
+}
+
+// Departures implements Provider. Timing points without passes map to an
+// empty slice.
+func (o *OVapiProvider) Departures(ctx context.Context, timingPointCodes []string) (map[string][]ovapi.Pass, error) {
+	timingPoints, err := o.client.GetDepartures(ctx, timingPointCodes...)
+	if err != nil {
+		return nil, err
+	}
+
+	passes := make(map[string][]ovapi.Pass, len(timingPointCodes))
+	for _, code := range timingPointCodes {
+		codePasses := []ovapi.Pass{}
+		for _, pass := range timingPoints[code].Passes {
+			codePasses = append(codePasses, pass)
+		}
+		passes[code] = codePasses
+	}
+	return passes, nil
+}
+
+// UpdatePass implements Provider. OVapi lists passes itself.
+func (o *OVapiProvider) UpdatePass(p ovapi.Pass, stops map[string]string) (ovapi.Pass, bool) {
+	return p, false
+}
+
+// Vehicles implements Provider by polling the actuals of every line. Lines
+// are reloaded once per listRefreshInterval; batches that fail are left out
+// and reported in the error.
+func (o *OVapiProvider) Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error) {
+	if !o.cfg.PollLines {
+		return nil, nil
+	}
+	lines, err := o.refreshLines(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lines: %w", err)
+	}
+
+	var mu sync.Mutex
+	var vehicles []models.Vehicle
+	var failed atomic.Int64
+	var lastErr atomic.Value
+	forEachBatch(ctx, lines, o.cfg.BatchSize, o.cfg.Concurrency, func(batch []string) {
+		actuals, err := o.client.GetLineActuals(ctx, batch...)
+		if err != nil {
+			failed.Add(1)
+			lastErr.Store(err)
+			return
+		}
+
+		var batchVehicles []models.Vehicle
+		for _, id := range batch {
+			batchVehicles = append(batchVehicles, lineVehicles(actuals[id], stops, now)...)
+		}
+		mu.Lock()
+		vehicles = append(vehicles, batchVehicles...)
+		mu.Unlock()
+	})
+
+	if n := failed.Load(); n > 0 {
+		return vehicles, fmt.Errorf("%d line batches failed: %w", n, lastErr.Load().(error))
+	}
+	return vehicles, nil
+}
+
+// refreshLines returns the IDs of all lines, reloading them once per
+// listRefreshInterval. A failed reload keeps the previous list.
+func (o *OVapiProvider) refreshLines(ctx context.Context) ([]string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.lines != nil && time.Since(o.linesLoaded) < listRefreshInterval {
+		return o.lines, nil
+	}
+
+	lines, err := o.client.GetLines(ctx)
+	if err != nil {
+		if o.lines != nil {
+			return o.lines, nil
+		}
+		return nil, err
+	}
+	ids := make([]string, 0, len(lines))
+	for id := range lines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	o.lines, o.linesLoaded = ids, time.Now()
+	return ids, nil
+}
+
+// Alerts implements Provider. OVapi has no alerts.
+func (o *OVapiProvider) Alerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	return nil, nil
+}
+
+// Lines returns the number of lines polled for vehicles.
+func (o *OVapiProvider) Lines() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.lines)
+}
@@ -0,0 +1,331 @@
+package realtime
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/store"
+)
+
+const (
+	// minObservations is the number of observations after which learned run
+	// and dwell times replace the timetable.
+	minObservations = 3
+	// runTimeWeight is the weight of a new observation once a mean is
+	// established, so run times follow changes in traffic and roadworks.
+	runTimeWeight = 0.2
+	// maxRunTime and maxDwellTime bound plausible observations.
+	maxRunTime   = 2 * time.Hour
+	maxDwellTime = 30 * time.Minute
+	// observedRetention is how long completed calls are remembered, so every
+	// call is observed once.
+	observedRetention = 24 * time.Hour
+	// runTimeHistory is how many days of archived calls run times are
+	// learned from at startup.
+	runTimeHistory = 7
+	// runTimeSeedTimeout bounds reading the archived calls.
+	runTimeSeedTimeout = 5 * time.Minute
+)
+
+// runTimeKey identifies the run between two consecutive timing points, or
+// the dwell at one when from and to are equal, in a period of the week.
+type runTimeKey struct {
+	from, to string
+	period   int
+}
+
+type runTimeStats struct {
+	mean float64 // Seconds
+	n    int
+}
+
+func (s *runTimeStats) add(seconds float64) {
+	s.n++
+	s.mean += (seconds - s.mean) / float64(min(s.n, int(1/runTimeWeight)))
+}
+
+// passKey identifies the pass of a journey at one of its stops.
+type passKey struct {
+	journey journeyKey
+	order   int
+}
+
+// RunTimes learns how long journeys take between consecutive timing points
+// and how long they stand at them, per period of the week, from the calls
+// they completed. It predicts the remaining calls of a journey from its
+// current delay, falling back to the timetable where too few observations
+// exist, and lets schedule slack absorb the delay.
+type RunTimes struct {
+	mu         sync.Mutex
+	stats      map[runTimeKey]*runTimeStats
+	observed   map[passKey]time.Time
+	lastPruned time.Time
+}
+
+// NewRunTimes creates an empty RunTimes.
+func NewRunTimes() *RunTimes {
+	return &RunTimes{
+		stats:    make(map[runTimeKey]*runTimeStats),
+		observed: make(map[passKey]time.Time),
+	}
+}
+
+// period buckets t into night, morning peak, midday, evening peak and
+// evening, on weekdays and in weekends.
+func period(t time.Time) int {
+	var band int
+	switch h := t.Hour(); {
+	case h < 6:
+		band = 0
+	case h < 9:
+		band = 1
+	case h < 15:
+		band = 2
+	case h < 19:
+		band = 3
+	default:
+		band = 4
+	}
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		band += 5
+	}
+	return band
+}
+
+// Observe records the runs and dwells of a journey's passes, in stop order,
+// that both ends of have been passed.
+func (r *RunTimes) Observe(key journeyKey, passes []ovapi.Pass, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.lastPruned) > time.Hour {
+		for k, at := range r.observed {
+			if now.Sub(at) > observedRetention {
+				delete(r.observed, k)
+			}
+		}
+		r.lastPruned = now
+	}
+
+	for i := 1; i < len(passes); i++ {
+		prev, p := passes[i-1], passes[i]
+		if prev.TripStopStatus != ovapi.TripStopPassed || p.TripStopStatus != ovapi.TripStopPassed {
+			continue
+		}
+		r.observe(key, prev, p, now)
+	}
+}
+
+// observe records the run from prev to p and the dwell at p, once per call.
+func (r *RunTimes) observe(key journeyKey, prev, p ovapi.Pass, now time.Time) {
+	observed := passKey{key, p.UserStopOrderNumber}
+	if _, ok := r.observed[observed]; ok {
+		return
+	}
+	r.observed[observed] = now
+	r.learn(prev, p)
+}
+
+// learn adds the run from prev to p and the dwell at p to the statistics.
+func (r *RunTimes) learn(prev, p ovapi.Pass) {
+	when := period(prev.TargetDepartureTime.Time)
+	run := p.ExpectedArrivalTime.Sub(prev.ExpectedDepartureTime.Time)
+	if !prev.ExpectedDepartureTime.IsZero() && !p.ExpectedArrivalTime.IsZero() && run >= 0 && run <= maxRunTime {
+		r.add(runTimeKey{prev.TimingPointCode, p.TimingPointCode, when}, run)
+	}
+	dwell := p.ExpectedDepartureTime.Sub(p.ExpectedArrivalTime.Time)
+	if !p.ExpectedArrivalTime.IsZero() && !p.ExpectedDepartureTime.IsZero() && dwell >= 0 && dwell <= maxDwellTime {
+		r.add(runTimeKey{p.TimingPointCode, p.TimingPointCode, period(p.TargetDepartureTime.Time)}, dwell)
+	}
+}
+
+// Seed learns from the calls archived for the service days from to to, so
+// predictions do not start over from the timetable after a restart. Calls
+// of journeys that may still be polled, those of today and yesterday, are
+// remembered as observed so they are not learned twice. It returns the
+// number of calls read.
+func (r *RunTimes) Seed(ctx context.Context, s store.ObservationStore, from, to, now time.Time) (int, error) {
+	recent := models.NewServiceDay(now).Date.AddDate(0, 0, -1).Format("20060102")
+	var prev models.CallObservation
+	n := 0
+	err := s.ObservedCalls(ctx, from, to, func(c models.CallObservation) error {
+		n++
+		if c.Status == models.DepartureDeparted && prev.Status == models.DepartureDeparted && c.ServiceDate == prev.ServiceDate &&
+			c.RealtimeTripID == prev.RealtimeTripID && c.StopSequence == prev.StopSequence+1 {
+			r.mu.Lock()
+			if c.ServiceDate >= recent {
+				r.observe(journeyKey{c.RealtimeTripID, operationDate(c.ServiceDate)}, observedPass(prev), observedPass(c), now)
+			} else {
+				r.learn(observedPass(prev), observedPass(c))
+			}
+			r.mu.Unlock()
+		}
+		prev = c
+		return nil
+	})
+	return n, err
+}
+
+// observedPass returns the passed pass an archived call was made from.
+func observedPass(c models.CallObservation) ovapi.Pass {
+	p := ovapi.Pass{
+		UserStopOrderNumber: c.StopSequence,
+		TimingPointCode:     c.TimingPointCode,
+		TripStopStatus:      ovapi.TripStopPassed,
+	}
+	for _, t := range []struct {
+		to   *ovapi.LocalTime
+		from *time.Time
+	}{
+		{&p.TargetArrivalTime, c.ScheduledArrival},
+		{&p.TargetDepartureTime, c.ScheduledDeparture},
+		{&p.ExpectedArrivalTime, c.ActualArrival},
+		{&p.ExpectedDepartureTime, c.ActualDeparture},
+	} {
+		if t.from != nil {
+			*t.to = ovapi.LocalTime{Time: t.from.In(models.TimetableLocation)}
+		}
+	}
+	return p
+}
+
+// operationDate formats a YYYYMMDD service date like OVapi, e.g.
+// "2024-01-15".
+func operationDate(serviceDate string) string {
+	if len(serviceDate) != 8 {
+		return serviceDate
+	}
+	return serviceDate[:4] + "-" + serviceDate[4:6] + "-" + serviceDate[6:]
+}
+
+func (r *RunTimes) add(key runTimeKey, d time.Duration) {
+	stats, ok := r.stats[key]
+	if !ok {
+		stats = &runTimeStats{}
+		r.stats[key] = stats
+	}
+	stats.add(d.Seconds())
+}
+
+// learned returns the learned duration of key, or scheduled when too few
+// observations exist.
+func (r *RunTimes) learned(key runTimeKey, scheduled time.Duration) time.Duration {
+	if stats, ok := r.stats[key]; ok && stats.n >= minObservations {
+		return time.Duration(stats.mean * float64(time.Second))
+	}
+	return scheduled
+}
+
+// Predict fills in the expected times of the passes of a journey, in stop
+// order, that follow its last tracked pass but have no expected times of
+// their own. From the tracked departure it adds the run to and dwell at
+// every next stop; a journey never leaves a stop before its timetabled
+// departure, which is how slack in the timetable absorbs delay. A pass
+// upstream already expects off its timetable keeps its times, and the
+// stops after it are predicted from them. Predicted passes are marked as
+// such; the others are left as they are.
+func (r *RunTimes) Predict(passes []ovapi.Pass) {
+	anchor := -1
+	for i, p := range passes {
+		if tracked(p) {
+			anchor = i
+		}
+	}
+	if anchor < 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	departed := passes[anchor].ExpectedDepartureTime.Time
+	if departed.IsZero() {
+		departed = passes[anchor].ExpectedArrivalTime.Time
+	}
+	if departed.IsZero() {
+		return
+	}
+	prev := &passes[anchor]
+	for i := anchor + 1; i < len(passes); i++ {
+		p := &passes[i]
+		if p.TripStopStatus == ovapi.TripStopCancel {
+			continue // Skipped stops are driven past
+		}
+		if p.TripStopStatus != ovapi.TripStopPlanned && p.TripStopStatus != ovapi.TripStopUnknown {
+			return
+		}
+		if expected(*p) {
+			if !p.ExpectedDepartureTime.IsZero() {
+				departed = p.ExpectedDepartureTime.Time
+			} else {
+				departed = p.ExpectedArrivalTime.Time
+			}
+			prev = p
+			continue
+		}
+
+		run := r.learned(runTimeKey{prev.TimingPointCode, p.TimingPointCode, period(prev.TargetDepartureTime.Time)},
+			p.TargetArrivalTime.Sub(prev.TargetDepartureTime.Time))
+		dwell := r.learned(runTimeKey{p.TimingPointCode, p.TimingPointCode, period(p.TargetDepartureTime.Time)},
+			p.TargetDepartureTime.Sub(p.TargetArrivalTime.Time))
+
+		arrival := departed.Add(max(0, run))
+		departure := arrival.Add(max(0, dwell))
+		if departure.Before(p.TargetDepartureTime.Time) {
+			departure = p.TargetDepartureTime.Time
+		}
+		p.ExpectedArrivalTime = ovapi.LocalTime{Time: arrival}
+		p.ExpectedDepartureTime = ovapi.LocalTime{Time: departure}
+		p.Predicted = true
+		prev, departed = p, departure
+	}
+}
+
+// tracked reports whether the source reported where the journey of p is,
+// rather than only its timetable.
+func tracked(p ovapi.Pass) bool {
+	switch p.TripStopStatus {
+	case ovapi.TripStopDriving, ovapi.TripStopArrived, ovapi.TripStopPassed:
+		return !p.ExpectedDepartureTime.IsZero() || !p.ExpectedArrivalTime.IsZero()
+	}
+	return false
+}
+
+// expected reports whether upstream expects p at other than its timetabled
+// times. Sources that do not predict a pass report its timetable as its
+// expected times.
+func expected(p ovapi.Pass) bool {
+	return !p.ExpectedArrivalTime.IsZero() && !p.ExpectedArrivalTime.Equal(p.TargetArrivalTime.Time) ||
+		!p.ExpectedDepartureTime.IsZero() && !p.ExpectedDepartureTime.Equal(p.TargetDepartureTime.Time)
+}
+
+// propagateDelays observes the completed calls of every journey and
+// predicts the calls upstream has no expected times for, in the passes by
+// journey as well as by timing point. It returns the number of predicted
+// passes.
+func (p *journeyPasses) propagateDelays(runTimes *RunTimes, now time.Time) int {
+	predicted := make(map[passKey]ovapi.Pass)
+	for key, passes := range p.passes {
+		sort.Slice(passes, func(a, b int) bool { return passes[a].UserStopOrderNumber < passes[b].UserStopOrderNumber })
+		runTimes.Observe(key, passes, now)
+		runTimes.Predict(passes)
+		for _, pass := range passes {
+			if pass.Predicted {
+				predicted[passKey{key, pass.UserStopOrderNumber}] = pass
+			}
+		}
+	}
+
+	for _, passes := range p.timingPoints {
+		for i, pass := range passes {
+			if prediction, ok := predicted[passKey{journeyKey{pass.RealtimeTripID(), pass.OperationDate}, pass.UserStopOrderNumber}]; ok {
+				passes[i] = prediction
+			}
+		}
+	}
+	return len(predicted)
+}
@@ -0,0 +1,102 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/store/memory"
+)
+
+// journeyAt returns the passes of a journey calling at TPCs 1, 2, 3 and 4
+// five minutes apart from start, without dwelling, all PLANNED with their
+// timetable as expected times like OVapi reports them.
+func journeyAt(start time.Time) []ovapi.Pass {
+	passes := make([]ovapi.Pass, 4)
+	for i := range passes {
+		at := ovapi.LocalTime{Time: start.Add(time.Duration(i) * 5 * time.Minute)}
+		passes[i] = ovapi.Pass{
+			UserStopOrderNumber: i + 1,
+			TimingPointCode:     fmt.Sprint(i + 1),
+			TargetArrivalTime:   at, TargetDepartureTime: at,
+			ExpectedArrivalTime: at, ExpectedDepartureTime: at,
+			TripStopStatus: ovapi.TripStopPlanned,
+		}
+	}
+	return passes
+}
+
+// delay moves the expected times of p by d.
+func delay(p *ovapi.Pass, d time.Duration) {
+	p.ExpectedArrivalTime = ovapi.LocalTime{Time: p.TargetArrivalTime.Add(d)}
+	p.ExpectedDepartureTime = ovapi.LocalTime{Time: p.TargetDepartureTime.Add(d)}
+}
+
+func TestPredictKeepsUpstreamExpectations(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, models.TimetableLocation)
+	passes := journeyAt(start)
+	// Left the first stop 2 minutes late; upstream expects 6 minutes at the
+	// third
+	passes[0].TripStopStatus = ovapi.TripStopPassed
+	delay(&passes[0], 2*time.Minute)
+	passes[2].TripStopStatus = ovapi.TripStopUnknown
+	delay(&passes[2], 6*time.Minute)
+
+	NewRunTimes().Predict(passes)
+
+	for i, want := range []struct {
+		delay     time.Duration
+		predicted bool
+	}{{2 * time.Minute, false}, {2 * time.Minute, true}, {6 * time.Minute, false}, {6 * time.Minute, true}} {
+		p := passes[i]
+		if got := p.ExpectedDepartureTime.Sub(p.TargetDepartureTime.Time); got != want.delay || p.Predicted != want.predicted {
+			t.Errorf("pass %d: delay %s (predicted %v), want %s (predicted %v)", i+1, got, p.Predicted, want.delay, want.predicted)
+		}
+	}
+}
+
+func TestSeedLearnsRunTimesFromArchive(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, models.TimetableLocation)
+
+	// Today and on the same day in earlier weeks the journey took 10
+	// minutes from 1 to 2, and stood a minute at 2
+	st := memory.New()
+	for day := 0; day < minObservations; day++ {
+		passes := journeyAt(start.AddDate(0, 0, -7*day))
+		passes[0].TripStopStatus, passes[1].TripStopStatus = ovapi.TripStopPassed, ovapi.TripStopPassed
+		passes[1].ExpectedArrivalTime = ovapi.LocalTime{Time: passes[0].ExpectedDepartureTime.Add(10 * time.Minute)}
+		passes[1].ExpectedDepartureTime = ovapi.LocalTime{Time: passes[1].ExpectedArrivalTime.Add(time.Minute)}
+		journeys := journeyPasses{passes: map[journeyKey][]ovapi.Pass{
+			{"GVB:1:100", passes[0].TargetDepartureTime.Format("2006-01-02")}: passes[:2],
+		}}
+		if err := st.RecordCalls(context.Background(), journeys.callObservations(nil, start)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewRunTimes()
+	n, err := r.Seed(context.Background(), st, start.AddDate(0, 0, -runTimeHistory*minObservations), start, start)
+	if err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	if n != 2*minObservations {
+		t.Errorf("Seed read %d calls, want %d", n, 2*minObservations)
+	}
+	// Only today's journey may still be polled
+	if len(r.observed) != 1 {
+		t.Errorf("Seed remembered %d calls as observed, want 1", len(r.observed))
+	}
+
+	passes := journeyAt(start)
+	passes[0].TripStopStatus = ovapi.TripStopPassed
+	r.Predict(passes)
+	if want := start.Add(10 * time.Minute); !passes[1].ExpectedArrivalTime.Equal(want) {
+		t.Errorf("predicted arrival at 2 = %s, want the learned %s", passes[1].ExpectedArrivalTime, want)
+	}
+	if want := start.Add(11 * time.Minute); !passes[1].ExpectedDepartureTime.Equal(want) {
+		t.Errorf("predicted departure from 2 = %s, want %s after the learned dwell", passes[1].ExpectedDepartureTime, want)
+	}
+}
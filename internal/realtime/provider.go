@@ -0,0 +1,333 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+// Realtime sources, recorded as provenance on merged records.
+const (
+	SourceOVapi  = "ovapi"
+	SourceNDOV   = "ndov"
+	SourceGTFSRT = "gtfs-rt"
+)
+
+// trustLag is how much older the value of a more trusted source may be than
+// the freshest value before the fresher one wins.
+const trustLag = time.Minute
+
+// Provider is a realtime source. The worker asks a Merger, which asks every
+// provider what it knows and combines the answers; a provider without data
+// of some kind returns nil for it.
+type Provider interface {
+	// Source names the provider; it is recorded on every record it
+	// contributes.
+	Source() string
+	// Available reports whether the provider can be asked, e.g. false while
+	// its circuit breaker is open, so the merger fails over without waiting
+	// for errors.
+	Available() bool
+	// Departures returns the passes at the given TimingPointCodes, keyed by
+	// code. Providers that cannot list passes per timing point return nil.
+	Departures(ctx context.Context, timingPointCodes []string) (map[string][]ovapi.Pass, error)
+	// UpdatePass returns the provider's version of a pass another provider
+	// listed, or false when it knows nothing about the journey. The version's
+	// LastUpdateTimeStamp is when the provider learned what it reports. stops
+	// maps TimingPointCodes to GTFS stop IDs.
+	UpdatePass(p ovapi.Pass, stops map[string]string) (ovapi.Pass, bool)
+	// Vehicles returns the vehicles the provider tracks. RouteID and TripID
+	// may be left empty when the vehicle ID is a realtime trip ID.
+	Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error)
+	// Alerts returns the service alerts that have not ended.
+	Alerts(ctx context.Context, now time.Time) ([]models.Alert, error)
+}
+
+// tripUpdateProvider is implemented by providers that also report trip
+// updates directly, including trips no other provider lists passes for.
+type tripUpdateProvider interface {
+	TripUpdates() []models.TripUpdate
+}
+
+// stopMessageProvider is implemented by providers that report KV15 stop
+// messages.
+type stopMessageProvider interface {
+	Messages(now time.Time) []models.StopMessage
+}
+
+// passUpdater is implemented by providers that only update the passes
+// others list: they report journeys, not the passes per timing point.
+type passUpdater interface {
+	updatesPassesOnly()
+}
+
+// expirer is implemented by providers that keep state which must be pruned.
+type expirer interface {
+	Expire(now time.Time)
+}
+
+// lineCounter is implemented by providers that poll lines.
+type lineCounter interface {
+	Lines() int
+}
+
+// Merger combines providers, ordered from most to least trusted. For every
+// journey and vehicle it takes the value of the most trusted provider,
+// unless that value lags the freshest one by more than trustLag.
+type Merger struct {
+	providers []Provider
+}
+
+// NewMerger creates a merger over providers, most trusted first.
+func NewMerger(providers ...Provider) *Merger {
+	return &Merger{providers: providers}
+}
+
+// Sources reports the availability of every provider by source.
+func (m *Merger) Sources() map[string]bool {
+	sources := make(map[string]bool, len(m.providers))
+	for _, p := range m.providers {
+		sources[p.Source()] = p.Available()
+	}
+	return sources
+}
+
+// DepartureSources returns the available sources that can list passes, in
+// order of trust. Departures fail over between them only; providers that
+// merely update passes, like NDOV and GTFS-Realtime, cannot replace them, so
+// without one the API serves the timetable.
+func (m *Merger) DepartureSources() []string {
+	sources := []string{}
+	for _, p := range m.providers {
+		if _, ok := p.(passUpdater); !ok && p.Available() {
+			sources = append(sources, p.Source())
+		}
+	}
+	return sources
+}
+
+// Departures lists the passes at the given TimingPointCodes from the most
+// trusted available provider that can list them, failing over to the next
+// one when it errors, and merges the other providers' versions into every
+// pass. See DepartureSources for which providers can list passes.
+func (m *Merger) Departures(ctx context.Context, timingPointCodes []string, stops map[string]string) (map[string][]ovapi.Pass, error) {
+	var errs []error
+	for _, p := range m.providers {
+		if _, ok := p.(passUpdater); ok || !p.Available() {
+			continue
+		}
+		passes, err := p.Departures(ctx, timingPointCodes)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Source(), err))
+			continue
+		}
+		if passes == nil {
+			continue
+		}
+
+		for code, codePasses := range passes {
+			for i := range codePasses {
+				codePasses[i].Source = p.Source()
+				codePasses[i] = m.mergePass(codePasses[i], stops)
+			}
+			passes[code] = codePasses
+		}
+		return passes, nil
+	}
+
+	if len(errs) == 0 {
+		return nil, errors.New("no available source lists departures")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// mergePass picks the version of a pass to keep among the one listed and
+// the versions of all available providers. The occupancy and assigned stop
+// are taken from the most trusted version that reports them.
+func (m *Merger) mergePass(listed ovapi.Pass, stops map[string]string) ovapi.Pass {
+	var versions []ovapi.Pass
+	for _, p := range m.providers {
+		if p.Source() == listed.Source {
+			versions = append(versions, listed)
+			continue
+		}
+		if !p.Available() {
+			continue
+		}
+		if version, ok := p.UpdatePass(listed, stops); ok {
+			version.Source = p.Source()
+			versions = append(versions, version)
+		}
+	}
+
+	times := make([]time.Time, len(versions))
+	for i, v := range versions {
+		times[i] = v.LastUpdateTimeStamp.Time
+	}
+	kept := versions[trusted(times)]
+	for _, v := range versions {
+		if kept.Occupancy == "" {
+			kept.Occupancy = v.Occupancy
+		}
+		if kept.AssignedStopID == "" {
+			kept.AssignedStopID = v.AssignedStopID
+		}
+	}
+	return kept
+}
+
+// trusted returns the index of the value to keep among values stamped with
+// times, ordered from most to least trusted source.
+func trusted(times []time.Time) int {
+	var freshest time.Time
+	for _, t := range times {
+		if t.After(freshest) {
+			freshest = t
+		}
+	}
+	for i, t := range times {
+		if !t.Before(freshest.Add(-trustLag)) {
+			return i
+		}
+	}
+	return 0
+}
+
+// Vehicles merges the vehicles of all available providers by ID. Fields the
+// kept version lacks are filled in from the others, most trusted first.
+// Errors of single providers are returned along with the vehicles of the
+// others.
+func (m *Merger) Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error) {
+	var errs []error
+	var order []string
+	versions := make(map[string][]models.Vehicle)
+	for _, p := range m.providers {
+		if !p.Available() {
+			continue
+		}
+		vehicles, err := p.Vehicles(ctx, stops, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Source(), err))
+		}
+		for _, v := range vehicles {
+			v.Source = p.Source()
+			if _, ok := versions[v.ID]; !ok {
+				order = append(order, v.ID)
+			}
+			versions[v.ID] = append(versions[v.ID], v)
+		}
+	}
+
+	vehicles := make([]models.Vehicle, 0, len(order))
+	for _, id := range order {
+		candidates := versions[id]
+		times := make([]time.Time, len(candidates))
+		for i, v := range candidates {
+			times[i] = v.Timestamp
+		}
+		v := candidates[trusted(times)]
+		for _, other := range candidates {
+			if v.StopID == nil {
+				v.StopID = other.StopID
+			}
+			if v.LastStopID == nil {
+				v.LastStopID = other.LastStopID
+			}
+			if v.Delay == nil {
+				v.Delay = other.Delay
+			}
+			if v.Occupancy == nil {
+				v.Occupancy = other.Occupancy
+			}
+			if v.TripID == "" {
+				v.TripID, v.RouteID = other.TripID, other.RouteID
+			}
+			if v.ServiceDate == "" {
+				v.ServiceDate = other.ServiceDate
+			}
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, errors.Join(errs...)
+}
+
+// Alerts collects the alerts of all available providers. An alert ID
+// reported by several providers is taken from the most trusted one.
+func (m *Merger) Alerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	var errs []error
+	seen := make(map[string]bool)
+	alerts := []models.Alert{}
+	for _, p := range m.providers {
+		if !p.Available() {
+			continue
+		}
+		providerAlerts, err := p.Alerts(ctx, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Source(), err))
+		}
+		for _, a := range providerAlerts {
+			if seen[a.ID] {
+				continue
+			}
+			seen[a.ID] = true
+			a.Source = p.Source()
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts, errors.Join(errs...)
+}
+
+// TripUpdates collects the trip updates providers report directly.
+func (m *Merger) TripUpdates() []models.TripUpdate {
+	var updates []models.TripUpdate
+	for _, p := range m.providers {
+		tp, ok := p.(tripUpdateProvider)
+		if !ok || !p.Available() {
+			continue
+		}
+		for _, u := range tp.TripUpdates() {
+			u.Source = p.Source()
+			updates = append(updates, u)
+		}
+	}
+	return updates
+}
+
+// StopMessages collects the KV15 stop messages of all providers, or returns
+// nil when no provider reports them.
+func (m *Merger) StopMessages(now time.Time) []models.StopMessage {
+	var messages []models.StopMessage
+	for _, p := range m.providers {
+		if sp, ok := p.(stopMessageProvider); ok {
+			messages = append(messages, sp.Messages(now)...)
+			if messages == nil {
+				messages = []models.StopMessage{}
+			}
+		}
+	}
+	return messages
+}
+
+// Lines returns the number of lines polled by all providers.
+func (m *Merger) Lines() int {
+	lines := 0
+	for _, p := range m.providers {
+		if lc, ok := p.(lineCounter); ok {
+			lines += lc.Lines()
+		}
+	}
+	return lines
+}
+
+// Expire prunes the state of all providers.
+func (m *Merger) Expire(now time.Time) {
+	for _, p := range m.providers {
+		if e, ok := p.(expirer); ok {
+			e.Expire(now)
+		}
+	}
+}
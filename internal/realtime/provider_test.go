@@ -0,0 +1,45 @@
+package realtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/store/memory"
+)
+
+// brokenPasses is an OVapi provider whose circuit breaker is open.
+type brokenPasses struct {
+	listedPasses
+}
+
+func (brokenPasses) Available() bool { return false }
+
+func TestMergerCannotFailOverToPassUpdaters(t *testing.T) {
+	// The GTFS-Realtime feed is up, but only updates the passes OVapi lists
+	state := pollRecordings(t, recordedStatic(), "trip-updates.pb")
+	codes := []string{"30001234"}
+
+	up := NewMerger(listedPasses{"30001234": {{TimingPointCode: "30001234", TripStopStatus: ovapi.TripStopPlanned}}}, state)
+	if sources := up.DepartureSources(); strings.Join(sources, ",") != SourceOVapi {
+		t.Errorf("departure sources = %v, want only %s", sources, SourceOVapi)
+	}
+	passes, err := up.Departures(context.Background(), codes, nil)
+	if err != nil || len(passes["30001234"]) != 1 {
+		t.Fatalf("Departures = %v, %v; want the OVapi pass", passes, err)
+	}
+
+	down := NewMerger(brokenPasses{}, state)
+	if sources := down.DepartureSources(); sources == nil || len(sources) != 0 {
+		t.Errorf("departure sources = %#v, want none", sources)
+	}
+	if _, err := down.Departures(context.Background(), codes, nil); err == nil {
+		t.Error("Departures succeeded without a source listing passes")
+	}
+	status := newTestWorker(down, recordedStatic(), memory.NewRealtime()).Cycle(context.Background(), time.Now())
+	if !status.Sources[SourceGTFSRT] || len(status.DepartureSources) != 0 {
+		t.Errorf("status sources = %v, departure sources = %v; want GTFS-RT up without departure sources", status.Sources, status.DepartureSources)
+	}
+}
@@ -0,0 +1,22 @@
+package realtime
+
+import (
+	"encoding/json"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// Snapshot wraps every value the worker stores. The worker keeps a snapshot
+// beyond its normal TTL, so readers can keep serving the last known data,
+// marked stale, while an upstream is failing.
+type Snapshot struct {
+	UpdatedAt  time.Time       `json:"updated_at"`
+	FreshUntil time.Time       `json:"fresh_until"` // Refreshed snapshots are replaced before this time
+	Data       json.RawMessage `json:"data"`
+}
+
+// Freshness describes the snapshot at now.
+func (s Snapshot) Freshness(now time.Time) models.Freshness {
+	return models.Freshness{LastUpdated: s.UpdatedAt, FreshUntil: s.FreshUntil}.At(now)
+}
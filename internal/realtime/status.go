@@ -0,0 +1,29 @@
+package realtime
+
+import "time"
+
+// Status describes the last polling cycle of the realtime worker.
+type Status struct {
+	CycleStarted   time.Time `json:"cycle_started"`
+	CycleFinished  time.Time `json:"cycle_finished"`
+	CycleDuration  float64   `json:"cycle_duration_seconds"`
+	Interval       float64   `json:"interval_seconds"`
+	LagSeconds     float64   `json:"lag_seconds"` // How far the cycle finished behind its scheduled start
+	TimingPoints   int       `json:"timing_points"`
+	Lines          int       `json:"lines"`
+	Vehicles       int       `json:"vehicles"`
+	StopMessages   int       `json:"stop_messages"`
+	TripUpdates    int       `json:"trip_updates"`
+	Predicted      int       `json:"predicted"` // Passes whose expected times were propagated from an earlier delay
+	Alerts         int       `json:"alerts"`
+	FailedRequests int       `json:"failed_requests"`
+
+	Sources          map[string]bool `json:"sources"`           // Availability of every realtime source at the start of the cycle
+	DepartureSources []string        `json:"departure_sources"` // Available sources that list departures; empty when only the timetable is served
+}
+
+// Stale reports whether the worker missed more than two cycles, i.e. stored
+// departures may already have expired.
+func (s Status) Stale(now time.Time) bool {
+	return now.Sub(s.CycleFinished).Seconds() > 3*s.Interval
+}
@@ -0,0 +1,167 @@
+package realtime
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+// journeyPasses collects the passes of a cycle by polled TimingPointCode
+// and by journey, keyed by realtime trip ID and operation date, and the GTFS
+// trips running the journeys.
+type journeyPasses struct {
+	mu           sync.Mutex
+	timingPoints map[string][]ovapi.Pass
+	passes       map[journeyKey][]ovapi.Pass
+	trips        map[models.Journey]models.Trip
+}
+
+type journeyKey struct {
+	realtimeTripID string
+	operationDate  string
+}
+
+// journey returns the key the GTFS trip of the journey is looked up by.
+func (k journeyKey) journey() models.Journey {
+	return models.Journey{RealtimeID: k.realtimeTripID, ServiceDate: strings.ReplaceAll(k.operationDate, "-", "")}
+}
+
+// trip returns the GTFS trip running the journey of pass.
+func (p *journeyPasses) trip(pass ovapi.Pass) (models.Trip, bool) {
+	trip, ok := p.trips[journeyKey{pass.RealtimeTripID(), pass.OperationDate}.journey()]
+	return trip, ok
+}
+
+func (p *journeyPasses) addTimingPoint(code string, passes []ovapi.Pass) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.passes == nil {
+		p.timingPoints = make(map[string][]ovapi.Pass)
+		p.passes = make(map[journeyKey][]ovapi.Pass)
+	}
+	p.timingPoints[code] = passes
+	for _, pass := range passes {
+		key := journeyKey{pass.RealtimeTripID(), pass.OperationDate}
+		p.passes[key] = append(p.passes[key], pass)
+	}
+}
+
+// cancelled reports whether every pass of the journey of pass is cancelled,
+// i.e. the whole journey does not run.
+func (p *journeyPasses) cancelled(pass ovapi.Pass) bool {
+	for _, other := range p.passes[journeyKey{pass.RealtimeTripID(), pass.OperationDate}] {
+		if other.TripStopStatus != ovapi.TripStopCancel {
+			return false
+		}
+	}
+	return true
+}
+
+// lineRoutes maps "DataOwnerCode:LinePlanningNumber" to the GTFS routes the
+// journeys of the line belong to.
+func (p *journeyPasses) lineRoutes() map[string][]string {
+	routes := make(map[string][]string)
+	seen := make(map[string]bool)
+	for journey, trip := range p.trips {
+		line := journey.RealtimeID[:strings.LastIndex(journey.RealtimeID, ":")]
+		if !seen[line+"|"+trip.RouteID] {
+			seen[line+"|"+trip.RouteID] = true
+			routes[line] = append(routes[line], trip.RouteID)
+		}
+	}
+	return routes
+}
+
+// tripUpdates turns the merged passes of a cycle into trip updates of their
+// GTFS trips and adds the updates providers report directly for trips
+// without passes.
+// The result is ordered by trip ID and start date.
+func (w *Worker) tripUpdates(journeys *journeyPasses) []models.TripUpdate {
+	updates := []models.TripUpdate{}
+	reported := make(map[string]bool)
+	for key, passes := range journeys.passes {
+		trip, ok := journeys.trips[key.journey()]
+		if !ok {
+			continue
+		}
+		update := passTripUpdate(trip, passes, w.tpcStops)
+		updates = append(updates, update)
+		reported[update.TripID+update.StartDate] = true
+	}
+	for _, update := range w.merger.TripUpdates() {
+		if !reported[update.TripID+update.StartDate] {
+			updates = append(updates, update)
+		}
+	}
+
+	sort.Slice(updates, func(a, b int) bool {
+		if updates[a].TripID != updates[b].TripID {
+			return updates[a].TripID < updates[b].TripID
+		}
+		return updates[a].StartDate < updates[b].StartDate
+	})
+	return updates
+}
+
+// passTripUpdate converts the passes of one journey, in stop order, into an
+// update of trip, attributed to the source of the latest pass. Cancelled
+// passes become skipped stops; a journey with only cancelled passes is a
+// cancelled trip. Passes at timing points without a GTFS stop are left out.
+func passTripUpdate(trip models.Trip, passes []ovapi.Pass, stops map[string]string) models.TripUpdate {
+	sort.Slice(passes, func(a, b int) bool { return passes[a].UserStopOrderNumber < passes[b].UserStopOrderNumber })
+
+	update := models.TripUpdate{
+		TripID:          trip.ID,
+		RouteID:         trip.RouteID,
+		StartDate:       strings.ReplaceAll(passes[0].OperationDate, "-", ""),
+		Relationship:    models.TripCanceled,
+		StopTimeUpdates: []models.StopTimeUpdate{},
+	}
+	for _, p := range passes {
+		if p.LastUpdateTimeStamp.After(update.Timestamp) || update.Source == "" {
+			update.Timestamp = p.LastUpdateTimeStamp.Time
+			update.Source = p.Source
+		}
+		if p.TripStopStatus != ovapi.TripStopCancel {
+			update.Relationship = models.TripScheduled
+		}
+
+		stopID, ok := stops[p.TimingPointCode]
+		if !ok {
+			continue
+		}
+		stu := models.StopTimeUpdate{StopID: stopID, Relationship: models.StopScheduled}
+		if p.TripStopStatus == ovapi.TripStopCancel {
+			stu.Relationship = models.StopSkipped
+		} else {
+			stu.ArrivalDelay, stu.ArrivalTime = passEvent(p.TargetArrivalTime, p.ExpectedArrivalTime)
+			stu.DepartureDelay, stu.DepartureTime = passEvent(p.TargetDepartureTime, p.ExpectedDepartureTime)
+			if p.Occupancy != "" {
+				occupancy := p.Occupancy
+				stu.Occupancy = &occupancy
+			}
+			stu.AssignedStopID = p.AssignedStopID
+		}
+		update.StopTimeUpdates = append(update.StopTimeUpdates, stu)
+	}
+
+	if update.Relationship == models.TripCanceled {
+		update.StopTimeUpdates = []models.StopTimeUpdate{}
+	}
+	return update
+}
+
+// passEvent returns the delay and expected time of a pass event, or nils
+// when either time is unknown.
+func passEvent(target, expected ovapi.LocalTime) (*int, *time.Time) {
+	if target.IsZero() || expected.IsZero() {
+		return nil, nil
+	}
+	delay := int(expected.Sub(target.Time).Seconds())
+	at := expected.Time
+	return &delay, &at
+}
@@ -0,0 +1,99 @@
+package realtime
+
+import (
+	"strings"
+	"time"
+
+	"arrivo-transit-api/internal/geo"
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+)
+
+// incomingWindow is how close to its expected arrival a driving vehicle is
+// reported as INCOMING_AT instead of IN_TRANSIT.
+const incomingWindow = 30 * time.Second
+
+// lineVehicles turns the actuals of a line into vehicles. KV78turbo has no
+// GPS positions, so a vehicle is placed at the last timing point it called
+// at (or the one it stands at) and heads towards the next one. Journeys that
+// are not tracked, cancelled or finished have no position and are skipped.
+//
+// stops maps TimingPointCodes to GTFS stop IDs. RouteID and TripID are left
+// for the caller to resolve from the vehicle ID, which is the realtime trip
+// ID, and the service date.
+func lineVehicles(line ovapi.LineActuals, stops map[string]string, now time.Time) []models.Vehicle {
+	var vehicles []models.Vehicle
+	for _, pass := range line.Actuals {
+		current, ok := line.NetworkStop(pass.JourneyPatternCode, pass.UserStopOrderNumber)
+		if !ok {
+			current = ovapi.NetworkStop{TimingPointCode: pass.TimingPointCode}
+		}
+		previous, hasPrevious := line.NetworkStop(pass.JourneyPatternCode, pass.UserStopOrderNumber-1)
+		next, hasNext := line.NetworkStop(pass.JourneyPatternCode, pass.UserStopOrderNumber+1)
+
+		v := models.Vehicle{
+			ID:          pass.RealtimeTripID(),
+			ServiceDate: strings.ReplaceAll(pass.OperationDate, "-", ""),
+			Timestamp:   pass.LastUpdateTimeStamp.Time,
+			StopID:      stopID(stops, current.TimingPointCode),
+		}
+
+		switch pass.TripStopStatus {
+		case ovapi.TripStopDriving:
+			v.Status = models.VehicleInTransit
+			if expected := pass.ExpectedArrivalTime.Time; !expected.IsZero() && expected.Sub(now) < incomingWindow {
+				v.Status = models.VehicleIncomingAt
+			}
+			if !hasPrevious {
+				// Not yet departed from the first stop
+				previous = current
+			}
+			v.Lat, v.Lon = previous.Latitude, previous.Longitude
+			v.LastStopID = stopID(stops, previous.TimingPointCode)
+			v.Bearing = bearing(previous, current)
+		case ovapi.TripStopArrived:
+			v.Status = models.VehicleStoppedAt
+			v.Lat, v.Lon = current.Latitude, current.Longitude
+			v.LastStopID = v.StopID
+			if hasNext {
+				v.Bearing = bearing(current, next)
+			}
+		case ovapi.TripStopOffRoute:
+			v.Status = models.VehicleOffRoute
+			v.Lat, v.Lon = current.Latitude, current.Longitude
+		default:
+			continue
+		}
+
+		// Timing points missing from the network have no coordinates
+		if v.Lat == 0 && v.Lon == 0 {
+			continue
+		}
+
+		if !pass.ExpectedArrivalTime.IsZero() && !pass.TargetArrivalTime.IsZero() {
+			delay := int(pass.ExpectedArrivalTime.Sub(pass.TargetArrivalTime.Time).Seconds())
+			v.Delay = &delay
+		}
+
+		vehicles = append(vehicles, v)
+	}
+	return vehicles
+}
+
+func stopID(stops map[string]string, timingPointCode string) *string {
+	id, ok := stops[timingPointCode]
+	if !ok {
+		return nil
+	}
+	return &id
+}
+
+// bearing returns the bearing from one timing point to the next, or nil when
+// they coincide.
+func bearing(from, to ovapi.NetworkStop) *float64 {
+	if from.Latitude == to.Latitude && from.Longitude == to.Longitude {
+		return nil
+	}
+	b := geo.Bearing(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	return &b
+}
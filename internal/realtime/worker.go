@@ -0,0 +1,394 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/store"
+)
+
+// listRefreshInterval is how often the TPC and line lists are reloaded.
+const listRefreshInterval = time.Hour
+
+// WorkerConfig tunes the realtime worker.
+type WorkerConfig struct {
+	Interval     time.Duration // Time between the starts of two polling cycles
+	BatchSize    int           // Codes per departures request
+	Concurrency  int           // Parallel departures requests
+	DepartureTTL time.Duration // Lifetime of departures per TPC in the store
+	LineTTL      time.Duration // Lifetime of vehicles in the store
+	MaxStaleness time.Duration // Age up to which the last snapshot of a key is kept when it is not refreshed
+	TimingPoints []string      // Only poll these TPCs; all mapped TPCs when empty
+	Archive      *Archive      // Receives the observed calls and vehicle positions; nil disables archiving
+}
+
+// Worker polls the realtime providers through a Merger and writes the
+// merged results to the realtime store, so API instances never call a
+// source on the request path.
+type Worker struct {
+	merger   *Merger
+	static   store.Static
+	realtime store.RealtimeStore
+	cfg      WorkerConfig
+	runTimes *RunTimes
+
+	codes       []string
+	tpcStops    map[string]string // GTFS stop ID by TimingPointCode
+	listsLoaded time.Time
+
+	vehicleRoutes map[string]bool // Routes stored with vehicles
+}
+
+// NewWorker creates a new realtime worker.
+func NewWorker(merger *Merger, static store.Static, realtime store.RealtimeStore, cfg WorkerConfig) *Worker {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	return &Worker{
+		merger:   merger,
+		static:   static,
+		realtime: realtime,
+		cfg:      cfg,
+		runTimes: NewRunTimes(),
+	}
+}
+
+// Run polls every Interval until ctx is cancelled. A cycle that overruns the
+// interval delays the next one instead of overlapping it. With an archive,
+// run times are meanwhile learned from the calls archived in the last week.
+func (w *Worker) Run(ctx context.Context) error {
+	if w.cfg.Archive != nil {
+		go w.seedRunTimes(ctx, time.Now())
+	}
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	scheduled := time.Now()
+	for {
+		w.Cycle(ctx, scheduled)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case scheduled = <-ticker.C:
+		}
+	}
+}
+
+// seedRunTimes learns run and dwell times from the archive. Without them,
+// predictions fall back to the timetable until enough calls are observed.
+func (w *Worker) seedRunTimes(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, runTimeSeedTimeout)
+	defer cancel()
+
+	n, err := w.runTimes.Seed(ctx, w.cfg.Archive.store, now.AddDate(0, 0, -runTimeHistory), now, now)
+	if err != nil {
+		log.Printf("WARN: Failed to learn run times from the archive: %v", err)
+		return
+	}
+	log.Printf("Learned run times from %d archived calls", n)
+}
+
+// Cycle runs one polling cycle that was due at scheduled and records its
+// Status.
+func (w *Worker) Cycle(ctx context.Context, scheduled time.Time) Status {
+	status := Status{CycleStarted: time.Now(), Interval: w.cfg.Interval.Seconds(), Sources: w.merger.Sources(),
+		DepartureSources: w.merger.DepartureSources()}
+
+	if err := w.refreshLists(ctx); err != nil {
+		log.Printf("WARN: Failed to refresh polling lists, using previous ones: %v", err)
+	}
+	status.TimingPoints = len(w.codes)
+
+	var failed atomic.Int64
+	var journeys journeyPasses
+	forEachBatch(ctx, w.codes, w.cfg.BatchSize, w.cfg.Concurrency, func(batch []string) {
+		if err := w.pollTimingPoints(ctx, batch, &journeys); err != nil {
+			failed.Add(1)
+			log.Printf("ERROR: Failed to poll %d timing points starting at %s: %v", len(batch), batch[0], err)
+		}
+	})
+	status.Predicted = journeys.propagateDelays(w.runTimes, time.Now())
+
+	// Vehicles are read before departures are stored, so the occupancy they
+	// report ends up on the passes they are heading to
+	vehicles, vehiclesErr := w.merger.Vehicles(ctx, w.tpcStops, time.Now())
+	if vehiclesErr != nil {
+		failed.Add(1)
+		log.Printf("ERROR: Failed to get vehicles: %v", vehiclesErr)
+	}
+	journeys.reportOccupancy(vehicles, w.tpcStops)
+
+	if err := w.storeDepartures(ctx, &journeys); err != nil {
+		log.Printf("ERROR: Failed to store departures: %v", err)
+	}
+	if w.cfg.Archive != nil {
+		w.cfg.Archive.AddCalls(journeys.callObservations(w.tpcStops, time.Now()))
+	}
+
+	// Without any vehicles from failing sources the last snapshot is kept
+	if vehiclesErr == nil || len(vehicles) > 0 {
+		if err := w.storeVehicles(ctx, vehicles, vehiclesErr != nil); err != nil {
+			log.Printf("ERROR: Failed to store vehicles: %v", err)
+		}
+		if w.cfg.Archive != nil {
+			w.cfg.Archive.AddPositions(positionObservations(vehicles))
+		}
+	}
+	status.Vehicles = len(vehicles)
+	status.Lines = w.merger.Lines()
+
+	w.merger.Expire(time.Now())
+	messages := w.merger.StopMessages(time.Now())
+	if messages != nil {
+		if err := w.store(ctx, StopMessagesKey, messages, 10*w.cfg.Interval); err != nil {
+			log.Printf("ERROR: Failed to store stop messages: %v", err)
+		}
+		status.StopMessages = len(messages)
+	}
+
+	tripUpdates := w.tripUpdates(&journeys)
+	if len(journeys.timingPoints) > 0 || len(w.codes) == 0 {
+		if err := w.store(ctx, TripUpdatesKey, tripUpdates, w.cfg.DepartureTTL); err != nil {
+			log.Printf("ERROR: Failed to store trip updates: %v", err)
+		}
+	}
+	status.TripUpdates = len(tripUpdates)
+
+	alerts, err := w.merger.Alerts(ctx, time.Now())
+	if err != nil {
+		log.Printf("ERROR: Failed to get alerts: %v", err)
+	}
+	alerts = append(alerts, stopMessageAlerts(messages, w.tpcStops, journeys.lineRoutes())...)
+	if err == nil || len(alerts) > 0 {
+		if err := w.store(ctx, AlertsKey, alerts, 10*w.cfg.Interval); err != nil {
+			log.Printf("ERROR: Failed to store alerts: %v", err)
+		}
+	}
+	status.Alerts = len(alerts)
+
+	status.CycleFinished = time.Now()
+	status.CycleDuration = status.CycleFinished.Sub(status.CycleStarted).Seconds()
+	status.LagSeconds = status.CycleFinished.Sub(scheduled).Seconds()
+	status.FailedRequests = int(failed.Load())
+
+	if status.LagSeconds > w.cfg.Interval.Seconds() {
+		log.Printf("WARN: Realtime polling lags %.1fs behind, cycle took %.1fs", status.LagSeconds, status.CycleDuration)
+	}
+	log.Printf("Realtime cycle done: %d timing points, %d lines, %d vehicles, %d failed requests in %.1fs",
+		status.TimingPoints, status.Lines, status.Vehicles, status.FailedRequests, status.CycleDuration)
+
+	if data, err := json.Marshal(status); err == nil {
+		if err := w.realtime.Set(ctx, StatusKey, data, 10*w.cfg.Interval); err != nil {
+			log.Printf("ERROR: Failed to store worker status: %v", err)
+		}
+	}
+
+	return status
+}
+
+// refreshLists reloads the TPCs to poll and the TPC to stop mapping once per
+// listRefreshInterval.
+func (w *Worker) refreshLists(ctx context.Context) error {
+	if w.codes != nil && time.Since(w.listsLoaded) < listRefreshInterval {
+		return nil
+	}
+
+	codes := w.cfg.TimingPoints
+	if len(codes) == 0 {
+		var err error
+		if codes, err = w.static.AllTimingPointCodes(ctx); err != nil {
+			return err
+		}
+	}
+
+	tpcStops, err := w.static.TimingPointStops(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.codes, w.tpcStops, w.listsLoaded = codes, tpcStops, time.Now()
+	return nil
+}
+
+// forEachBatch calls fn for every size slice of items, running at most
+// concurrency calls at a time.
+func forEachBatch(ctx context.Context, items []string, size, concurrency int, fn func(batch []string)) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for start := 0; start < len(items); start += size {
+		if ctx.Err() != nil {
+			break
+		}
+		batch := items[start:min(start+size, len(items))]
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			fn(batch)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// pollTimingPoints fetches the merged passes of one batch of TPCs and adds
+// them to journeys.
+func (w *Worker) pollTimingPoints(ctx context.Context, codes []string, journeys *journeyPasses) error {
+	passes, err := w.merger.Departures(ctx, codes, w.tpcStops)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		journeys.addTimingPoint(code, passes[code])
+	}
+	return nil
+}
+
+// storeDepartures resolves the GTFS trips of all journeys and stores the
+// departures of every polled TPC, linked to their stop, route and trip. A
+// cancelled pass is a skipped stop unless the whole journey is cancelled;
+// journeys without a trip are added to the timetable. TPCs without passes
+// are stored as empty, so readers can tell "no departures" from "not
+// polled".
+func (w *Worker) storeDepartures(ctx context.Context, journeys *journeyPasses) error {
+	keys := make([]models.Journey, 0, len(journeys.passes))
+	for key := range journeys.passes {
+		keys = append(keys, key.journey())
+	}
+	trips, err := w.static.TripsByRealtimeID(ctx, keys)
+	if err != nil {
+		log.Printf("WARN: Failed to look up trips, storing departures without them: %v", err)
+	}
+	journeys.trips = trips
+
+	codes := make([]string, 0, len(journeys.timingPoints))
+	for code := range journeys.timingPoints {
+		codes = append(codes, code)
+	}
+
+	var failed atomic.Int64
+	var lastErr atomic.Value
+	forEachBatch(ctx, codes, w.cfg.BatchSize, w.cfg.Concurrency, func(batch []string) {
+		for _, code := range batch {
+			departures := []models.Departure{}
+			for _, pass := range journeys.timingPoints[code] {
+				departure := pass.Departure()
+				departure.StopID = w.tpcStops[code]
+				if pass.AssignedStopID != "" {
+					departure.StopID = pass.AssignedStopID
+				}
+				if trip, ok := journeys.trip(pass); ok {
+					departure.RouteID, departure.TripID = trip.RouteID, trip.ID
+				} else if trips != nil {
+					departure.Added = true
+				}
+				if pass.TripStopStatus == ovapi.TripStopCancel {
+					departure.Cancelled = journeys.cancelled(pass)
+					departure.Skipped = !departure.Cancelled
+				}
+				departures = append(departures, departure)
+			}
+			if err := w.store(ctx, DeparturesKey(code), departures, w.cfg.DepartureTTL); err != nil {
+				failed.Add(1)
+				lastErr.Store(err)
+			}
+		}
+	})
+
+	if n := failed.Load(); n > 0 {
+		return fmt.Errorf("%d timing points failed: %w", n, lastErr.Load().(error))
+	}
+	return nil
+}
+
+// storeVehicles resolves the GTFS trip and route of every vehicle from its
+// realtime trip ID and service date and stores them per route and as a
+// whole. Routes that had vehicles in the previous cycle but none now are
+// stored empty instead of waiting for their TTL, unless the vehicles are
+// partial because a provider failed: its routes keep their last snapshot.
+func (w *Worker) storeVehicles(ctx context.Context, vehicles []models.Vehicle, partial bool) error {
+	keys := make([]models.Journey, 0, len(vehicles))
+	for _, v := range vehicles {
+		if v.ServiceDate != "" {
+			keys = append(keys, models.Journey{RealtimeID: v.ID, ServiceDate: v.ServiceDate})
+		}
+	}
+	trips, err := w.static.TripsByRealtimeID(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	byRoute := make(map[string][]models.Vehicle)
+	for i, v := range vehicles {
+		if trip, ok := trips[models.Journey{RealtimeID: v.ID, ServiceDate: v.ServiceDate}]; ok {
+			vehicles[i].TripID, vehicles[i].RouteID = trip.ID, trip.RouteID
+		}
+		if vehicles[i].RouteID == "" {
+			continue
+		}
+		byRoute[vehicles[i].RouteID] = append(byRoute[vehicles[i].RouteID], vehicles[i])
+	}
+
+	if !partial {
+		for routeID := range w.vehicleRoutes {
+			if _, ok := byRoute[routeID]; !ok {
+				byRoute[routeID] = []models.Vehicle{}
+			}
+		}
+	}
+	for routeID, routeVehicles := range byRoute {
+		value := models.RouteVehicles{RouteID: routeID, Vehicles: routeVehicles}
+		if err := w.store(ctx, RouteVehiclesKey(routeID), value, w.cfg.LineTTL); err != nil {
+			return err
+		}
+	}
+
+	// Routes left out of a partial update are emptied by the next complete
+	// one
+	vehicleRoutes := w.vehicleRoutes
+	if !partial || vehicleRoutes == nil {
+		vehicleRoutes = make(map[string]bool, len(byRoute))
+	}
+	for routeID, routeVehicles := range byRoute {
+		if len(routeVehicles) > 0 {
+			vehicleRoutes[routeID] = true
+		}
+	}
+	w.vehicleRoutes = vehicleRoutes
+
+	if vehicles == nil {
+		vehicles = []models.Vehicle{}
+	}
+	return w.store(ctx, VehiclesKey, vehicles, w.cfg.LineTTL)
+}
+
+// store writes v under key as a Snapshot that is fresh for ttl and kept
+// until it is MaxStaleness old, so readers can fall back to it when a later
+// cycle fails to refresh it.
+func (w *Worker) store(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	snapshot, err := json.Marshal(Snapshot{UpdatedAt: now, FreshUntil: now.Add(ttl), Data: data})
+	if err != nil {
+		return err
+	}
+	return w.realtime.Set(ctx, key, snapshot, max(ttl, w.cfg.MaxStaleness))
+}
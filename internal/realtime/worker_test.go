@@ -0,0 +1,99 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/store/memory"
+)
+
+// trackedVehicles is a Provider reporting vehicles, or failing.
+type trackedVehicles struct {
+	source string
+
+	mu       sync.Mutex
+	vehicles []models.Vehicle
+	err      error
+}
+
+func (p *trackedVehicles) set(vehicles []models.Vehicle, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.vehicles, p.err = vehicles, err
+}
+
+func (p *trackedVehicles) Source() string  { return p.source }
+func (p *trackedVehicles) Available() bool { return true }
+
+func (p *trackedVehicles) Departures(ctx context.Context, timingPointCodes []string) (map[string][]ovapi.Pass, error) {
+	return nil, nil
+}
+
+func (p *trackedVehicles) UpdatePass(pass ovapi.Pass, stops map[string]string) (ovapi.Pass, bool) {
+	return ovapi.Pass{}, false
+}
+
+func (p *trackedVehicles) Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.vehicles, p.err
+}
+
+func (p *trackedVehicles) Alerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	return nil, nil
+}
+
+func TestWorkerKeepsRoutesOfFailingVehicleProviders(t *testing.T) {
+	now := time.Now()
+	today := models.NewServiceDay(now).String()
+	st := memory.New()
+	for _, route := range []string{"tram", "bus"} {
+		realtimeID := "GVB:" + route + ":1"
+		st.PutRoute(models.Route{ID: route})
+		st.PutTrip(models.Trip{ID: route + "-trip", RouteID: route, ServiceID: "daily", RealtimeID: &realtimeID})
+	}
+	st.SetServiceDate("daily", today, true)
+
+	trams := &trackedVehicles{source: SourceOVapi}
+	buses := &trackedVehicles{source: SourceGTFSRT}
+	vehicle := func(route string) []models.Vehicle {
+		return []models.Vehicle{{ID: "GVB:" + route + ":1", ServiceDate: today, Lat: 52.37, Lon: 4.9, Timestamp: now}}
+	}
+	rt := memory.NewRealtime()
+	w := newTestWorker(NewMerger(trams, buses), st, rt)
+	routeVehicles := func(route string) []models.Vehicle {
+		t.Helper()
+		var stored models.RouteVehicles
+		readStored(t, rt, RouteVehiclesKey(route), &stored)
+		return stored.Vehicles
+	}
+
+	trams.set(vehicle("tram"), nil)
+	buses.set(vehicle("bus"), nil)
+	w.Cycle(context.Background(), now)
+	if len(routeVehicles("tram")) != 1 || len(routeVehicles("bus")) != 1 {
+		t.Fatalf("tram %v, bus %v; want a vehicle on each", routeVehicles("tram"), routeVehicles("bus"))
+	}
+
+	// The bus provider fails: its route keeps the last snapshot
+	buses.set(nil, errors.New("feed unavailable"))
+	w.Cycle(context.Background(), now)
+	if len(routeVehicles("bus")) != 1 {
+		t.Errorf("bus route = %v after its provider failed, want the last vehicle", routeVehicles("bus"))
+	}
+
+	// Once every provider answers, routes without vehicles are emptied
+	buses.set(nil, nil)
+	w.Cycle(context.Background(), now)
+	if vehicles := routeVehicles("bus"); len(vehicles) != 0 {
+		t.Errorf("bus route = %v, want it emptied", vehicles)
+	}
+	if len(routeVehicles("tram")) != 1 {
+		t.Errorf("tram route = %v, want its vehicle", routeVehicles("tram"))
+	}
+}
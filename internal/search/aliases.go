@@ -0,0 +1,50 @@
+package search
+
+import "strings"
+
+// Aliases maps normalized alias phrases ("cs", "den bosch") to the normalized
+// text they stand for ("centraal station", "s hertogenbosch").
+type Aliases map[string]string
+
+// NewAliases builds an alias dictionary from raw alias/expansion pairs,
+// normalizing both sides.
+func NewAliases(pairs map[string]string) Aliases {
+	aliases := make(Aliases, len(pairs))
+	for alias, expansion := range pairs {
+		key := Normalize(alias)
+		if key == "" {
+			continue
+		}
+		aliases[key] = Normalize(expansion)
+	}
+	return aliases
+}
+
+// Expand rewrites a normalized query by replacing every alias phrase with its
+// expansion. Longer phrases win, so "a dam zuid" expands "a dam" rather than
+// leaving "a" and "dam" alone. Expanded text is not expanded again.
+func (a Aliases) Expand(query string) string {
+	if len(a) == 0 {
+		return query
+	}
+
+	tokens := strings.Fields(query)
+	out := make([]string, 0, len(tokens))
+	for i := 0; i < len(tokens); {
+		matched := false
+		for j := len(tokens); j > i; j-- {
+			if expansion, ok := a[strings.Join(tokens[i:j], " ")]; ok {
+				out = append(out, expansion)
+				i = j
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out = append(out, tokens[i])
+			i++
+		}
+	}
+
+	return strings.Join(out, " ")
+}
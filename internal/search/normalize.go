@@ -0,0 +1,62 @@
+// Package search contains the text handling shared by the stop search
+// queries, so Go code and SQL agree on what a "normalized" name is.
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations spells the letters that do not decompose into a base
+// letter and diacritics the way unaccent does, except for ø, which is "oe"
+// in both (migration 000015).
+var transliterations = map[rune]string{
+	'ß': "ss", 'ẞ': "ss",
+	'æ': "ae", 'Æ': "ae",
+	'ø': "oe", 'Ø': "oe",
+	'œ': "oe", 'Œ': "oe",
+	'ĳ': "ij", 'Ĳ': "ij",
+	'đ': "d", 'Đ': "d", 'ð': "d", 'Ð': "d",
+	'þ': "th", 'Þ': "th",
+	'ł': "l", 'Ł': "l", 'ŀ': "l", 'Ŀ': "l",
+	'ħ': "h", 'Ħ': "h",
+	'ŧ': "t", 'Ŧ': "t",
+	'ı': "i",
+	'ﬀ': "ff", 'ﬁ': "fi", 'ﬂ': "fl", 'ﬃ': "ffi", 'ﬄ': "ffl", 'ﬆ': "st",
+}
+
+// Normalize lowercases s, strips diacritics, transliterates letters such as
+// ß and æ and collapses every run of characters that is not a letter or
+// digit into a single space. It mirrors the stop_search_name() SQL function
+// from migrations 000003 and 000015, so "'s-Hertogenbosch" and
+// "s'hertogenbosch" both become "s hertogenbosch".
+func Normalize(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	space := false
+	write := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Combining mark left over from decomposition, drop it.
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(unicode.ToLower(r)))
+		case transliterations[r] != "":
+			write(transliterations[r])
+		default:
+			space = true
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,28 @@
+package search
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"'s-Hertogenbosch", "s hertogenbosch"},
+		{"s'hertogenbosch", "s hertogenbosch"},
+		{"Amsterdam, Centraal Station", "amsterdam centraal station"},
+		{"Café Zürich", "cafe zurich"},
+		{"Straße", "strasse"},
+		{"GROẞE STRAẞE", "grosse strasse"},
+		{"Ærøskøbing", "aeroeskoebing"},
+		{"København H", "koebenhavn h"},
+		{"Œuvre", "oeuvre"},
+		{"ĳsselmeer", "ijsselmeer"},
+		{"Łódź", "lodz"},
+		{"Þingvellir", "thingvellir"},
+		{"ﬁets", "fiets"},
+		{"  --  ", ""},
+	} {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
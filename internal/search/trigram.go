@@ -0,0 +1,87 @@
+package search
+
+import "strings"
+
+// The functions below approximate pg_trgm so the in-memory stores rank
+// results like the Postgres queries do. They expect normalized input.
+
+// trigrams returns the set of trigrams of s the way pg_trgm builds them: each
+// word is padded with two leading spaces and one trailing space.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range strings.Fields(s) {
+		padded := "  " + word + " "
+		for i := 0; i+3 <= len(padded); i++ {
+			set[padded[i:i+3]] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Similarity is the equivalent of pg_trgm similarity(a, b): the number of
+// shared trigrams divided by the number of distinct trigrams in both.
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// WordSimilarity approximates pg_trgm word_similarity(query, text): the share
+// of the query's trigrams that also occur in text, so a short query scores
+// high against a long name that contains it.
+func WordSimilarity(query, text string) float64 {
+	tq, tt := trigrams(query), trigrams(text)
+	if len(tq) == 0 || len(tt) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range tq {
+		if _, ok := tt[t]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(tq))
+}
+
+// SplitCity splits a GTFS-NL stop name of the form "City, Stop name" into its
+// normalized city and place parts, like the search_city and search_place
+// columns. Names without a city prefix return an empty city.
+func SplitCity(name string) (city, place string) {
+	if i := strings.IndexByte(name, ','); i >= 0 {
+		return Normalize(name[:i]), Normalize(name[i+1:])
+	}
+	return "", Normalize(name)
+}
+
+// Score ranks a stop name against a normalized query the same way the
+// Postgres stop search does. It returns 0 for names that would not match.
+func Score(query, name string) float64 {
+	normalized := Normalize(name)
+	city, place := SplitCity(name)
+
+	similarity := Similarity(normalized, query)
+	wordSimilarity := WordSimilarity(query, normalized)
+	placeSimilarity := Similarity(place, query)
+	contains := strings.Contains(normalized, query)
+	if similarity < 0.3 && wordSimilarity < 0.6 && placeSimilarity < 0.3 && !contains {
+		return 0
+	}
+
+	score := max(similarity, wordSimilarity)
+	if city != "" && strings.Contains(query, city) {
+		rest := strings.TrimSpace(strings.ReplaceAll(query, city, ""))
+		score = max(score, Similarity(place, rest)+0.2)
+	}
+	if strings.HasPrefix(normalized, query) || strings.HasPrefix(place, query) {
+		score += 0.5
+	}
+	return score
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// AlertFilter selects service alerts. Empty fields do not filter.
+type AlertFilter struct {
+	RouteID         string    // Alerts affecting the route, including alerts for its whole agency
+	StopID          string    // Alerts affecting the stop or, for a station, its quays
+	AgencyID        string    // Alerts selecting the agency
+	Effects         []string  // Any of these effects
+	Severities      []string  // Any of these severities
+	At              time.Time // Active at this time; now when zero
+	IncludeUpcoming bool      // Also alerts that become active after At
+}
+
+// GetAlerts returns the service alerts matching filter. An unknown route or
+// stop returns ErrNotFound.
+func (s *TransitService) GetAlerts(ctx context.Context, filter AlertFilter) ([]models.Alert, error) {
+	if filter.At.IsZero() {
+		filter.At = time.Now()
+	}
+
+	var route *models.Route
+	if filter.RouteID != "" {
+		var err error
+		if route, err = s.static.GetRoute(ctx, filter.RouteID); err != nil {
+			return nil, err
+		}
+	}
+	var quays map[string]models.Stop
+	if filter.StopID != "" {
+		var err error
+		if quays, err = s.static.Quays(ctx, filter.StopID); err != nil {
+			return nil, err
+		}
+		if len(quays) == 0 {
+			return nil, ErrNotFound
+		}
+	}
+
+	all, err := s.RealtimeAlerts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []models.Alert{}
+	for _, a := range all {
+		if filter.IncludeUpcoming {
+			if a.Ended(filter.At) {
+				continue
+			}
+		} else if !a.ActiveAt(filter.At) {
+			continue
+		}
+		if len(filter.Effects) > 0 && !slices.Contains(filter.Effects, a.Effect) {
+			continue
+		}
+		if len(filter.Severities) > 0 && !slices.Contains(filter.Severities, a.Severity) {
+			continue
+		}
+		if route != nil && !affectsRoute(a, route) {
+			continue
+		}
+		if quays != nil && !informs(a, func(e models.AlertEntity) bool {
+			_, ok := quays[e.StopID]
+			return ok
+		}) {
+			continue
+		}
+		if filter.AgencyID != "" && !informs(a, func(e models.AlertEntity) bool { return e.AgencyID == filter.AgencyID }) {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+// activeAlerts returns the alerts active now. Failures are logged and
+// reported as no alerts, so they never fail the response alerts are
+// attached to.
+func (s *TransitService) activeAlerts(ctx context.Context) []models.Alert {
+	alerts, err := s.GetAlerts(ctx, AlertFilter{})
+	if err != nil {
+		log.Printf("WARN: Failed to get alerts: %v", err)
+		return nil
+	}
+	return alerts
+}
+
+// informs reports whether any informed entity of a satisfies match.
+func informs(a models.Alert, match func(models.AlertEntity) bool) bool {
+	return slices.ContainsFunc(a.Informed, match)
+}
+
+// affectsRoute reports whether a selects route or the whole agency operating
+// it. Alerts selecting only trips or stops are not matched: telling whether
+// those are on the route takes its timetable.
+func affectsRoute(a models.Alert, route *models.Route) bool {
+	return informs(a, func(e models.AlertEntity) bool {
+		if e.RouteID != "" {
+			return e.RouteID == route.ID
+		}
+		return e.AgencyID != "" && e.TripID == "" && e.StopID == "" &&
+			route.AgencyID != nil && e.AgencyID == *route.AgencyID
+	})
+}
+
+// attachDepartureAlerts sets the alerts affecting every departure from the
+// stop stopID, matching on the departure's operator, route, trip and quay.
+func attachDepartureAlerts(departures []models.Departure, alerts []models.Alert, stopID string) {
+	for i, d := range departures {
+		var matched []models.Alert
+		for _, a := range alerts {
+			if informs(a, func(e models.AlertEntity) bool {
+				return e.Matches(d.Operator, d.RouteID, d.TripID, d.StopID, stopID)
+			}) {
+				matched = append(matched, a)
+			}
+		}
+		departures[i].Alerts = matched
+	}
+}
+
+// routeAlerts returns the alerts among alerts that affect route.
+func routeAlerts(alerts []models.Alert, route *models.Route) []models.Alert {
+	var matched []models.Alert
+	for _, a := range alerts {
+		if affectsRoute(a, route) {
+			matched = append(matched, a)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// minOccupancyVehicles is the number of vehicles seen below which a
+// forecast falls back to a coarser one.
+const minOccupancyVehicles = 3
+
+// occupancyForecasts counts the vehicles seen leaving stops by trip and
+// kind of day with Hour -1, by route, kind of day and hour, and by route and
+// hour on any day with Weekday -1.
+type occupancyForecasts map[models.OccupancySlot]*models.OccupancyCounts
+
+// dayType groups ISO weekdays into the kinds of day timetables tell apart:
+// Monday to Friday as 1, Saturday as 6 and Sunday as 7.
+func dayType(weekday int) int {
+	if weekday <= 5 {
+		return 1
+	}
+	return weekday
+}
+
+func newOccupancyForecasts(counts map[models.OccupancySlot]*models.OccupancyCounts) occupancyForecasts {
+	forecasts := make(occupancyForecasts)
+	for slot, c := range counts {
+		days := dayType(slot.Weekday)
+		for _, key := range []models.OccupancySlot{
+			{TripID: slot.TripID, StopID: slot.StopID, Weekday: days, Hour: -1},
+			{RouteID: slot.RouteID, StopID: slot.StopID, Weekday: days, Hour: slot.Hour},
+			{RouteID: slot.RouteID, StopID: slot.StopID, Weekday: -1, Hour: slot.Hour},
+		} {
+			if forecasts[key] == nil {
+				forecasts[key] = models.NewOccupancyCounts()
+			}
+			forecasts[key].Add(c)
+		}
+	}
+	return forecasts
+}
+
+// forecast returns the occupancy a vehicle of a trip leaving a stop at a
+// time most likely has: the median of earlier vehicles of the trip on the
+// same kind of day, or else of its route in the same hour on the same kind
+// of day, or on any day. It returns "" without enough history.
+func (f occupancyForecasts) forecast(routeID, tripID, stopID string, at time.Time) string {
+	local := at.In(models.TimetableLocation)
+	weekday := int(local.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	days := dayType(weekday)
+
+	for _, key := range []models.OccupancySlot{
+		{TripID: tripID, StopID: stopID, Weekday: days, Hour: -1},
+		{RouteID: routeID, StopID: stopID, Weekday: days, Hour: local.Hour()},
+		{RouteID: routeID, StopID: stopID, Weekday: -1, Hour: local.Hour()},
+	} {
+		if key.RouteID == "" && key.TripID == "" {
+			continue
+		}
+		if c := f[key]; c != nil && c.Vehicles >= minOccupancyVehicles {
+			return c.Median()
+		}
+	}
+	return ""
+}
+
+// occupancyForecasts returns the forecasts for the stops on routeID, or at
+// stopID and its quays when routeID is empty, from the rollups of the last
+// profileDays. Failures are logged and reported as no history.
+func (s *TransitService) occupancyForecasts(ctx context.Context, routeID, stopID string, now time.Time) occupancyForecasts {
+	cacheKey := "occupancy:stop:" + stopID
+	if routeID != "" {
+		cacheKey = "occupancy:route:" + routeID
+	}
+	if forecasts, ok := s.profiles.get(cacheKey, now); ok {
+		return forecasts.(occupancyForecasts)
+	}
+
+	to := models.NewServiceDay(now).Date
+	counts, err := s.static.OccupancyCounts(ctx, routeID, stopID, to.AddDate(0, 0, -profileDays), to)
+	if err != nil {
+		log.Printf("WARN: Failed to get occupancy for %s: %v", cacheKey, err)
+		return nil
+	}
+	forecasts := newOccupancyForecasts(counts)
+	s.profiles.add(cacheKey, forecasts, now)
+	return forecasts
+}
+
+// forecastDepartureOccupancy sets a forecast occupancy on the departures at
+// stopID that will still leave and no realtime source reported one for.
+func (s *TransitService) forecastDepartureOccupancy(ctx context.Context, stopID string, departures []models.Departure, now time.Time) {
+	if len(departures) == 0 {
+		return
+	}
+	forecasts := s.occupancyForecasts(ctx, "", stopID, now)
+	for i := range departures {
+		d := &departures[i]
+		if d.Occupancy != nil || d.Cancelled || d.Skipped {
+			continue
+		}
+		quay := d.StopID
+		if quay == "" {
+			quay = stopID
+		}
+		if occupancy := forecasts.forecast(d.RouteID, d.TripID, quay, d.Departure); occupancy != "" {
+			d.Occupancy, d.OccupancyForecast = &occupancy, true
+		}
+	}
+}
+
+// forecastVehicleOccupancy sets a forecast occupancy, at the stop they are
+// heading to, on the vehicles of routeID that do not report one.
+func (s *TransitService) forecastVehicleOccupancy(ctx context.Context, routeID string, vehicles []models.Vehicle, now time.Time) {
+	if len(vehicles) == 0 {
+		return
+	}
+	forecasts := s.occupancyForecasts(ctx, routeID, "", now)
+	for i := range vehicles {
+		v := &vehicles[i]
+		if v.Occupancy != nil || v.StopID == nil {
+			continue
+		}
+		if occupancy := forecasts.forecast(routeID, v.TripID, *v.StopID, now); occupancy != "" {
+			v.Occupancy, v.OccupancyForecast = &occupancy, true
+		}
+	}
+}
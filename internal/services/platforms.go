@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+)
+
+// attachPlatforms sets the platform the departures at stopID leave from and
+// the one in the timetable, and flags platform changes. Quays outside stopID,
+// which a realtime source may assign a journey to, are looked up one by one.
+func (s *TransitService) attachPlatforms(ctx context.Context, stopID string, departures []models.Departure) error {
+	if len(departures) == 0 {
+		return nil
+	}
+	quays, err := s.static.Quays(ctx, stopID)
+	if err != nil {
+		return fmt.Errorf("failed to get quays of stop %s: %w", stopID, err)
+	}
+	platform := func(id string) (string, error) {
+		if id == "" {
+			return "", nil
+		}
+		if quay, ok := quays[id]; ok {
+			return quay.PlatformCode, nil
+		}
+		quay, err := s.static.GetStop(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			quays[id] = models.Stop{ID: id}
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get quay %s: %w", id, err)
+		}
+		quays[id] = *quay
+		return quay.PlatformCode, nil
+	}
+
+	for i := range departures {
+		d := &departures[i]
+		if d.Platform, err = platform(d.StopID); err != nil {
+			return err
+		}
+		if d.PlannedPlatform, err = platform(d.PlannedStopID); err != nil {
+			return err
+		}
+		d.PlatformChanged = d.PlannedStopID != "" && d.StopID != "" && d.StopID != d.PlannedStopID
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+)
+
+const (
+	maxDeadReckoning = 2 * time.Minute  // Longest projection beyond a vehicle's last report
+	maxPathOffset    = 150.0            // Meters a report may lie off its trip's path to be projected along it
+	locateWindow     = 2000.0           // Meters around its timetabled position a report is looked for on the path
+	predictionStep   = 5 * time.Second  // Interval between predicted positions
+	predictionSpan   = 30 * time.Second // How far ahead positions are predicted
+	tripPathTTL      = time.Hour
+	tripPathEntries  = 2000
+	tripPathLoads    = 8 // Trip paths loaded at once
+)
+
+// tripPathCache keeps the paths of recently seen trips, which change only
+// with a new GTFS feed.
+type tripPathCache struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+type tripPathEntry struct {
+	path     *models.TripPath
+	loadedAt time.Time
+}
+
+func newTripPathCache() *tripPathCache {
+	return &tripPathCache{cache: lru.New(tripPathEntries)}
+}
+
+// tripPath returns the path of a trip from the cache or the static store.
+func (s *TransitService) tripPath(ctx context.Context, tripID string) (*models.TripPath, error) {
+	s.paths.mu.Lock()
+	if v, ok := s.paths.cache.Get(tripID); ok && time.Since(v.(tripPathEntry).loadedAt) < tripPathTTL {
+		s.paths.mu.Unlock()
+		return v.(tripPathEntry).path, nil
+	}
+	s.paths.mu.Unlock()
+
+	path, err := s.static.TripPath(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.paths.mu.Lock()
+	s.paths.cache.Add(tripID, tripPathEntry{path, time.Now()})
+	s.paths.mu.Unlock()
+	return path, nil
+}
+
+// estimatePositions projects the vehicles with a known trip from their last
+// reported position to where they are expected now, and predicts their
+// positions for the next predictionSpan. Vehicles that cannot be projected
+// keep their reported position. Up to tripPathLoads paths are loaded at
+// once.
+func (s *TransitService) estimatePositions(ctx context.Context, vehicles []models.Vehicle, now time.Time) {
+	loads := make(chan struct{}, tripPathLoads)
+	var wg sync.WaitGroup
+	for i := range vehicles {
+		v := &vehicles[i]
+		if v.TripID == "" || v.Status == models.VehicleOffRoute {
+			continue
+		}
+		loads <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-loads
+				wg.Done()
+			}()
+			path, err := s.tripPath(ctx, v.TripID)
+			if err != nil {
+				if !errors.Is(err, store.ErrNotFound) {
+					log.Printf("WARN: Failed to get path of trip %s: %v", v.TripID, err)
+				}
+				return
+			}
+			estimatePosition(v, path, now)
+		}()
+	}
+	wg.Wait()
+}
+
+// estimatePosition dead-reckons v along path: from where its last report
+// lies on the path, it advances as far as the timetable, shifted by the
+// vehicle's delay, moves it between the report and the time asked for.
+// Projections stop maxDeadReckoning after the report.
+func estimatePosition(v *models.Vehicle, path *models.TripPath, now time.Time) {
+	delay := 0.0
+	if v.Delay != nil {
+		delay = float64(*v.Delay)
+	}
+
+	// The service day is the one whose timetable has the trip running then
+	scheduled := func(t time.Time) (float64, bool) {
+		for _, day := range models.ServiceDays(t, t) {
+			if dist, ok := path.ScheduledDist(t.Sub(day.Start).Seconds() - delay); ok {
+				return dist, true
+			}
+		}
+		return 0, false
+	}
+
+	reportedScheduled, ok := scheduled(v.Timestamp)
+	if !ok {
+		return
+	}
+	reported, offset, ok := path.Locate(v.Lat, v.Lon, reportedScheduled, locateWindow)
+	if !ok || offset > maxPathOffset {
+		return
+	}
+
+	at := func(t time.Time) float64 {
+		if t.Sub(v.Timestamp) > maxDeadReckoning {
+			t = v.Timestamp.Add(maxDeadReckoning)
+		}
+		dist, ok := scheduled(t)
+		if !ok {
+			return reported
+		}
+		return reported + max(0, dist-reportedScheduled)
+	}
+
+	v.Reported = &models.Position{Lat: v.Lat, Lon: v.Lon, Time: v.Timestamp}
+	var bearing float64
+	v.Lat, v.Lon, bearing = path.PointAt(at(now))
+	v.Bearing = &bearing
+	v.Estimated = true
+
+	v.Path = make([]models.Position, 0, predictionSpan/predictionStep)
+	for t := now.Add(predictionStep); !t.After(now.Add(predictionSpan)); t = t.Add(predictionStep) {
+		lat, lon, _ := path.PointAt(at(t))
+		v.Path = append(v.Path, models.Position{Lat: lat, Lon: lon, Time: t})
+	}
+}
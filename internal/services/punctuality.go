@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+const (
+	// defaultPunctualityDays is the window of service days, up to today,
+	// when none is asked for.
+	defaultPunctualityDays = 28
+	// maxPunctualityDays bounds the window of service days.
+	maxPunctualityDays = 366
+)
+
+// PunctualityFilter selects the calls punctuality is computed over. Exactly
+// one of RouteID and StopID is set.
+type PunctualityFilter struct {
+	RouteID string
+	StopID  string    // Includes the quays of a station
+	From    time.Time // First service day; defaultPunctualityDays before To when zero
+	To      time.Time // Last service day; today when zero
+}
+
+// GetPunctuality returns the punctuality of a route or stop over a window of
+// service days, from the rollups of archived calls. An unknown route or stop
+// returns ErrNotFound; a window that ends before it starts or spans more
+// than maxPunctualityDays returns ErrInvalid.
+func (s *TransitService) GetPunctuality(ctx context.Context, filter PunctualityFilter) (*models.Punctuality, error) {
+	if filter.To.IsZero() {
+		filter.To = time.Now()
+	}
+	to := models.NewServiceDay(filter.To).Date
+	from := to.AddDate(0, 0, 1-defaultPunctualityDays)
+	if !filter.From.IsZero() {
+		from = models.NewServiceDay(filter.From).Date
+	}
+	if to.Before(from) || to.Sub(from) >= maxPunctualityDays*24*time.Hour {
+		return nil, ErrInvalid
+	}
+
+	cacheKey := fmt.Sprintf("punctuality:%s:%s:%s:%s", filter.RouteID, filter.StopID, from.Format("20060102"), to.Format("20060102"))
+
+	// Rollups change hourly, so responses are cached like searches
+	if cachedData, found := s.cache.Get(ctx, cacheKey); found {
+		log.Printf("CACHE HIT (LRU): %s", cacheKey)
+		var punctuality models.Punctuality
+		if err := json.Unmarshal(cachedData, &punctuality); err == nil {
+			return &punctuality, nil
+		}
+	}
+	if cachedData, err := s.realtime.Get(ctx, cacheKey); err == nil {
+		log.Printf("CACHE HIT (Redis): %s", cacheKey)
+		var punctuality models.Punctuality
+		if err := json.Unmarshal(cachedData, &punctuality); err == nil {
+			s.cache.Set(ctx, cacheKey, cachedData)
+			return &punctuality, nil
+		}
+	}
+
+	if filter.RouteID != "" {
+		if _, err := s.static.GetRoute(ctx, filter.RouteID); err != nil {
+			return nil, err
+		}
+	} else if _, err := s.static.GetStop(ctx, filter.StopID); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.static.PunctualityCounts(ctx, filter.RouteID, filter.StopID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count punctuality: %w", err)
+	}
+
+	punctuality := summarizePunctuality(counts)
+	punctuality.RouteID, punctuality.StopID = filter.RouteID, filter.StopID
+	punctuality.From, punctuality.To = from.Format("2006-01-02"), to.Format("2006-01-02")
+
+	if marshaledData, err := json.Marshal(punctuality); err == nil {
+		s.realtime.Set(ctx, cacheKey, marshaledData, redisCacheDuration)
+		s.cache.Set(ctx, cacheKey, marshaledData)
+	}
+	return punctuality, nil
+}
+
+// summarizePunctuality combines the counts per weekday and hour into the
+// overall, hourly and weekday statistics.
+func summarizePunctuality(counts map[models.PunctualitySlot]*models.PunctualityCounts) *models.Punctuality {
+	overall := models.NewPunctualityCounts()
+	byHour := make(map[int]*models.PunctualityCounts)
+	byWeekday := make(map[int]*models.PunctualityCounts)
+	for slot, c := range counts {
+		overall.Add(c)
+		if byHour[slot.Hour] == nil {
+			byHour[slot.Hour] = models.NewPunctualityCounts()
+		}
+		byHour[slot.Hour].Add(c)
+		if byWeekday[slot.Weekday] == nil {
+			byWeekday[slot.Weekday] = models.NewPunctualityCounts()
+		}
+		byWeekday[slot.Weekday].Add(c)
+	}
+
+	punctuality := &models.Punctuality{
+		Overall:   overall.Stats(),
+		ByHour:    hourPunctuality(byHour),
+		ByWeekday: []models.WeekdayPunctuality{},
+	}
+	for weekday, c := range byWeekday {
+		hours := make(map[int]*models.PunctualityCounts)
+		for slot, slotCounts := range counts {
+			if slot.Weekday == weekday {
+				hours[slot.Hour] = slotCounts
+			}
+		}
+		punctuality.ByWeekday = append(punctuality.ByWeekday, models.WeekdayPunctuality{
+			Weekday:          weekday,
+			PunctualityStats: c.Stats(),
+			Hours:            hourPunctuality(hours),
+		})
+	}
+	sort.Slice(punctuality.ByWeekday, func(i, j int) bool {
+		return punctuality.ByWeekday[i].Weekday < punctuality.ByWeekday[j].Weekday
+	})
+	return punctuality
+}
+
+// hourPunctuality returns the statistics of every hour, in order.
+func hourPunctuality(byHour map[int]*models.PunctualityCounts) []models.HourPunctuality {
+	hours := make([]models.HourPunctuality, 0, len(byHour))
+	for hour, c := range byHour {
+		hours = append(hours, models.HourPunctuality{Hour: hour, PunctualityStats: c.Stats()})
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Hour < hours[j].Hour })
+	return hours
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/realtime"
+)
+
+const (
+	profileDays    = 28        // Service days of history a profile covers
+	profileTTL     = time.Hour // Rollups are recomputed hourly
+	profileEntries = 5000
+	// minProfileCalls is the number of measured calls below which an hour
+	// of a route falls back to the route's whole day.
+	minProfileCalls = 20
+
+	// The likely departure is the 10% to 90% interval.
+	lowerQuantile = 0.1
+	upperQuantile = 0.9
+
+	defaultSourceError = 30.0 // Seconds of error of a tracked departure about to leave
+	trackedErrorGrowth = 0.08 // Seconds of error per second ahead, for tracked departures
+	predictedError     = 60.0
+	predictedGrowth    = 0.15
+	historyHorizon     = 30 * time.Minute // Lead time from which on a route's delay spread applies in full
+	reliabilityScale   = 300.0            // Interval width in seconds that halves the score
+)
+
+// sourceErrors is the error in seconds of a departure about to leave, by the
+// realtime source that tracks it. KV6 through NDOV is pushed within seconds;
+// OVapi and GTFS-Realtime are polled.
+var sourceErrors = map[string]float64{
+	realtime.SourceNDOV:   20,
+	realtime.SourceGTFSRT: 30,
+	realtime.SourceOVapi:  30,
+}
+
+// basisWeights caps the score of a departure by what its time is based on.
+var basisWeights = map[string]float64{
+	models.ReliabilityRealtime:  1,
+	models.ReliabilityPredicted: 0.9,
+	models.ReliabilityTimetable: 0.75,
+}
+
+// profileCache keeps what was learned from the rollups about recently
+// asked stops and routes for profileTTL.
+type profileCache struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+type profileEntry struct {
+	profile  interface{}
+	loadedAt time.Time
+}
+
+func newProfileCache() *profileCache {
+	return &profileCache{cache: lru.New(profileEntries)}
+}
+
+func (c *profileCache) get(key string, now time.Time) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.cache.Get(key)
+	if !ok || now.Sub(v.(profileEntry).loadedAt) >= profileTTL {
+		return nil, false
+	}
+	return v.(profileEntry).profile, true
+}
+
+func (c *profileCache) add(key string, profile interface{}, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Add(key, profileEntry{profile, now})
+}
+
+// delayProfiles returns the delays measured at a stop over the last
+// profileDays, by route and hour, with the route's whole day under hour -1.
+// Failures are logged and reported as no history.
+func (s *TransitService) delayProfiles(ctx context.Context, stopID string, now time.Time) map[models.DelayProfileKey]*models.PunctualityCounts {
+	cacheKey := "delays:" + stopID
+	if profiles, ok := s.profiles.get(cacheKey, now); ok {
+		return profiles.(map[models.DelayProfileKey]*models.PunctualityCounts)
+	}
+
+	to := models.NewServiceDay(now).Date
+	profiles, err := s.static.DelayProfiles(ctx, stopID, to.AddDate(0, 0, -profileDays), to)
+	if err != nil {
+		log.Printf("WARN: Failed to get delay profiles of stop %s: %v", stopID, err)
+		return nil
+	}
+	for key, profile := range profiles {
+		if key.Hour < 0 {
+			continue
+		}
+		day := models.DelayProfileKey{RouteID: key.RouteID, Hour: -1}
+		if profiles[day] == nil {
+			profiles[day] = models.NewPunctualityCounts()
+		}
+		profiles[day].Add(profile)
+	}
+
+	s.profiles.add(cacheKey, profiles, now)
+	return profiles
+}
+
+// scoreDepartures attaches a Reliability to every departure that will still
+// leave.
+func (s *TransitService) scoreDepartures(ctx context.Context, stopID string, departures []models.Departure, now time.Time) {
+	if len(departures) == 0 {
+		return
+	}
+	profiles := s.delayProfiles(ctx, stopID, now)
+	for i := range departures {
+		d := &departures[i]
+		if d.Cancelled || d.Skipped || d.Status == models.DepartureDeparted {
+			continue
+		}
+		d.Reliability = reliability(d, profile(profiles, d), now)
+	}
+}
+
+// profile returns the delays measured for the route of d in the hour of its
+// scheduled departure, or in the whole day when that hour has too few, or
+// nil without history.
+func profile(profiles map[models.DelayProfileKey]*models.PunctualityCounts, d *models.Departure) *models.PunctualityCounts {
+	if d.RouteID == "" {
+		return nil
+	}
+	hour := d.ScheduledDeparture.In(models.TimetableLocation).Hour()
+	for _, key := range []models.DelayProfileKey{{RouteID: d.RouteID, Hour: hour}, {RouteID: d.RouteID, Hour: -1}} {
+		if p := profiles[key]; p != nil && p.Measured >= minProfileCalls {
+			return p
+		}
+	}
+	return nil
+}
+
+// reliability estimates the interval d will likely depart in and scores it.
+// A tracked departure is off by the error of its source, growing with how
+// far ahead it is; one predicted from an earlier delay more so. Both widen
+// towards the delay spread of the route at this stop and hour as the lead
+// time grows, since a journey has more time to lose or make up time. A
+// departure known from the timetable alone spreads as its route's delays
+// did. Stale realtime data widens the interval by its age.
+func reliability(d *models.Departure, history *models.PunctualityCounts, now time.Time) *models.Reliability {
+	lead := max(0, d.Departure.Sub(now).Seconds())
+
+	// Delay spread around the median, in seconds
+	var spreadEarly, spreadLate float64
+	if history != nil {
+		median := float64(history.DelayQuantile(0.5))
+		spreadEarly = max(0, median-float64(history.DelayQuantile(lowerQuantile)))
+		spreadLate = max(0, float64(history.DelayQuantile(upperQuantile))-median)
+	}
+	weight := min(1, lead/historyHorizon.Seconds())
+
+	var basis string
+	var early, late float64
+	switch {
+	case d.ExpectedDeparture != nil && d.Predicted:
+		basis = models.ReliabilityPredicted
+		early = predictedError/2 + predictedGrowth/2*lead + spreadEarly*weight
+		late = predictedError + predictedGrowth*lead + spreadLate*weight
+	case d.ExpectedDeparture != nil:
+		basis = models.ReliabilityRealtime
+		sourceError, ok := sourceErrors[d.Source]
+		if !ok {
+			sourceError = defaultSourceError
+		}
+		early = sourceError/2 + trackedErrorGrowth/2*lead + spreadEarly*weight
+		late = sourceError + trackedErrorGrowth*lead + spreadLate*weight
+	case history != nil:
+		basis = models.ReliabilityTimetable
+		early = -float64(history.DelayQuantile(lowerQuantile))
+		late = float64(history.DelayQuantile(upperQuantile))
+	default:
+		basis = models.ReliabilityTimetable
+		early, late = -models.OnTimeEarliest, models.OnTimeLatest
+	}
+	if d.Freshness != nil && d.Freshness.Stale {
+		early += float64(d.Freshness.Age)
+		late += float64(d.Freshness.Age)
+	}
+
+	earliest := d.Departure.Add(-time.Duration(early * float64(time.Second)))
+	latest := d.Departure.Add(time.Duration(late * float64(time.Second)))
+	if d.ExpectedDeparture == nil {
+		// The timetable spread is around the scheduled time
+		earliest = d.ScheduledDeparture.Add(-time.Duration(early * float64(time.Second)))
+		latest = d.ScheduledDeparture.Add(time.Duration(late * float64(time.Second)))
+	}
+	// A vehicle still to leave does not leave in the past
+	if earliest.Before(now) {
+		earliest = now
+	}
+	if latest.Before(earliest) {
+		latest = earliest
+	}
+
+	width := latest.Sub(earliest).Seconds()
+	score := basisWeights[basis] * reliabilityScale / (reliabilityScale + width)
+	return &models.Reliability{
+		Score:    int(math.Round(score * 100)),
+		Earliest: earliest.Truncate(time.Second),
+		Latest:   latest.Truncate(time.Second),
+		Basis:    basis,
+	}
+}
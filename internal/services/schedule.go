@@ -0,0 +1,68 @@
+package services
+
+import (
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// departureHorizon is how far ahead scheduled departures are listed.
+const departureHorizon = 90 * time.Minute
+
+// serviceTrip identifies one run of a GTFS trip.
+type serviceTrip struct {
+	tripID      string
+	serviceDate string
+}
+
+// mergeSchedule merges the realtime departures live into the timetabled
+// calls scheduled. A realtime departure replaces the call of its trip on the
+// same service day with the nearest scheduled time and takes its planned
+// quay, so platform changes show as StopID differing from PlannedStopID.
+// The worker resolves the trip of a realtime departure on its service day,
+// as GTFS-NL reuses realtime journey IDs for other trips on other days.
+// Calls without realtime data remain scheduled, realtime departures without
+// a call (added trips, or trips outside the window) are kept as they are.
+func mergeSchedule(scheduled, live []models.Departure) []models.Departure {
+	calls := make(map[serviceTrip][]int)
+	for i, d := range scheduled {
+		key := serviceTrip{d.TripID, d.ServiceDate}
+		calls[key] = append(calls[key], i)
+	}
+
+	matched := make([]bool, len(scheduled))
+	merged := make([]models.Departure, 0, len(scheduled)+len(live))
+	for _, d := range live {
+		best := -1
+		if d.TripID != "" {
+			for _, i := range calls[serviceTrip{d.TripID, d.ServiceDate}] {
+				if !matched[i] && (best < 0 || absDuration(scheduled[i].ScheduledDeparture.Sub(d.ScheduledDeparture)) <
+					absDuration(scheduled[best].ScheduledDeparture.Sub(d.ScheduledDeparture))) {
+					best = i
+				}
+			}
+		}
+		if best >= 0 {
+			matched[best] = true
+			d.PlannedStopID = scheduled[best].PlannedStopID
+			if d.StopID == "" {
+				d.StopID = scheduled[best].StopID
+			}
+		}
+		merged = append(merged, d)
+	}
+
+	for i, d := range scheduled {
+		if !matched[i] {
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/cache"
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/realtime"
+	"arrivo-transit-api/internal/store/memory"
+)
+
+// passProvider is a realtime.Provider listing fixed passes.
+type passProvider struct {
+	passes map[string][]ovapi.Pass
+}
+
+func (p passProvider) Source() string  { return realtime.SourceOVapi }
+func (p passProvider) Available() bool { return true }
+
+func (p passProvider) Departures(ctx context.Context, timingPointCodes []string) (map[string][]ovapi.Pass, error) {
+	passes := make(map[string][]ovapi.Pass)
+	for _, code := range timingPointCodes {
+		passes[code] = p.passes[code]
+	}
+	return passes, nil
+}
+
+func (p passProvider) UpdatePass(pass ovapi.Pass, stops map[string]string) (ovapi.Pass, bool) {
+	return ovapi.Pass{}, false
+}
+
+func (p passProvider) Vehicles(ctx context.Context, stops map[string]string, now time.Time) ([]models.Vehicle, error) {
+	return nil, nil
+}
+
+func (p passProvider) Alerts(ctx context.Context, now time.Time) ([]models.Alert, error) {
+	return nil, nil
+}
+
+// pollPasses runs one worker cycle over passes, so the realtime store holds
+// what the worker would write for them.
+func pollPasses(t *testing.T, st *memory.Store, rt *memory.Realtime, passes map[string][]ovapi.Pass) {
+	t.Helper()
+	worker := realtime.NewWorker(realtime.NewMerger(passProvider{passes}), st, rt, realtime.WorkerConfig{
+		Interval:     time.Minute,
+		DepartureTTL: time.Minute,
+		LineTTL:      time.Minute,
+		MaxStaleness: 5 * time.Minute,
+	})
+	worker.Cycle(context.Background(), time.Now())
+}
+
+func TestMergeScheduleResolvesJourneyPerServiceDay(t *testing.T) {
+	now := time.Now()
+	today := models.NewServiceDay(now)
+	yesterday := models.NewServiceDay(today.Date.AddDate(0, 0, -1))
+	departure := now.Add(10 * time.Minute).Truncate(time.Minute)
+
+	// GTFS-NL reuses the journey ID; the trip with the lowest ID runs
+	// yesterday only
+	st := memory.New()
+	st.PutStop(models.Stop{ID: "quay"})
+	st.PutTimingPoint("quay", "30001234")
+	line := "1"
+	st.PutRoute(models.Route{ID: "route", ShortName: &line, Type: models.RouteTypeTram})
+	realtimeID := "GVB:1:100"
+	for _, trip := range []struct {
+		id  string
+		day models.ServiceDay
+	}{{"trip-a", yesterday}, {"trip-b", today}} {
+		st.PutTrip(models.Trip{ID: trip.id, RouteID: "route", ServiceID: trip.id, RealtimeID: &realtimeID})
+		st.SetServiceDate(trip.id, trip.day.String(), true)
+		st.PutStopTime(models.StopTime{TripID: trip.id, StopID: "quay", StopSequence: 1, ArrivalSec: today.Seconds(departure), DepartureSec: today.Seconds(departure)})
+	}
+
+	rt := memory.NewRealtime()
+	pollPasses(t, st, rt, map[string][]ovapi.Pass{"30001234": {{
+		DataOwnerCode:         "GVB",
+		OperationDate:         today.Date.Format("2006-01-02"),
+		LinePlanningNumber:    "1",
+		LinePublicNumber:      "1",
+		JourneyNumber:         100,
+		UserStopOrderNumber:   1,
+		TimingPointCode:       "30001234",
+		TargetDepartureTime:   ovapi.LocalTime{Time: departure},
+		ExpectedDepartureTime: ovapi.LocalTime{Time: departure.Add(2 * time.Minute)},
+		TripStopStatus:        ovapi.TripStopDriving,
+		LastUpdateTimeStamp:   ovapi.LocalTime{Time: now},
+	}}})
+
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, rt)
+	departures, err := s.GetDepartures(context.Background(), "quay")
+	if err != nil {
+		t.Fatalf("GetDepartures: %v", err)
+	}
+	if len(departures) != 1 {
+		t.Fatalf("got %d departures, want the journey once: %+v", len(departures), departures)
+	}
+	d := departures[0]
+	if d.TripID != "trip-b" || d.ServiceDate != today.String() {
+		t.Errorf("trip = %s on %s, want trip-b on %s", d.TripID, d.ServiceDate, today)
+	}
+	if !d.Realtime || d.Added || d.Delay != 120 {
+		t.Errorf("realtime = %v, added = %v, delay = %d; want a tracked timetabled journey 120s late", d.Realtime, d.Added, d.Delay)
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/realtime"
+)
+
+// Transit answers the passenger facing API. TransitService implements it.
+type Transit interface {
+	GetDepartures(ctx context.Context, stopID string) ([]models.Departure, error)
+	SearchStops(ctx context.Context, query string, lat, lon *float64) ([]models.Stop, error)
+	GetNearbyStops(ctx context.Context, lat, lon, radius float64) ([]models.Stop, error)
+	SearchRoutes(ctx context.Context, query string) ([]models.Route, error)
+	GetVehiclesByRoute(ctx context.Context, routeID string) (*models.RouteVehicles, error)
+	GetAllActiveVehicles(ctx context.Context, estimate bool) ([]models.Vehicle, error)
+	GetAlerts(ctx context.Context, filter AlertFilter) ([]models.Alert, error)
+	GetPunctuality(ctx context.Context, filter PunctualityFilter) (*models.Punctuality, error)
+	RealtimeStatus(ctx context.Context) (*realtime.Status, error)
+}
+
+// RealtimeFeeds provides the merged realtime state published as
+// GTFS-Realtime.
+type RealtimeFeeds interface {
+	RealtimeTripUpdates(ctx context.Context) ([]models.TripUpdate, error)
+	RealtimeVehicles(ctx context.Context) ([]models.Vehicle, error)
+	RealtimeAlerts(ctx context.Context) ([]models.Alert, error)
+}
+
+// StopAliases maintains the stop alias dictionary search expands queries
+// with.
+type StopAliases interface {
+	ListStopAliases(ctx context.Context) ([]models.StopAlias, error)
+	CreateStopAlias(ctx context.Context, alias models.StopAlias) (*models.StopAlias, error)
+	UpdateStopAlias(ctx context.Context, id int64, alias models.StopAlias) (*models.StopAlias, error)
+	DeleteStopAlias(ctx context.Context, id int64) error
+}
+
+var (
+	_ Transit       = (*TransitService)(nil)
+	_ RealtimeFeeds = (*TransitService)(nil)
+	_ StopAliases   = (*TransitService)(nil)
+)
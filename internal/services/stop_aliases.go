@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/search"
+	"arrivo-transit-api/internal/store"
+)
+
+// aliasRefreshInterval bounds how long an alias change made through another
+// API instance takes to reach this one.
+const aliasRefreshInterval = 5 * time.Minute
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = store.ErrNotFound
+	// ErrInvalid is returned when a record fails validation.
+	ErrInvalid = errors.New("invalid")
+	// ErrConflict is returned when a write would duplicate a unique record.
+	ErrConflict = store.ErrConflict
+)
+
+// stopAliases returns the alias dictionary used to expand search queries,
+// reloading it from the database when it is older than aliasRefreshInterval.
+// A failed reload keeps serving the previous dictionary.
+func (s *TransitService) stopAliases(ctx context.Context) search.Aliases {
+	s.aliasMu.RLock()
+	aliases, loadedAt := s.aliases, s.aliasesLoadedAt
+	s.aliasMu.RUnlock()
+
+	if aliases != nil && time.Since(loadedAt) < aliasRefreshInterval {
+		return aliases
+	}
+
+	list, err := s.ListStopAliases(ctx)
+	if err != nil {
+		log.Printf("WARN: Failed to load stop aliases: %v", err)
+		return aliases
+	}
+
+	pairs := make(map[string]string, len(list))
+	for _, alias := range list {
+		pairs[alias.Alias] = alias.Expansion
+	}
+	aliases = search.NewAliases(pairs)
+
+	s.aliasMu.Lock()
+	s.aliases, s.aliasesLoadedAt = aliases, time.Now()
+	s.aliasMu.Unlock()
+
+	return aliases
+}
+
+// invalidateStopAliases forces the next search to reload the alias dictionary.
+func (s *TransitService) invalidateStopAliases() {
+	s.aliasMu.Lock()
+	s.aliasesLoadedAt = time.Time{}
+	s.aliasMu.Unlock()
+}
+
+// ListStopAliases returns all stop aliases ordered by alias.
+func (s *TransitService) ListStopAliases(ctx context.Context) ([]models.StopAlias, error) {
+	return s.static.ListStopAliases(ctx)
+}
+
+// CreateStopAlias stores a new alias. An existing alias that normalizes to the
+// same text is overwritten.
+func (s *TransitService) CreateStopAlias(ctx context.Context, alias models.StopAlias) (*models.StopAlias, error) {
+	if !alias.Valid() {
+		return nil, ErrInvalid
+	}
+
+	created, err := s.static.CreateStopAlias(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateStopAliases()
+	return created, nil
+}
+
+// UpdateStopAlias replaces the alias with the given ID.
+func (s *TransitService) UpdateStopAlias(ctx context.Context, id int64, alias models.StopAlias) (*models.StopAlias, error) {
+	if !alias.Valid() {
+		return nil, ErrInvalid
+	}
+
+	updated, err := s.static.UpdateStopAlias(ctx, id, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateStopAliases()
+	return updated, nil
+}
+
+// DeleteStopAlias removes the alias with the given ID.
+func (s *TransitService) DeleteStopAlias(ctx context.Context, id int64) error {
+	if err := s.static.DeleteStopAlias(ctx, id); err != nil {
+		return err
+	}
+
+	s.invalidateStopAliases()
+	return nil
+}
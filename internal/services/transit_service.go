@@ -2,217 +2,276 @@ package services
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
 
 	"arrivo-transit-api/internal/cache"
 	"arrivo-transit-api/internal/models"
-	"arrivo-transit-api/internal/ovapi"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/redis/go-redis/v9"
+	"arrivo-transit-api/internal/realtime"
+	"arrivo-transit-api/internal/search"
+	"arrivo-transit-api/internal/store"
 )
 
 const (
 	redisCacheDuration = 5 * time.Minute
+	stopSearchLimit    = 20
 )
 
 type TransitService struct {
-	ovapiClient *ovapi.Client
-	lruCache    *cache.LRUCache
-	redisClient *redis.Client
-	db          *pgxpool.Pool
+	cache    cache.Cache
+	static   store.Static
+	realtime store.RealtimeStore
+	paths    *tripPathCache
+	profiles *profileCache
+
+	aliasMu         sync.RWMutex
+	aliases         search.Aliases
+	aliasesLoadedAt time.Time
 }
 
-func NewTransitService(ovapiClient *ovapi.Client, lruCache *cache.LRUCache, redisClient *redis.Client, db *pgxpool.Pool) *TransitService {
+func NewTransitService(c cache.Cache, static store.Static, realtime store.RealtimeStore) *TransitService {
 	return &TransitService{
-		ovapiClient: ovapiClient,
-		lruCache:    lruCache,
-		redisClient: redisClient,
-		db:          db,
+		cache:    c,
+		static:   static,
+		realtime: realtime,
+		paths:    newTripPathCache(),
+		profiles: newProfileCache(),
 	}
 }
 
+// GetDepartures returns the upcoming departures for a GTFS stop: the
+// timetabled calls, updated with what the realtime worker wrote to the
+// realtime store. When the realtime store cannot be read, the timetable is
+// served alone. Stations fan out to the TimingPointCodes of all their quays.
+// Unknown stops return ErrNotFound.
 func (s *TransitService) GetDepartures(ctx context.Context, stopID string) ([]models.Departure, error) {
 	cacheKey := fmt.Sprintf("departures:%s", stopID)
 
 	// 1. Try in-memory LRU cache
-	if cachedData, ok := s.lruCache.Get(ctx, cacheKey); ok {
+	if cachedData, ok := s.cache.Get(ctx, cacheKey); ok {
 		var departures []models.Departure
 		if err := json.Unmarshal(cachedData, &departures); err == nil {
 			log.Printf("CACHE HIT (LRU): %s", cacheKey)
+			now := time.Now()
+			for _, d := range departures {
+				if d.Freshness != nil {
+					*d.Freshness = d.Freshness.At(now)
+				}
+			}
+			s.scoreDepartures(ctx, stopID, departures, now)
 			return departures, nil
 		}
 	}
 
-	// 2. Try Redis cache
-	if cachedData, err := s.redisClient.Get(ctx, cacheKey).Bytes(); err == nil {
-		var departures []models.Departure
-		if err := json.Unmarshal(cachedData, &departures); err == nil {
-			log.Printf("CACHE HIT (Redis): %s", cacheKey)
-			// Store in LRU for future requests
-			s.lruCache.Set(ctx, cacheKey, cachedData)
-			return departures, nil
+	// 2. Resolve the TimingPointCodes OVapi data is keyed by
+	codes, err := s.static.TimingPointCodes(ctx, stopID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Read what the realtime worker stored for each of them
+	passes, realtimeErr := s.realtimeDepartures(ctx, codes)
+	if realtimeErr != nil {
+		log.Printf("WARN: Failed to read realtime departures for stop %s, serving the timetable only: %v", stopID, realtimeErr)
+	}
+
+	// 4. Merge them into the timetable, so journeys without realtime data
+	// still show up as scheduled
+	now := time.Now()
+	scheduled, err := s.static.ScheduledDepartures(ctx, stopID, now.Add(-time.Minute), now.Add(departureHorizon))
+	if err != nil {
+		if realtimeErr != nil {
+			return nil, err
 		}
+		log.Printf("WARN: Failed to get scheduled departures for stop %s: %v", stopID, err)
+	}
+	departures := upcomingDepartures(mergeSchedule(scheduled, passes), now)
+	attachDepartureAlerts(departures, s.activeAlerts(ctx), stopID)
+	s.forecastDepartureOccupancy(ctx, stopID, departures, now)
+	if err := s.attachPlatforms(ctx, stopID, departures); err != nil {
+		log.Printf("WARN: Failed to attach platforms to departures for stop %s: %v", stopID, err)
 	}
 
-	log.Printf("CACHE MISS: %s", cacheKey)
-	
-	// TODO: Replace with real OVapi integration when service is available
-	// For now, return mock data to test the API structure
-	departures := []models.Departure{
-		{
-			Line:        "1",
-			Destination: "Centraal Station",
-			Departure:   time.Now().Add(3 * time.Minute),
-		},
-		{
-			Line:        "5",
-			Destination: "Amstelveen Centrum",
-			Departure:   time.Now().Add(7 * time.Minute),
-		},
-		{
-			Line:        "12",
-			Destination: "Station Sloterdijk",
-			Departure:   time.Now().Add(12 * time.Minute),
-		},
-	}
-
-	// 4. Store in caches
+	// 5. Store in LRU cache, without the reliability that depends on the
+	// time; freshness is brought up to date when served from the cache
 	if marshaledData, err := json.Marshal(departures); err == nil {
-		s.redisClient.Set(ctx, cacheKey, marshaledData, redisCacheDuration)
-		s.lruCache.Set(ctx, cacheKey, marshaledData)
+		s.cache.Set(ctx, cacheKey, marshaledData)
 	}
 
+	s.scoreDepartures(ctx, stopID, departures, now)
 	return departures, nil
 }
 
+// realtimeDepartures returns the departures the realtime worker stored for
+// the TimingPointCodes, with the freshness of their snapshot. Codes without
+// departures, e.g. because their last snapshot outlived the maximum
+// staleness during an OVapi outage, are skipped.
+func (s *TransitService) realtimeDepartures(ctx context.Context, codes []string) ([]models.Departure, error) {
+	var passes []models.Departure
+	for _, code := range codes {
+		var tpcDepartures []models.Departure
+		freshness, err := s.readSnapshot(ctx, realtime.DeparturesKey(code), &tpcDepartures)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read departures for %s: %w", code, err)
+		}
+
+		for i := range tpcDepartures {
+			tpcDepartures[i].Freshness = &freshness
+		}
+		passes = append(passes, tpcDepartures...)
+	}
+	return passes, nil
+}
+
+// upcomingDepartures drops journeys that already left, as well as passes more
+// than a minute in the past, and orders the rest by departure time.
+func upcomingDepartures(passes []models.Departure, now time.Time) []models.Departure {
+	departures := []models.Departure{}
+	for _, departure := range passes {
+		if departure.Status == models.DepartureDeparted || departure.Departure.Before(now.Add(-time.Minute)) {
+			continue
+		}
+		departures = append(departures, departure)
+	}
+
+	sort.Slice(departures, func(i, j int) bool {
+		return departures[i].Departure.Before(departures[j].Departure)
+	})
+	return departures
+}
+
+// SearchStops finds stops matching query. The query is also expanded with the
+// stop alias dictionary ("rdam cs" -> "rotterdam centraal station") and both
+// variants are searched, keeping the best score per stop.
 func (s *TransitService) SearchStops(ctx context.Context, query string, lat, lon *float64) ([]models.Stop, error) {
-	var rows pgx.Rows
-	var err error
-
-	baseQuery := `
-		SELECT stop_id, stop_name, stop_lat, stop_lon %s
-		FROM stops 
-		WHERE stop_name ILIKE $1 
-		%s 
-		LIMIT 20;`
-
-	if lat != nil && lon != nil {
-		// Query with distance calculation and ordering
-		distanceField := ", (6371000 * acos(cos(radians($2)) * cos(radians(stop_lat)) * cos(radians(stop_lon) - radians($3)) + sin(radians($2)) * sin(radians(stop_lat)))) AS distance"
-		orderBy := "ORDER BY distance"
-		searchQuery := fmt.Sprintf(baseQuery, distanceField, orderBy)
-		rows, err = s.db.Query(ctx, searchQuery, "%"+query+"%", *lat, *lon)
-	} else {
-		// Original query without location
-		searchQuery := fmt.Sprintf(baseQuery, "", "")
-		rows, err = s.db.Query(ctx, searchQuery, "%"+query+"%")
+	normalized := search.Normalize(query)
+	if normalized == "" {
+		return []models.Stop{}, nil
 	}
 
+	stops, err := s.static.SearchStops(ctx, normalized, lat, lon, stopSearchLimit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query stops: %w", err)
-	}
-	defer rows.Close()
-
-	var stops []models.Stop
-	for rows.Next() {
-		var stop models.Stop
-		var err error
-		if lat != nil && lon != nil {
-			err = rows.Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon, &stop.Distance)
-		} else {
-			err = rows.Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan stop: %w", err)
+		return nil, err
+	}
+
+	expanded := s.stopAliases(ctx).Expand(normalized)
+	if expanded == normalized {
+		return stops, nil
+	}
+
+	aliasStops, err := s.static.SearchStops(ctx, expanded, lat, lon, stopSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeStopResults(stops, aliasStops, stopSearchLimit), nil
+}
+
+// mergeStopResults combines two ranked result sets, keeping the highest score
+// for stops present in both, and returns at most limit stops by score.
+func mergeStopResults(a, b []models.Stop, limit int) []models.Stop {
+	byID := make(map[string]int, len(a)+len(b))
+	merged := make([]models.Stop, 0, len(a)+len(b))
+	for _, stop := range append(a, b...) {
+		if i, ok := byID[stop.ID]; ok {
+			if stop.Score > merged[i].Score {
+				merged[i].Score = stop.Score
+			}
+			continue
 		}
-		stops = append(stops, stop)
+		byID[stop.ID] = len(merged)
+		merged = append(merged, stop)
 	}
 
-	return stops, nil
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
 }
 
-// GetVehiclesByRoute fetches all vehicles currently serving a specific route
+// GetVehiclesByRoute returns the vehicles currently serving a GTFS route, as
+// tracked by the realtime worker, with their positions projected along their
+// trips to the current time and a forecast occupancy when none is reported.
+// Unknown routes return ErrNotFound.
 func (s *TransitService) GetVehiclesByRoute(ctx context.Context, routeID string) (*models.RouteVehicles, error) {
 	cacheKey := fmt.Sprintf("vehicles:route:%s", routeID)
 
 	// Try LRU cache first
-	if cachedData, found := s.lruCache.Get(ctx, cacheKey); found {
+	if cachedData, found := s.cache.Get(ctx, cacheKey); found {
 		log.Printf("CACHE HIT (LRU): %s", cacheKey)
 		var routeVehicles models.RouteVehicles
 		if err := json.Unmarshal(cachedData, &routeVehicles); err == nil {
+			now := time.Now()
+			routeVehicles.Freshness = routeVehicles.Freshness.At(now)
+			s.estimatePositions(ctx, routeVehicles.Vehicles, now)
 			return &routeVehicles, nil
 		}
 	}
 
-	// Try Redis cache
-	if cachedData, err := s.redisClient.Get(ctx, cacheKey).Bytes(); err == nil {
-		log.Printf("CACHE HIT (Redis): %s", cacheKey)
-		var routeVehicles models.RouteVehicles
-		if err := json.Unmarshal(cachedData, &routeVehicles); err == nil {
-			// Store in LRU cache for faster access
-			s.lruCache.Set(ctx, cacheKey, cachedData)
-			return &routeVehicles, nil
-		}
+	route, err := s.static.GetRoute(ctx, routeID)
+	if err != nil {
+		return nil, err
 	}
 
-	// For now, return mock data since OVapi vehicle tracking is not implemented
-	// In production, this would fetch real-time vehicle positions from OVapi
-	routeVehicles := &models.RouteVehicles{
-		RouteID:   routeID,
-		RouteName: fmt.Sprintf("Route %s", routeID),
-		Vehicles: []models.Vehicle{
-			{
-				ID:        fmt.Sprintf("VEH_%s_001", routeID),
-				RouteID:   routeID,
-				TripID:    fmt.Sprintf("TRIP_%s_001", routeID),
-				Lat:       52.3676 + (float64(len(routeID)%10) * 0.001), // Mock coordinates around Amsterdam
-				Lon:       4.9041 + (float64(len(routeID)%10) * 0.001),
-				Bearing:   func() *float64 { b := 45.0; return &b }(),
-				Speed:     func() *float64 { s := 25.5; return &s }(),
-				Timestamp: time.Now(),
-				Delay:     func() *int { d := 120; return &d }(), // 2 minutes late
-				Status:    "IN_TRANSIT",
-				Occupancy: func() *string { o := "MANY_SEATS_AVAILABLE"; return &o }(),
-			},
-			{
-				ID:        fmt.Sprintf("VEH_%s_002", routeID),
-				RouteID:   routeID,
-				TripID:    fmt.Sprintf("TRIP_%s_002", routeID),
-				Lat:       52.3700 + (float64(len(routeID)%7) * 0.001),
-				Lon:       4.9000 + (float64(len(routeID)%7) * 0.001),
-				Bearing:   func() *float64 { b := 225.0; return &b }(),
-				Speed:     func() *float64 { s := 18.2; return &s }(),
-				Timestamp: time.Now(),
-				Delay:     func() *int { d := -30; return &d }(), // 30 seconds early
-				Status:    "STOPPED_AT",
-				StopID:    func() *string { s := "2992167"; return &s }(),
-				Occupancy: func() *string { o := "FEW_SEATS_AVAILABLE"; return &o }(),
-			},
-		},
-		LastUpdated: time.Now(),
+	routeVehicles := &models.RouteVehicles{RouteID: routeID, Vehicles: []models.Vehicle{}}
+	freshness, err := s.readSnapshot(ctx, realtime.RouteVehiclesKey(routeID), routeVehicles)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read vehicles for route %s: %w", routeID, err)
 	}
+	routeVehicles.Freshness = freshness
 
-	// Cache the result
+	routeVehicles.RouteName = routeID
+	if route.ShortName != nil {
+		routeVehicles.RouteName = *route.ShortName
+	} else if route.LongName != nil {
+		routeVehicles.RouteName = *route.LongName
+	}
+
+	routeVehicles.Alerts = routeAlerts(s.activeAlerts(ctx), route)
+	s.forecastVehicleOccupancy(ctx, routeID, routeVehicles.Vehicles, time.Now())
+
+	// Cache the result, without the estimates that depend on the time;
+	// freshness is brought up to date when served from the cache
 	if marshaledData, err := json.Marshal(routeVehicles); err == nil {
-		s.redisClient.Set(ctx, cacheKey, marshaledData, redisCacheDuration)
-		s.lruCache.Set(ctx, cacheKey, marshaledData)
+		s.cache.Set(ctx, cacheKey, marshaledData)
 	}
 
+	s.estimatePositions(ctx, routeVehicles.Vehicles, time.Now())
 	return routeVehicles, nil
 }
 
-// GetAllActiveVehicles fetches all currently active vehicles across all routes
-func (s *TransitService) GetAllActiveVehicles(ctx context.Context) ([]models.Vehicle, error) {
+// GetAllActiveVehicles returns every vehicle tracked by the realtime worker
+// at its reported position. With estimate, the positions are projected along
+// their trips to the current time, which loads the path of every trip.
+func (s *TransitService) GetAllActiveVehicles(ctx context.Context, estimate bool) ([]models.Vehicle, error) {
+	vehicles, err := s.RealtimeVehicles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if estimate {
+		s.estimatePositions(ctx, vehicles, time.Now())
+	}
+	return vehicles, nil
+}
+
+// RealtimeVehicles returns every vehicle tracked by the realtime worker as
+// reported, for the GTFS-Realtime feed.
+func (s *TransitService) RealtimeVehicles(ctx context.Context) ([]models.Vehicle, error) {
 	cacheKey := "vehicles:all:active"
 
 	// Try LRU cache first
-	if cachedData, found := s.lruCache.Get(ctx, cacheKey); found {
+	if cachedData, found := s.cache.Get(ctx, cacheKey); found {
 		log.Printf("CACHE HIT (LRU): %s", cacheKey)
 		var vehicles []models.Vehicle
 		if err := json.Unmarshal(cachedData, &vehicles); err == nil {
@@ -220,75 +279,39 @@ func (s *TransitService) GetAllActiveVehicles(ctx context.Context) ([]models.Veh
 		}
 	}
 
-	// Try Redis cache
-	if cachedData, err := s.redisClient.Get(ctx, cacheKey).Bytes(); err == nil {
-		log.Printf("CACHE HIT (Redis): %s", cacheKey)
-		var vehicles []models.Vehicle
-		if err := json.Unmarshal(cachedData, &vehicles); err == nil {
-			// Store in LRU cache for faster access
-			s.lruCache.Set(ctx, cacheKey, cachedData)
-			return vehicles, nil
-		}
-	}
-
-	// For now, return mock data since OVapi vehicle tracking is not implemented
-	// In production, this would fetch all active vehicles from OVapi
-	vehicles := []models.Vehicle{
-		{
-			ID:        "VEH_001",
-			RouteID:   "1",
-			TripID:    "TRIP_001",
-			Lat:       52.3676,
-			Lon:       4.9041,
-			Bearing:   func() *float64 { b := 90.0; return &b }(),
-			Speed:     func() *float64 { s := 30.0; return &s }(),
-			Timestamp: time.Now(),
-			Status:    "IN_TRANSIT",
-			Occupancy: func() *string { o := "MANY_SEATS_AVAILABLE"; return &o }(),
-		},
-		{
-			ID:        "VEH_002",
-			RouteID:   "2",
-			TripID:    "TRIP_002",
-			Lat:       52.3700,
-			Lon:       4.9000,
-			Bearing:   func() *float64 { b := 180.0; return &b }(),
-			Speed:     func() *float64 { s := 22.5; return &s }(),
-			Timestamp: time.Now(),
-			Delay:     func() *int { d := 60; return &d }(),
-			Status:    "STOPPED_AT",
-			StopID:    func() *string { s := "2992167"; return &s }(),
-			Occupancy: func() *string { o := "FEW_SEATS_AVAILABLE"; return &o }(),
-		},
-		{
-			ID:        "VEH_003",
-			RouteID:   "5",
-			TripID:    "TRIP_003",
-			Lat:       52.3650,
-			Lon:       4.9100,
-			Bearing:   func() *float64 { b := 270.0; return &b }(),
-			Speed:     func() *float64 { s := 15.8; return &s }(),
-			Timestamp: time.Now(),
-			Status:    "IN_TRANSIT",
-			Occupancy: func() *string { o := "STANDING_ROOM_ONLY"; return &o }(),
-		},
+	vehicles := []models.Vehicle{}
+	if err := s.readRealtime(ctx, realtime.VehiclesKey, &vehicles); err != nil {
+		return nil, fmt.Errorf("failed to read vehicles: %w", err)
 	}
 
 	// Cache the result
 	if marshaledData, err := json.Marshal(vehicles); err == nil {
-		s.redisClient.Set(ctx, cacheKey, marshaledData, redisCacheDuration)
-		s.lruCache.Set(ctx, cacheKey, marshaledData)
+		s.cache.Set(ctx, cacheKey, marshaledData)
 	}
 
 	return vehicles, nil
 }
 
-// SearchRoutes searches for routes/lines by name or short name
+// SearchRoutes searches for routes/lines by name or short name, with the
+// alerts active on each.
 func (s *TransitService) SearchRoutes(ctx context.Context, query string) ([]models.Route, error) {
+	routes, err := s.searchRoutes(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := s.activeAlerts(ctx)
+	for i := range routes {
+		routes[i].Alerts = routeAlerts(alerts, &routes[i])
+	}
+	return routes, nil
+}
+
+func (s *TransitService) searchRoutes(ctx context.Context, query string) ([]models.Route, error) {
 	cacheKey := fmt.Sprintf("routes:search:%s", query)
 
 	// Try LRU cache first
-	if cachedData, found := s.lruCache.Get(ctx, cacheKey); found {
+	if cachedData, found := s.cache.Get(ctx, cacheKey); found {
 		log.Printf("CACHE HIT (LRU): %s", cacheKey)
 		var routes []models.Route
 		if err := json.Unmarshal(cachedData, &routes); err == nil {
@@ -297,129 +320,92 @@ func (s *TransitService) SearchRoutes(ctx context.Context, query string) ([]mode
 	}
 
 	// Try Redis cache
-	if cachedData, err := s.redisClient.Get(ctx, cacheKey).Bytes(); err == nil {
+	if cachedData, err := s.realtime.Get(ctx, cacheKey); err == nil {
 		log.Printf("CACHE HIT (Redis): %s", cacheKey)
 		var routes []models.Route
 		if err := json.Unmarshal(cachedData, &routes); err == nil {
 			// Store in LRU cache for faster access
-			s.lruCache.Set(ctx, cacheKey, cachedData)
+			s.cache.Set(ctx, cacheKey, cachedData)
 			return routes, nil
 		}
 	}
 
-	// Query database
-	searchQuery := `
-		SELECT id, agency_id, route_short_name, route_long_name, route_desc, route_type, route_url, route_color, route_text_color
-		FROM routes 
-		WHERE route_short_name ILIKE $1 OR route_long_name ILIKE $1
-		ORDER BY 
-			CASE 
-				WHEN route_short_name ILIKE $1 THEN 1
-				WHEN route_long_name ILIKE $1 THEN 2
-				ELSE 3
-			END,
-			route_short_name, route_long_name
-		LIMIT 20`
-
-	rows, err := s.db.Query(ctx, searchQuery, "%"+query+"%")
+	routes, err := s.static.SearchRoutes(ctx, query, 20)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query routes: %w", err)
-	}
-	defer rows.Close()
-
-	var routes []models.Route
-	for rows.Next() {
-		var route models.Route
-		var agencyID, shortName, longName, description, url, color, textColor sql.NullString
-		
-		err := rows.Scan(
-			&route.ID,
-			&agencyID,
-			&shortName,
-			&longName,
-			&description,
-			&route.Type,
-			&url,
-			&color,
-			&textColor,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan route: %w", err)
-		}
-
-		// Handle nullable fields
-		if agencyID.Valid {
-			route.AgencyID = &agencyID.String
-		}
-		if shortName.Valid {
-			route.ShortName = &shortName.String
-		}
-		if longName.Valid {
-			route.LongName = &longName.String
-		}
-		if description.Valid {
-			route.Description = &description.String
-		}
-		if url.Valid {
-			route.URL = &url.String
-		}
-		if color.Valid {
-			route.Color = &color.String
-		}
-		if textColor.Valid {
-			route.TextColor = &textColor.String
-		}
-
-		routes = append(routes, route)
+		return nil, err
 	}
 
 	// Cache the result
 	if marshaledData, err := json.Marshal(routes); err == nil {
-		s.redisClient.Set(ctx, cacheKey, marshaledData, redisCacheDuration)
-		s.lruCache.Set(ctx, cacheKey, marshaledData)
+		s.realtime.Set(ctx, cacheKey, marshaledData, redisCacheDuration)
+		s.cache.Set(ctx, cacheKey, marshaledData)
 	}
 
 	return routes, nil
 }
 
 func (s *TransitService) GetNearbyStops(ctx context.Context, lat, lon, radius float64) ([]models.Stop, error) {
-	query := `
-		SELECT stop_id, stop_name, stop_lat, stop_lon, ( 
-			6371000 * acos( 
-				cos( radians($1) ) 
-				* cos( radians( stop_lat ) ) 
-				* cos( radians( stop_lon ) - radians($2) ) 
-				+ sin( radians($1) ) 
-				* sin( radians( stop_lat ) ) 
-			) 
-		) AS distance 
-		FROM stops 
-		WHERE ( 
-			6371000 * acos( 
-				cos( radians($1) ) 
-				* cos( radians( stop_lat ) ) 
-				* cos( radians( stop_lon ) - radians($2) ) 
-				+ sin( radians($1) ) 
-				* sin( radians( stop_lat ) ) 
-			) 
-		) < $3 
-		ORDER BY distance
-		LIMIT 50;`
-
-	rows, err := s.db.Query(ctx, query, lat, lon, radius)
+	return s.static.NearbyStops(ctx, lat, lon, radius, 50)
+}
+
+// RealtimeStatus returns the status of the last realtime worker cycle.
+// ErrNotFound means no worker reported within its status TTL.
+func (s *TransitService) RealtimeStatus(ctx context.Context) (*realtime.Status, error) {
+	data, err := s.realtime.Get(ctx, realtime.StatusKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query nearby stops: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	var stops []models.Stop
-	for rows.Next() {
-		var stop models.Stop
-		if err := rows.Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon, &stop.Distance); err != nil {
-			return nil, fmt.Errorf("failed to scan stop: %w", err)
-		}
-		stops = append(stops, stop)
+	var status realtime.Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode realtime status: %w", err)
+	}
+	return &status, nil
+}
+
+// RealtimeTripUpdates returns the trip updates the realtime worker merged
+// from all sources.
+func (s *TransitService) RealtimeTripUpdates(ctx context.Context) ([]models.TripUpdate, error) {
+	updates := []models.TripUpdate{}
+	if err := s.readRealtime(ctx, realtime.TripUpdatesKey, &updates); err != nil {
+		return nil, fmt.Errorf("failed to read trip updates: %w", err)
 	}
+	return updates, nil
+}
 
-	return stops, nil
-}
\ No newline at end of file
+// RealtimeAlerts returns the active service alerts.
+func (s *TransitService) RealtimeAlerts(ctx context.Context) ([]models.Alert, error) {
+	alerts := []models.Alert{}
+	if err := s.readRealtime(ctx, realtime.AlertsKey, &alerts); err != nil {
+		return nil, fmt.Errorf("failed to read alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+// readRealtime decodes the value the realtime worker stored under key into
+// v, fresh or not. A missing key leaves v as is.
+func (s *TransitService) readRealtime(ctx context.Context, key string, v interface{}) error {
+	_, err := s.readSnapshot(ctx, key, v)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// readSnapshot decodes the snapshot the realtime worker stored under key
+// into v and returns its freshness. A missing key returns ErrNotFound.
+func (s *TransitService) readSnapshot(ctx context.Context, key string, v interface{}) (models.Freshness, error) {
+	data, err := s.realtime.Get(ctx, key)
+	if err != nil {
+		return models.Freshness{}, err
+	}
+
+	var snapshot realtime.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return models.Freshness{}, fmt.Errorf("failed to decode snapshot %s: %w", key, err)
+	}
+	if err := json.Unmarshal(snapshot.Data, v); err != nil {
+		return models.Freshness{}, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return snapshot.Freshness(time.Now()), nil
+}
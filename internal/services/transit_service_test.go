@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"arrivo-transit-api/internal/cache"
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/ovapi"
+	"arrivo-transit-api/internal/realtime"
+	"arrivo-transit-api/internal/store/memory"
+)
+
+// unavailableRealtime is a realtime store that cannot be reached.
+type unavailableRealtime struct{}
+
+func (unavailableRealtime) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (unavailableRealtime) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+// unscheduled is a static store whose timetable cannot be read.
+type unscheduled struct {
+	*memory.Store
+}
+
+func (unscheduled) ScheduledDepartures(ctx context.Context, stopID string, from, to time.Time) ([]models.Departure, error) {
+	return nil, errors.New("statement timeout")
+}
+
+// timetable returns a static store with stop "quay" (TimingPointCode
+// 30001234) where line 1 journeys 100 and 101 depart at the given times
+// today.
+func timetable(today models.ServiceDay, departures ...time.Time) *memory.Store {
+	st := memory.New()
+	st.PutStop(models.Stop{ID: "quay", Name: "Centraal Station"})
+	st.PutTimingPoint("quay", "30001234")
+	line := "1"
+	st.PutRoute(models.Route{ID: "route", ShortName: &line, Type: models.RouteTypeTram})
+	st.SetServiceDate("weekday", today.String(), true)
+	for i, departure := range departures {
+		realtimeID := "GVB:1:" + []string{"100", "101"}[i]
+		tripID := "trip-" + []string{"100", "101"}[i]
+		st.PutTrip(models.Trip{ID: tripID, RouteID: "route", ServiceID: "weekday", RealtimeID: &realtimeID})
+		st.PutStopTime(models.StopTime{TripID: tripID, StopID: "quay", StopSequence: 1, ArrivalSec: today.Seconds(departure), DepartureSec: today.Seconds(departure)})
+	}
+	return st
+}
+
+// trackedPass is an OVapi pass of a line 1 journey at 30001234, departing
+// delay late.
+func trackedPass(today models.ServiceDay, journey int, departure time.Time, delay time.Duration) ovapi.Pass {
+	return ovapi.Pass{
+		DataOwnerCode:         "GVB",
+		OperationDate:         today.Date.Format("2006-01-02"),
+		LinePlanningNumber:    "1",
+		LinePublicNumber:      "1",
+		JourneyNumber:         journey,
+		UserStopOrderNumber:   1,
+		TimingPointCode:       "30001234",
+		TargetDepartureTime:   ovapi.LocalTime{Time: departure},
+		ExpectedDepartureTime: ovapi.LocalTime{Time: departure.Add(delay)},
+		TripStopStatus:        ovapi.TripStopDriving,
+		LastUpdateTimeStamp:   ovapi.LocalTime{Time: time.Now()},
+	}
+}
+
+func TestGetDeparturesMergesRealtimeIntoTimetable(t *testing.T) {
+	now := time.Now()
+	today := models.NewServiceDay(now)
+	first := now.Add(10 * time.Minute).Truncate(time.Minute)
+	second := first.Add(10 * time.Minute)
+	st := timetable(today, first, second)
+
+	// Journey 100 is tracked, 101 is not, and 900 is not in the timetable
+	rt := memory.NewRealtime()
+	pollPasses(t, st, rt, map[string][]ovapi.Pass{"30001234": {
+		trackedPass(today, 100, first, 2*time.Minute),
+		trackedPass(today, 900, first.Add(5*time.Minute), 0),
+	}})
+
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, rt)
+	departures, err := s.GetDepartures(context.Background(), "quay")
+	if err != nil {
+		t.Fatalf("GetDepartures: %v", err)
+	}
+	if len(departures) != 3 {
+		t.Fatalf("got %d departures, want 3: %+v", len(departures), departures)
+	}
+
+	tracked, added, scheduled := departures[0], departures[1], departures[2]
+	if tracked.TripID != "trip-100" || !tracked.Realtime || tracked.Delay != 120 || tracked.Freshness == nil {
+		t.Errorf("first departure = %s, realtime %v, delay %d; want trip-100 tracked 120s late", tracked.TripID, tracked.Realtime, tracked.Delay)
+	}
+	if added.JourneyNumber != 900 || !added.Added || !added.Realtime {
+		t.Errorf("second departure = journey %d, added %v; want the added journey 900", added.JourneyNumber, added.Added)
+	}
+	if scheduled.TripID != "trip-101" || scheduled.Realtime || !scheduled.Departure.Equal(second) || scheduled.Freshness != nil {
+		t.Errorf("third departure = %s, realtime %v at %s; want trip-101 from the timetable", scheduled.TripID, scheduled.Realtime, scheduled.Departure)
+	}
+}
+
+func TestGetDeparturesFallsBackToTimetable(t *testing.T) {
+	now := time.Now()
+	today := models.NewServiceDay(now)
+	departure := now.Add(10 * time.Minute).Truncate(time.Minute)
+	st := timetable(today, departure)
+
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, unavailableRealtime{})
+	departures, err := s.GetDepartures(context.Background(), "quay")
+	if err != nil {
+		t.Fatalf("GetDepartures: %v", err)
+	}
+	if len(departures) != 1 || departures[0].TripID != "trip-100" || departures[0].Realtime {
+		t.Errorf("departures = %+v, want trip-100 from the timetable", departures)
+	}
+
+	if _, err := s.GetDepartures(context.Background(), "nowhere"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDepartures(nowhere) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetDeparturesFallsBackToRealtime(t *testing.T) {
+	now := time.Now()
+	today := models.NewServiceDay(now)
+	departure := now.Add(10 * time.Minute).Truncate(time.Minute)
+	st := timetable(today, departure)
+	rt := memory.NewRealtime()
+	pollPasses(t, st, rt, map[string][]ovapi.Pass{"30001234": {trackedPass(today, 100, departure, time.Minute)}})
+
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), unscheduled{st}, rt)
+	departures, err := s.GetDepartures(context.Background(), "quay")
+	if err != nil {
+		t.Fatalf("GetDepartures: %v", err)
+	}
+	if len(departures) != 1 || !departures[0].Realtime || departures[0].Delay != 60 {
+		t.Errorf("departures = %+v, want the tracked journey alone", departures)
+	}
+
+	// Without either there is nothing to serve
+	s = NewTransitService(cache.NewLRUCache(10, time.Minute), unscheduled{st}, unavailableRealtime{})
+	if _, err := s.GetDepartures(context.Background(), "quay"); err == nil {
+		t.Error("GetDepartures succeeded without timetable and realtime data")
+	}
+}
+
+func TestSearchStopsExpandsAliases(t *testing.T) {
+	st := memory.New()
+	st.PutStop(models.Stop{ID: "stadion", Name: "Rotterdam, Stadion Feijenoord"})
+	st.PutStop(models.Stop{ID: "kuiperstraat", Name: "Rotterdam, Kuiperstraat"})
+	st.PutStop(models.Stop{ID: "blaak", Name: "Rotterdam, Blaak"})
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, memory.NewRealtime())
+	ctx := context.Background()
+
+	stops, err := s.SearchStops(ctx, "De Kuip", nil, nil)
+	if err != nil {
+		t.Fatalf("SearchStops: %v", err)
+	}
+	for _, stop := range stops {
+		if stop.ID == "stadion" {
+			t.Fatalf("found %s before the alias exists", stop.ID)
+		}
+	}
+
+	// Creating an alias takes effect on the next search
+	if _, err := s.CreateStopAlias(ctx, models.StopAlias{Alias: "De Kuip", Expansion: "stadion feijenoord", Kind: models.StopAliasColloquial}); err != nil {
+		t.Fatalf("CreateStopAlias: %v", err)
+	}
+	stops, err = s.SearchStops(ctx, "de  kuip", nil, nil)
+	if err != nil {
+		t.Fatalf("SearchStops: %v", err)
+	}
+	if len(stops) == 0 || stops[0].ID != "stadion" {
+		t.Fatalf("stops = %+v, want Stadion Feijenoord first", stops)
+	}
+	seen := make(map[string]bool)
+	for _, stop := range stops {
+		if seen[stop.ID] {
+			t.Errorf("%s is listed twice", stop.ID)
+		}
+		seen[stop.ID] = true
+	}
+
+	if stops, err := s.SearchStops(ctx, " ", nil, nil); err != nil || len(stops) != 0 {
+		t.Errorf("SearchStops(blank) = %v, %v; want no stops", stops, err)
+	}
+}
+
+func TestUpdateStopAliasRejectsDuplicatesAndEmptyKeys(t *testing.T) {
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), memory.New(), memory.NewRealtime())
+	ctx := context.Background()
+
+	if _, err := s.CreateStopAlias(ctx, models.StopAlias{Alias: "CS", Expansion: "centraal station", Kind: models.StopAliasAbbreviation}); err != nil {
+		t.Fatalf("CreateStopAlias: %v", err)
+	}
+	dk, err := s.CreateStopAlias(ctx, models.StopAlias{Alias: "De Kuip", Expansion: "stadion feijenoord", Kind: models.StopAliasColloquial})
+	if err != nil {
+		t.Fatalf("CreateStopAlias: %v", err)
+	}
+
+	if _, err := s.UpdateStopAlias(ctx, dk.ID, models.StopAlias{Alias: " cs ", Expansion: "stadion feijenoord", Kind: models.StopAliasColloquial}); !errors.Is(err, ErrConflict) {
+		t.Errorf("UpdateStopAlias(duplicate) = %v, want ErrConflict", err)
+	}
+	if _, err := s.UpdateStopAlias(ctx, dk.ID, models.StopAlias{Alias: "de kuip!", Expansion: "stadion feijenoord", Kind: models.StopAliasColloquial}); err != nil {
+		t.Errorf("UpdateStopAlias(same key) = %v", err)
+	}
+	for _, alias := range []models.StopAlias{
+		{Alias: "–", Expansion: "stadion feijenoord", Kind: models.StopAliasColloquial},
+		{Alias: "De Kuip", Expansion: "...", Kind: models.StopAliasColloquial},
+	} {
+		if _, err := s.UpdateStopAlias(ctx, dk.ID, alias); !errors.Is(err, ErrInvalid) {
+			t.Errorf("UpdateStopAlias(%q, %q) = %v, want ErrInvalid", alias.Alias, alias.Expansion, err)
+		}
+	}
+}
+
+func TestGetAlertsForStationIncludesQuays(t *testing.T) {
+	st := memory.New()
+	st.PutStop(models.Stop{ID: "station"})
+	st.PutStop(models.Stop{ID: "quay"})
+	st.PutChildStop("station", "quay")
+	st.PutStop(models.Stop{ID: "elsewhere"})
+	rt := memory.NewRealtime()
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, rt)
+	ctx := context.Background()
+
+	now := time.Now()
+	storeSnapshot(t, rt, realtime.AlertsKey, []models.Alert{
+		{ID: "station", Informed: []models.AlertEntity{{StopID: "station"}}},
+		{ID: "quay", Informed: []models.AlertEntity{{StopID: "quay"}}},
+		{ID: "elsewhere", Informed: []models.AlertEntity{{StopID: "elsewhere"}}},
+	}, now, now.Add(time.Minute))
+
+	for stopID, want := range map[string][]string{
+		"station": {"station", "quay"},
+		"quay":    {"quay"},
+	} {
+		alerts, err := s.GetAlerts(ctx, AlertFilter{StopID: stopID})
+		if err != nil {
+			t.Fatalf("GetAlerts(%s): %v", stopID, err)
+		}
+		var got []string
+		for _, a := range alerts {
+			got = append(got, a.ID)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("GetAlerts(%s) = %v, want %v", stopID, got, want)
+		}
+	}
+	if _, err := s.GetAlerts(ctx, AlertFilter{StopID: "unknown"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAlerts(unknown) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetPunctuality(t *testing.T) {
+	st := memory.New()
+	line := "1"
+	st.PutRoute(models.Route{ID: "route", ShortName: &line, Type: models.RouteTypeTram})
+	st.PutStop(models.Stop{ID: "quay"})
+
+	// Monday 15 January 2024, departures at 8:00 and 9:00
+	day := models.NewServiceDay(time.Date(2024, 1, 15, 12, 0, 0, 0, models.TimetableLocation))
+	var calls []models.CallObservation
+	for i, delay := range []time.Duration{0, 2 * time.Minute, 10 * time.Minute, -1} {
+		scheduled := day.Time(8*3600 + i/2*3600 + i*60)
+		c := models.CallObservation{
+			ServiceDate:        day.String(),
+			RealtimeTripID:     "GVB:1:100",
+			StopSequence:       i + 1,
+			RouteID:            "route",
+			StopID:             "quay",
+			ScheduledDeparture: &scheduled,
+			Status:             models.DepartureDeparted,
+		}
+		if delay < 0 {
+			c.Status = models.DepartureCancelled
+		} else {
+			actual := scheduled.Add(delay)
+			c.ActualDeparture = &actual
+		}
+		calls = append(calls, c)
+	}
+	if err := st.RecordCalls(context.Background(), calls); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, memory.NewRealtime())
+	punctuality, err := s.GetPunctuality(context.Background(), PunctualityFilter{RouteID: "route", From: day.Date, To: day.Date})
+	if err != nil {
+		t.Fatalf("GetPunctuality: %v", err)
+	}
+	overall := punctuality.Overall
+	if overall.Calls != 4 || overall.Measured != 3 || overall.CancelledPercentage != 25 {
+		t.Errorf("overall = %+v, want 4 calls, 3 measured, 25%% cancelled", overall)
+	}
+	if overall.OnTimePercentage < 66 || overall.OnTimePercentage > 67 {
+		t.Errorf("on time = %.1f%%, want two of three", overall.OnTimePercentage)
+	}
+	if len(punctuality.ByHour) != 2 || punctuality.ByHour[0].Hour != 8 || punctuality.ByHour[1].Calls != 2 {
+		t.Errorf("by hour = %+v, want 8:00 and 9:00 with 2 calls each", punctuality.ByHour)
+	}
+	if len(punctuality.ByWeekday) != 1 || punctuality.ByWeekday[0].Weekday != 1 {
+		t.Errorf("by weekday = %+v, want Monday only", punctuality.ByWeekday)
+	}
+	if punctuality.From != "2024-01-15" || punctuality.To != "2024-01-15" {
+		t.Errorf("window = %s to %s", punctuality.From, punctuality.To)
+	}
+
+	if _, err := s.GetPunctuality(context.Background(), PunctualityFilter{RouteID: "unknown", From: day.Date, To: day.Date}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPunctuality(unknown route) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetPunctuality(context.Background(), PunctualityFilter{StopID: "quay", From: day.Date, To: day.Date.AddDate(0, 0, -1)}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("GetPunctuality(reversed window) = %v, want ErrInvalid", err)
+	}
+}
+
+// storeSnapshot stores v under key like the realtime worker, updated at
+// updatedAt and fresh until freshUntil.
+func storeSnapshot(t *testing.T, rt *memory.Realtime, key string, v interface{}, updatedAt, freshUntil time.Time) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := json.Marshal(realtime.Snapshot{UpdatedAt: updatedAt, FreshUntil: freshUntil, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Set(context.Background(), key, snapshot, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachedResponsesReportCurrentFreshness(t *testing.T) {
+	now := time.Now()
+	today := models.NewServiceDay(now)
+	departure := now.Add(10 * time.Minute).Truncate(time.Minute)
+	st := timetable(today, departure)
+
+	// The snapshot turns stale while the responses are cached
+	updatedAt, freshUntil := now.Add(-2*time.Minute), now.Add(100*time.Millisecond)
+	rt := memory.NewRealtime()
+	storeSnapshot(t, rt, realtime.DeparturesKey("30001234"), []models.Departure{{
+		TripID: "trip-100", ServiceDate: today.String(), Departure: departure, ScheduledDeparture: departure,
+		Status: models.DepartureEnRoute, Realtime: true,
+	}}, updatedAt, freshUntil)
+	storeSnapshot(t, rt, realtime.RouteVehiclesKey("route"), models.RouteVehicles{RouteID: "route", Vehicles: []models.Vehicle{}}, updatedAt, freshUntil)
+
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, rt)
+	ctx := context.Background()
+	departures, err := s.GetDepartures(ctx, "quay")
+	if err != nil {
+		t.Fatalf("GetDepartures: %v", err)
+	}
+	if len(departures) != 1 || departures[0].Freshness == nil || departures[0].Stale {
+		t.Fatalf("departures = %+v, want one with fresh realtime data", departures)
+	}
+	vehicles, err := s.GetVehiclesByRoute(ctx, "route")
+	if err != nil {
+		t.Fatalf("GetVehiclesByRoute: %v", err)
+	}
+	if vehicles.Stale {
+		t.Fatalf("route vehicles are stale before %s", freshUntil)
+	}
+
+	time.Sleep(time.Until(freshUntil) + 1100*time.Millisecond)
+	departures, err = s.GetDepartures(ctx, "quay")
+	if err != nil {
+		t.Fatalf("GetDepartures: %v", err)
+	}
+	if f := departures[0].Freshness; !f.Stale || f.Age <= 120 || !f.LastUpdated.Equal(updatedAt) {
+		t.Errorf("cached departure freshness = %+v, want stale and over 120s old", f)
+	}
+	vehicles, err = s.GetVehiclesByRoute(ctx, "route")
+	if err != nil {
+		t.Fatalf("GetVehiclesByRoute: %v", err)
+	}
+	if !vehicles.Stale || vehicles.Age <= 120 {
+		t.Errorf("cached route vehicles freshness = %+v, want stale and over 120s old", vehicles.Freshness)
+	}
+}
+
+func TestGetAllActiveVehiclesProjectsPositions(t *testing.T) {
+	now := time.Now()
+	today := models.NewServiceDay(now)
+	departed := now.Add(-time.Minute).Truncate(time.Second)
+
+	// A trip from the quay to a stop 1.1km north, 5 minutes later
+	st := memory.New()
+	st.PutStop(models.Stop{ID: "quay", Name: "Centraal Station", Lat: 52.3780, Lon: 4.9000})
+	st.PutStop(models.Stop{ID: "north", Name: "Noord", Lat: 52.3880, Lon: 4.9000})
+	st.PutRoute(models.Route{ID: "route", Type: models.RouteTypeTram})
+	st.SetServiceDate("weekday", today.String(), true)
+	st.PutTrip(models.Trip{ID: "trip-100", RouteID: "route", ServiceID: "weekday"})
+	st.PutStopTime(models.StopTime{TripID: "trip-100", StopID: "quay", StopSequence: 1, ArrivalSec: today.Seconds(departed), DepartureSec: today.Seconds(departed)})
+	arrival := departed.Add(5 * time.Minute)
+	st.PutStopTime(models.StopTime{TripID: "trip-100", StopID: "north", StopSequence: 2, ArrivalSec: today.Seconds(arrival), DepartureSec: today.Seconds(arrival)})
+
+	// Last reported departing the quay
+	rt := memory.NewRealtime()
+	storeSnapshot(t, rt, realtime.VehiclesKey, []models.Vehicle{{
+		ID: "GVB:1:100", TripID: "trip-100", RouteID: "route", Lat: 52.3780, Lon: 4.9000,
+		Status: models.VehicleInTransit, Timestamp: departed,
+	}}, now, now.Add(time.Minute))
+
+	s := NewTransitService(cache.NewLRUCache(10, time.Minute), st, rt)
+	ctx := context.Background()
+	// The second call is served from the cache
+	for _, source := range []string{"realtime store", "cache"} {
+		vehicles, err := s.GetAllActiveVehicles(ctx, true)
+		if err != nil {
+			t.Fatalf("GetAllActiveVehicles: %v", err)
+		}
+		if len(vehicles) != 1 {
+			t.Fatalf("got %d vehicles from the %s, want 1", len(vehicles), source)
+		}
+		v := vehicles[0]
+		if !v.Estimated || v.Reported == nil || v.Lat <= 52.3780 || v.Lat >= 52.3880 {
+			t.Errorf("vehicle from the %s at %f (estimated %v), want projected north of the quay", source, v.Lat, v.Estimated)
+		}
+	}
+
+	// Unless asked for, and in the GTFS-Realtime feed, vehicles are where
+	// they were reported
+	unprojected, err := s.GetAllActiveVehicles(ctx, false)
+	if err != nil {
+		t.Fatalf("GetAllActiveVehicles: %v", err)
+	}
+	reported, err := s.RealtimeVehicles(ctx)
+	if err != nil {
+		t.Fatalf("RealtimeVehicles: %v", err)
+	}
+	for name, vehicles := range map[string][]models.Vehicle{"without estimate": unprojected, "feed": reported} {
+		if len(vehicles) != 1 || vehicles[0].Estimated || vehicles[0].Lat != 52.3780 {
+			t.Errorf("%s: vehicles = %+v, want the reported position", name, vehicles)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"arrivo-transit-api/internal/gtfs"
+	"arrivo-transit-api/internal/models"
+)
+
+// LoadGTFS creates a store from a GTFS zip file. stops.txt and routes.txt are
+// required; trips.txt, stop_times.txt and the calendars are loaded when
+// present.
+func LoadGTFS(zipPath string) (*Store, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	s := New()
+
+	err = readGTFSFile(&r.Reader, "stops.txt", true, func(row map[string]string) {
+		lat, _ := strconv.ParseFloat(row["stop_lat"], 64)
+		lon, _ := strconv.ParseFloat(row["stop_lon"], 64)
+		s.PutStop(models.Stop{ID: row["stop_id"], Name: row["stop_name"], Lat: lat, Lon: lon, PlatformCode: row["platform_code"]})
+		// Only stops and quays have a TPC, like in the Postgres store
+		locationType, _ := strconv.Atoi(row["location_type"])
+		if row["stop_code"] != "" && locationType == 0 {
+			s.PutTimingPoint(row["stop_id"], row["stop_code"])
+		}
+		if row["parent_station"] != "" {
+			s.PutChildStop(row["parent_station"], row["stop_id"])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&r.Reader, "routes.txt", true, func(row map[string]string) {
+		routeType, _ := strconv.Atoi(row["route_type"])
+		s.PutRoute(models.Route{
+			ID:          row["route_id"],
+			AgencyID:    optional(row["agency_id"]),
+			ShortName:   optional(row["route_short_name"]),
+			LongName:    optional(row["route_long_name"]),
+			Description: optional(row["route_desc"]),
+			Type:        routeType,
+			URL:         optional(row["route_url"]),
+			Color:       optional(row["route_color"]),
+			TextColor:   optional(row["route_text_color"]),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&r.Reader, "trips.txt", false, func(row map[string]string) {
+		directionID, _ := strconv.Atoi(row["direction_id"])
+		s.PutTrip(models.Trip{
+			ID:          row["trip_id"],
+			RouteID:     row["route_id"],
+			ServiceID:   row["service_id"],
+			Headsign:    optional(row["trip_headsign"]),
+			ShortName:   optional(row["trip_short_name"]),
+			DirectionID: directionID,
+			ShapeID:     optional(row["shape_id"]),
+			RealtimeID:  optional(row["realtime_trip_id"]),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&r.Reader, "stop_times.txt", false, func(row map[string]string) {
+		seq, _ := strconv.Atoi(row["stop_sequence"])
+		dist, _ := strconv.ParseFloat(row["shape_dist_traveled"], 64)
+		pickup, _ := strconv.Atoi(row["pickup_type"])
+		s.PutStopTime(models.StopTime{
+			TripID:            row["trip_id"],
+			StopID:            row["stop_id"],
+			StopSequence:      seq,
+			ArrivalSec:        gtfs.ParseSeconds(row["arrival_time"]),
+			DepartureSec:      gtfs.ParseSeconds(row["departure_time"]),
+			Headsign:          optional(row["stop_headsign"]),
+			ShapeDistTraveled: dist,
+			PickupType:        pickup,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&r.Reader, "shapes.txt", false, func(row map[string]string) {
+		seq, _ := strconv.Atoi(row["shape_pt_sequence"])
+		lat, _ := strconv.ParseFloat(row["shape_pt_lat"], 64)
+		lon, _ := strconv.ParseFloat(row["shape_pt_lon"], 64)
+		s.PutShapePoint(row["shape_id"], seq, lat, lon)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&r.Reader, "calendar.txt", false, func(row map[string]string) {
+		start, err := time.Parse("20060102", row["start_date"])
+		if err != nil {
+			return
+		}
+		end, err := time.Parse("20060102", row["end_date"])
+		if err != nil {
+			return
+		}
+		for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+			if row[strings.ToLower(date.Weekday().String())] == "1" {
+				s.SetServiceDate(row["service_id"], date.Format("20060102"), true)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Exceptions apply on top of calendar.txt
+	err = readGTFSFile(&r.Reader, "calendar_dates.txt", false, func(row map[string]string) {
+		s.SetServiceDate(row["service_id"], row["date"], row["exception_type"] == "1")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// readGTFSFile calls fn for every record of name, keyed by header column.
+func readGTFSFile(r *zip.Reader, name string, required bool, fn func(map[string]string)) error {
+	f, err := r.Open(name)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read %s header: %w", name, err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+		fn(row)
+	}
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeGTFS writes a GTFS zip with the given files to a temporary directory.
+func writeGTFS(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gtfs.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadGTFSMapsTimingPointsOfQuays(t *testing.T) {
+	// Like the Postgres ingest, only stops and quays (location_type 0 or
+	// empty) carry a TPC; the station, its entrance and its boarding area
+	// do not.
+	path := writeGTFS(t, map[string]string{
+		"stops.txt": strings.Join([]string{
+			"stop_id,stop_code,stop_name,stop_lat,stop_lon,location_type,parent_station",
+			"stoparea:1,9999,Centraal Station,52.378,4.900,1,",
+			"quay:a,30001234,Centraal Station,52.378,4.900,0,stoparea:1",
+			"quay:b,30001235,Centraal Station,52.378,4.901,,stoparea:1",
+			"quay:c,30001234,Centraal Station,52.378,4.902,0,stoparea:1",
+			"entrance:1,30009998,Centraal Station,52.378,4.903,2,stoparea:1",
+			"boarding:1,30009999,Centraal Station,52.378,4.904,4,stoparea:1",
+		}, "\n"),
+		"routes.txt": "route_id,route_short_name,route_type\nroute,1,0\n",
+	})
+
+	s, err := LoadGTFS(path)
+	if err != nil {
+		t.Fatalf("LoadGTFS: %v", err)
+	}
+
+	ctx := context.Background()
+	codes, err := s.TimingPointCodes(ctx, "stoparea:1")
+	if err != nil {
+		t.Fatalf("TimingPointCodes: %v", err)
+	}
+	if strings.Join(codes, ",") != "30001234,30001235" {
+		t.Errorf("station timing points = %v, want 30001234,30001235", codes)
+	}
+	all, err := s.AllTimingPointCodes(ctx)
+	if err != nil {
+		t.Fatalf("AllTimingPointCodes: %v", err)
+	}
+	if strings.Join(all, ",") != "30001234,30001235" {
+		t.Errorf("all timing points = %v, want 30001234,30001235", all)
+	}
+}
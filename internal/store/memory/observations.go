@@ -0,0 +1,232 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+)
+
+var _ store.ObservationStore = (*Store)(nil)
+
+type callKey struct {
+	serviceDate, realtimeTripID string
+	stopSequence                int
+}
+
+type positionKey struct {
+	vehicleID  string
+	observedAt time.Time
+}
+
+// RecordCalls implements store.ObservationStore.
+func (s *Store) RecordCalls(ctx context.Context, calls []models.CallObservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range calls {
+		key := callKey{c.ServiceDate, c.RealtimeTripID, c.StopSequence}
+		if stored, ok := s.calls[key]; ok && stored.Status == c.Status {
+			continue
+		}
+		s.calls[key] = c
+	}
+	return nil
+}
+
+// RecordPositions implements store.ObservationStore.
+func (s *Store) RecordPositions(ctx context.Context, positions []models.PositionObservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range positions {
+		key := positionKey{p.VehicleID, p.ObservedAt.UTC()}
+		if _, ok := s.positions[key]; !ok {
+			s.positions[key] = p
+		}
+	}
+	return nil
+}
+
+// ObservedCalls implements store.ObservationStore.
+func (s *Store) ObservedCalls(ctx context.Context, from, to time.Time, fn func(models.CallObservation) error) error {
+	s.mu.RLock()
+	fromDate, toDate := from.Format("20060102"), to.Format("20060102")
+	var calls []models.CallObservation
+	for _, c := range s.calls {
+		if c.ServiceDate >= fromDate && c.ServiceDate <= toDate {
+			calls = append(calls, c)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(calls, func(i, j int) bool {
+		a, b := calls[i], calls[j]
+		if a.ServiceDate != b.ServiceDate {
+			return a.ServiceDate < b.ServiceDate
+		}
+		if a.RealtimeTripID != b.RealtimeTripID {
+			return a.RealtimeTripID < b.RealtimeTripID
+		}
+		return a.StopSequence < b.StopSequence
+	})
+	for _, c := range calls {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureObservationPartitions implements store.ObservationStore; the
+// memory store is not partitioned.
+func (s *Store) EnsureObservationPartitions(ctx context.Context, from, to time.Time) error {
+	return nil
+}
+
+// DropObservationPartitions implements store.ObservationStore by dropping
+// the observations of the days that ended before before.
+func (s *Store) DropObservationPartitions(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := models.NewServiceDay(before).Date
+	for key := range s.calls {
+		date, err := time.ParseInLocation("20060102", key.serviceDate, models.TimetableLocation)
+		if err == nil && date.Before(cutoff) {
+			delete(s.calls, key)
+		}
+	}
+	for key := range s.positions {
+		if key.observedAt.Before(cutoff) {
+			delete(s.positions, key)
+		}
+	}
+	return 0, nil
+}
+
+// RollupPunctuality implements store.ObservationStore; the memory store
+// counts punctuality from its calls when asked.
+func (s *Store) RollupPunctuality(ctx context.Context, serviceDate time.Time) (int, error) {
+	return 0, nil
+}
+
+// PunctualityCounts implements store.PunctualityStore.
+func (s *Store) PunctualityCounts(ctx context.Context, routeID, stopID string, from, to time.Time) (map[models.PunctualitySlot]*models.PunctualityCounts, error) {
+	counts := make(map[models.PunctualitySlot]*models.PunctualityCounts)
+	s.eachCall(routeID, stopID, from, to, func(c models.CallObservation, weekday, hour int) {
+		slot := models.PunctualitySlot{Weekday: weekday, Hour: hour}
+		if counts[slot] == nil {
+			counts[slot] = models.NewPunctualityCounts()
+		}
+		counts[slot].AddCall(c)
+	})
+	return counts, nil
+}
+
+// DelayProfiles implements store.PunctualityStore.
+func (s *Store) DelayProfiles(ctx context.Context, stopID string, from, to time.Time) (map[models.DelayProfileKey]*models.PunctualityCounts, error) {
+	profiles := make(map[models.DelayProfileKey]*models.PunctualityCounts)
+	s.eachCall("", stopID, from, to, func(c models.CallObservation, weekday, hour int) {
+		delay, ok := c.Delay()
+		if !ok {
+			return
+		}
+		key := models.DelayProfileKey{RouteID: c.RouteID, Hour: hour}
+		if profiles[key] == nil {
+			profiles[key] = models.NewPunctualityCounts()
+		}
+		profiles[key].Measured++
+		profiles[key].Delays[models.DelayBucket(delay)]++
+	})
+	return profiles, nil
+}
+
+// eachCall calls fn for the calls on routeID, or at stopID and its quays
+// when routeID is empty, on the service days from to to, with the ISO
+// weekday and hour of their scheduled departure. Like the rollups, it skips
+// calls without a route, stop or scheduled time.
+func (s *Store) eachCall(routeID, stopID string, from, to time.Time, fn func(c models.CallObservation, weekday, hour int)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quays := map[string]bool{stopID: true}
+	for _, child := range s.children[stopID] {
+		quays[child] = true
+	}
+	fromDate, toDate := from.Format("20060102"), to.Format("20060102")
+
+	for _, c := range s.calls {
+		if c.ServiceDate < fromDate || c.ServiceDate > toDate || c.RouteID == "" || c.StopID == "" {
+			continue
+		}
+		if routeID != "" && c.RouteID != routeID || routeID == "" && !quays[c.StopID] {
+			continue
+		}
+		scheduled := c.Scheduled()
+		if scheduled.IsZero() {
+			continue
+		}
+		date, err := time.ParseInLocation("20060102", c.ServiceDate, models.TimetableLocation)
+		if err != nil {
+			continue
+		}
+		weekday := int(date.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		fn(c, weekday, scheduled.In(models.TimetableLocation).Hour())
+	}
+}
+
+// RollupOccupancy implements store.ObservationStore; the memory store
+// counts occupancy from its positions when asked.
+func (s *Store) RollupOccupancy(ctx context.Context, day time.Time) (int, error) {
+	return 0, nil
+}
+
+// OccupancyCounts implements store.OccupancyStore. Like the rollups, every
+// vehicle counts once per trip and stop, with the last occupancy it
+// reported, on the local day of that report.
+func (s *Store) OccupancyCounts(ctx context.Context, routeID, stopID string, from, to time.Time) (map[models.OccupancySlot]*models.OccupancyCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quays := map[string]bool{stopID: true}
+	for _, child := range s.children[stopID] {
+		quays[child] = true
+	}
+
+	type visit struct{ date, vehicleID, tripID, stopID string }
+	last := make(map[visit]models.PositionObservation)
+	for _, p := range s.positions {
+		if p.Occupancy == nil || p.TripID == "" || p.RouteID == "" || p.StopID == nil {
+			continue
+		}
+		if routeID != "" && p.RouteID != routeID || routeID == "" && !quays[*p.StopID] {
+			continue
+		}
+		v := visit{p.ObservedAt.In(models.TimetableLocation).Format("20060102"), p.VehicleID, p.TripID, *p.StopID}
+		if seen, ok := last[v]; !ok || p.ObservedAt.After(seen.ObservedAt) {
+			last[v] = p
+		}
+	}
+
+	fromDate, toDate := from.Format("20060102"), to.Format("20060102")
+	counts := make(map[models.OccupancySlot]*models.OccupancyCounts)
+	for _, p := range last {
+		local := p.ObservedAt.In(models.TimetableLocation)
+		if date := local.Format("20060102"); date < fromDate || date > toDate {
+			continue
+		}
+		weekday := int(local.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		slot := models.OccupancySlot{RouteID: p.RouteID, TripID: p.TripID, StopID: *p.StopID, Weekday: weekday, Hour: local.Hour()}
+		if counts[slot] == nil {
+			counts[slot] = models.NewOccupancyCounts()
+		}
+		counts[slot].AddLevel(models.OccupancyLevel(*p.Occupancy))
+	}
+	return counts, nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"arrivo-transit-api/internal/store"
+)
+
+// Realtime is an in-process store.RealtimeStore. Expired values are dropped
+// lazily on read.
+type Realtime struct {
+	mu      sync.Mutex
+	entries map[string]realtimeEntry
+	now     func() time.Time
+}
+
+type realtimeEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+var _ store.RealtimeStore = (*Realtime)(nil)
+
+// NewRealtime creates an empty in-memory realtime store.
+func NewRealtime() *Realtime {
+	return &Realtime{
+		entries: make(map[string]realtimeEntry),
+		now:     time.Now,
+	}
+}
+
+// Get implements store.RealtimeStore.
+func (r *Realtime) Get(ctx context.Context, key string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if !entry.expiresAt.IsZero() && !r.now().Before(entry.expiresAt) {
+		delete(r.entries, key)
+		return nil, store.ErrNotFound
+	}
+	return entry.value, nil
+}
+
+// Set implements store.RealtimeStore. A ttl of zero keeps the value forever.
+func (r *Realtime) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := realtimeEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expiresAt = r.now().Add(ttl)
+	}
+	r.entries[key] = entry
+	return nil
+}
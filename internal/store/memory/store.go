@@ -0,0 +1,563 @@
+// Package memory implements the store interfaces in process. A Store can be
+// filled by hand or loaded from a GTFS zip with LoadGTFS, which makes it
+// suitable for hermetic tests of services and handlers.
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"arrivo-transit-api/internal/geo"
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/search"
+	"arrivo-transit-api/internal/store"
+)
+
+// Store keeps static GTFS data in maps.
+type Store struct {
+	mu        sync.RWMutex
+	stops     map[string]models.Stop
+	routes    map[string]models.Route
+	trips     map[string]models.Trip
+	stopTimes map[string][]models.StopTime // by trip ID, ordered by stop_sequence
+	aliases   map[int64]models.StopAlias
+	nextAlias int64
+
+	timingPoints map[string][]string        // TimingPointCodes by stop ID
+	children     map[string][]string        // Child stop IDs by parent station
+	services     map[string]map[string]bool // Dates (YYYYMMDD) a service runs on, by service ID
+	shapes       map[string][]shapePoint    // Points by shape ID, ordered by sequence
+
+	calls     map[callKey]models.CallObservation
+	positions map[positionKey]models.PositionObservation
+}
+
+type shapePoint struct {
+	sequence int
+	models.ShapePoint
+}
+
+var _ store.Static = (*Store)(nil)
+
+// New creates an empty in-memory store.
+func New() *Store {
+	return &Store{
+		stops:     make(map[string]models.Stop),
+		routes:    make(map[string]models.Route),
+		trips:     make(map[string]models.Trip),
+		stopTimes: make(map[string][]models.StopTime),
+		aliases:   make(map[int64]models.StopAlias),
+
+		timingPoints: make(map[string][]string),
+		children:     make(map[string][]string),
+		services:     make(map[string]map[string]bool),
+		shapes:       make(map[string][]shapePoint),
+
+		calls:     make(map[callKey]models.CallObservation),
+		positions: make(map[positionKey]models.PositionObservation),
+	}
+}
+
+// PutStop adds or replaces a stop.
+func (s *Store) PutStop(stop models.Stop) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stops[stop.ID] = stop
+}
+
+// PutTimingPoint maps a stop to an OVapi TimingPointCode.
+func (s *Store) PutTimingPoint(stopID, timingPointCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timingPoints[stopID] = append(s.timingPoints[stopID], timingPointCode)
+}
+
+// PutChildStop records stopID as a quay of the parent station.
+func (s *Store) PutChildStop(parentID, stopID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.children[parentID] = append(s.children[parentID], stopID)
+}
+
+// PutRoute adds or replaces a route.
+func (s *Store) PutRoute(route models.Route) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[route.ID] = route
+}
+
+// PutTrip adds or replaces a trip.
+func (s *Store) PutTrip(trip models.Trip) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trips[trip.ID] = trip
+}
+
+// SetServiceDate records whether the trips of a service run on date
+// (YYYYMMDD).
+func (s *Store) SetServiceDate(serviceID, date string, runs bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.services[serviceID] == nil {
+		s.services[serviceID] = make(map[string]bool)
+	}
+	if runs {
+		s.services[serviceID][date] = true
+	} else {
+		delete(s.services[serviceID], date)
+	}
+}
+
+// PutStopTime adds or replaces a call of a trip, keeping the trip's calls
+// ordered by stop_sequence.
+func (s *Store) PutStopTime(st models.StopTime) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := s.stopTimes[st.TripID]
+	i := sort.Search(len(calls), func(i int) bool { return calls[i].StopSequence >= st.StopSequence })
+	if i < len(calls) && calls[i].StopSequence == st.StopSequence {
+		calls[i] = st
+		return
+	}
+	calls = append(calls, models.StopTime{})
+	copy(calls[i+1:], calls[i:])
+	calls[i] = st
+	s.stopTimes[st.TripID] = calls
+}
+
+// PutShapePoint adds or replaces a point of a shape, keeping the shape's
+// points ordered by sequence.
+func (s *Store) PutShapePoint(shapeID string, sequence int, lat, lon float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	points := s.shapes[shapeID]
+	point := shapePoint{sequence, models.ShapePoint{Lat: lat, Lon: lon}}
+	i := sort.Search(len(points), func(i int) bool { return points[i].sequence >= sequence })
+	if i < len(points) && points[i].sequence == sequence {
+		points[i] = point
+		return
+	}
+	points = append(points, shapePoint{})
+	copy(points[i+1:], points[i:])
+	points[i] = point
+	s.shapes[shapeID] = points
+}
+
+// SearchStops implements store.StopStore.
+func (s *Store) SearchStops(ctx context.Context, normalized string, lat, lon *float64, limit int) ([]models.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stops []models.Stop
+	for _, stop := range s.stops {
+		score := search.Score(normalized, stop.Name)
+		if score == 0 {
+			continue
+		}
+		if lat != nil && lon != nil {
+			stop.Distance = geo.Distance(*lat, *lon, stop.Lat, stop.Lon)
+			score += 0.3 * math.Exp(-stop.Distance/2000)
+		}
+		stop.Score = score
+		stops = append(stops, stop)
+	}
+
+	sort.Slice(stops, func(i, j int) bool {
+		if stops[i].Score != stops[j].Score {
+			return stops[i].Score > stops[j].Score
+		}
+		return stops[i].Name < stops[j].Name
+	})
+	if len(stops) > limit {
+		stops = stops[:limit]
+	}
+	return stops, nil
+}
+
+// NearbyStops implements store.StopStore.
+func (s *Store) NearbyStops(ctx context.Context, lat, lon, radius float64, limit int) ([]models.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stops []models.Stop
+	for _, stop := range s.stops {
+		stop.Distance = geo.Distance(lat, lon, stop.Lat, stop.Lon)
+		if stop.Distance < radius {
+			stops = append(stops, stop)
+		}
+	}
+
+	sort.Slice(stops, func(i, j int) bool { return stops[i].Distance < stops[j].Distance })
+	if len(stops) > limit {
+		stops = stops[:limit]
+	}
+	return stops, nil
+}
+
+// GetStop implements store.StopStore.
+func (s *Store) GetStop(ctx context.Context, stopID string) (*models.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stop, ok := s.stops[stopID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &stop, nil
+}
+
+// Quays implements store.StopStore.
+func (s *Store) Quays(ctx context.Context, stopID string) (map[string]models.Stop, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quays := make(map[string]models.Stop)
+	for _, id := range append([]string{stopID}, s.children[stopID]...) {
+		if stop, ok := s.stops[id]; ok {
+			quays[id] = stop
+		}
+	}
+	return quays, nil
+}
+
+// TimingPointCodes implements store.StopStore.
+func (s *Store) TimingPointCodes(ctx context.Context, stopID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.stops[stopID]; !ok {
+		return nil, store.ErrNotFound
+	}
+
+	seen := make(map[string]bool)
+	codes := []string{}
+	for _, id := range append([]string{stopID}, s.children[stopID]...) {
+		for _, code := range s.timingPoints[id] {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes, nil
+}
+
+// AllTimingPointCodes implements store.StopStore.
+func (s *Store) AllTimingPointCodes(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	codes := []string{}
+	for _, stopCodes := range s.timingPoints {
+		for _, code := range stopCodes {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+// TimingPointStops implements store.StopStore.
+func (s *Store) TimingPointStops(ctx context.Context) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stops := make(map[string]string)
+	for stopID, codes := range s.timingPoints {
+		for _, code := range codes {
+			stops[code] = stopID
+		}
+	}
+	return stops, nil
+}
+
+// ListStopAliases implements store.AliasStore.
+func (s *Store) ListStopAliases(ctx context.Context) ([]models.StopAlias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	aliases := make([]models.StopAlias, 0, len(s.aliases))
+	for _, alias := range s.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		return search.Normalize(aliases[i].Alias) < search.Normalize(aliases[j].Alias)
+	})
+	return aliases, nil
+}
+
+// CreateStopAlias implements store.AliasStore.
+func (s *Store) CreateStopAlias(ctx context.Context, alias models.StopAlias) (*models.StopAlias, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := search.Normalize(alias.Alias)
+	for id, existing := range s.aliases {
+		if search.Normalize(existing.Alias) == key {
+			alias.ID, alias.CreatedAt, alias.UpdatedAt = id, existing.CreatedAt, now
+			s.aliases[id] = alias
+			return &alias, nil
+		}
+	}
+
+	s.nextAlias++
+	alias.ID, alias.CreatedAt, alias.UpdatedAt = s.nextAlias, now, now
+	s.aliases[alias.ID] = alias
+	return &alias, nil
+}
+
+// UpdateStopAlias implements store.AliasStore.
+func (s *Store) UpdateStopAlias(ctx context.Context, id int64, alias models.StopAlias) (*models.StopAlias, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.aliases[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	key := search.Normalize(alias.Alias)
+	for other, a := range s.aliases {
+		if other != id && search.Normalize(a.Alias) == key {
+			return nil, store.ErrConflict
+		}
+	}
+	alias.ID, alias.CreatedAt, alias.UpdatedAt = id, existing.CreatedAt, time.Now()
+	s.aliases[id] = alias
+	return &alias, nil
+}
+
+// DeleteStopAlias implements store.AliasStore.
+func (s *Store) DeleteStopAlias(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.aliases[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.aliases, id)
+	return nil
+}
+
+// SearchRoutes implements store.RouteStore.
+func (s *Store) SearchRoutes(ctx context.Context, query string, limit int) ([]models.Route, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	contains := func(field *string) bool {
+		return field != nil && strings.Contains(strings.ToLower(*field), query)
+	}
+
+	var routes []models.Route
+	for _, route := range s.routes {
+		if contains(route.ShortName) || contains(route.LongName) {
+			routes = append(routes, route)
+		}
+	}
+
+	rank := func(r models.Route) (int, string, string) {
+		rank := 2
+		if contains(r.ShortName) {
+			rank = 1
+		}
+		return rank, deref(r.ShortName), deref(r.LongName)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		ri, si, li := rank(routes[i])
+		rj, sj, lj := rank(routes[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if si != sj {
+			return si < sj
+		}
+		return li < lj
+	})
+	if len(routes) > limit {
+		routes = routes[:limit]
+	}
+	return routes, nil
+}
+
+// GetRoute implements store.RouteStore.
+func (s *Store) GetRoute(ctx context.Context, routeID string) (*models.Route, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	route, ok := s.routes[routeID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &route, nil
+}
+
+// GetTrip implements store.TripStore.
+func (s *Store) GetTrip(ctx context.Context, tripID string) (*models.Trip, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trip, ok := s.trips[tripID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &trip, nil
+}
+
+// TripPath implements store.ShapeStore.
+func (s *Store) TripPath(ctx context.Context, tripID string) (*models.TripPath, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trip, ok := s.trips[tripID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	var points []models.ShapePoint
+	if trip.ShapeID != nil {
+		for _, p := range s.shapes[*trip.ShapeID] {
+			points = append(points, p.ShapePoint)
+		}
+	}
+	var calls []models.PathCall
+	for _, st := range s.stopTimes[tripID] {
+		stop, ok := s.stops[st.StopID]
+		if !ok {
+			continue
+		}
+		calls = append(calls, models.PathCall{StopID: st.StopID, Lat: stop.Lat, Lon: stop.Lon, ArrivalSec: st.ArrivalSec, DepartureSec: st.DepartureSec})
+	}
+	return models.NewTripPath(tripID, points, calls), nil
+}
+
+// StopTimes implements store.TripStore.
+func (s *Store) StopTimes(ctx context.Context, tripID string) ([]models.StopTime, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.StopTime(nil), s.stopTimes[tripID]...), nil
+}
+
+// TripsByRealtimeID implements store.TripStore.
+func (s *Store) TripsByRealtimeID(ctx context.Context, journeys []models.Journey) (map[models.Journey]models.Trip, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string][]string, len(journeys))
+	for _, j := range journeys {
+		wanted[j.RealtimeID] = append(wanted[j.RealtimeID], j.ServiceDate)
+	}
+
+	trips := make(map[models.Journey]models.Trip)
+	for _, trip := range s.trips {
+		if trip.RealtimeID == nil {
+			continue
+		}
+		for _, date := range wanted[*trip.RealtimeID] {
+			if !s.services[trip.ServiceID][date] {
+				continue
+			}
+			// Pick the lowest trip ID running that day, like the Postgres
+			// store does
+			key := models.Journey{RealtimeID: *trip.RealtimeID, ServiceDate: date}
+			if existing, ok := trips[key]; !ok || trip.ID < existing.ID {
+				trips[key] = trip
+			}
+		}
+	}
+	return trips, nil
+}
+
+// TripsByID implements store.TripStore.
+func (s *Store) TripsByID(ctx context.Context, tripIDs []string) (map[string]models.Trip, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trips := make(map[string]models.Trip)
+	for _, id := range tripIDs {
+		if trip, ok := s.trips[id]; ok {
+			trips[id] = trip
+		}
+	}
+	return trips, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// ScheduledDepartures implements store.ScheduleStore.
+func (s *Store) ScheduledDepartures(ctx context.Context, stopID string, from, to time.Time) ([]models.Departure, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	quays := map[string]bool{stopID: true}
+	for _, child := range s.children[stopID] {
+		quays[child] = true
+	}
+
+	departures := []models.Departure{}
+	for _, day := range models.ServiceDays(from, to) {
+		fromSec, toSec := day.Seconds(from), day.Seconds(to)
+		for tripID, calls := range s.stopTimes {
+			trip, ok := s.trips[tripID]
+			if !ok || !s.services[trip.ServiceID][day.String()] {
+				continue
+			}
+			route := s.routes[trip.RouteID]
+
+			for _, st := range calls {
+				if !quays[st.StopID] || st.PickupType == 1 || st.DepartureSec < fromSec || st.DepartureSec > toSec {
+					continue
+				}
+				d := models.Departure{
+					Departure:          day.Time(st.DepartureSec),
+					ScheduledDeparture: day.Time(st.DepartureSec),
+					Status:             models.DepartureScheduled,
+					TransportType:      models.TransportType(route.Type),
+					StopID:             st.StopID,
+					PlannedStopID:      st.StopID,
+					RouteID:            trip.RouteID,
+					TripID:             trip.ID,
+					ServiceDate:        day.String(),
+				}
+				if route.ShortName != nil {
+					d.Line = *route.ShortName
+				} else if route.LongName != nil {
+					d.Line = *route.LongName
+				}
+				if route.AgencyID != nil {
+					d.Operator = *route.AgencyID
+				}
+				if st.Headsign != nil {
+					d.Destination = *st.Headsign
+				} else if trip.Headsign != nil {
+					d.Destination = *trip.Headsign
+				}
+				departures = append(departures, d)
+			}
+		}
+	}
+
+	sort.Slice(departures, func(i, j int) bool {
+		if !departures[i].ScheduledDeparture.Equal(departures[j].ScheduledDeparture) {
+			return departures[i].ScheduledDeparture.Before(departures[j].ScheduledDeparture)
+		}
+		return departures[i].TripID < departures[j].TripID
+	})
+	return departures, nil
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ListStopAliases implements store.AliasStore.
+func (s *Store) ListStopAliases(ctx context.Context) ([]models.StopAlias, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, alias, expansion, kind, created_at, updated_at
+		FROM stop_aliases
+		ORDER BY alias_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stop aliases: %w", err)
+	}
+	defer rows.Close()
+
+	aliases := []models.StopAlias{}
+	for rows.Next() {
+		var alias models.StopAlias
+		if err := rows.Scan(&alias.ID, &alias.Alias, &alias.Expansion, &alias.Kind, &alias.CreatedAt, &alias.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stop alias: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// CreateStopAlias implements store.AliasStore.
+func (s *Store) CreateStopAlias(ctx context.Context, alias models.StopAlias) (*models.StopAlias, error) {
+	err := s.db.Primary().QueryRow(ctx, `
+		INSERT INTO stop_aliases (alias, expansion, kind)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (alias_key) DO UPDATE SET
+			alias = EXCLUDED.alias,
+			expansion = EXCLUDED.expansion,
+			kind = EXCLUDED.kind,
+			updated_at = now()
+		RETURNING id, created_at, updated_at`,
+		alias.Alias, alias.Expansion, alias.Kind,
+	).Scan(&alias.ID, &alias.CreatedAt, &alias.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stop alias: %w", err)
+	}
+	return &alias, nil
+}
+
+// UpdateStopAlias implements store.AliasStore.
+func (s *Store) UpdateStopAlias(ctx context.Context, id int64, alias models.StopAlias) (*models.StopAlias, error) {
+	alias.ID = id
+	err := s.db.Primary().QueryRow(ctx, `
+		UPDATE stop_aliases
+		SET alias = $2, expansion = $3, kind = $4, updated_at = now()
+		WHERE id = $1
+		RETURNING created_at, updated_at`,
+		id, alias.Alias, alias.Expansion, alias.Kind,
+	).Scan(&alias.CreatedAt, &alias.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, store.ErrConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update stop alias %d: %w", id, err)
+	}
+	return &alias, nil
+}
+
+// DeleteStopAlias implements store.AliasStore.
+func (s *Store) DeleteStopAlias(ctx context.Context, id int64) error {
+	tag, err := s.db.Exec(ctx, "DELETE FROM stop_aliases WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete stop alias %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+)
+
+var _ store.ObservationStore = (*Store)(nil)
+
+// observationTables are the tables partitioned by day.
+var observationTables = []string{"call_observations", "vehicle_observations"}
+
+// partitionDateFormat is the suffix of a partition name, e.g.
+// call_observations_20240115.
+const partitionDateFormat = "20060102"
+
+// RecordCalls implements store.ObservationStore.
+func (s *Store) RecordCalls(ctx context.Context, calls []models.CallObservation) error {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	const cols = 14
+	valueStrings := make([]string, 0, len(calls))
+	valueArgs := make([]interface{}, 0, len(calls)*cols)
+	for i, c := range calls {
+		valueStrings = append(valueStrings, placeholders(i, cols))
+		valueArgs = append(valueArgs, c.ServiceDate, c.RealtimeTripID, c.StopSequence, nullable(c.TripID), nullable(c.RouteID),
+			nullable(c.StopID), c.TimingPointCode, c.ScheduledArrival, c.ScheduledDeparture, c.ActualArrival, c.ActualDeparture,
+			c.Status, c.Source, c.ObservedAt)
+	}
+
+	_, err := s.db.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO call_observations (service_date, realtime_trip_id, stop_sequence, trip_id, route_id, stop_id,
+			timing_point_code, scheduled_arrival, scheduled_departure, actual_arrival, actual_departure, status, source, observed_at)
+		VALUES %s
+		ON CONFLICT (service_date, realtime_trip_id, stop_sequence) DO UPDATE SET
+			actual_arrival = EXCLUDED.actual_arrival,
+			actual_departure = EXCLUDED.actual_departure,
+			status = EXCLUDED.status,
+			source = EXCLUDED.source,
+			observed_at = EXCLUDED.observed_at
+		WHERE call_observations.status <> EXCLUDED.status`, strings.Join(valueStrings, ",")), valueArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to insert %d call observations: %w", len(calls), rejected(err))
+	}
+	return nil
+}
+
+// RecordPositions implements store.ObservationStore.
+func (s *Store) RecordPositions(ctx context.Context, positions []models.PositionObservation) error {
+	if len(positions) == 0 {
+		return nil
+	}
+
+	const cols = 13
+	valueStrings := make([]string, 0, len(positions))
+	valueArgs := make([]interface{}, 0, len(positions)*cols)
+	for i, p := range positions {
+		valueStrings = append(valueStrings, placeholders(i, cols))
+		valueArgs = append(valueArgs, p.ObservedAt, p.VehicleID, nullable(p.TripID), nullable(p.RouteID), p.Lat, p.Lon,
+			p.Bearing, p.Speed, p.Delay, p.Status, p.StopID, p.Source, p.Occupancy)
+	}
+
+	_, err := s.db.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO vehicle_observations (observed_at, vehicle_id, trip_id, route_id, lat, lon, bearing, speed, delay, status, stop_id, source, occupancy)
+		VALUES %s
+		ON CONFLICT (vehicle_id, observed_at) DO NOTHING`, strings.Join(valueStrings, ",")), valueArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to insert %d vehicle observations: %w", len(positions), rejected(err))
+	}
+	return nil
+}
+
+// ObservedCalls implements store.ObservationStore.
+func (s *Store) ObservedCalls(ctx context.Context, from, to time.Time, fn func(models.CallObservation) error) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT to_char(service_date, 'YYYYMMDD'), realtime_trip_id, stop_sequence, COALESCE(trip_id, ''), COALESCE(route_id, ''),
+			COALESCE(stop_id, ''), timing_point_code, scheduled_arrival, scheduled_departure, actual_arrival, actual_departure,
+			status, source, observed_at
+		FROM call_observations
+		WHERE service_date BETWEEN $1 AND $2
+		ORDER BY service_date, realtime_trip_id, stop_sequence`,
+		from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to query call observations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.CallObservation
+		if err := rows.Scan(&c.ServiceDate, &c.RealtimeTripID, &c.StopSequence, &c.TripID, &c.RouteID, &c.StopID,
+			&c.TimingPointCode, &c.ScheduledArrival, &c.ScheduledDeparture, &c.ActualArrival, &c.ActualDeparture,
+			&c.Status, &c.Source, &c.ObservedAt); err != nil {
+			return fmt.Errorf("failed to scan call observation: %w", err)
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EnsureObservationPartitions implements store.ObservationStore. Days start
+// at local midnight.
+func (s *Store) EnsureObservationPartitions(ctx context.Context, from, to time.Time) error {
+	for day := startOfDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		for _, table := range observationTables {
+			// call_observations is partitioned by date, vehicle_observations by
+			// timestamp; both accept a timestamp with offset as bound
+			_, err := s.db.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+				pgx.Identifier{partitionName(table, day)}.Sanitize(), pgx.Identifier{table}.Sanitize(),
+				boundary(table, day), boundary(table, next)))
+			if err != nil {
+				return fmt.Errorf("failed to create partition of %s for %s: %w", table, day.Format(partitionDateFormat), err)
+			}
+		}
+	}
+	return nil
+}
+
+// DropObservationPartitions implements store.ObservationStore.
+func (s *Store) DropObservationPartitions(ctx context.Context, before time.Time) (int, error) {
+	dropped := 0
+	for _, table := range observationTables {
+		rows, err := s.db.Query(ctx, `
+			SELECT c.relname
+			FROM pg_inherits i
+			JOIN pg_class c ON c.oid = i.inhrelid
+			JOIN pg_class p ON p.oid = i.inhparent
+			WHERE p.relname = $1`, table)
+		if err != nil {
+			return dropped, fmt.Errorf("failed to list partitions of %s: %w", table, err)
+		}
+		partitions, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return dropped, fmt.Errorf("failed to read partitions of %s: %w", table, err)
+		}
+
+		for _, partition := range partitions {
+			day, err := time.ParseInLocation(partitionDateFormat, strings.TrimPrefix(partition, table+"_"), time.Local)
+			if err != nil || day.AddDate(0, 0, 1).After(before) {
+				continue
+			}
+			if _, err := s.db.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{partition}.Sanitize()); err != nil {
+				return dropped, fmt.Errorf("failed to drop partition %s: %w", partition, err)
+			}
+			dropped++
+		}
+	}
+	return dropped, nil
+}
+
+func partitionName(table string, day time.Time) string {
+	return table + "_" + day.Format(partitionDateFormat)
+}
+
+// boundary formats the start of day as a partition bound of table.
+func boundary(table string, day time.Time) string {
+	if table == "call_observations" {
+		return day.Format("2006-01-02")
+	}
+	return day.Format(time.RFC3339)
+}
+
+// rejected marks errors about the rows themselves with store.ErrRejected:
+// cardinality violations (a row affected twice), data exceptions and
+// integrity constraint violations, including rows outside every partition.
+func rejected(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code[:2] {
+	case "21", "22", "23":
+		return fmt.Errorf("%w: %w", store.ErrRejected, err)
+	}
+	return err
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// placeholders returns the parameter list of row i of a multi-row insert
+// with cols columns, e.g. "($4, $5, $6)" for row 1 of 3 columns.
+func placeholders(i, cols int) string {
+	params := make([]string, cols)
+	for j := range params {
+		params[j] = fmt.Sprintf("$%d", i*cols+j+1)
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+// nullable maps an empty string to NULL.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
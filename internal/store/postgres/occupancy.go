@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// occupancyKey identifies an occupancy rollup of a day.
+type occupancyKey struct {
+	routeID, tripID, stopID string
+	hour                    int
+}
+
+// RollupOccupancy implements store.ObservationStore. Every vehicle counts
+// once per trip and stop, with the last occupancy it reported at or heading
+// to the stop. Reports without a known trip, route or stop are left out.
+func (s *Store) RollupOccupancy(ctx context.Context, day time.Time) (int, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, models.TimetableLocation)
+	date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Read from the primary, which has the positions archived last
+	rows, err := s.db.Primary().Query(ctx, `
+		SELECT route_id, trip_id, stop_id, EXTRACT(HOUR FROM observed_at AT TIME ZONE $3)::int, occupancy, count(*)
+		FROM (
+			SELECT DISTINCT ON (vehicle_id, trip_id, stop_id) route_id, trip_id, stop_id, observed_at, occupancy
+			FROM vehicle_observations
+			WHERE observed_at >= $1 AND observed_at < $2 AND occupancy IS NOT NULL
+				AND trip_id IS NOT NULL AND route_id IS NOT NULL AND stop_id IS NOT NULL
+			ORDER BY vehicle_id, trip_id, stop_id, observed_at DESC
+		) v
+		GROUP BY 1, 2, 3, 4, 5`,
+		start, start.AddDate(0, 0, 1), models.TimetableLocation.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to query vehicle positions of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	rollups := make(map[occupancyKey]*models.OccupancyCounts)
+	for rows.Next() {
+		var key occupancyKey
+		var occupancy string
+		var vehicles int
+		if err := rows.Scan(&key.routeID, &key.tripID, &key.stopID, &key.hour, &occupancy, &vehicles); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan vehicle positions: %w", err)
+		}
+		level := models.OccupancyLevel(occupancy)
+		if level < 0 {
+			continue
+		}
+		counts, ok := rollups[key]
+		if !ok {
+			counts = models.NewOccupancyCounts()
+			rollups[key] = counts
+		}
+		counts.Vehicles += vehicles
+		counts.Levels[level] += vehicles
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read vehicle positions of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	tx, err := s.db.Primary().Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM occupancy_rollups WHERE service_date = $1", date); err != nil {
+		return 0, fmt.Errorf("failed to delete occupancy rollups of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	rollupRows := make([][]interface{}, 0, len(rollups))
+	for key, counts := range rollups {
+		levels := make([]int32, len(counts.Levels))
+		for i, n := range counts.Levels {
+			levels[i] = int32(n)
+		}
+		rollupRows = append(rollupRows, []interface{}{date, key.routeID, key.tripID, key.stopID, key.hour, counts.Vehicles, levels})
+	}
+	cols := []string{"service_date", "route_id", "trip_id", "stop_id", "hour", "vehicles", "levels"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"occupancy_rollups"}, cols, pgx.CopyFromRows(rollupRows)); err != nil {
+		return 0, fmt.Errorf("failed to store occupancy rollups of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(rollupRows), nil
+}
+
+// OccupancyCounts implements store.OccupancyStore.
+func (s *Store) OccupancyCounts(ctx context.Context, routeID, stopID string, from, to time.Time) (map[models.OccupancySlot]*models.OccupancyCounts, error) {
+	filter, id := "route_id = $1", routeID
+	if routeID == "" {
+		filter, id = "stop_id IN (SELECT stop_id FROM stops WHERE stop_id = $1 OR parent_station = $1)", stopID
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT route_id, trip_id, stop_id, EXTRACT(ISODOW FROM service_date)::int, hour, vehicles, levels
+		FROM occupancy_rollups
+		WHERE `+filter+` AND service_date BETWEEN $2 AND $3`, id, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query occupancy: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[models.OccupancySlot]*models.OccupancyCounts)
+	for rows.Next() {
+		var slot models.OccupancySlot
+		var vehicles int
+		var levels []int32
+		if err := rows.Scan(&slot.RouteID, &slot.TripID, &slot.StopID, &slot.Weekday, &slot.Hour, &vehicles, &levels); err != nil {
+			return nil, fmt.Errorf("failed to scan occupancy: %w", err)
+		}
+		c, ok := counts[slot]
+		if !ok {
+			c = models.NewOccupancyCounts()
+			counts[slot] = c
+		}
+		c.Vehicles += vehicles
+		for i, n := range levels {
+			if i < len(c.Levels) {
+				c.Levels[i] += int(n)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read occupancy: %w", err)
+	}
+	return counts, nil
+}
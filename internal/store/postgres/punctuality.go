@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// punctualityKey identifies a punctuality rollup of a service day.
+type punctualityKey struct {
+	routeID, stopID string
+	hour            int
+}
+
+// RollupPunctuality implements store.ObservationStore. Calls without a
+// known route or stop are left out.
+func (s *Store) RollupPunctuality(ctx context.Context, serviceDate time.Time) (int, error) {
+	date := time.Date(serviceDate.Year(), serviceDate.Month(), serviceDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Read from the primary, which has the calls archived last
+	rows, err := s.db.Primary().Query(ctx, `
+		SELECT route_id, stop_id, EXTRACT(HOUR FROM scheduled AT TIME ZONE $2)::int AS hour,
+			CASE WHEN delay IS NOT NULL THEN LEAST(GREATEST(FLOOR((delay - $3::int)::float / $4::int), 0), $5::int - 1)::int END AS bucket,
+			count(*),
+			count(*) FILTER (WHERE delay BETWEEN $6::int AND $7::int),
+			count(*) FILTER (WHERE status = $8),
+			count(*) FILTER (WHERE status = $9)
+		FROM (
+			SELECT route_id, stop_id, status,
+				COALESCE(scheduled_departure, scheduled_arrival) AS scheduled,
+				CASE
+					WHEN status <> $10 THEN NULL
+					WHEN actual_departure IS NOT NULL AND scheduled_departure IS NOT NULL
+						THEN EXTRACT(EPOCH FROM actual_departure - scheduled_departure)::int
+					WHEN actual_arrival IS NOT NULL AND scheduled_arrival IS NOT NULL
+						THEN EXTRACT(EPOCH FROM actual_arrival - scheduled_arrival)::int
+				END AS delay
+			FROM call_observations
+			WHERE service_date = $1 AND route_id IS NOT NULL AND stop_id IS NOT NULL
+				AND COALESCE(scheduled_departure, scheduled_arrival) IS NOT NULL
+		) c
+		GROUP BY 1, 2, 3, 4`,
+		date, models.TimetableLocation.String(),
+		models.DelayHistogramStart, models.DelayBucketWidth, models.DelayBuckets,
+		models.OnTimeEarliest, models.OnTimeLatest,
+		models.DepartureCancelled, models.CallSkipped, models.DepartureDeparted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query calls of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	rollups := make(map[punctualityKey]*models.PunctualityCounts)
+	for rows.Next() {
+		var key punctualityKey
+		var bucket *int
+		var calls, onTime, cancelled, skipped int
+		if err := rows.Scan(&key.routeID, &key.stopID, &key.hour, &bucket, &calls, &onTime, &cancelled, &skipped); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan calls: %w", err)
+		}
+		counts, ok := rollups[key]
+		if !ok {
+			counts = models.NewPunctualityCounts()
+			rollups[key] = counts
+		}
+		counts.Calls += calls
+		counts.OnTime += onTime
+		counts.Cancelled += cancelled
+		counts.Skipped += skipped
+		if bucket != nil {
+			counts.Measured += calls
+			counts.Delays[*bucket] += calls
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read calls of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	tx, err := s.db.Primary().Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM punctuality_rollups WHERE service_date = $1", date); err != nil {
+		return 0, fmt.Errorf("failed to delete rollups of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	rollupRows := make([][]interface{}, 0, len(rollups))
+	for key, counts := range rollups {
+		histogram := make([]int32, len(counts.Delays))
+		for i, n := range counts.Delays {
+			histogram[i] = int32(n)
+		}
+		rollupRows = append(rollupRows, []interface{}{date, key.routeID, key.stopID, key.hour,
+			counts.Calls, counts.Measured, counts.OnTime, counts.Cancelled, counts.Skipped, histogram})
+	}
+	cols := []string{"service_date", "route_id", "stop_id", "hour", "calls", "measured", "on_time", "cancelled", "skipped", "delay_histogram"}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"punctuality_rollups"}, cols, pgx.CopyFromRows(rollupRows)); err != nil {
+		return 0, fmt.Errorf("failed to store rollups of %s: %w", date.Format("2006-01-02"), err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(rollupRows), nil
+}
+
+// PunctualityCounts implements store.PunctualityStore.
+func (s *Store) PunctualityCounts(ctx context.Context, routeID, stopID string, from, to time.Time) (map[models.PunctualitySlot]*models.PunctualityCounts, error) {
+	filter, id := "route_id = $1", routeID
+	if routeID == "" {
+		filter, id = "stop_id IN (SELECT stop_id FROM stops WHERE stop_id = $1 OR parent_station = $1)", stopID
+	}
+	fromDate, toDate := from.Format("2006-01-02"), to.Format("2006-01-02")
+
+	counts := make(map[models.PunctualitySlot]*models.PunctualityCounts)
+	slot := func(s models.PunctualitySlot) *models.PunctualityCounts {
+		c, ok := counts[s]
+		if !ok {
+			c = models.NewPunctualityCounts()
+			counts[s] = c
+		}
+		return c
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT EXTRACT(ISODOW FROM service_date)::int, hour,
+			sum(calls), sum(measured), sum(on_time), sum(cancelled), sum(skipped)
+		FROM punctuality_rollups
+		WHERE `+filter+` AND service_date BETWEEN $2 AND $3
+		GROUP BY 1, 2`, id, fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query punctuality: %w", err)
+	}
+	for rows.Next() {
+		var s models.PunctualitySlot
+		var calls, measured, onTime, cancelled, skipped int
+		if err := rows.Scan(&s.Weekday, &s.Hour, &calls, &measured, &onTime, &cancelled, &skipped); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan punctuality: %w", err)
+		}
+		c := slot(s)
+		c.Calls, c.Measured, c.OnTime, c.Cancelled, c.Skipped = calls, measured, onTime, cancelled, skipped
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read punctuality: %w", err)
+	}
+
+	// Histograms are summed per bucket; ordinality counts from 1
+	rows, err = s.db.Query(ctx, `
+		SELECT EXTRACT(ISODOW FROM r.service_date)::int, r.hour, h.i::int - 1, sum(h.n)
+		FROM punctuality_rollups r, unnest(r.delay_histogram) WITH ORDINALITY AS h(n, i)
+		WHERE r.`+filter+` AND r.service_date BETWEEN $2 AND $3 AND h.n > 0
+		GROUP BY 1, 2, 3`, id, fromDate, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delays: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s models.PunctualitySlot
+		var bucket, n int
+		if err := rows.Scan(&s.Weekday, &s.Hour, &bucket, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan delays: %w", err)
+		}
+		if bucket >= 0 && bucket < models.DelayBuckets {
+			slot(s).Delays[bucket] += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read delays: %w", err)
+	}
+	return counts, nil
+}
+
+// DelayProfiles implements store.PunctualityStore.
+func (s *Store) DelayProfiles(ctx context.Context, stopID string, from, to time.Time) (map[models.DelayProfileKey]*models.PunctualityCounts, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT r.route_id, r.hour, h.i::int - 1, sum(h.n)
+		FROM punctuality_rollups r, unnest(r.delay_histogram) WITH ORDINALITY AS h(n, i)
+		WHERE r.stop_id IN (SELECT stop_id FROM stops WHERE stop_id = $1 OR parent_station = $1)
+			AND r.service_date BETWEEN $2 AND $3 AND h.n > 0
+		GROUP BY 1, 2, 3`, stopID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delay profiles of stop %s: %w", stopID, err)
+	}
+	defer rows.Close()
+
+	profiles := make(map[models.DelayProfileKey]*models.PunctualityCounts)
+	for rows.Next() {
+		var key models.DelayProfileKey
+		var bucket, n int
+		if err := rows.Scan(&key.RouteID, &key.Hour, &bucket, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan delay profile: %w", err)
+		}
+		if bucket < 0 || bucket >= models.DelayBuckets {
+			continue
+		}
+		profile, ok := profiles[key]
+		if !ok {
+			profile = models.NewPunctualityCounts()
+			profiles[key] = profile
+		}
+		profile.Measured += n
+		profile.Delays[bucket] += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read delay profiles of stop %s: %w", stopID, err)
+	}
+	return profiles, nil
+}
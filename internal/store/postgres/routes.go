@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const routeColumns = "id, agency_id, route_short_name, route_long_name, route_desc, route_type, route_url, route_color, route_text_color"
+
+// SearchRoutes implements store.RouteStore.
+func (s *Store) SearchRoutes(ctx context.Context, query string, limit int) ([]models.Route, error) {
+	searchQuery := `
+		SELECT ` + routeColumns + `
+		FROM routes 
+		WHERE route_short_name ILIKE $1 OR route_long_name ILIKE $1
+		ORDER BY 
+			CASE 
+				WHEN route_short_name ILIKE $1 THEN 1
+				WHEN route_long_name ILIKE $1 THEN 2
+				ELSE 3
+			END,
+			route_short_name, route_long_name
+		LIMIT $2`
+
+	rows, err := s.db.Query(ctx, searchQuery, "%"+query+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []models.Route
+	for rows.Next() {
+		route, err := scanRoute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan route: %w", err)
+		}
+		routes = append(routes, *route)
+	}
+
+	return routes, rows.Err()
+}
+
+// GetRoute implements store.RouteStore.
+func (s *Store) GetRoute(ctx context.Context, routeID string) (*models.Route, error) {
+	route, err := scanRoute(s.db.QueryRow(ctx, "SELECT "+routeColumns+" FROM routes WHERE id = $1", routeID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route %s: %w", routeID, err)
+	}
+	return route, nil
+}
+
+// scanRoute scans a row selected with routeColumns.
+func scanRoute(row pgx.Row) (*models.Route, error) {
+	var route models.Route
+	var agencyID, shortName, longName, description, url, color, textColor sql.NullString
+
+	err := row.Scan(
+		&route.ID,
+		&agencyID,
+		&shortName,
+		&longName,
+		&description,
+		&route.Type,
+		&url,
+		&color,
+		&textColor,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle nullable fields
+	if agencyID.Valid {
+		route.AgencyID = &agencyID.String
+	}
+	if shortName.Valid {
+		route.ShortName = &shortName.String
+	}
+	if longName.Valid {
+		route.LongName = &longName.String
+	}
+	if description.Valid {
+		route.Description = &description.String
+	}
+	if url.Valid {
+		route.URL = &url.String
+	}
+	if color.Valid {
+		route.Color = &color.String
+	}
+	if textColor.Valid {
+		route.TextColor = &textColor.String
+	}
+
+	return &route, nil
+}
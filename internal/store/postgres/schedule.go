@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// servicesOn returns a query selecting the services that run on the date
+// expression day, according to calendar and calendar_dates.
+func servicesOn(day string) string {
+	return `
+		SELECT service_id FROM calendar
+		WHERE ` + day + ` BETWEEN start_date AND end_date
+		  AND CASE extract(isodow FROM ` + day + `)
+			WHEN 1 THEN monday WHEN 2 THEN tuesday WHEN 3 THEN wednesday WHEN 4 THEN thursday
+			WHEN 5 THEN friday WHEN 6 THEN saturday ELSE sunday END = 1
+		UNION
+		SELECT service_id FROM calendar_dates WHERE date = ` + day + ` AND exception_type = 1
+		EXCEPT
+		SELECT service_id FROM calendar_dates WHERE date = ` + day + ` AND exception_type = 2`
+}
+
+// scheduledDeparturesQuery selects the calls at a stop or its quays ($1)
+// between two times in seconds ($3, $4) of the service day $2, for the
+// services running that day.
+var scheduledDeparturesQuery = `
+	WITH quays AS (
+		SELECT stop_id FROM stops WHERE stop_id = $1 OR parent_station = $1
+	), services AS (` + servicesOn("$2::date") + `
+	)
+	SELECT st.trip_id, st.stop_id, st.departure_sec, COALESCE(NULLIF(st.stop_headsign, ''), t.trip_headsign, ''),
+		t.route_id, COALESCE(NULLIF(r.route_short_name, ''), r.route_long_name, ''), COALESCE(r.agency_id, ''), r.route_type
+	FROM stop_times st
+	JOIN trips t ON t.id = st.trip_id
+	JOIN routes r ON r.id = t.route_id
+	WHERE st.stop_id IN (SELECT stop_id FROM quays)
+	  AND st.departure_sec BETWEEN $3 AND $4
+	  AND COALESCE(st.pickup_type, 0) <> 1
+	  AND t.service_id IN (SELECT service_id FROM services)
+	ORDER BY st.departure_sec`
+
+// ScheduledDepartures implements store.ScheduleStore.
+func (s *Store) ScheduledDepartures(ctx context.Context, stopID string, from, to time.Time) ([]models.Departure, error) {
+	departures := []models.Departure{}
+	for _, day := range models.ServiceDays(from, to) {
+		rows, err := s.db.Query(ctx, scheduledDeparturesQuery, stopID, day.Date.Format("2006-01-02"), day.Seconds(from), day.Seconds(to))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query scheduled departures for stop %s on %s: %w", stopID, day, err)
+		}
+
+		for rows.Next() {
+			var d models.Departure
+			var departureSec, routeType int
+			if err := rows.Scan(&d.TripID, &d.StopID, &departureSec, &d.Destination, &d.RouteID, &d.Line, &d.Operator, &routeType); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan scheduled departure: %w", err)
+			}
+			d.PlannedStopID = d.StopID
+			d.ServiceDate = day.String()
+			d.ScheduledDeparture = day.Time(departureSec)
+			d.Departure = d.ScheduledDeparture
+			d.Status = models.DepartureScheduled
+			d.TransportType = models.TransportType(routeType)
+			departures = append(departures, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read scheduled departures: %w", err)
+		}
+	}
+
+	// Days overlap after midnight
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].ScheduledDeparture.Before(departures[j].ScheduledDeparture)
+	})
+	return departures, nil
+}
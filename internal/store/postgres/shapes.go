@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// TripPath implements store.ShapeStore.
+func (s *Store) TripPath(ctx context.Context, tripID string) (*models.TripPath, error) {
+	trip, err := s.GetTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []models.ShapePoint
+	if trip.ShapeID != nil {
+		rows, err := s.db.Query(ctx, `
+			SELECT shape_pt_lat, shape_pt_lon
+			FROM shapes
+			WHERE shape_id = $1
+			ORDER BY shape_pt_sequence`, *trip.ShapeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query shape %s: %w", *trip.ShapeID, err)
+		}
+		for rows.Next() {
+			var p models.ShapePoint
+			if err := rows.Scan(&p.Lat, &p.Lon); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan shape point: %w", err)
+			}
+			points = append(points, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read shape %s: %w", *trip.ShapeID, err)
+		}
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT st.stop_id, s.stop_lat, s.stop_lon, COALESCE(st.arrival_sec, -1), COALESCE(st.departure_sec, -1)
+		FROM stop_times st
+		JOIN stops s ON s.stop_id = st.stop_id
+		WHERE st.trip_id = $1
+		ORDER BY st.stop_sequence`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calls of trip %s: %w", tripID, err)
+	}
+	defer rows.Close()
+
+	var calls []models.PathCall
+	for rows.Next() {
+		var c models.PathCall
+		if err := rows.Scan(&c.StopID, &c.Lat, &c.Lon, &c.ArrivalSec, &c.DepartureSec); err != nil {
+			return nil, fmt.Errorf("failed to scan call: %w", err)
+		}
+		calls = append(calls, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calls of trip %s: %w", tripID, err)
+	}
+
+	return models.NewTripPath(tripID, points, calls), nil
+}
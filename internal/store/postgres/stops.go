@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// stopSearchQuery ranks stops by trigram similarity of their normalized name
+// ($1) against the normalized query. Names starting with the query get a
+// prefix boost, and when the query mentions the stop's city the remainder is
+// matched against the place part of the name only ("utrecht neude").
+// The first %s adds the distance column and the second the final score, which
+// includes a proximity boost when a location is given.
+const stopSearchQuery = `
+	SELECT stop_id, stop_name, stop_lat, stop_lon, platform_code, quay_name, score %s
+	FROM (
+		SELECT stop_id, stop_name, stop_lat, stop_lon, COALESCE(platform_code, '') AS platform_code,
+			COALESCE(quay_name, '') AS quay_name, search_name, search_city, search_place,
+			GREATEST(
+				similarity(search_name, $1),
+				word_similarity($1, search_name),
+				CASE WHEN search_city <> '' AND position(search_city IN $1) > 0
+					THEN similarity(search_place, btrim(replace($1, search_city, ''))) + 0.2
+					ELSE 0 END
+			)
+			+ CASE WHEN search_name LIKE $1 || '%%' OR search_place LIKE $1 || '%%' THEN 0.5 ELSE 0 END
+			AS text_score
+		FROM stops
+		WHERE search_name %% $1
+			OR $1 <%% search_name
+			OR search_place %% $1
+			OR search_name LIKE '%%' || $1 || '%%'
+	) candidates,
+	LATERAL (SELECT %s AS score) ranked
+	ORDER BY score DESC, stop_name
+	LIMIT %d;`
+
+// distanceExpr is the haversine distance in meters from ($2, $3) to a stop.
+const distanceExpr = "(6371000 * acos(LEAST(1, cos(radians($2)) * cos(radians(stop_lat)) * cos(radians(stop_lon) - radians($3)) + sin(radians($2)) * sin(radians(stop_lat)))))"
+
+// SearchStops implements store.StopStore.
+func (s *Store) SearchStops(ctx context.Context, normalized string, lat, lon *float64, limit int) ([]models.Stop, error) {
+	var rows pgx.Rows
+	var err error
+
+	if lat != nil && lon != nil {
+		// Nearby stops get up to 0.3 extra score, halving roughly every 1.4 km
+		searchQuery := fmt.Sprintf(stopSearchQuery, ", "+distanceExpr+" AS distance", "text_score + 0.3 * exp(-"+distanceExpr+" / 2000.0)", limit)
+		rows, err = s.db.Query(ctx, searchQuery, normalized, *lat, *lon)
+	} else {
+		searchQuery := fmt.Sprintf(stopSearchQuery, "", "text_score", limit)
+		rows, err = s.db.Query(ctx, searchQuery, normalized)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stops: %w", err)
+	}
+	defer rows.Close()
+
+	var stops []models.Stop
+	for rows.Next() {
+		var stop models.Stop
+		var err error
+		if lat != nil && lon != nil {
+			err = rows.Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon, &stop.PlatformCode, &stop.QuayName, &stop.Score, &stop.Distance)
+		} else {
+			err = rows.Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon, &stop.PlatformCode, &stop.QuayName, &stop.Score)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stop: %w", err)
+		}
+		stops = append(stops, stop)
+	}
+
+	return stops, rows.Err()
+}
+
+// NearbyStops implements store.StopStore.
+func (s *Store) NearbyStops(ctx context.Context, lat, lon, radius float64, limit int) ([]models.Stop, error) {
+	query := `
+		SELECT stop_id, stop_name, stop_lat, stop_lon, COALESCE(platform_code, ''), COALESCE(quay_name, ''), ( 
+			6371000 * acos( 
+				cos( radians($1) ) 
+				* cos( radians( stop_lat ) ) 
+				* cos( radians( stop_lon ) - radians($2) ) 
+				+ sin( radians($1) ) 
+				* sin( radians( stop_lat ) ) 
+			) 
+		) AS distance 
+		FROM stops 
+		WHERE ( 
+			6371000 * acos( 
+				cos( radians($1) ) 
+				* cos( radians( stop_lat ) ) 
+				* cos( radians( stop_lon ) - radians($2) ) 
+				+ sin( radians($1) ) 
+				* sin( radians( stop_lat ) ) 
+			) 
+		) < $3 
+		ORDER BY distance
+		LIMIT $4;`
+
+	rows, err := s.db.Query(ctx, query, lat, lon, radius, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nearby stops: %w", err)
+	}
+	defer rows.Close()
+
+	var stops []models.Stop
+	for rows.Next() {
+		var stop models.Stop
+		if err := rows.Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon, &stop.PlatformCode, &stop.QuayName, &stop.Distance); err != nil {
+			return nil, fmt.Errorf("failed to scan stop: %w", err)
+		}
+		stops = append(stops, stop)
+	}
+
+	return stops, rows.Err()
+}
+
+// GetStop implements store.StopStore.
+func (s *Store) GetStop(ctx context.Context, stopID string) (*models.Stop, error) {
+	var stop models.Stop
+	err := s.db.QueryRow(ctx, `
+		SELECT stop_id, stop_name, stop_lat, stop_lon, COALESCE(platform_code, ''), COALESCE(quay_name, '')
+		FROM stops WHERE stop_id = $1`, stopID).
+		Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon, &stop.PlatformCode, &stop.QuayName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stop %s: %w", stopID, err)
+	}
+	return &stop, nil
+}
+
+// Quays implements store.StopStore.
+func (s *Store) Quays(ctx context.Context, stopID string) (map[string]models.Stop, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT stop_id, stop_name, stop_lat, stop_lon, COALESCE(platform_code, ''), COALESCE(quay_name, '')
+		FROM stops WHERE stop_id = $1 OR parent_station = $1`, stopID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quays of stop %s: %w", stopID, err)
+	}
+	defer rows.Close()
+
+	quays := make(map[string]models.Stop)
+	for rows.Next() {
+		var stop models.Stop
+		if err := rows.Scan(&stop.ID, &stop.Name, &stop.Lat, &stop.Lon, &stop.PlatformCode, &stop.QuayName); err != nil {
+			return nil, fmt.Errorf("failed to scan quay: %w", err)
+		}
+		quays[stop.ID] = stop
+	}
+	return quays, rows.Err()
+}
+
+// TimingPointCodes implements store.StopStore.
+func (s *Store) TimingPointCodes(ctx context.Context, stopID string) ([]string, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT s.stop_id, tp.timing_point_code
+		FROM stops s
+		LEFT JOIN stops child ON child.parent_station = s.stop_id
+		LEFT JOIN stop_timing_points tp ON tp.stop_id IN (s.stop_id, child.stop_id)
+		WHERE s.stop_id = $1`, stopID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query timing points for stop %s: %w", stopID, err)
+	}
+	defer rows.Close()
+
+	found := false
+	seen := make(map[string]bool)
+	codes := []string{}
+	for rows.Next() {
+		var id string
+		var code *string
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, fmt.Errorf("failed to scan timing point: %w", err)
+		}
+		found = true
+		if code != nil && !seen[*code] {
+			seen[*code] = true
+			codes = append(codes, *code)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, store.ErrNotFound
+	}
+
+	return codes, nil
+}
+
+// AllTimingPointCodes implements store.StopStore.
+func (s *Store) AllTimingPointCodes(ctx context.Context) ([]string, error) {
+	rows, err := s.db.Query(ctx, "SELECT DISTINCT timing_point_code FROM stop_timing_points ORDER BY timing_point_code")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query timing points: %w", err)
+	}
+	defer rows.Close()
+
+	codes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan timing point: %w", err)
+	}
+	return codes, nil
+}
+
+// TimingPointStops implements store.StopStore.
+func (s *Store) TimingPointStops(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.Query(ctx, "SELECT timing_point_code, stop_id FROM stop_timing_points")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query timing points: %w", err)
+	}
+	defer rows.Close()
+
+	stops := make(map[string]string)
+	for rows.Next() {
+		var code, stopID string
+		if err := rows.Scan(&code, &stopID); err != nil {
+			return nil, fmt.Errorf("failed to scan timing point: %w", err)
+		}
+		stops[code] = stopID
+	}
+	return stops, rows.Err()
+}
@@ -0,0 +1,19 @@
+// Package postgres implements the store interfaces on the GTFS database.
+package postgres
+
+import (
+	"arrivo-transit-api/internal/database"
+	"arrivo-transit-api/internal/store"
+)
+
+// Store reads static data from replicas and writes to the primary.
+type Store struct {
+	db *database.DB
+}
+
+var _ store.Static = (*Store)(nil)
+
+// New creates a new Postgres store.
+func New(db *database.DB) *Store {
+	return &Store{db: db}
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"arrivo-transit-api/internal/models"
+	"arrivo-transit-api/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const tripColumns = `id, route_id, service_id, NULLIF(trip_headsign, ''), NULLIF(trip_short_name, ''), COALESCE(direction_id, 0), NULLIF(shape_id, ''), realtime_trip_id`
+
+// GetTrip implements store.TripStore.
+func (s *Store) GetTrip(ctx context.Context, tripID string) (*models.Trip, error) {
+	var trip models.Trip
+	err := s.db.QueryRow(ctx, `
+		SELECT `+tripColumns+`
+		FROM trips
+		WHERE id = $1`, tripID,
+	).Scan(&trip.ID, &trip.RouteID, &trip.ServiceID, &trip.Headsign, &trip.ShortName, &trip.DirectionID, &trip.ShapeID, &trip.RealtimeID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip %s: %w", tripID, err)
+	}
+	return &trip, nil
+}
+
+// StopTimes implements store.TripStore.
+func (s *Store) StopTimes(ctx context.Context, tripID string) ([]models.StopTime, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT trip_id, stop_id, stop_sequence, COALESCE(arrival_sec, -1), COALESCE(departure_sec, -1), NULLIF(stop_headsign, ''), COALESCE(shape_dist_traveled, 0), COALESCE(pickup_type, 0)
+		FROM stop_times
+		WHERE trip_id = $1
+		ORDER BY stop_sequence`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stop times for trip %s: %w", tripID, err)
+	}
+	defer rows.Close()
+
+	var stopTimes []models.StopTime
+	for rows.Next() {
+		var st models.StopTime
+		if err := rows.Scan(&st.TripID, &st.StopID, &st.StopSequence, &st.ArrivalSec, &st.DepartureSec, &st.Headsign, &st.ShapeDistTraveled, &st.PickupType); err != nil {
+			return nil, fmt.Errorf("failed to scan stop time: %w", err)
+		}
+		stopTimes = append(stopTimes, st)
+	}
+
+	return stopTimes, rows.Err()
+}
+
+// TripsByRealtimeID implements store.TripStore. Of several trips running a
+// journey on the same day the lowest trip ID is taken.
+func (s *Store) TripsByRealtimeID(ctx context.Context, journeys []models.Journey) (map[models.Journey]models.Trip, error) {
+	realtimeIDs := make([]string, len(journeys))
+	dates := make([]string, len(journeys))
+	for i, j := range journeys {
+		realtimeIDs[i], dates[i] = j.RealtimeID, j.ServiceDate
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT j.service_date, t.*
+		FROM unnest($1::text[], $2::text[]) AS j(realtime_trip_id, service_date)
+		CROSS JOIN LATERAL (
+			SELECT `+tripColumns+`
+			FROM trips
+			WHERE realtime_trip_id = j.realtime_trip_id
+			  AND service_id IN (`+servicesOn("to_date(j.service_date, 'YYYYMMDD')")+`)
+			ORDER BY id
+			LIMIT 1
+		) t`, realtimeIDs, dates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trips by realtime ID: %w", err)
+	}
+	defer rows.Close()
+
+	trips := make(map[models.Journey]models.Trip)
+	for rows.Next() {
+		var date string
+		var trip models.Trip
+		if err := rows.Scan(&date, &trip.ID, &trip.RouteID, &trip.ServiceID, &trip.Headsign, &trip.ShortName, &trip.DirectionID, &trip.ShapeID, &trip.RealtimeID); err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		trips[models.Journey{RealtimeID: *trip.RealtimeID, ServiceDate: date}] = trip
+	}
+
+	return trips, rows.Err()
+}
+
+// TripsByID implements store.TripStore.
+func (s *Store) TripsByID(ctx context.Context, tripIDs []string) (map[string]models.Trip, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+tripColumns+`
+		FROM trips
+		WHERE id = ANY($1)`, tripIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trips: %w", err)
+	}
+	defer rows.Close()
+
+	trips := make(map[string]models.Trip)
+	for rows.Next() {
+		var trip models.Trip
+		if err := rows.Scan(&trip.ID, &trip.RouteID, &trip.ServiceID, &trip.Headsign, &trip.ShortName, &trip.DirectionID, &trip.ShapeID, &trip.RealtimeID); err != nil {
+			return nil, fmt.Errorf("failed to scan trip: %w", err)
+		}
+		trips[trip.ID] = trip
+	}
+
+	return trips, rows.Err()
+}
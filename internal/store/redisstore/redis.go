@@ -0,0 +1,38 @@
+// Package redisstore implements store.RealtimeStore on Redis.
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"arrivo-transit-api/internal/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store keeps realtime values in Redis.
+type Store struct {
+	client *redis.Client
+}
+
+var _ store.RealtimeStore = (*Store)(nil)
+
+// New creates a new Redis realtime store.
+func New(client *redis.Client) *Store {
+	return &Store{client: client}
+}
+
+// Get implements store.RealtimeStore.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, store.ErrNotFound
+	}
+	return value, err
+}
+
+// Set implements store.RealtimeStore.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
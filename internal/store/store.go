@@ -0,0 +1,173 @@
+// Package store defines the data access interfaces the services depend on.
+// The postgres subpackage implements them on top of the GTFS database, the
+// memory subpackage keeps everything in process (loadable from a GTFS zip) so
+// services and handlers can be exercised without infrastructure.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"arrivo-transit-api/internal/models"
+)
+
+// ErrNotFound is returned when the requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrRejected is returned when the database refuses the data written, e.g.
+// a row outside every partition, as opposed to the database being
+// unavailable. Writing the same data again fails the same way.
+var ErrRejected = errors.New("rejected")
+
+// ErrConflict is returned when a write would duplicate a unique record, such
+// as an alias that normalizes to the text of another alias.
+var ErrConflict = errors.New("conflict")
+
+// StopStore reads stops.
+type StopStore interface {
+	// SearchStops ranks stops against an already normalized query (see
+	// search.Normalize). With lat/lon, nearby stops are boosted and the
+	// distance is filled in.
+	SearchStops(ctx context.Context, normalized string, lat, lon *float64, limit int) ([]models.Stop, error)
+	// NearbyStops returns stops within radius meters, closest first.
+	NearbyStops(ctx context.Context, lat, lon, radius float64, limit int) ([]models.Stop, error)
+	// GetStop returns a single stop or ErrNotFound.
+	GetStop(ctx context.Context, stopID string) (*models.Stop, error)
+	// Quays returns a stop and, for a station, its quays, by stop ID. An
+	// unknown stop returns an empty map.
+	Quays(ctx context.Context, stopID string) (map[string]models.Stop, error)
+	// TimingPointCodes returns the OVapi TimingPointCodes served by a stop.
+	// For a station (parent stop) these are the codes of all its quays. A
+	// known stop without realtime coverage returns an empty slice; an unknown
+	// stop returns ErrNotFound.
+	TimingPointCodes(ctx context.Context, stopID string) ([]string, error)
+	// AllTimingPointCodes returns every TimingPointCode mapped to a stop.
+	AllTimingPointCodes(ctx context.Context) ([]string, error)
+	// TimingPointStops maps every TimingPointCode to the stop (quay) it
+	// belongs to.
+	TimingPointStops(ctx context.Context) (map[string]string, error)
+}
+
+// AliasStore maintains the stop alias dictionary.
+type AliasStore interface {
+	ListStopAliases(ctx context.Context) ([]models.StopAlias, error)
+	// CreateStopAlias overwrites an existing alias that normalizes to the
+	// same text.
+	CreateStopAlias(ctx context.Context, alias models.StopAlias) (*models.StopAlias, error)
+	// UpdateStopAlias fails with ErrConflict when another alias normalizes
+	// to the same text.
+	UpdateStopAlias(ctx context.Context, id int64, alias models.StopAlias) (*models.StopAlias, error)
+	DeleteStopAlias(ctx context.Context, id int64) error
+}
+
+// RouteStore reads routes.
+type RouteStore interface {
+	// SearchRoutes matches short and long names, short name matches first.
+	SearchRoutes(ctx context.Context, query string, limit int) ([]models.Route, error)
+	// GetRoute returns a single route or ErrNotFound.
+	GetRoute(ctx context.Context, routeID string) (*models.Route, error)
+}
+
+// TripStore reads trips and their stop times.
+type TripStore interface {
+	// GetTrip returns a single trip or ErrNotFound.
+	GetTrip(ctx context.Context, tripID string) (*models.Trip, error)
+	// StopTimes returns the calls of a trip ordered by stop_sequence.
+	StopTimes(ctx context.Context, tripID string) ([]models.StopTime, error)
+	// TripsByRealtimeID looks up the trips running realtime journeys on
+	// their service days, resolved through calendar and calendar_dates.
+	// Journeys without a trip running that day are missing from the result.
+	TripsByRealtimeID(ctx context.Context, journeys []models.Journey) (map[models.Journey]models.Trip, error)
+	// TripsByID looks up trips by ID. IDs without a trip are missing from the
+	// result.
+	TripsByID(ctx context.Context, tripIDs []string) (map[string]models.Trip, error)
+}
+
+// ScheduleStore reads the timetable.
+type ScheduleStore interface {
+	// ScheduledDepartures returns the timetabled departures from a stop, or
+	// from all quays of a station, between from and to, ordered by time.
+	// Only trips whose service runs on their service day are included and
+	// calls without boarding are left out. An unknown stop has none.
+	ScheduledDepartures(ctx context.Context, stopID string, from, to time.Time) ([]models.Departure, error)
+}
+
+// ShapeStore reads trip geometry.
+type ShapeStore interface {
+	// TripPath returns the shape of a trip with its calls located on it, or
+	// ErrNotFound for an unknown trip. A trip without a shape runs from stop
+	// to stop in straight lines.
+	TripPath(ctx context.Context, tripID string) (*models.TripPath, error)
+}
+
+// Static is the static (GTFS) data a service reads, with the statistics
+// derived from archived realtime data.
+type Static interface {
+	StopStore
+	AliasStore
+	RouteStore
+	TripStore
+	ScheduleStore
+	ShapeStore
+	PunctualityStore
+	OccupancyStore
+}
+
+// ObservationStore archives realtime observations in day partitions.
+type ObservationStore interface {
+	// RecordCalls stores completed and skipped calls. A call that is stored
+	// already is updated when its status changed. calls must not repeat a
+	// call (service date, journey and stop sequence). Fails with ErrRejected
+	// when a call cannot be stored.
+	RecordCalls(ctx context.Context, calls []models.CallObservation) error
+	// RecordPositions stores reported vehicle positions, ignoring reports
+	// that are stored already. Fails with ErrRejected when a position cannot
+	// be stored.
+	RecordPositions(ctx context.Context, positions []models.PositionObservation) error
+	// ObservedCalls calls fn with the calls stored for the service days from
+	// to to, inclusive, in order of service day, journey and stop sequence,
+	// and stops at the first error fn returns.
+	ObservedCalls(ctx context.Context, from, to time.Time, fn func(models.CallObservation) error) error
+	// EnsureObservationPartitions creates the partitions for every day from
+	// from to to, inclusive.
+	EnsureObservationPartitions(ctx context.Context, from, to time.Time) error
+	// DropObservationPartitions drops the partitions of days that ended
+	// before before and returns how many it dropped.
+	DropObservationPartitions(ctx context.Context, before time.Time) (int, error)
+	// RollupPunctuality recomputes the punctuality rollups of a service day
+	// from its calls and returns how many it stored.
+	RollupPunctuality(ctx context.Context, serviceDate time.Time) (int, error)
+	// RollupOccupancy recomputes the occupancy rollups of a day from the
+	// vehicle positions reported on it and returns how many it stored.
+	RollupOccupancy(ctx context.Context, day time.Time) (int, error)
+}
+
+// PunctualityStore reads punctuality rollups.
+type PunctualityStore interface {
+	// PunctualityCounts counts the calls on routeID, or at stopID and its
+	// quays when routeID is empty, on the service days from to to, by
+	// weekday and hour of the scheduled departure.
+	PunctualityCounts(ctx context.Context, routeID, stopID string, from, to time.Time) (map[models.PunctualitySlot]*models.PunctualityCounts, error)
+	// DelayProfiles returns the delays measured at stopID and its quays on
+	// the service days from to to, by route and hour of the scheduled
+	// departure. Only Measured and Delays are counted.
+	DelayProfiles(ctx context.Context, stopID string, from, to time.Time) (map[models.DelayProfileKey]*models.PunctualityCounts, error)
+}
+
+// OccupancyStore reads occupancy rollups.
+type OccupancyStore interface {
+	// OccupancyCounts counts the vehicles leaving stops on routeID, or
+	// stopID and its quays when routeID is empty, on the days from to to, by
+	// route, trip, stop, weekday and hour.
+	OccupancyCounts(ctx context.Context, routeID, stopID string, from, to time.Time) (map[models.OccupancySlot]*models.OccupancyCounts, error)
+}
+
+// RealtimeStore holds short-lived realtime data and cached responses as
+// opaque values with a TTL.
+type RealtimeStore interface {
+	// Get returns the value stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
@@ -0,0 +1,38 @@
+package upstream
+
+import "sync"
+
+// maxRetryTokens bounds the retries a burst of failures can spend at once.
+const maxRetryTokens = 10
+
+// retryBudget is a token bucket that limits retries and hedges to a share of
+// the requests made, so retries cannot multiply the load on an upstream that
+// is struggling. Every request deposits ratio tokens, every retry or hedge
+// withdraws a whole one.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+}
+
+func newRetryBudget(ratio float64) *retryBudget {
+	return &retryBudget{ratio: ratio, tokens: maxRetryTokens}
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, maxRetryTokens)
+}
+
+// withdraw takes a token for a retry or hedge, or returns false when the
+// budget is spent.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,367 @@
+// Package upstream is the HTTP layer for calls to upstream data sources. A
+// Client bounds every attempt with a deadline, retries transient failures
+// with jittered backoff within a retry budget, hedges slow requests, isolates
+// endpoints from each other with bulkheads and trips a circuit breaker whose
+// state changes are reported to metrics.
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+
+	"arrivo-transit-api/internal/metrics"
+)
+
+// ErrBulkheadFull is returned when an endpoint already has MaxConcurrent
+// requests in flight.
+var ErrBulkheadFull = errors.New("too many concurrent requests")
+
+// errAbandoned marks attempts that were cancelled by the caller or lost a
+// hedge; they say nothing about the health of the upstream.
+var errAbandoned = errors.New("attempt abandoned")
+
+// StatusError is returned for responses with a status other than 200 OK.
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d from %s", e.StatusCode, e.URL)
+}
+
+// retryable reports whether a request that got the status may succeed when
+// repeated.
+func (e *StatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Config tunes a Client.
+type Config struct {
+	Name            string        // Name in logs and metrics, e.g. "OVapi"
+	BaseURL         string        // Prefixed to relative paths
+	Accept          string        // Accept header, if any
+	Timeout         time.Duration // Deadline of a single attempt
+	MaxAttempts     int           // Attempts per request, including the first
+	BackoffBase     time.Duration // Backoff before the first retry, doubled for every next one
+	BackoffMax      time.Duration // Upper bound of the backoff
+	RetryBudget     float64       // Retries and hedges allowed per request, on average
+	HedgeAfter      time.Duration // Start a second attempt when the first takes longer; 0 disables hedging
+	MaxConcurrent   int           // Requests in flight per endpoint
+	MaxBodySize     int64         // Largest response body accepted
+	BreakerFailures int           // Consecutive failed attempts that open the breaker
+	BreakerTimeout  time.Duration // Time the breaker stays open before letting requests through again
+}
+
+// DefaultConfig returns the default settings for the upstream name at
+// baseURL. Hedging is off by default.
+func DefaultConfig(name, baseURL string) Config {
+	return Config{
+		Name:            name,
+		BaseURL:         baseURL,
+		Timeout:         10 * time.Second,
+		MaxAttempts:     3,
+		BackoffBase:     200 * time.Millisecond,
+		BackoffMax:      2 * time.Second,
+		RetryBudget:     0.2,
+		MaxConcurrent:   16,
+		MaxBodySize:     64 << 20,
+		BreakerFailures: 4,
+		BreakerTimeout:  5 * time.Second,
+	}
+}
+
+// Client makes GET requests to one upstream.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+	cb         *gobreaker.CircuitBreaker
+	budget     *retryBudget
+
+	mu        sync.Mutex
+	bulkheads map[string]chan struct{}
+}
+
+// New creates a new upstream client. Zero settings in cfg take their
+// DefaultConfig value.
+func New(cfg Config) *Client {
+	defaults := DefaultConfig(cfg.Name, cfg.BaseURL)
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaults.MaxAttempts
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = defaults.BackoffBase
+	}
+	if cfg.BackoffMax < cfg.BackoffBase {
+		cfg.BackoffMax = max(defaults.BackoffMax, cfg.BackoffBase)
+	}
+	if cfg.RetryBudget <= 0 {
+		cfg.RetryBudget = defaults.RetryBudget
+	}
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaults.MaxConcurrent
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaults.MaxBodySize
+	}
+	if cfg.BreakerFailures <= 0 {
+		cfg.BreakerFailures = defaults.BreakerFailures
+	}
+	if cfg.BreakerTimeout <= 0 {
+		cfg.BreakerTimeout = defaults.BreakerTimeout
+	}
+
+	st := gobreaker.Settings{
+		Name:        cfg.Name,
+		MaxRequests: 5,
+		Interval:    10 * time.Second,
+		Timeout:     cfg.BreakerTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= uint32(cfg.BreakerFailures)
+		},
+		IsSuccessful: func(err error) bool {
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) {
+				return !statusErr.retryable()
+			}
+			return err == nil || errors.Is(err, errAbandoned)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			log.Printf("WARN: %s circuit breaker changed from %s to %s", name, from, to)
+			metrics.BreakerTransition(name, to.String())
+		},
+	}
+	metrics.BreakerState(cfg.Name, gobreaker.StateClosed.String())
+
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{},
+		cb:         gobreaker.NewCircuitBreaker(st),
+		budget:     newRetryBudget(cfg.RetryBudget),
+		bulkheads:  make(map[string]chan struct{}),
+	}
+}
+
+// Available reports whether requests are let through, i.e. the circuit
+// breaker is not open.
+func (c *Client) Available() bool {
+	return c.cb.State() != gobreaker.StateOpen
+}
+
+// GetJSON fetches path like Get and decodes the JSON body into v.
+func (c *Client) GetJSON(ctx context.Context, endpoint, path string, v interface{}) error {
+	data, err := c.Get(ctx, endpoint, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", c.cfg.Name, err)
+	}
+	return nil
+}
+
+// Get fetches path, relative to BaseURL unless it is an absolute URL, and
+// returns the response body. endpoint names the bulkhead and metrics the
+// request is counted under, e.g. "tpc".
+func (c *Client) Get(ctx context.Context, endpoint, path string) ([]byte, error) {
+	url := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = strings.TrimSuffix(c.cfg.BaseURL, "/") + path
+	}
+
+	bulkhead := c.bulkhead(endpoint)
+	select {
+	case bulkhead <- struct{}{}:
+		defer func() { <-bulkhead }()
+	default:
+		metrics.Request(c.cfg.Name, endpoint, "rejected")
+		return nil, fmt.Errorf("%s %s: %w", c.cfg.Name, endpoint, ErrBulkheadFull)
+	}
+
+	c.budget.deposit()
+	data, err := c.retry(ctx, url)
+	switch {
+	case err == nil:
+		metrics.Request(c.cfg.Name, endpoint, "ok")
+	case errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		metrics.Request(c.cfg.Name, endpoint, "open")
+	default:
+		metrics.Request(c.cfg.Name, endpoint, "error")
+	}
+	return data, err
+}
+
+func (c *Client) bulkhead(endpoint string) chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bulkhead, ok := c.bulkheads[endpoint]
+	if !ok {
+		bulkhead = make(chan struct{}, c.cfg.MaxConcurrent)
+		c.bulkheads[endpoint] = bulkhead
+	}
+	return bulkhead
+}
+
+// retry makes up to MaxAttempts hedged attempts, backing off between them,
+// as long as the failures are transient and the retry budget allows.
+func (c *Client) retry(ctx context.Context, url string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		data, err := c.hedged(ctx, url)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt >= c.cfg.MaxAttempts || !retryable(err) || !c.Available() || !c.budget.withdraw() {
+			return nil, err
+		}
+		metrics.Retry(c.cfg.Name)
+
+		timer := time.NewTimer(c.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether err is a transient failure worth retrying.
+func retryable(err error) bool {
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.retryable()
+	}
+	return true
+}
+
+// backoff returns the jittered wait before retry attempt: a random duration
+// between half and all of BackoffBase doubled attempt-1 times, capped at
+// BackoffMax.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.BackoffMax
+	if shift := attempt - 1; shift < 30 && c.cfg.BackoffBase<<shift < d {
+		d = c.cfg.BackoffBase << shift
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+type result struct {
+	data []byte
+	err  error
+}
+
+// hedged makes one attempt and, when it has not finished after HedgeAfter
+// and the retry budget allows, a second one. The first success wins and the
+// other attempt is cancelled.
+func (c *Client) hedged(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, 2)
+	launch := func() {
+		go func() {
+			data, err := c.attempt(ctx, url)
+			results <- result{data, err}
+		}()
+	}
+	launch()
+	inFlight := 1
+
+	var hedge <-chan time.Time
+	if c.cfg.HedgeAfter > 0 {
+		timer := time.NewTimer(c.cfg.HedgeAfter)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	var err error
+	for inFlight > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			if c.budget.withdraw() {
+				metrics.Hedge(c.cfg.Name)
+				launch()
+				inFlight++
+			}
+		case r := <-results:
+			inFlight--
+			if r.err == nil {
+				return r.data, nil
+			}
+			if err == nil || errors.Is(err, errAbandoned) {
+				err = r.err
+			}
+		}
+	}
+	return nil, err
+}
+
+// attempt makes a single request through the circuit breaker within Timeout.
+func (c *Client) attempt(ctx context.Context, url string) ([]byte, error) {
+	data, err := c.cb.Execute(func() (interface{}, error) {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+
+		data, err := c.do(attemptCtx, url)
+		if err != nil && ctx.Err() != nil {
+			return nil, fmt.Errorf("%w: %w", errAbandoned, ctx.Err())
+		}
+		return data, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.cfg.Name, err)
+	}
+	return data.([]byte), nil
+}
+
+func (c *Client) do(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.Accept != "" {
+		req.Header.Set("Accept", c.cfg.Accept)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		return nil, &StatusError{StatusCode: resp.StatusCode, URL: url}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > c.cfg.MaxBodySize {
+		return nil, fmt.Errorf("response from %s exceeds %d bytes", url, c.cfg.MaxBodySize)
+	}
+	return data, nil
+}